@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// updateAccountMetadata validates and replaces the metadata of an account owned by the user
+func updateAccountMetadata(ctx context.Context, queries *sqlc.Queries, userID, accountID int64, raw json.RawMessage) (sqlc.Account, error) {
+	metadata, err := helpers.NormalizeAccountMetadata(raw)
+	if err != nil {
+		return sqlc.Account{}, err
+	}
+
+	if _, err := findUserAccount(ctx, queries, userID, accountID); err != nil {
+		return sqlc.Account{}, err
+	}
+
+	return queries.UpdateAccountMetadata(ctx, sqlc.UpdateAccountMetadataParams{ID: accountID, Metadata: metadata})
+}
+
+// listAccountsByMetadata returns the user's accounts, or only those with the metadata key (and value,
+// when given). Numbers match on their JSON text, so value=2 finds {"tier": 2}.
+func listAccountsByMetadata(ctx context.Context, queries *sqlc.Queries, userID int64, key, value string) ([]sqlc.Account, error) {
+	if key == "" {
+		return queries.ListAccountsByUser(ctx, userID)
+	}
+
+	return queries.ListAccountsByUserMetadata(ctx, sqlc.ListAccountsByUserMetadataParams{
+		UserID: userID,
+		Key:    key,
+		Value:  pgtype.Text{String: value, Valid: value != ""},
+	})
+}
+
+// accountIDs validates the userId and accountId path variables
+func accountIDs(r *http.Request) (int64, int64, error) {
+	vars := mux.Vars(r)
+	userID, err := helpers.ValidateID(vars["userId"])
+	if err != nil {
+		return 0, 0, err
+	}
+	accountID, err := helpers.ValidateID(vars["accountId"])
+	if err != nil {
+		return 0, 0, err
+	}
+	return userID, accountID, nil
+}
+
+// GetAccountHandler handles GET /user/{userId}/account/{accountId} - returns the account with its metadata
+func GetAccountHandler(w http.ResponseWriter, r *http.Request) {
+	userID, accountID, err := accountIDs(r)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	account, err := GetUserAccount(r.Context(), userID, accountID)
+	if err != nil {
+		handleAccountError(w, err)
+		return
+	}
+
+	helpers.RespondSuccess(w, "Account retrieved successfully", account)
+}
+
+// UpdateAccountMetadataHandler handles PUT /user/{userId}/account/{accountId}/metadata - replaces the
+// account metadata with {"metadata": {...}}
+func UpdateAccountMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	userID, accountID, err := accountIDs(r)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	var update models.AccountMetadataUpdate
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &update); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	account, err := updateAccountMetadata(r.Context(), database.DBClient.Queries, userID, accountID, update.Metadata)
+	switch err {
+	case nil:
+		helpers.RespondSuccess(w, "Account metadata updated successfully", account)
+	case helpers.ErrInvalidMetadata, helpers.ErrMetadataTooLarge, helpers.ErrAccountNotFound:
+		helpers.HandleAPIError(w, err)
+	default:
+		helpers.HandleDatabaseError(w, err, "Account")
+	}
+}
+
+// ListAccountsHandler handles GET /user/{userId}/accounts?metadata_key=...&metadata_value=... - lists
+// the user's accounts, optionally only those whose metadata has the key (and value), with balances as
+// of a single point in time
+func ListAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := helpers.ValidateID(mux.Vars(r)["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	key, value := r.URL.Query().Get("metadata_key"), r.URL.Query().Get("metadata_value")
+	if key == "" && value != "" {
+		helpers.RespondError(w, http.StatusBadRequest, "metadata_value requires metadata_key")
+		return
+	}
+
+	var accounts []sqlc.Account
+	err = runInSnapshot(r.Context(), database.DBClient.Pool, database.DBClient.Queries, func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		accounts, err = listAccountsByMetadata(ctx, queries, userID, key, value)
+		return err
+	})
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Account")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Accounts retrieved successfully", accounts)
+}
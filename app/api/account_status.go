@@ -0,0 +1,59 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// updateAccountStatus freezes or reactivates the account a request addresses. Closed accounts stay
+// closed; the update only matches open accounts, so a close racing with it cannot be undone.
+func updateAccountStatus(ctx context.Context, queries *sqlc.Queries, userID int64, accountIDStr, status string) (sqlc.Account, error) {
+	account, err := requestAccount(ctx, queries, userID, accountIDStr)
+	if err != nil {
+		return sqlc.Account{}, err
+	}
+
+	updated, err := queries.UpdateAccountStatus(ctx, sqlc.UpdateAccountStatusParams{ID: account.ID, Status: status})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Account{}, helpers.ErrAccountClosed
+	}
+	return updated, err
+}
+
+// UpdateAccountStatusHandler handles PATCH /user/{userId}/account/status and
+// /user/{userId}/account/{accountId}/status - sets the status with {"status": "frozen"} or
+// {"status": "active"}. Frozen accounts reject transactions but can still be read.
+func UpdateAccountStatusHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userID, err := helpers.ValidateID(vars["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	var update models.AccountStatusUpdate
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &update); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	account, err := updateAccountStatus(r.Context(), database.DBClient.Queries, userID, vars["accountId"], update.Status)
+	if errors.Is(err, helpers.ErrAccountClosed) {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+	if err != nil {
+		handleAccountError(w, err)
+		return
+	}
+
+	helpers.RespondSuccess(w, "Account status updated successfully", account)
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateAccountStatus(t *testing.T) {
+	active := sqlc.Account{ID: 10, UserID: 1, Currency: "EUR", Status: helpers.AccountStatusActive}
+	frozen := active
+	frozen.Status = helpers.AccountStatusFrozen
+
+	tests := []struct {
+		name           string
+		rows           map[string]fakeRow
+		accountID      string
+		status         string
+		expectedStatus string
+		expectedErr    error
+	}{
+		{
+			name:           "Freeze the first account",
+			rows:           map[string]fakeRow{"GetAccountByUser": accountRow(active), "UpdateAccountStatus": accountRow(frozen)},
+			status:         helpers.AccountStatusFrozen,
+			expectedStatus: helpers.AccountStatusFrozen,
+		},
+		{
+			name:           "Reactivate a given account",
+			rows:           map[string]fakeRow{"GetAccount": accountRow(frozen), "UpdateAccountStatus": accountRow(active)},
+			accountID:      "10",
+			status:         helpers.AccountStatusActive,
+			expectedStatus: helpers.AccountStatusActive,
+		},
+		{
+			name:        "Account of another user",
+			rows:        map[string]fakeRow{"GetAccount": accountRow(sqlc.Account{ID: 11, UserID: 2})},
+			accountID:   "11",
+			status:      helpers.AccountStatusFrozen,
+			expectedErr: helpers.ErrAccountNotFound,
+		},
+		{
+			name:        "Closed accounts stay closed",
+			rows:        map[string]fakeRow{"GetAccountByUser": accountRow(sqlc.Account{ID: 10, UserID: 1, Status: helpers.AccountStatusClosed})},
+			status:      helpers.AccountStatusActive,
+			expectedErr: helpers.ErrAccountClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{rows: tt.rows}
+
+			account, err := updateAccountStatus(context.Background(), sqlc.New(db), 1, tt.accountID, tt.status)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, account.Status)
+			assert.Equal(t, []interface{}{int64(10), tt.status}, db.args["UpdateAccountStatus"])
+		})
+	}
+}
+
+func TestUpdateAccountStatusHandlerValidation(t *testing.T) {
+	for _, body := range []string{`{}`, `{"status":"closed"}`, `{"status":"suspended"}`} {
+		t.Run(body, func(t *testing.T) {
+			req := httptest.NewRequest("PATCH", "/user/1/account/status", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req = mux.SetURLVars(req, map[string]string{"userId": "1"})
+			recorder := httptest.NewRecorder()
+
+			UpdateAccountStatusHandler(recorder, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		})
+	}
+}
@@ -2,27 +2,62 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/rathorevk/GoBanking/app/database"
 	"github.com/rathorevk/GoBanking/app/database/sqlc"
 	"github.com/rathorevk/GoBanking/app/helpers"
 	"github.com/rathorevk/GoBanking/app/models"
 )
 
-func CreateAccount(userID int64) (sqlc.Account, error) {
+func CreateAccount(ctx context.Context, userID int64, accountType, currency, openingBalance string, metadata json.RawMessage) (sqlc.Account, error) {
+	return createAccountInDB(ctx, database.DBClient.Queries, userID, accountType, currency, openingBalance, metadata)
+}
+
+// createAccountInDB creates an account holding the decimal openingBalance, empty for none, in EUR when
+// currency is empty. Metadata must already be normalized, nil means none.
+func createAccountInDB(ctx context.Context, queries *sqlc.Queries, userID int64, accountType, currency, openingBalance string, metadata json.RawMessage) (sqlc.Account, error) {
 	log.Println("Creating account for user ID:", userID)
 
+	if accountType == "" {
+		accountType = helpers.AccountTypeGeneral
+	}
+	if currency == "" {
+		currency = helpers.DefaultAccountCurrency
+	}
+	if metadata == nil {
+		metadata = json.RawMessage("{}")
+	}
+
+	// The opening balance must be exact in the currency's minor units, like transaction amounts
+	var balanceMinor int64
+	if openingBalance != "" {
+		var err error
+		balanceMinor, err = helpers.ParseMoney(openingBalance, currency, helpers.MoneyOptions{AllowZero: true})
+		if err != nil {
+			return sqlc.Account{}, err
+		}
+	}
+
 	params := sqlc.CreateAccountParams{
-		UserID:  userID,
-		Balance: 0.0, // Starting balance
+		UserID:       userID,
+		Balance:      helpers.NumericFromMinorUnits(balanceMinor, currency),
+		BalanceMinor: balanceMinor,
+		Currency:     currency,
+		AccountType:  accountType,
+		Metadata:     metadata,
 	}
 
 	// Create account in the database
-	accountCreated, err := database.DBClient.Queries.CreateAccount(context.Background(), params)
+	accountCreated, err := queries.CreateAccount(ctx, params)
 	if err != nil {
 		return sqlc.Account{}, err
 	}
@@ -31,13 +66,95 @@ func CreateAccount(userID int64) (sqlc.Account, error) {
 	return accountCreated, nil
 }
 
-func GetAccountByUser(user_id int64) (sqlc.Account, error) {
+func GetAccountByUser(ctx context.Context, user_id int64) (sqlc.Account, error) {
 	// Use the generated SQLC method to get user
-	account, err := database.DBClient.Queries.GetAccountByUser(context.Background(), user_id)
+	account, err := database.DBClient.Queries.GetAccountByUser(ctx, user_id)
 	return account, err
 }
 
-// GetBalanceHandler handles GET /user/{user_id}/balance - retrieves user balance
+// GetUserAccount fetches an account by ID, making sure it belongs to the given user
+func GetUserAccount(ctx context.Context, userID, accountID int64) (sqlc.Account, error) {
+	return findUserAccount(ctx, database.DBClient.Queries, userID, accountID)
+}
+
+func findUserAccount(ctx context.Context, queries *sqlc.Queries, userID, accountID int64) (sqlc.Account, error) {
+	account, err := queries.GetAccount(ctx, accountID)
+	if err != nil {
+		return sqlc.Account{}, err
+	}
+
+	if account.UserID != userID {
+		return sqlc.Account{}, helpers.ErrAccountNotFound
+	}
+
+	return account, nil
+}
+
+// requestAccount returns the account a request addresses: the {accountId} account, which must belong
+// to the user, or the user's first account on routes without an account ID
+func requestAccount(ctx context.Context, queries *sqlc.Queries, userID int64, accountIDStr string) (sqlc.Account, error) {
+	if accountIDStr == "" {
+		return queries.GetAccountByUser(ctx, userID)
+	}
+
+	accountID, err := helpers.ValidateID(accountIDStr)
+	if err != nil {
+		return sqlc.Account{}, err
+	}
+	return findUserAccount(ctx, queries, userID, accountID)
+}
+
+// handleAccountError answers a failed account lookup; accounts of other users are reported as not found
+func handleAccountError(w http.ResponseWriter, err error) {
+	switch err {
+	case helpers.ErrInvalidID, helpers.ErrIDTooLarge, helpers.ErrAccountNotFound:
+		helpers.HandleAPIError(w, err)
+	default:
+		helpers.HandleDatabaseError(w, err, "Account")
+	}
+}
+
+// balanceETag identifies a balance breakdown. The version is bumped on every balance change, so unlike a
+// timestamp it tells apart changes made within the same second. Pending and available also change when
+// a step-up confirmation is created or expires, which leaves the version alone, so they are part of it.
+func balanceETag(account sqlc.Account, breakdown models.UserBalance) string {
+	return `"` + strconv.FormatInt(account.Version, 10) + "-" + breakdown.Pending + "-" + breakdown.Available + `"`
+}
+
+// balanceBreakdown splits an account balance into the settled balance, the balance once pending
+// transactions (step-up confirmations not yet confirmed or expired) settle, and the available
+// balance, which holds back pending debits. Without pending transactions all three are equal.
+func balanceBreakdown(ctx context.Context, queries *sqlc.Queries, account sqlc.Account) (models.UserBalance, error) {
+	pendingTransactions, err := queries.ListPendingConfirmationsByAccount(ctx, account.ID)
+	if err != nil {
+		return models.UserBalance{}, err
+	}
+
+	// All three are summed in minor units of the account currency
+	balance := account.BalanceMinor
+	pending, available := balance, balance
+	for _, transaction := range pendingTransactions {
+		transactionType, ok := helpers.LookupTransactionType(transaction.Type)
+		if !ok {
+			continue
+		}
+		pending += transactionType.Sign * transaction.AmountMinor
+		if transactionType.Sign < 0 {
+			available -= transaction.AmountMinor
+		}
+	}
+
+	return models.UserBalance{
+		UserID:    account.UserID,
+		Balance:   helpers.FormatMoney(balance, account.Currency),
+		Pending:   helpers.FormatMoney(pending, account.Currency),
+		Available: helpers.FormatMoney(available, account.Currency),
+		Currency:  account.Currency,
+	}, nil
+}
+
+// GetBalanceHandler handles GET /user/{user_id}/balance and /user/{userId}/account/{accountId}/balance -
+// retrieves the balance of the user's first or the given account
 func GetBalanceHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userIDStr := vars["userId"]
@@ -49,23 +166,213 @@ func GetBalanceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Use the generated SQLC method to get balance
-	account, err := GetAccountByUser(userID)
+	if err := authorizePrincipal(r, helpers.OperationReadBalance, "", ""); err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	account, err := requestAccount(r.Context(), database.DBClient.Queries, userID, vars["accountId"])
+	if err != nil {
+		handleAccountError(w, err)
+		return
+	}
+
+	responseData, err := balanceBreakdown(r.Context(), database.DBClient.Queries, account)
 	if err != nil {
 		helpers.HandleDatabaseError(w, err, "Account")
 		return
 	}
 
-	// Create response data
-	balanceStr := strconv.FormatFloat(account.Balance, 'f', 2, 64)
-	responseData := models.UserBalance{
-		UserID:  userID,
-		Balance: balanceStr,
+	// Skip the body when neither the balance nor the pending confirmations changed since the client's copy
+	if helpers.CheckNotModified(w, r, balanceETag(account, responseData)) {
+		return
 	}
 
 	helpers.RespondSuccess(w, "Balance retrieved successfully", responseData)
 }
 
+// buildMiniStatement turns the rows of GetMiniStatement into the widget response. The rows carry
+// the account on every row; an account without transactions yields a single row with NULL
+// transaction columns.
+func buildMiniStatement(rows []sqlc.GetMiniStatementRow) (models.MiniStatement, error) {
+	if len(rows) == 0 {
+		return models.MiniStatement{}, helpers.ErrAccountNotFound
+	}
+
+	account := rows[0]
+	statement := models.MiniStatement{
+		UserID:       account.UserID,
+		AccountID:    account.AccountID,
+		Currency:     account.Currency,
+		Balance:      helpers.FormatMoney(account.BalanceMinor, account.Currency),
+		Transactions: []models.MiniStatementEntry{},
+	}
+
+	for _, row := range rows {
+		if !row.TransactionID.Valid {
+			continue
+		}
+		statement.Transactions = append(statement.Transactions, models.MiniStatementEntry{
+			TransactionID: row.TransactionID.String,
+			Type:          row.Type.String,
+			Source:        row.Source.String,
+			Amount:        helpers.FormatMoney(row.AmountMinor.Int64, account.Currency),
+			InsertedAt:    row.InsertedAt,
+		})
+	}
+
+	return statement, nil
+}
+
+// miniStatement returns the mini-statement of the {accountId} account, or of the user's first account,
+// with a single query. Accounts of other users are not found.
+func miniStatement(ctx context.Context, queries *sqlc.Queries, userID int64, accountIDStr string, size int32) (models.MiniStatement, error) {
+	params := sqlc.GetMiniStatementParams{UserID: userID, Size: size}
+	if accountIDStr != "" {
+		accountID, err := helpers.ValidateID(accountIDStr)
+		if err != nil {
+			return models.MiniStatement{}, err
+		}
+		params.AccountID = pgtype.Int8{Int64: accountID, Valid: true}
+	}
+
+	rows, err := queries.GetMiniStatement(ctx, params)
+	if err != nil {
+		return models.MiniStatement{}, err
+	}
+	return buildMiniStatement(rows)
+}
+
+// MiniStatementHandler handles GET /user/{userId}/mini-statement and
+// /user/{userId}/account/{accountId}/mini-statement - balance plus the latest size transactions of the
+// user's first or the given account. size is validated at startup.
+func MiniStatementHandler(size int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		userID, err := helpers.ValidateID(vars["userId"])
+		if err != nil {
+			helpers.HandleAPIError(w, err)
+			return
+		}
+
+		statement, err := miniStatement(r.Context(), database.DBClient.Queries, userID, vars["accountId"], size)
+		if err != nil {
+			handleAccountError(w, err)
+			return
+		}
+
+		helpers.RespondSuccess(w, "Mini-statement retrieved successfully", statement)
+	}
+}
+
+// converter is used to express balances in another currency
+var converter helpers.CurrencyConverter = helpers.NewStaticConverter(helpers.DefaultRates)
+
+// ListAccountsByUser returns the user's accounts with their balances as of a single point in time
+func ListAccountsByUser(ctx context.Context, userID int64) ([]sqlc.Account, error) {
+	var accounts []sqlc.Account
+	err := runInSnapshot(ctx, database.DBClient.Pool, database.DBClient.Queries, func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		accounts, err = queries.ListAccountsByUser(ctx, userID)
+		return err
+	})
+	return accounts, err
+}
+
+// snapshotIsolation makes every read of a transaction see the database as of its first query
+const snapshotIsolation = "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY"
+
+// runInSnapshot runs fn in a read-only repeatable read transaction, so that reads spanning several
+// accounts never observe a transfer half-applied, however many queries fn makes
+func runInSnapshot(ctx context.Context, starter txStarter, baseQueries *sqlc.Queries, fn func(ctx context.Context, queries *sqlc.Queries) error) error {
+	tx, err := starter.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, snapshotIsolation); err != nil {
+		return errors.Join(err, tx.Rollback(ctx))
+	}
+	if err := fn(ctx, baseQueries.WithTx(tx)); err != nil {
+		return errors.Join(err, tx.Rollback(ctx))
+	}
+	return tx.Commit(ctx)
+}
+
+func computeNetWorth(userID int64, accounts []sqlc.Account, base string) (models.NetWorth, error) {
+	netWorth := models.NetWorth{
+		UserID:   userID,
+		Base:     base,
+		Accounts: []models.AccountWorth{},
+	}
+
+	var total int64
+	for _, account := range accounts {
+		rate, err := converter.Rate(account.Currency, base)
+		if err != nil {
+			return models.NetWorth{}, err
+		}
+
+		converted, err := helpers.ConvertMinorUnits(account.BalanceMinor, account.Currency, base, rate)
+		if err != nil {
+			return models.NetWorth{}, err
+		}
+		if (converted > 0 && total > math.MaxInt64-converted) || (converted < 0 && total < math.MinInt64-converted) {
+			return models.NetWorth{}, helpers.ErrBalanceOverflow
+		}
+		total += converted
+
+		netWorth.Accounts = append(netWorth.Accounts, models.AccountWorth{
+			AccountID: account.ID,
+			Currency:  account.Currency,
+			Balance:   helpers.FormatMoney(account.BalanceMinor, account.Currency),
+			Rate:      rate,
+			Converted: helpers.FormatMoney(converted, base),
+		})
+	}
+
+	netWorth.Total = helpers.FormatMoney(total, base)
+	return netWorth, nil
+}
+
+// NetWorthHandler handles GET /user/{userId}/networth - sums all account balances in a base currency
+func NetWorthHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userIDStr := vars["userId"]
+
+	// Validate user ID using helper function
+	userID, err := helpers.ValidateID(userIDStr)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	// Default to the account default currency when no base is requested
+	base := strings.ToUpper(r.URL.Query().Get("base"))
+	if base == "" {
+		base = "EUR"
+	}
+	if _, err := converter.Rate(base, base); err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	accounts, err := ListAccountsByUser(r.Context(), userID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Account")
+		return
+	}
+
+	netWorth, err := computeNetWorth(userID, accounts, base)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	helpers.RespondSuccess(w, "Net worth retrieved successfully", netWorth)
+}
+
 // CreateAccountHandler handles POST /accounts - creates a new account
 func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 	var accountData models.Account
@@ -82,9 +389,23 @@ func CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
 		helpers.HandleAPIError(w, helpers.ErrInvalidID)
 		return
 	}
+	if err := helpers.AuthorizeUser(r.Context(), userID); err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	metadata, err := helpers.NormalizeAccountMetadata(accountData.Metadata)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
 
 	// Create account
-	account, err := CreateAccount(userID)
+	account, err := CreateAccount(r.Context(), userID, accountData.AccountType, accountData.Currency, accountData.Balance.String(), metadata)
+	if _, isAmountErr := helpers.AmountErrorMessage(err); isAmountErr {
+		helpers.HandleAPIError(w, err)
+		return
+	}
 	if err != nil {
 		helpers.HandleDatabaseError(w, err, "Account")
 		return
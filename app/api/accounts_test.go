@@ -2,12 +2,21 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
 	"github.com/rathorevk/GoBanking/app/helpers"
 	"github.com/rathorevk/GoBanking/app/models"
 	"github.com/stretchr/testify/assert"
@@ -146,7 +155,7 @@ func TestAccountValidationWithDetails(t *testing.T) {
 			name: "Valid account",
 			account: models.Account{
 				UserID:   "123",
-				Balance:  100.0,
+				Balance:  json.Number("100.0"),
 				Currency: "USD",
 			},
 			expectValid: true,
@@ -155,24 +164,33 @@ func TestAccountValidationWithDetails(t *testing.T) {
 			name: "Missing user ID",
 			account: models.Account{
 				UserID:   "",
-				Balance:  100.0,
+				Balance:  json.Number("100.0"),
 				Currency: "USD",
 			},
 			expectValid: false,
 		},
 		{
-			name: "Missing balance",
+			name: "Missing balance opens the account empty",
 			account: models.Account{
 				UserID:   "123",
 				Currency: "USD",
 			},
-			expectValid: false,
+			expectValid: true,
 		},
 		{
-			name: "Missing currency",
+			name: "Missing currency defaults to EUR",
 			account: models.Account{
 				UserID:  "123",
-				Balance: 100.0,
+				Balance: json.Number("100.0"),
+			},
+			expectValid: true,
+		},
+		{
+			name: "Negative balance",
+			account: models.Account{
+				UserID:   "123",
+				Balance:  json.Number("-5.0"),
+				Currency: "USD",
 			},
 			expectValid: false,
 		},
@@ -180,11 +198,31 @@ func TestAccountValidationWithDetails(t *testing.T) {
 			name: "Invalid currency",
 			account: models.Account{
 				UserID:   "123",
-				Balance:  100.0,
+				Balance:  json.Number("100.0"),
 				Currency: "INVALID",
 			},
 			expectValid: false,
 		},
+		{
+			name: "Savings account type",
+			account: models.Account{
+				UserID:      "123",
+				Balance:     json.Number("100.0"),
+				Currency:    "EUR",
+				AccountType: "savings",
+			},
+			expectValid: true,
+		},
+		{
+			name: "Unknown account type",
+			account: models.Account{
+				UserID:      "123",
+				Balance:     json.Number("100.0"),
+				Currency:    "EUR",
+				AccountType: "brokerage",
+			},
+			expectValid: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -208,6 +246,270 @@ func TestAccountValidationWithDetails(t *testing.T) {
 	}
 }
 
+func TestNetWorthHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{
+			name:           "Invalid user ID format",
+			url:            "/user/invalid/networth?base=USD",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unsupported base currency",
+			url:            "/user/1/networth?base=JPY",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.HandleFunc("/user/{userId}/networth", NetWorthHandler).Methods("GET")
+
+			req, err := http.NewRequest("GET", tt.url, nil)
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+
+			var response map[string]interface{}
+			err = json.Unmarshal(recorder.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Contains(t, response, "error")
+		})
+	}
+}
+
+func TestComputeNetWorth(t *testing.T) {
+	original := converter
+	converter = helpers.NewStaticConverter(map[string]float64{"USD": 1.0, "EUR": 0.5})
+	defer func() { converter = original }()
+
+	accounts := []sqlc.Account{
+		{ID: 1, UserID: 7, BalanceMinor: 10000, Currency: "USD"},
+		{ID: 2, UserID: 7, BalanceMinor: 2550, Currency: "EUR"},
+	}
+
+	netWorth, err := computeNetWorth(7, accounts, "USD")
+	assert.NoError(t, err)
+	assert.Equal(t, "USD", netWorth.Base)
+	assert.Equal(t, "151.00", netWorth.Total)
+	assert.Len(t, netWorth.Accounts, 2)
+	assert.Equal(t, 1.0, netWorth.Accounts[0].Rate)
+	assert.Equal(t, "100.00", netWorth.Accounts[0].Converted)
+	assert.Equal(t, 2.0, netWorth.Accounts[1].Rate)
+	assert.Equal(t, "51.00", netWorth.Accounts[1].Converted)
+
+	_, err = computeNetWorth(7, []sqlc.Account{{ID: 3, BalanceMinor: 1, Currency: "JPY"}}, "USD")
+	assert.ErrorIs(t, err, helpers.ErrUnsupportedCurrency)
+
+	// Converted balances and the total use the precision of the base currency
+	converter = helpers.NewStaticConverter(map[string]float64{"USD": 1.0, "JPY": 150, "BTC": 0.00001})
+	accounts = []sqlc.Account{
+		{ID: 4, UserID: 7, BalanceMinor: 1005, Currency: "USD"},
+		{ID: 5, UserID: 7, BalanceMinor: 100000, Currency: "BTC"},
+	}
+
+	netWorth, err = computeNetWorth(7, accounts, "JPY")
+	assert.NoError(t, err)
+	assert.Equal(t, "1508", netWorth.Accounts[0].Converted)
+	assert.Equal(t, "15000", netWorth.Accounts[1].Converted)
+	assert.Equal(t, "16508", netWorth.Total)
+
+	netWorth, err = computeNetWorth(7, accounts[:1], "BTC")
+	assert.NoError(t, err)
+	assert.Equal(t, "0.00010050", netWorth.Total)
+}
+
+func TestBalanceConditionalGet(t *testing.T) {
+	account := sqlc.Account{ID: 1, Version: 42}
+	settled := models.UserBalance{Balance: "100.00", Pending: "100.00", Available: "100.00"}
+	current := balanceETag(account, settled)
+
+	tests := []struct {
+		name            string
+		ifNoneMatch     string
+		account         sqlc.Account
+		breakdown       models.UserBalance
+		expectNotModify bool
+	}{
+		{
+			name:            "No conditional header",
+			account:         account,
+			expectNotModify: false,
+		},
+		{
+			name:            "Client copy is current",
+			ifNoneMatch:     current,
+			account:         account,
+			expectNotModify: true,
+		},
+		{
+			name:            "Client copy is one of several",
+			ifNoneMatch:     `"40-100.00-100.00", ` + current,
+			account:         account,
+			expectNotModify: true,
+		},
+		{
+			name:            "Weak tag from an intermediary",
+			ifNoneMatch:     "W/" + current,
+			account:         account,
+			expectNotModify: true,
+		},
+		{
+			name:            "Any representation",
+			ifNoneMatch:     `*`,
+			account:         account,
+			expectNotModify: true,
+		},
+		{
+			// Two transactions within one second both bump the version
+			name:            "New transaction since client copy",
+			ifNoneMatch:     current,
+			account:         sqlc.Account{ID: 1, Version: 43},
+			expectNotModify: false,
+		},
+		{
+			name:            "Confirmation created since client copy",
+			ifNoneMatch:     current,
+			account:         account,
+			breakdown:       models.UserBalance{Balance: "100.00", Pending: "70.00", Available: "70.00"},
+			expectNotModify: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/user/1/balance", nil)
+			assert.NoError(t, err)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.ifNoneMatch)
+			}
+
+			recorder := httptest.NewRecorder()
+			breakdown := tt.breakdown
+			if breakdown == (models.UserBalance{}) {
+				breakdown = settled
+			}
+			etag := balanceETag(tt.account, breakdown)
+			notModified := helpers.CheckNotModified(recorder, req, etag)
+
+			assert.Equal(t, tt.expectNotModify, notModified)
+			assert.Equal(t, etag, recorder.Header().Get("ETag"))
+			if tt.expectNotModify {
+				assert.Equal(t, http.StatusNotModified, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestBalanceBreakdown(t *testing.T) {
+	account := sqlc.Account{ID: 1, UserID: 7, BalanceMinor: 10000, Currency: "EUR", Status: "active", AccountType: "general"}
+	pendingRow := func(transactionType string, amount int64) fakeRow {
+		return fakeRow{values: []interface{}{transactionType, amount}}
+	}
+
+	tests := []struct {
+		name     string
+		pending  []fakeRow
+		expected models.UserBalance
+	}{
+		{
+			name:     "No pending transactions",
+			pending:  []fakeRow{},
+			expected: models.UserBalance{UserID: 7, Balance: "100.00", Pending: "100.00", Available: "100.00", Currency: "EUR"},
+		},
+		{
+			name:     "Pending withdrawal is held back",
+			pending:  []fakeRow{pendingRow("withdrawal", 3000)},
+			expected: models.UserBalance{UserID: 7, Balance: "100.00", Pending: "70.00", Available: "70.00", Currency: "EUR"},
+		},
+		{
+			name:     "Pending deposit is not available yet",
+			pending:  []fakeRow{pendingRow("deposit", 2550), pendingRow("withdrawal", 1000)},
+			expected: models.UserBalance{UserID: 7, Balance: "100.00", Pending: "115.50", Available: "90.00", Currency: "EUR"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{results: map[string][]fakeRow{"ListPendingConfirmationsByAccount": tt.pending}}
+
+			breakdown, err := balanceBreakdown(context.Background(), sqlc.New(db), account)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, breakdown)
+			assert.Equal(t, []interface{}{int64(1)}, db.args["ListPendingConfirmationsByAccount"])
+		})
+	}
+
+	// The currency chosen at account creation is echoed back
+	usdAccount := account
+	usdAccount.Currency = "USD"
+	db := &fakeDB{results: map[string][]fakeRow{"ListPendingConfirmationsByAccount": {}}}
+	breakdown, err := balanceBreakdown(context.Background(), sqlc.New(db), usdAccount)
+	assert.NoError(t, err)
+	assert.Equal(t, "USD", breakdown.Currency)
+
+	// The breakdown uses the precision of the account currency
+	jpyAccount := sqlc.Account{ID: 2, UserID: 7, BalanceMinor: 1500, Currency: "JPY"}
+	db = &fakeDB{results: map[string][]fakeRow{"ListPendingConfirmationsByAccount": {
+		{values: []interface{}{"withdrawal", int64(500)}},
+	}}}
+	breakdown, err = balanceBreakdown(context.Background(), sqlc.New(db), jpyAccount)
+	assert.NoError(t, err)
+	assert.Equal(t, models.UserBalance{UserID: 7, Balance: "1500", Pending: "1000", Available: "1000", Currency: "JPY"}, breakdown)
+}
+
+func TestFormatAmountByCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		currency string
+		expected string
+	}{
+		{name: "EUR uses two decimals", amount: 104.65, currency: "EUR", expected: "104.65"},
+		{name: "USD pads to two decimals", amount: 10, currency: "USD", expected: "10.00"},
+		{name: "JPY has no decimals", amount: 1500, currency: "JPY", expected: "1500"},
+		{name: "BTC uses eight decimals", amount: 0.5, currency: "BTC", expected: "0.50000000"},
+		{name: "Unknown currency falls back to two decimals", amount: 3.1, currency: "XYZ", expected: "3.10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, helpers.FormatAmount(tt.amount, tt.currency))
+		})
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := []struct {
+		name     string
+		minor    int64
+		currency string
+		expected string
+	}{
+		{name: "EUR uses two decimals", minor: 10465, currency: "EUR", expected: "104.65"},
+		{name: "Amount below one unit", minor: 5, currency: "USD", expected: "0.05"},
+		{name: "Negative amount", minor: -1500, currency: "EUR", expected: "-15.00"},
+		{name: "JPY has no decimals", minor: 1500, currency: "JPY", expected: "1500"},
+		{name: "BTC uses eight decimals", minor: 1, currency: "BTC", expected: "0.00000001"},
+		{name: "Largest balance is exact", minor: math.MaxInt64, currency: "EUR", expected: "92233720368547758.07"},
+		{name: "Smallest balance is exact", minor: math.MinInt64, currency: "EUR", expected: "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, helpers.FormatMoney(tt.minor, tt.currency))
+		})
+	}
+}
+
 // Benchmark tests
 func BenchmarkGetBalanceHandler(b *testing.B) {
 	router := mux.NewRouter()
@@ -220,3 +522,524 @@ func BenchmarkGetBalanceHandler(b *testing.B) {
 		router.ServeHTTP(recorder, req)
 	}
 }
+
+func TestAccountTypeRules(t *testing.T) {
+	tests := []struct {
+		name            string
+		accountType     string
+		transactionType string
+		source          string
+		amount          float64
+		expectedErr     error
+		expectedStatus  int
+	}{
+		{
+			name:            "Savings rejects a withdrawal above its limit",
+			accountType:     "savings",
+			transactionType: "withdrawal",
+			source:          "payment",
+			amount:          1500.00,
+			expectedErr:     helpers.ErrWithdrawalLimitExceeded,
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "Checking allows the same withdrawal",
+			accountType:     "checking",
+			transactionType: "withdrawal",
+			source:          "payment",
+			amount:          1500.00,
+		},
+		{
+			name:            "Savings allows a withdrawal within its limit",
+			accountType:     "savings",
+			transactionType: "withdrawal",
+			source:          "payment",
+			amount:          500.00,
+		},
+		{
+			name:            "Checking rejects game wins",
+			accountType:     "checking",
+			transactionType: "win",
+			source:          "game",
+			amount:          10.00,
+			expectedErr:     helpers.ErrTransactionTypeNotAllowed,
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "Game account rejects payment sources",
+			accountType:     "game",
+			transactionType: "win",
+			source:          "payment",
+			amount:          10.00,
+			expectedErr:     helpers.ErrSourceNotAllowed,
+			expectedStatus:  http.StatusBadRequest,
+		},
+		{
+			name:            "General accepts everything",
+			accountType:     "general",
+			transactionType: "win",
+			source:          "payment",
+			amount:          5000.00,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := sqlc.Account{ID: 1, UserID: 1, BalanceMinor: 200000, Status: "active", AccountType: tt.accountType}
+			db := &fakeDB{rows: map[string]fakeRow{
+				"GetAccountForUpdate": accountRow(account),
+				"UpdateAccount":       accountRow(account),
+			}}
+
+			_, err := updateBalanceInTx(context.Background(), sqlc.New(db), 1, helpers.ToMinorUnits(tt.amount, account.Currency), tt.transactionType, tt.source)
+
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+				assert.Contains(t, db.queries, "UpdateAccount")
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.NotContains(t, db.queries, "UpdateAccount")
+
+			recorder := httptest.NewRecorder()
+			helpers.HandleAPIError(recorder, transactionRuleError(err))
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestMiniStatement(t *testing.T) {
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	statementRows := func(account sqlc.Account, count int) []fakeRow {
+		row := func(values ...interface{}) fakeRow {
+			return fakeRow{values: append([]interface{}{account.ID, account.UserID, account.BalanceMinor, account.Currency}, values...)}
+		}
+		if count == 0 {
+			return []fakeRow{row(pgtype.Text{}, pgtype.Int8{}, pgtype.Text{}, pgtype.Text{}, models.Timestamp{})}
+		}
+		rows := []fakeRow{}
+		for i := 0; i < count; i++ {
+			rows = append(rows, row(
+				pgtype.Text{String: fmt.Sprintf("tx-%d", i), Valid: true},
+				pgtype.Int8{Int64: 1010, Valid: true},
+				pgtype.Text{String: "win", Valid: true},
+				pgtype.Text{String: "game", Valid: true},
+				models.NewTimestamp(base.Add(-time.Duration(i)*time.Minute)),
+			))
+		}
+		return rows
+	}
+	first := sqlc.Account{ID: 1, UserID: 7, BalanceMinor: 123450, Currency: "USD"}
+	second := sqlc.Account{ID: 2, UserID: 7, BalanceMinor: 9900, Currency: "EUR"}
+
+	tests := []struct {
+		name                 string
+		accountID            string
+		rows                 []fakeRow
+		expectedAccountParam pgtype.Int8
+		expectedAccountID    int64
+		expectedBalance      string
+		expectedCurrency     string
+		expectedTransactions int
+		expectedErr          error
+	}{
+		{
+			name:                 "First account",
+			rows:                 statementRows(first, 3),
+			expectedAccountID:    1,
+			expectedBalance:      "1234.50",
+			expectedCurrency:     "USD",
+			expectedTransactions: 3,
+		},
+		{
+			name:                 "Second of two accounts",
+			accountID:            "2",
+			rows:                 statementRows(second, 5),
+			expectedAccountParam: pgtype.Int8{Int64: 2, Valid: true},
+			expectedAccountID:    2,
+			expectedBalance:      "99.00",
+			expectedCurrency:     "EUR",
+			expectedTransactions: 5,
+		},
+		{
+			name:              "Account without transactions",
+			rows:              statementRows(first, 0),
+			expectedAccountID: 1,
+			expectedBalance:   "1234.50",
+			expectedCurrency:  "USD",
+		},
+		{
+			// The query only finds accounts of the user
+			name:                 "Account of another user",
+			accountID:            "3",
+			rows:                 []fakeRow{},
+			expectedAccountParam: pgtype.Int8{Int64: 3, Valid: true},
+			expectedErr:          helpers.ErrAccountNotFound,
+		},
+		{
+			name:        "No account",
+			rows:        []fakeRow{},
+			expectedErr: helpers.ErrAccountNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{results: map[string][]fakeRow{"GetMiniStatement": tt.rows}}
+
+			statement, err := miniStatement(context.Background(), sqlc.New(db), 7, tt.accountID, 5)
+
+			// The account and its latest transactions are read in one round trip
+			assert.Equal(t, []string{"GetMiniStatement"}, db.queries)
+			assert.Equal(t, []interface{}{int64(7), tt.expectedAccountParam, int32(5)}, db.args["GetMiniStatement"])
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), statement.UserID)
+			assert.Equal(t, tt.expectedAccountID, statement.AccountID)
+			assert.Equal(t, tt.expectedBalance, statement.Balance)
+			assert.Equal(t, tt.expectedCurrency, statement.Currency)
+			assert.Len(t, statement.Transactions, tt.expectedTransactions)
+			for _, entry := range statement.Transactions {
+				assert.Equal(t, "10.10", entry.Amount)
+			}
+		})
+	}
+
+	// An invalid account ID is rejected before querying
+	db := &fakeDB{}
+	_, err := miniStatement(context.Background(), sqlc.New(db), 7, "abc", 5)
+	assert.ErrorIs(t, err, helpers.ErrInvalidID)
+	assert.Empty(t, db.queries)
+}
+
+func TestMiniStatementSize(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         string
+		expectedSize int32
+		expectError  bool
+	}{
+		{name: "Default size", size: "", expectedSize: 5},
+		{name: "Configured size", size: "3", expectedSize: 3},
+		{name: "Largest size", size: "100", expectedSize: 100},
+		{name: "Zero", size: "0", expectError: true},
+		{name: "Negative", size: "-1", expectError: true},
+		{name: "Above the page limit", size: "101", expectError: true},
+		{name: "Overflowing", size: "99999999999999999999", expectError: true},
+		{name: "Not a number", size: "five", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MINI_STATEMENT_SIZE", tt.size)
+
+			size, err := helpers.MiniStatementSize()
+
+			if tt.expectError {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "MINI_STATEMENT_SIZE")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedSize, size)
+		})
+	}
+}
+
+func TestMiniStatementHandlerInvalidID(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/user/{userId}/mini-statement", MiniStatementHandler(5)).Methods("GET")
+
+	req, err := http.NewRequest("GET", "/user/abc/mini-statement", nil)
+	assert.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
+
+func TestBuildMeta(t *testing.T) {
+	meta := buildMeta(map[string]float64{"USD": 10000, "JPY": 1500000})
+
+	assert.Equal(t, map[string]string{"USD": "10000.00", "JPY": "1500000"}, meta.DailyDebitCaps)
+	assert.Equal(t, []string{"lose", "withdrawal"}, meta.CappedTypes)
+}
+
+func TestNormalizeAccountMetadata(t *testing.T) {
+	manyKeys := map[string]string{}
+	for i := 0; i <= helpers.MaxAccountMetadataKeys; i++ {
+		manyKeys[fmt.Sprintf("key%d", i)] = "v"
+	}
+	tooManyKeys, _ := json.Marshal(manyKeys)
+	longValue, _ := json.Marshal(map[string]string{"note": string(bytes.Repeat([]byte("x"), helpers.MaxAccountMetadataBytes))})
+	longKey, _ := json.Marshal(map[string]string{string(bytes.Repeat([]byte("k"), helpers.MaxAccountMetadataKeyLength+1)): "v"})
+
+	tests := []struct {
+		name        string
+		metadata    string
+		expected    string
+		expectedErr error
+	}{
+		{name: "Strings and numbers", metadata: `{ "tier": 2, "partner_id": "P-17" }`, expected: `{"partner_id":"P-17","tier":2}`},
+		{name: "Absent", metadata: ``, expected: `{}`},
+		{name: "Null", metadata: `null`, expected: `{}`},
+		{name: "Nested object", metadata: `{"partner":{"id":"P-17"}}`, expectedErr: helpers.ErrInvalidMetadata},
+		{name: "Array value", metadata: `{"tags":["a"]}`, expectedErr: helpers.ErrInvalidMetadata},
+		{name: "Boolean value", metadata: `{"vip":true}`, expectedErr: helpers.ErrInvalidMetadata},
+		{name: "Not an object", metadata: `["a"]`, expectedErr: helpers.ErrInvalidMetadata},
+		{name: "Empty key", metadata: `{"":"v"}`, expectedErr: helpers.ErrInvalidMetadata},
+		{name: "Too many keys", metadata: string(tooManyKeys), expectedErr: helpers.ErrMetadataTooLarge},
+		{name: "Too many bytes", metadata: string(longValue), expectedErr: helpers.ErrMetadataTooLarge},
+		{name: "Key too long", metadata: string(longKey), expectedErr: helpers.ErrMetadataTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metadata, err := helpers.NormalizeAccountMetadata(json.RawMessage(tt.metadata))
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+
+				recorder := httptest.NewRecorder()
+				helpers.HandleAPIError(recorder, err)
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(metadata))
+		})
+	}
+}
+
+func TestAccountMetadataRoundTrip(t *testing.T) {
+	stored := map[int64]json.RawMessage{}
+	db := &fakeDB{
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			switch name {
+			case "CreateAccount":
+				stored[5] = args[5].(json.RawMessage)
+				return accountRow(sqlc.Account{ID: 5, UserID: 1, Metadata: stored[5]}), true
+			case "GetAccount":
+				return accountRow(sqlc.Account{ID: 5, UserID: 1, Metadata: stored[5]}), true
+			case "UpdateAccountMetadata":
+				stored[5] = args[1].(json.RawMessage)
+				return accountRow(sqlc.Account{ID: 5, UserID: 1, Metadata: stored[5]}), true
+			}
+			return fakeRow{}, false
+		},
+	}
+	queries := sqlc.New(db)
+
+	metadata, err := helpers.NormalizeAccountMetadata(json.RawMessage(`{"partner_id":"P-17","tier":1}`))
+	assert.NoError(t, err)
+	account, err := createAccountInDB(context.Background(), queries, 1, "", "", "", metadata)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"partner_id":"P-17","tier":1}`, string(account.Metadata))
+
+	// Updates replace the metadata and are returned in the account response
+	account, err = updateAccountMetadata(context.Background(), queries, 1, 5, json.RawMessage(`{"tier":2}`))
+	assert.NoError(t, err)
+	body, err := json.Marshal(account)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"metadata":{"tier":2}`)
+
+	// Invalid metadata and other users' accounts are rejected without writing
+	_, err = updateAccountMetadata(context.Background(), queries, 1, 5, json.RawMessage(`{"tier":{"level":3}}`))
+	assert.ErrorIs(t, err, helpers.ErrInvalidMetadata)
+	_, err = updateAccountMetadata(context.Background(), queries, 2, 5, json.RawMessage(`{"tier":3}`))
+	assert.ErrorIs(t, err, helpers.ErrAccountNotFound)
+	assert.JSONEq(t, `{"tier":2}`, string(stored[5]))
+
+	// Accounts created without metadata get an empty object
+	account, err = createAccountInDB(context.Background(), queries, 1, "", "", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, `{}`, string(account.Metadata))
+}
+
+func TestListAccountsByMetadata(t *testing.T) {
+	db := &fakeDB{results: map[string][]fakeRow{
+		"ListAccountsByUser":         {accountRow(sqlc.Account{ID: 5, UserID: 1}), accountRow(sqlc.Account{ID: 6, UserID: 1})},
+		"ListAccountsByUserMetadata": {accountRow(sqlc.Account{ID: 6, UserID: 1, Metadata: json.RawMessage(`{"tier":2}`)})},
+	}}
+	queries := sqlc.New(db)
+
+	accounts, err := listAccountsByMetadata(context.Background(), queries, 1, "", "")
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+
+	accounts, err = listAccountsByMetadata(context.Background(), queries, 1, "tier", "2")
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 1)
+	assert.Equal(t, []interface{}{int64(1), "tier", pgtype.Text{String: "2", Valid: true}}, db.args["ListAccountsByUserMetadata"])
+
+	// Without a value any account having the key matches
+	_, err = listAccountsByMetadata(context.Background(), queries, 1, "tier", "")
+	assert.NoError(t, err)
+	assert.Equal(t, pgtype.Text{}, db.args["ListAccountsByUserMetadata"][2])
+}
+
+func TestRunInSnapshot(t *testing.T) {
+	db := &fakeDB{results: map[string][]fakeRow{
+		"ListAccountsByUser": {accountRow(sqlc.Account{ID: 5, UserID: 1, BalanceMinor: 1000}), accountRow(sqlc.Account{ID: 6, UserID: 1, BalanceMinor: 2000})},
+	}}
+	starter := &fakeStarter{db: db}
+
+	// Both balances are read in one repeatable read transaction
+	var accounts []sqlc.Account
+	err := runInSnapshot(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		accounts, err = queries.ListAccountsByUser(ctx, 1)
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+	assert.Equal(t, []string{"SET", "ListAccountsByUser"}, db.queries)
+	assert.Len(t, starter.txs, 1)
+	assert.True(t, starter.txs[0].committed)
+
+	// A failed read ends the snapshot with a rollback
+	readErr := errors.New("connection reset")
+	err = runInSnapshot(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+		return readErr
+	})
+	assert.ErrorIs(t, err, readErr)
+	assert.True(t, starter.txs[1].rolledBack)
+	assert.False(t, starter.txs[1].committed)
+}
+
+func TestSourceTypesHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	SourceTypesHandler(recorder, httptest.NewRequest("GET", "/meta/source-types", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+
+	var response models.SourceTypes
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	allTypes := []string{"adjustment", "deposit", "lose", "reversal", "win", "withdrawal"}
+	assert.Equal(t, map[string][]string{
+		"general":  allTypes,
+		"checking": {},
+		"savings":  {},
+		"game":     {"adjustment", "lose", "reversal", "win"},
+	}, response.Sources["game"])
+	assert.Equal(t, map[string][]string{
+		"general":  allTypes,
+		"checking": {"adjustment", "deposit", "reversal", "withdrawal"},
+		"savings":  {"adjustment", "deposit", "reversal", "withdrawal"},
+		"game":     {},
+	}, response.Sources["payment"])
+
+	// Every combination agrees with the rules enforced on transactions
+	for source, byAccountType := range response.Sources {
+		for accountType, allowed := range byAccountType {
+			for _, transactionType := range allTypes {
+				err := helpers.CheckAccountRules(accountType, transactionType, source, 0, helpers.DefaultAccountCurrency)
+				assert.Equal(t, err == nil, slices.Contains(allowed, transactionType), "%s %s %s", source, accountType, transactionType)
+			}
+		}
+	}
+	assert.Len(t, response.Sources, 3)
+}
+
+func TestCreateAccountOpeningBalance(t *testing.T) {
+	tests := []struct {
+		name             string
+		currency         string
+		openingBalance   string
+		expectedCurrency string
+		expectedMinor    int64
+		expectedErr      error
+	}{
+		{name: "Defaults to an empty EUR account", expectedCurrency: "EUR"},
+		{name: "Opening balance in USD", currency: "USD", openingBalance: "25.50", expectedCurrency: "USD", expectedMinor: 2550},
+		{name: "Negative opening balance", currency: "GBP", openingBalance: "-1", expectedErr: helpers.ErrAmountMustBePositive},
+		{name: "Sub-cent opening balance", currency: "EUR", openingBalance: "10.001", expectedErr: helpers.ErrTooManyDecimals},
+		{name: "Zero opening balance", currency: "EUR", openingBalance: "0", expectedCurrency: "EUR"},
+		{name: "Opening balance in JPY", currency: "JPY", openingBalance: "1500", expectedCurrency: "JPY", expectedMinor: 1500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{rows: map[string]fakeRow{
+				"CreateAccount": accountRow(sqlc.Account{ID: 9, UserID: 1}),
+			}}
+
+			_, err := createAccountInDB(context.Background(), sqlc.New(db), 1, "", tt.currency, tt.openingBalance, nil)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Empty(t, db.queries)
+				return
+			}
+			assert.NoError(t, err)
+			args := db.args["CreateAccount"]
+			assert.Equal(t, helpers.NumericFromMinorUnits(tt.expectedMinor, tt.expectedCurrency), args[1])
+			assert.Equal(t, tt.expectedMinor, args[2])
+			assert.Equal(t, tt.expectedCurrency, args[3])
+		})
+	}
+}
+
+func TestRequestAccount(t *testing.T) {
+	db := &fakeDB{
+		rows: map[string]fakeRow{"GetAccountByUser": accountRow(sqlc.Account{ID: 5, UserID: 1, Currency: "EUR"})},
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			if name != "GetAccount" {
+				return fakeRow{}, false
+			}
+			switch args[0].(int64) {
+			case 6:
+				return accountRow(sqlc.Account{ID: 6, UserID: 1, Currency: "USD"}), true
+			case 7:
+				return accountRow(sqlc.Account{ID: 7, UserID: 2, Currency: "EUR"}), true
+			}
+			return fakeRow{}, false
+		},
+	}
+	queries := sqlc.New(db)
+
+	tests := []struct {
+		name        string
+		accountID   string
+		expectedID  int64
+		expectedErr error
+	}{
+		{name: "Routes without an account ID use the first account", accountID: "", expectedID: 5},
+		{name: "Account of the user", accountID: "6", expectedID: 6},
+		{name: "Account of another user", accountID: "7", expectedErr: helpers.ErrAccountNotFound},
+		{name: "Invalid account ID", accountID: "abc", expectedErr: helpers.ErrInvalidID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account, err := requestAccount(context.Background(), queries, 1, tt.accountID)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedID, account.ID)
+		})
+	}
+}
+
+func TestHandleAccountError(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	handleAccountError(recorder, helpers.ErrAccountNotFound)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handleAccountError(recorder, pgx.ErrNoRows)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handleAccountError(recorder, helpers.ErrInvalidID)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+}
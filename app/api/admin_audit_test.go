@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func adminAuditRow(record sqlc.AdminAudit) fakeRow {
+	return fakeRow{values: []interface{}{
+		record.ID,
+		record.Actor,
+		record.Action,
+		record.TargetUserID,
+		record.TargetAccountID,
+		record.Params,
+		record.InsertedAt,
+	}}
+}
+
+func TestPayoutWritesAdminAudit(t *testing.T) {
+	db := payoutDB()
+	starter := &fakeStarter{db: db}
+	db.rows["GetAccountByUser"] = accountRow(sqlc.Account{ID: 10, UserID: 1})
+
+	var auditArgs []interface{}
+	accountLookup := db.rowFunc
+	db.rowFunc = func(name string, args []interface{}) (fakeRow, bool) {
+		if name == "CreateAdminAudit" {
+			auditArgs = args
+		}
+		return accountLookup(name, args)
+	}
+
+	entries := []models.PayoutEntry{{UserID: 1, Amount: "10.00", Memo: "March payroll"}}
+	results := applyPayouts(context.Background(), starter, sqlc.New(db), "ops@example.com", "batch-1", entries, true)
+
+	assert.Equal(t, payoutStatusApplied, results[0].Status)
+	assert.True(t, starter.txs[0].committed)
+	assert.Contains(t, db.queries, "CreateAdminAudit")
+
+	assert.Len(t, auditArgs, 5)
+	assert.Equal(t, "ops@example.com", auditArgs[0])
+	assert.Equal(t, "payout", auditArgs[1])
+	assert.Equal(t, pgtype.Int8{Int64: 1, Valid: true}, auditArgs[2])
+	assert.Equal(t, pgtype.Int8{Int64: 10, Valid: true}, auditArgs[3])
+
+	var params map[string]string
+	assert.NoError(t, json.Unmarshal(auditArgs[4].([]byte), &params))
+	assert.Equal(t, "10.00", params["amount"])
+	assert.Equal(t, "batch-1", params["batch_id"])
+	assert.Equal(t, results[0].TransactionID, params["transaction_id"])
+}
+
+func TestPayoutAuditFailureRollsBack(t *testing.T) {
+	db := payoutDB()
+	starter := &fakeStarter{db: db}
+	db.rows["GetAccountByUser"] = accountRow(sqlc.Account{ID: 10, UserID: 1})
+	delete(db.rows, "CreateAdminAudit")
+
+	entries := []models.PayoutEntry{{UserID: 1, Amount: "10.00"}}
+	results := applyPayouts(context.Background(), starter, sqlc.New(db), "ops@example.com", "batch-1", entries, false)
+
+	assert.Equal(t, payoutStatusFailed, results[0].Status)
+	assert.True(t, starter.txs[0].rolledBack)
+}
+
+func TestAdminActor(t *testing.T) {
+	// A client-supplied header does not name the actor
+	req := httptest.NewRequest("GET", "/admin/audit", nil)
+	req.Header.Set("Admin-Subject", "someone-else")
+	_, err := adminActor(req)
+	assert.ErrorIs(t, err, helpers.ErrAdminRequired)
+
+	req = req.WithContext(helpers.WithAdmin(req.Context(), "alice"))
+	actor, err := adminActor(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", actor)
+}
+
+func TestListAdminAuditHandlerPagination(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "Zero limit", url: "/admin/audit?limit=0"},
+		{name: "Limit above maximum", url: "/admin/audit?limit=101"},
+		{name: "Negative offset", url: "/admin/audit?offset=-1"},
+		{name: "Non-numeric limit", url: "/admin/audit?limit=ten"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, nil)
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			ListAdminAuditHandler(recorder, req)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		})
+	}
+}
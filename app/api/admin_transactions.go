@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+var (
+	errInvalidTransactionFilter = errors.New("invalid transaction filter")
+	errInvalidCursor            = errors.New("invalid cursor")
+	errInvalidOrder             = errors.New("invalid order")
+)
+
+// Sort directions accepted by the order query parameter
+const (
+	orderAscending  = "asc"
+	orderDescending = "desc"
+)
+
+// parseOrder reads the optional order query parameter; newest first unless asc is requested
+func parseOrder(value string) (bool, error) {
+	switch value {
+	case "", orderDescending:
+		return false, nil
+	case orderAscending:
+		return true, nil
+	default:
+		return false, errInvalidOrder
+	}
+}
+
+// adminTransactionPage is one page of the admin transaction browser
+type adminTransactionPage struct {
+	Transactions []sqlc.Transaction `json:"transactions"`
+	Limit        int32              `json:"limit"`
+	NextCursor   string             `json:"next_cursor,omitempty"`
+}
+
+// encodeTransactionCursor points after a transaction in (inserted_at, id) order. Ascending cursors
+// carry their direction so they cannot be replayed against the other order.
+func encodeTransactionCursor(transaction sqlc.Transaction, ascending bool) string {
+	value := transaction.InsertedAt.Time.Format(time.RFC3339Nano) + "|" + transaction.ID
+	if ascending {
+		value = orderAscending + "|" + value
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// decodeTransactionCursor returns the position of a cursor and whether it was issued for ascending order
+func decodeTransactionCursor(cursor string) (models.Timestamp, string, bool, error) {
+	value, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return models.Timestamp{}, "", false, errInvalidCursor
+	}
+
+	position, ascending := strings.CutPrefix(string(value), orderAscending+"|")
+	insertedAt, id, ok := strings.Cut(position, "|")
+	if !ok || id == "" {
+		return models.Timestamp{}, "", false, errInvalidCursor
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, insertedAt)
+	if err != nil {
+		return models.Timestamp{}, "", false, errInvalidCursor
+	}
+	return models.NewTimestamp(parsed), id, ascending, nil
+}
+
+func parseOptionalID(value string) (pgtype.Int8, error) {
+	if value == "" {
+		return pgtype.Int8{}, nil
+	}
+	id, err := helpers.ValidateID(value)
+	if err != nil {
+		return pgtype.Int8{}, errInvalidTransactionFilter
+	}
+	return pgtype.Int8{Int64: id, Valid: true}, nil
+}
+
+func parseOptionalAmount(value string) (pgtype.Float8, error) {
+	if value == "" {
+		return pgtype.Float8{}, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount < 0 {
+		return pgtype.Float8{}, errInvalidTransactionFilter
+	}
+	return pgtype.Float8{Float64: amount, Valid: true}, nil
+}
+
+func parseOptionalTimestamp(value string) (models.Timestamp, error) {
+	parsed, err := helpers.ParseTimestamp(value)
+	if err != nil {
+		return models.Timestamp{}, errInvalidTransactionFilter
+	}
+	return models.NewTimestamp(parsed), nil
+}
+
+// adminTransactionFilter holds the filters and cursor of GET /admin/transactions and the sort direction,
+// which picks the query so each one has a plain ORDER BY the (inserted_at, id) index can serve
+type adminTransactionFilter struct {
+	sqlc.ListAdminTransactionsParams
+	Ascending bool
+}
+
+// parseAdminTransactionFilter reads the filters, page size and cursor of GET /admin/transactions
+func parseAdminTransactionFilter(query url.Values) (adminTransactionFilter, int32, error) {
+	var params adminTransactionFilter
+	var err error
+
+	limit, err := helpers.ParseLimit(query)
+	if err != nil {
+		return params, 0, err
+	}
+
+	if params.AccountID, err = parseOptionalID(query.Get("account_id")); err != nil {
+		return params, 0, err
+	}
+	if params.UserID, err = parseOptionalID(query.Get("user_id")); err != nil {
+		return params, 0, err
+	}
+
+	if source := query.Get("source"); source != "" {
+		if !helpers.IsValidSource(source) {
+			return params, 0, errInvalidTransactionFilter
+		}
+		params.Source = pgtype.Text{String: source, Valid: true}
+	}
+	if transactionType := query.Get("type"); transactionType != "" {
+		if _, ok := helpers.LookupTransactionType(transactionType); !ok {
+			return params, 0, errInvalidTransactionFilter
+		}
+		params.Type = pgtype.Text{String: transactionType, Valid: true}
+	}
+
+	if params.MinAmount, err = parseOptionalAmount(query.Get("min_amount")); err != nil {
+		return params, 0, err
+	}
+	if params.MaxAmount, err = parseOptionalAmount(query.Get("max_amount")); err != nil {
+		return params, 0, err
+	}
+	if params.MinAmount.Valid && params.MaxAmount.Valid && params.MinAmount.Float64 > params.MaxAmount.Float64 {
+		return params, 0, errInvalidTransactionFilter
+	}
+
+	if params.InsertedFrom, err = parseOptionalTimestamp(query.Get("from")); err != nil {
+		return params, 0, err
+	}
+	if params.InsertedTo, err = parseOptionalTimestamp(query.Get("to")); err != nil {
+		return params, 0, err
+	}
+
+	if params.Ascending, err = parseOrder(query.Get("order")); err != nil {
+		return params, 0, err
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		insertedAt, id, ascending, err := decodeTransactionCursor(cursor)
+		if err != nil {
+			return params, 0, err
+		}
+		// A cursor only continues the order it was issued for
+		if ascending != params.Ascending {
+			return params, 0, errInvalidCursor
+		}
+		params.AfterInsertedAt = insertedAt
+		params.AfterID = pgtype.Text{String: id, Valid: true}
+	}
+
+	return params, limit, nil
+}
+
+// listAdminTransactions fetches one page, reading one extra row to know whether another page follows
+func listAdminTransactions(ctx context.Context, queries *sqlc.Queries, params adminTransactionFilter, limit int32) (adminTransactionPage, error) {
+	params.PageSize = limit + 1
+
+	var transactions []sqlc.Transaction
+	var err error
+	if params.Ascending {
+		transactions, err = queries.ListAdminTransactionsAscending(ctx, sqlc.ListAdminTransactionsAscendingParams(params.ListAdminTransactionsParams))
+	} else {
+		transactions, err = queries.ListAdminTransactions(ctx, params.ListAdminTransactionsParams)
+	}
+	if err != nil {
+		return adminTransactionPage{}, err
+	}
+
+	page := adminTransactionPage{Transactions: transactions, Limit: limit}
+	if len(transactions) > int(limit) {
+		page.Transactions = transactions[:limit]
+		page.NextCursor = encodeTransactionCursor(page.Transactions[limit-1], params.Ascending)
+	}
+	return page, nil
+}
+
+// ListAdminTransactionsHandler handles GET /admin/transactions - browses transactions across all accounts,
+// newest first unless order=asc, filtered by account_id, user_id, source, type, min_amount, max_amount, from and to
+func ListAdminTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	params, limit, err := parseAdminTransactionFilter(r.URL.Query())
+	switch {
+	case errors.Is(err, errInvalidTransactionFilter):
+		helpers.RespondError(w, http.StatusBadRequest, "Invalid transaction filter")
+		return
+	case errors.Is(err, errInvalidOrder):
+		helpers.RespondError(w, http.StatusBadRequest, "Order must be one of: asc desc")
+		return
+	case errors.Is(err, errInvalidCursor):
+		helpers.RespondError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	case err != nil:
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	page, err := listAdminTransactions(r.Context(), database.DBClient.Queries, params, limit)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transaction")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Transactions retrieved successfully", page)
+}
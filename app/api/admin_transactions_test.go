@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAdminTransactionFilter(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		query       string
+		expectedErr error
+		check       func(t *testing.T, params adminTransactionFilter, limit int32)
+	}{
+		{
+			name:  "No filters",
+			query: "",
+			check: func(t *testing.T, params adminTransactionFilter, limit int32) {
+				assert.Equal(t, int32(helpers.DefaultPageLimit), limit)
+				assert.False(t, params.AccountID.Valid)
+				assert.False(t, params.UserID.Valid)
+				assert.False(t, params.Source.Valid)
+				assert.True(t, params.InsertedFrom.IsZero())
+				assert.False(t, params.AfterID.Valid)
+			},
+		},
+		{
+			name:  "Account and source",
+			query: "account_id=3&source=game&limit=10",
+			check: func(t *testing.T, params adminTransactionFilter, limit int32) {
+				assert.Equal(t, int32(10), limit)
+				assert.Equal(t, pgtype.Int8{Int64: 3, Valid: true}, params.AccountID)
+				assert.Equal(t, pgtype.Text{String: "game", Valid: true}, params.Source)
+				assert.False(t, params.Type.Valid)
+			},
+		},
+		{
+			name:  "User, type, amount and date range",
+			query: "user_id=7&type=deposit&min_amount=10&max_amount=500.5&from=2025-01-01T00:00:00Z&to=2025-02-01T00:00:00Z",
+			check: func(t *testing.T, params adminTransactionFilter, limit int32) {
+				assert.Equal(t, pgtype.Int8{Int64: 7, Valid: true}, params.UserID)
+				assert.Equal(t, pgtype.Text{String: "deposit", Valid: true}, params.Type)
+				assert.Equal(t, pgtype.Float8{Float64: 10, Valid: true}, params.MinAmount)
+				assert.Equal(t, pgtype.Float8{Float64: 500.5, Valid: true}, params.MaxAmount)
+				assert.Equal(t, from, params.InsertedFrom.Time)
+				assert.Equal(t, from.AddDate(0, 1, 0), params.InsertedTo.Time)
+			},
+		},
+		{name: "Invalid account ID", query: "account_id=abc", expectedErr: errInvalidTransactionFilter},
+		{name: "Unknown source", query: "source=casino", expectedErr: errInvalidTransactionFilter},
+		{name: "Unknown type", query: "type=bonus", expectedErr: errInvalidTransactionFilter},
+		{name: "Negative amount", query: "min_amount=-1", expectedErr: errInvalidTransactionFilter},
+		{name: "Inverted amount range", query: "min_amount=100&max_amount=10", expectedErr: errInvalidTransactionFilter},
+		{name: "Invalid date", query: "from=yesterday", expectedErr: errInvalidTransactionFilter},
+		{name: "Page size above maximum", query: "limit=1000", expectedErr: helpers.ErrInvalidPagination},
+		{name: "Malformed cursor", query: "cursor=not-a-cursor", expectedErr: errInvalidCursor},
+		{
+			name:  "Oldest first",
+			query: "order=asc",
+			check: func(t *testing.T, params adminTransactionFilter, limit int32) {
+				assert.True(t, params.Ascending)
+			},
+		},
+		{
+			name:  "Explicit newest first",
+			query: "order=desc",
+			check: func(t *testing.T, params adminTransactionFilter, limit int32) {
+				assert.False(t, params.Ascending)
+			},
+		},
+		{name: "Unknown order", query: "order=random", expectedErr: errInvalidOrder},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			assert.NoError(t, err)
+
+			params, limit, err := parseAdminTransactionFilter(query)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			tt.check(t, params, limit)
+		})
+	}
+}
+
+func TestListAdminTransactionsKeysetPagination(t *testing.T) {
+	insertedAt := time.Date(2025, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	// tx-b and tx-a share a timestamp, so only the id keeps their order stable across pages
+	rows := []sqlc.Transaction{
+		{ID: "tx-c", AccountID: 1, InsertedAt: models.NewTimestamp(insertedAt.Add(time.Second))},
+		{ID: "tx-b", AccountID: 2, InsertedAt: models.NewTimestamp(insertedAt)},
+		{ID: "tx-a", AccountID: 1, InsertedAt: models.NewTimestamp(insertedAt)},
+	}
+	db := &fakeDB{results: map[string][]fakeRow{}}
+	for _, row := range rows {
+		db.results["ListAdminTransactions"] = append(db.results["ListAdminTransactions"], transactionRow(row))
+	}
+
+	page, err := listAdminTransactions(context.Background(), sqlc.New(db), adminTransactionFilter{}, 2)
+	assert.NoError(t, err)
+	assert.Len(t, page.Transactions, 2)
+	assert.Equal(t, "tx-b", page.Transactions[1].ID)
+	assert.NotEmpty(t, page.NextCursor)
+
+	// One extra row is requested to detect the next page
+	assert.Equal(t, int32(3), db.args["ListAdminTransactions"][10])
+
+	// The cursor resumes strictly after the last row of the page
+	params, _, err := parseAdminTransactionFilter(url.Values{"cursor": {page.NextCursor}, "limit": {"2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, insertedAt, params.AfterInsertedAt.Time)
+	assert.Equal(t, pgtype.Text{String: "tx-b", Valid: true}, params.AfterID)
+
+	// The last page has no cursor
+	db.results["ListAdminTransactions"] = db.results["ListAdminTransactions"][2:]
+	page, err = listAdminTransactions(context.Background(), sqlc.New(db), params, 2)
+	assert.NoError(t, err)
+	assert.Len(t, page.Transactions, 1)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestListAdminTransactionsOrder(t *testing.T) {
+	insertedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	oldest := sqlc.Transaction{ID: "tx-a", AccountID: 1, InsertedAt: models.NewTimestamp(insertedAt)}
+	middle := sqlc.Transaction{ID: "tx-b", AccountID: 1, InsertedAt: models.NewTimestamp(insertedAt.Add(time.Second))}
+	newest := sqlc.Transaction{ID: "tx-c", AccountID: 1, InsertedAt: models.NewTimestamp(insertedAt.Add(2 * time.Second))}
+
+	tests := []struct {
+		name      string
+		order     string
+		rows      []sqlc.Transaction
+		query     string
+		ascending bool
+	}{
+		{name: "Newest first", order: "desc", rows: []sqlc.Transaction{newest, middle, oldest}, query: "ListAdminTransactions", ascending: false},
+		{name: "Oldest first", order: "asc", rows: []sqlc.Transaction{oldest, middle, newest}, query: "ListAdminTransactionsAscending", ascending: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{results: map[string][]fakeRow{}}
+			for _, row := range tt.rows {
+				db.results[tt.query] = append(db.results[tt.query], transactionRow(row))
+			}
+
+			params, limit, err := parseAdminTransactionFilter(url.Values{"order": {tt.order}, "limit": {"2"}})
+			assert.NoError(t, err)
+
+			// Each direction has its own query, so neither sorts through a CASE expression
+			page, err := listAdminTransactions(context.Background(), sqlc.New(db), params, limit)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tt.query}, db.queries)
+			assert.Equal(t, []string{tt.rows[0].ID, tt.rows[1].ID}, []string{page.Transactions[0].ID, page.Transactions[1].ID})
+
+			// The cursor continues in the same direction after the last row of the page
+			next, _, err := parseAdminTransactionFilter(url.Values{"order": {tt.order}, "cursor": {page.NextCursor}})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.ascending, next.Ascending)
+			assert.Equal(t, tt.rows[1].InsertedAt.Time, next.AfterInsertedAt.Time)
+			assert.Equal(t, pgtype.Text{String: "tx-b", Valid: true}, next.AfterID)
+
+			// Replaying it against the other order is rejected
+			opposite := orderAscending
+			if tt.ascending {
+				opposite = orderDescending
+			}
+			_, _, err = parseAdminTransactionFilter(url.Values{"order": {opposite}, "cursor": {page.NextCursor}})
+			assert.ErrorIs(t, err, errInvalidCursor)
+		})
+	}
+}
+
+func TestListAdminTransactionsHandlerInvalidFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "Unknown source", url: "/admin/transactions?source=casino"},
+		{name: "Malformed cursor", url: "/admin/transactions?cursor=bm90LWEtY3Vyc29y"},
+		{name: "Limit above maximum", url: "/admin/transactions?limit=101"},
+		{name: "Unknown order", url: "/admin/transactions?order=up"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			ListAdminTransactionsHandler(recorder, httptest.NewRequest("GET", tt.url, nil))
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/middleware"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// authorizePrincipal applies the permissions of the API key that authenticated the request.
+// Requests without a principal are allowed, as API key authentication is optional.
+func authorizePrincipal(r *http.Request, operation, source, transactionType string) error {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+	return helpers.Authorize(principal, operation, source, transactionType)
+}
+
+// LookupAPIKey resolves a key hash to its principal; it satisfies middleware.APIKeyLookup
+func LookupAPIKey(ctx context.Context, keyHash string) (models.APIPrincipal, error) {
+	return lookupAPIKey(ctx, database.DBClient.Queries, keyHash)
+}
+
+func lookupAPIKey(ctx context.Context, queries *sqlc.Queries, keyHash string) (models.APIPrincipal, error) {
+	apiKey, err := queries.GetAPIKeyByHash(ctx, keyHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.APIPrincipal{}, helpers.ErrInvalidAPIKey
+	}
+	if err != nil {
+		return models.APIPrincipal{}, err
+	}
+
+	return models.APIPrincipal{
+		KeyID:   apiKey.ID,
+		Name:    apiKey.Name,
+		Scopes:  apiKey.Scopes,
+		Sources: apiKey.Sources,
+	}, nil
+}
+
+// provisionAPIKey stores the hash of a new key together with its audit record
+func provisionAPIKey(ctx context.Context, starter txStarter, baseQueries *sqlc.Queries, actor, key string, request models.CreateAPIKeyRequest) (sqlc.ApiKey, error) {
+	if request.Sources == nil {
+		request.Sources = []string{}
+	}
+
+	var created sqlc.ApiKey
+	err := runInTxWith(ctx, starter, baseQueries, func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		created, err = queries.CreateAPIKey(ctx, sqlc.CreateAPIKeyParams{
+			Name:    request.Name,
+			KeyHash: helpers.HashAPIKey(key),
+			Scopes:  request.Scopes,
+			Sources: request.Sources,
+		})
+		if err != nil {
+			return err
+		}
+
+		return recordAdminAudit(ctx, queries, adminAuditEntry{
+			Actor:  actor,
+			Action: "create_api_key",
+			Params: map[string]interface{}{
+				"api_key_id": created.ID,
+				"name":       request.Name,
+				"scopes":     request.Scopes,
+				"sources":    request.Sources,
+			},
+		})
+	})
+	return created, err
+}
+
+// CreateAPIKeyHandler handles POST /admin/api-keys - provisions a key; the plain key is only returned here
+func CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	actor, err := adminActor(r)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	var request models.CreateAPIKeyRequest
+
+	// Validate and decode JSON request body using enhanced validation
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &request); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	key, err := helpers.NewAPIKey()
+	if err != nil {
+		helpers.RespondError(w, http.StatusInternalServerError, "Unable to generate API key")
+		return
+	}
+
+	created, err := provisionAPIKey(r.Context(), database.DBClient.Pool, database.DBClient.Queries, actor, key, request)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "API key")
+		return
+	}
+
+	responseData := map[string]interface{}{
+		"id":          created.ID,
+		"name":        created.Name,
+		"scopes":      created.Scopes,
+		"sources":     created.Sources,
+		"inserted_at": created.InsertedAt,
+		"api_key":     key,
+	}
+	helpers.RespondSuccess(w, "API key created successfully", responseData)
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// issueTokens signs an access token for the user and stores a new refresh token in the family
+func issueTokens(ctx context.Context, queries *sqlc.Queries, userID int64, familyID, secret string, now time.Time) (models.TokenPair, error) {
+	ttl := helpers.AccessTokenTTL()
+	expiresAt := now.Add(ttl)
+	accessToken, err := helpers.NewAccessToken(userID, secret, now, expiresAt)
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	refreshToken, err := helpers.NewRefreshToken()
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+	stored, err := queries.CreateRefreshToken(ctx, sqlc.CreateRefreshTokenParams{
+		TokenHash: helpers.HashRefreshToken(refreshToken),
+		UserID:    userID,
+		FamilyID:  familyID,
+		ExpiresAt: models.NewTimestamp(now.Add(helpers.RefreshTokenTTL())),
+	})
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return models.TokenPair{
+		AccessToken:           accessToken,
+		TokenType:             "Bearer",
+		ExpiresIn:             int64(ttl.Seconds()),
+		ExpiresAt:             models.NewTimestamp(expiresAt),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: stored.ExpiresAt,
+	}, nil
+}
+
+// login verifies the credentials and issues tokens in a new refresh token family. Unknown usernames
+// and users without a password fail exactly like a wrong password, so the response does not reveal
+// whether the username exists.
+func login(ctx context.Context, queries *sqlc.Queries, request models.LoginRequest, secret string, now time.Time) (models.TokenPair, error) {
+	user, err := queries.GetUserByUsername(ctx, request.Username)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return models.TokenPair{}, err
+	}
+
+	if err := helpers.CheckPassword(user.PasswordHash.String, request.Password); err != nil {
+		return models.TokenPair{}, err
+	}
+
+	return issueTokens(ctx, queries, user.ID, uuid.NewString(), secret, now)
+}
+
+// refreshTokens exchanges a refresh token for a new pair and rotates it, so it cannot be used again.
+// Presenting a token that was already rotated means it leaked or was replayed: the whole family,
+// including the token that replaced it, is revoked and the revocation is committed before failing.
+func refreshTokens(ctx context.Context, starter txStarter, baseQueries *sqlc.Queries, refreshToken, secret string, now time.Time) (models.TokenPair, error) {
+	var pair models.TokenPair
+	var reused *sqlc.RefreshToken
+
+	err := runInTxWith(ctx, starter, baseQueries, func(ctx context.Context, queries *sqlc.Queries) error {
+		stored, err := queries.GetRefreshTokenForUpdate(ctx, helpers.HashRefreshToken(refreshToken))
+		if errors.Is(err, pgx.ErrNoRows) {
+			return helpers.ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		if !stored.RevokedAt.IsZero() {
+			return helpers.ErrInvalidRefreshToken
+		}
+		if !stored.RotatedAt.IsZero() {
+			reused = &stored
+			return queries.RevokeRefreshTokenFamily(ctx, stored.FamilyID)
+		}
+		if !now.Before(stored.ExpiresAt.Time) {
+			return helpers.ErrInvalidRefreshToken
+		}
+
+		// Deleted users cannot keep their sessions alive
+		if _, err := queries.GetUser(ctx, stored.UserID); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return helpers.ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if err := queries.MarkRefreshTokenRotated(ctx, stored.TokenHash); err != nil {
+			return err
+		}
+		pair, err = issueTokens(ctx, queries, stored.UserID, stored.FamilyID, secret, now)
+		return err
+	})
+	if err != nil {
+		return models.TokenPair{}, err
+	}
+
+	if reused != nil {
+		log.Printf("Refresh token reuse detected for user %d, revoked token family %s", reused.UserID, reused.FamilyID)
+		return models.TokenPair{}, helpers.ErrRefreshTokenReused
+	}
+	return pair, nil
+}
+
+// LoginHandler handles POST /auth/login - exchanges {"username": "...", "password": "..."} for a
+// bearer JWT and a refresh token. Login is disabled unless JWT_SECRET is set.
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	secret := helpers.JWTSecret()
+	if secret == "" {
+		helpers.HandleAPIError(w, helpers.ErrLoginDisabled)
+		return
+	}
+
+	var request models.LoginRequest
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &request); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	tokens, err := login(r.Context(), database.DBClient.Queries, request, secret, time.Now())
+	if errors.Is(err, helpers.ErrInvalidCredentials) {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "User")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Login successful", tokens)
+}
+
+// RefreshHandler handles POST /auth/refresh - exchanges {"refresh_token": "..."} for a new access
+// and refresh token pair
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	secret := helpers.JWTSecret()
+	if secret == "" {
+		helpers.HandleAPIError(w, helpers.ErrLoginDisabled)
+		return
+	}
+
+	var request models.RefreshRequest
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &request); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	tokens, err := refreshTokens(r.Context(), database.DBClient.Pool, database.DBClient.Queries, request.RefreshToken, secret, time.Now())
+	if errors.Is(err, helpers.ErrInvalidRefreshToken) || errors.Is(err, helpers.ErrRefreshTokenReused) {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Refresh token")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Tokens refreshed successfully", tokens)
+}
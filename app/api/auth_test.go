@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func refreshTokenRow(token sqlc.RefreshToken) fakeRow {
+	return fakeRow{values: []interface{}{
+		token.TokenHash,
+		token.UserID,
+		token.FamilyID,
+		token.ExpiresAt,
+		token.RotatedAt,
+		token.RevokedAt,
+		token.InsertedAt,
+	}}
+}
+
+func TestLogin(t *testing.T) {
+	const secret = "test-secret"
+	now := time.Now().Truncate(time.Second)
+	hash, err := helpers.HashPassword("correct horse")
+	assert.NoError(t, err)
+	registered := userRow(sqlc.User{ID: 7, Username: "jdoe", PasswordHash: pgtype.Text{String: hash, Valid: true}})
+	refreshToken := refreshTokenRow(sqlc.RefreshToken{UserID: 7, ExpiresAt: models.NewTimestamp(now.Add(time.Hour))})
+
+	tests := []struct {
+		name        string
+		rows        map[string]fakeRow
+		password    string
+		expectedErr error
+	}{
+		{name: "Valid credentials", rows: map[string]fakeRow{"GetUserByUsername": registered, "CreateRefreshToken": refreshToken}, password: "correct horse"},
+		{name: "Wrong password", rows: map[string]fakeRow{"GetUserByUsername": registered}, password: "wrong horse", expectedErr: helpers.ErrInvalidCredentials},
+		{name: "Unknown username", password: "correct horse", expectedErr: helpers.ErrInvalidCredentials},
+		{
+			name:        "User without a password",
+			rows:        map[string]fakeRow{"GetUserByUsername": userRow(sqlc.User{ID: 8, Username: "legacy"})},
+			password:    "",
+			expectedErr: helpers.ErrInvalidCredentials,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ACCESS_TOKEN_TTL_SECONDS", "600")
+			db := &fakeDB{rows: tt.rows}
+
+			tokens, err := login(context.Background(), sqlc.New(db), models.LoginRequest{Username: "jdoe", Password: tt.password}, secret, now)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Empty(t, tokens.AccessToken)
+				assert.NotContains(t, db.queries, "CreateRefreshToken")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "Bearer", tokens.TokenType)
+			assert.Equal(t, int64(600), tokens.ExpiresIn)
+			assert.Equal(t, now.Add(10*time.Minute).UTC(), tokens.ExpiresAt.Time.UTC())
+
+			// The token authenticates the user with the auth middleware
+			userID, err := helpers.ParseAccessToken(tokens.AccessToken, secret)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), userID)
+
+			// Only the hash of the refresh token is stored
+			stored := db.args["CreateRefreshToken"]
+			assert.Equal(t, helpers.HashRefreshToken(tokens.RefreshToken), stored[0])
+			assert.Equal(t, int64(7), stored[1])
+		})
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	const secret = "test-secret"
+	now := time.Now().Truncate(time.Second)
+	at := func(offset time.Duration) models.Timestamp { return models.NewTimestamp(now.Add(offset)) }
+	current := sqlc.RefreshToken{TokenHash: helpers.HashRefreshToken("gbr_current"), UserID: 7, FamilyID: "family-1", ExpiresAt: at(time.Hour)}
+	rotated := current
+	rotated.RotatedAt = at(-time.Minute)
+	revoked := current
+	revoked.RevokedAt = at(-time.Minute)
+	expired := current
+	expired.ExpiresAt = at(-time.Second)
+
+	tests := []struct {
+		name          string
+		rows          map[string]fakeRow
+		expectedErr   error
+		expectQueries []string
+	}{
+		{
+			name: "Token is rotated into a new pair of the same family",
+			rows: map[string]fakeRow{
+				"GetRefreshTokenForUpdate": refreshTokenRow(current),
+				"GetUser":                  userRow(sqlc.User{ID: 7}),
+				"CreateRefreshToken":       refreshTokenRow(sqlc.RefreshToken{UserID: 7, FamilyID: "family-1", ExpiresAt: at(time.Hour)}),
+			},
+			expectQueries: []string{"SET", "GetRefreshTokenForUpdate", "GetUser", "MarkRefreshTokenRotated", "CreateRefreshToken"},
+		},
+		{
+			name:          "Reused token revokes the whole family",
+			rows:          map[string]fakeRow{"GetRefreshTokenForUpdate": refreshTokenRow(rotated)},
+			expectedErr:   helpers.ErrRefreshTokenReused,
+			expectQueries: []string{"SET", "GetRefreshTokenForUpdate", "RevokeRefreshTokenFamily"},
+		},
+		{
+			name:          "Revoked token",
+			rows:          map[string]fakeRow{"GetRefreshTokenForUpdate": refreshTokenRow(revoked)},
+			expectedErr:   helpers.ErrInvalidRefreshToken,
+			expectQueries: []string{"SET", "GetRefreshTokenForUpdate"},
+		},
+		{
+			name:          "Expired token",
+			rows:          map[string]fakeRow{"GetRefreshTokenForUpdate": refreshTokenRow(expired)},
+			expectedErr:   helpers.ErrInvalidRefreshToken,
+			expectQueries: []string{"SET", "GetRefreshTokenForUpdate"},
+		},
+		{
+			name:          "Unknown token",
+			expectedErr:   helpers.ErrInvalidRefreshToken,
+			expectQueries: []string{"SET", "GetRefreshTokenForUpdate"},
+		},
+		{
+			name:          "Deleted user",
+			rows:          map[string]fakeRow{"GetRefreshTokenForUpdate": refreshTokenRow(current)},
+			expectedErr:   helpers.ErrInvalidRefreshToken,
+			expectQueries: []string{"SET", "GetRefreshTokenForUpdate", "GetUser"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{rows: tt.rows}
+			starter := &fakeStarter{db: db}
+
+			tokens, err := refreshTokens(context.Background(), starter, sqlc.New(db), "gbr_current", secret, now)
+
+			assert.Equal(t, tt.expectQueries, db.queries)
+			assert.Len(t, starter.txs, 1)
+			if tt.expectedErr == helpers.ErrRefreshTokenReused {
+				// The revocation is committed even though the request fails
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.True(t, starter.txs[0].committed)
+				assert.Equal(t, []interface{}{"family-1"}, db.args["RevokeRefreshTokenFamily"])
+				return
+			}
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.True(t, starter.txs[0].rolledBack)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, starter.txs[0].committed)
+			assert.NotEqual(t, "gbr_current", tokens.RefreshToken)
+			assert.Equal(t, []interface{}{current.TokenHash}, db.args["MarkRefreshTokenRotated"])
+			assert.Equal(t, "family-1", db.args["CreateRefreshToken"][2])
+
+			userID, err := helpers.ParseAccessToken(tokens.AccessToken, secret)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(7), userID)
+		})
+	}
+}
+
+func TestCreateUserHashesPassword(t *testing.T) {
+	db := &fakeDB{rows: map[string]fakeRow{"CreateUser": userRow(sqlc.User{ID: 4, Username: "newuser"})}}
+	user := models.User{
+		Username:    "newuser",
+		FullName:    "New User",
+		Email:       "newuser@example.com",
+		DateOfBirth: "1990-05-17",
+		Country:     "DE",
+		Password:    "correct horse",
+	}
+
+	_, err := createUserWithoutAccount(context.Background(), sqlc.New(db), user)
+	assert.NoError(t, err)
+
+	args := db.args["CreateUser"]
+	stored, ok := args[len(args)-1].(pgtype.Text)
+	assert.True(t, ok)
+	assert.True(t, stored.Valid)
+	assert.NotEqual(t, "correct horse", stored.String)
+	assert.NoError(t, helpers.CheckPassword(stored.String, "correct horse"))
+
+	// Neither the password nor its hash is ever serialized
+	body, err := json.Marshal(sqlc.User{ID: 4, PasswordHash: stored})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(body), stored.String)
+	assert.NotContains(t, string(body), "password")
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// nonZeroBalanceError reports every account that blocked a close-all request
+type nonZeroBalanceError struct {
+	accounts []models.NonZeroBalance
+}
+
+func (e *nonZeroBalanceError) Error() string {
+	return helpers.ErrBalanceNotZero.Error()
+}
+
+func (e *nonZeroBalanceError) Unwrap() error {
+	return helpers.ErrBalanceNotZero
+}
+
+// checkAccountClosable allows closing an account only once its balance is zero
+func checkAccountClosable(account sqlc.Account) error {
+	if account.BalanceMinor != 0 {
+		return helpers.ErrBalanceNotZero
+	}
+	return nil
+}
+
+// closeAccountInTx closes a single locked account; closing an already closed account is a no-op
+func closeAccountInTx(ctx context.Context, queries *sqlc.Queries, account sqlc.Account) (sqlc.Account, error) {
+	if account.Status == helpers.AccountStatusClosed {
+		return account, nil
+	}
+
+	if err := checkAccountClosable(account); err != nil {
+		return sqlc.Account{}, err
+	}
+
+	return queries.CloseAccount(ctx, account.ID)
+}
+
+// closeAllAccounts closes every account of a user in one transaction. Every account is checked
+// before any is closed, so a single nonzero balance leaves all of them untouched.
+func closeAllAccounts(ctx context.Context, starter txStarter, queries *sqlc.Queries, userID int64) ([]sqlc.Account, error) {
+	var closed []sqlc.Account
+
+	err := runInTxWith(ctx, starter, queries, func(ctx context.Context, queries *sqlc.Queries) error {
+		accounts, err := queries.ListAccountsByUserForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(accounts) == 0 {
+			return helpers.ErrAccountNotFound
+		}
+
+		conflict := &nonZeroBalanceError{}
+		for _, account := range accounts {
+			if account.Status == helpers.AccountStatusClosed {
+				continue
+			}
+			if errors.Is(checkAccountClosable(account), helpers.ErrBalanceNotZero) {
+				conflict.accounts = append(conflict.accounts, models.NonZeroBalance{
+					AccountID: account.ID,
+					Currency:  account.Currency,
+					Balance:   helpers.FormatMoney(account.BalanceMinor, account.Currency),
+				})
+			}
+		}
+		if len(conflict.accounts) > 0 {
+			return conflict
+		}
+
+		closed = make([]sqlc.Account, 0, len(accounts))
+		for _, account := range accounts {
+			updated, err := closeAccountInTx(ctx, queries, account)
+			if err != nil {
+				return err
+			}
+			closed = append(closed, updated)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return closed, nil
+}
+
+// CloseAllAccountsHandler handles POST /user/{userId}/close-all - closes every account of a user
+// if all of them have a zero balance
+func CloseAllAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := helpers.ValidateID(mux.Vars(r)["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	accounts, err := closeAllAccounts(r.Context(), database.DBClient.Pool, database.DBClient.Queries, userID)
+
+	var conflict *nonZeroBalanceError
+	switch {
+	case errors.As(err, &conflict):
+		helpers.RespondJSON(w, http.StatusConflict, models.CloseAccountsConflict{
+			Error:    "Every account must have a zero balance to be closed",
+			Accounts: conflict.accounts,
+		})
+		return
+	case errors.Is(err, helpers.ErrAccountNotFound):
+		helpers.HandleAPIError(w, err)
+		return
+	case err != nil:
+		helpers.HandleDatabaseError(w, err, "Account")
+		return
+	}
+
+	response := models.CloseAccountsResponse{UserID: userID, Accounts: []models.ClosedAccount{}}
+	for _, account := range accounts {
+		response.Accounts = append(response.Accounts, models.ClosedAccount{
+			AccountID: account.ID,
+			Currency:  account.Currency,
+			Status:    account.Status,
+		})
+	}
+
+	helpers.RespondSuccess(w, "Accounts closed successfully", response)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseAllAccounts(t *testing.T) {
+	zero := sqlc.Account{ID: 1, UserID: 7, Currency: "EUR", Status: "active", AccountType: "general"}
+	funded := sqlc.Account{ID: 2, UserID: 7, BalanceMinor: 1250, Currency: "USD", Status: "active", AccountType: "savings"}
+	closed := sqlc.Account{ID: 3, UserID: 7, Currency: "GBP", Status: "closed", AccountType: "general"}
+
+	tests := []struct {
+		name             string
+		accounts         []sqlc.Account
+		expectedErr      error
+		expectedConflict []models.NonZeroBalance
+		expectedClosed   []int64
+	}{
+		{
+			name:        "One nonzero balance closes nothing",
+			accounts:    []sqlc.Account{zero, funded},
+			expectedErr: helpers.ErrBalanceNotZero,
+			expectedConflict: []models.NonZeroBalance{
+				{AccountID: 2, Currency: "USD", Balance: "12.50"},
+			},
+		},
+		{
+			name:           "All zero balances are closed",
+			accounts:       []sqlc.Account{zero, closed},
+			expectedClosed: []int64{1},
+		},
+		{
+			name:        "User without accounts",
+			accounts:    []sqlc.Account{},
+			expectedErr: helpers.ErrAccountNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := []fakeRow{}
+			for _, account := range tt.accounts {
+				rows = append(rows, accountRow(account))
+			}
+			var closedIDs []int64
+			db := &fakeDB{
+				results: map[string][]fakeRow{"ListAccountsByUserForUpdate": rows},
+				rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+					if name != "CloseAccount" {
+						return fakeRow{}, false
+					}
+					closedIDs = append(closedIDs, args[0].(int64))
+					account := zero
+					account.Status = helpers.AccountStatusClosed
+					return accountRow(account), true
+				},
+			}
+			starter := &fakeStarter{db: db}
+
+			accounts, err := closeAllAccounts(context.Background(), starter, sqlc.New(db), 7)
+
+			assert.Equal(t, tt.expectedClosed, closedIDs)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.True(t, starter.txs[0].rolledBack)
+				assert.False(t, starter.txs[0].committed)
+
+				var conflict *nonZeroBalanceError
+				if tt.expectedConflict != nil {
+					assert.True(t, errors.As(err, &conflict))
+					assert.Equal(t, tt.expectedConflict, conflict.accounts)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, starter.txs[0].committed)
+			assert.Len(t, accounts, len(tt.accounts))
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+const confirmationRequiredStatus = "confirmation_required"
+
+// requiresStepUp reports whether an amount in minor units of the currency exceeds the configured step-up
+// threshold
+func requiresStepUp(amount int64, currency string) bool {
+	threshold := helpers.StepUpThreshold()
+	return threshold > 0 && amount > helpers.ToMinorUnits(threshold, currency)
+}
+
+func newConfirmationToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// createConfirmation parks a parsed transaction until the client confirms it. The token is returned to be
+// shown to the client once; only its hash is stored.
+func createConfirmation(ctx context.Context, queries *sqlc.Queries, userID int64, transaction models.Transaction, now time.Time) (string, sqlc.TransactionConfirmation, error) {
+	token, err := newConfirmationToken()
+	if err != nil {
+		return "", sqlc.TransactionConfirmation{}, err
+	}
+
+	confirmation, err := queries.CreateTransactionConfirmation(ctx, sqlc.CreateTransactionConfirmationParams{
+		TokenHash:       helpers.HashConfirmationToken(token),
+		UserID:          userID,
+		AccountID:       transaction.AccountID,
+		TransactionID:   transaction.ID,
+		AmountMinor:     transaction.MinorAmount,
+		Source:          transaction.Source,
+		Type:            transaction.TransactionType,
+		Memo:            pgtype.Text{String: transaction.Memo, Valid: transaction.Memo != ""},
+		ClientReference: pgtype.Text{String: transaction.ClientReference, Valid: transaction.ClientReference != ""},
+		ExpiresAt:       models.NewTimestamp(now.Add(helpers.StepUpTokenTTL())),
+	})
+	if err != nil {
+		return "", sqlc.TransactionConfirmation{}, err
+	}
+	return token, confirmation, nil
+}
+
+// confirmationAccount returns the account a confirmation token was issued for, which must belong to
+// the user. Its transaction slot is taken before the token is consumed, so the token is read without a
+// lock here and checked again by consumeConfirmation.
+func confirmationAccount(ctx context.Context, queries *sqlc.Queries, token string, userID int64) (sqlc.Account, error) {
+	confirmation, err := queries.GetTransactionConfirmation(ctx, helpers.HashConfirmationToken(token))
+	// Tokens of other users are reported as missing so they cannot be probed
+	if errors.Is(err, pgx.ErrNoRows) || err == nil && confirmation.UserID != userID {
+		return sqlc.Account{}, helpers.ErrConfirmationNotFound
+	}
+	if err != nil {
+		return sqlc.Account{}, err
+	}
+
+	return findUserAccount(ctx, queries, userID, confirmation.AccountID)
+}
+
+// consumeConfirmation locks the token, checks it is the user's, unused and unexpired, and marks it used.
+// Run it in the transaction that executes the confirmed transaction so a failure keeps the token usable.
+func consumeConfirmation(ctx context.Context, queries *sqlc.Queries, token string, userID int64, now time.Time) (models.Transaction, error) {
+	tokenHash := helpers.HashConfirmationToken(token)
+	confirmation, err := queries.GetTransactionConfirmationForUpdate(ctx, tokenHash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Transaction{}, helpers.ErrConfirmationNotFound
+	}
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	// Tokens of other users are reported as missing so they cannot be probed
+	if confirmation.UserID != userID {
+		return models.Transaction{}, helpers.ErrConfirmationNotFound
+	}
+	if !confirmation.UsedAt.IsZero() {
+		return models.Transaction{}, helpers.ErrConfirmationUsed
+	}
+	if !now.Before(confirmation.ExpiresAt.Time) {
+		return models.Transaction{}, helpers.ErrConfirmationExpired
+	}
+
+	if err := queries.MarkTransactionConfirmationUsed(ctx, tokenHash); err != nil {
+		return models.Transaction{}, err
+	}
+
+	return models.Transaction{
+		ID:              confirmation.TransactionID,
+		AccountID:       confirmation.AccountID,
+		MinorAmount:     confirmation.AmountMinor,
+		Source:          confirmation.Source,
+		TransactionType: confirmation.Type,
+		Memo:            confirmation.Memo.String,
+		ClientReference: confirmation.ClientReference.String,
+	}, nil
+}
+
+// confirmationError returns the confirmation or permission error that rejected a request, if any
+func confirmationError(err error) error {
+	for _, confirmationErr := range []error{
+		helpers.ErrConfirmationNotFound,
+		helpers.ErrConfirmationExpired,
+		helpers.ErrConfirmationUsed,
+		helpers.ErrInsufficientScope,
+		helpers.ErrSourceNotPermitted,
+		helpers.ErrOperationNotPermitted,
+	} {
+		if errors.Is(err, confirmationErr) {
+			return confirmationErr
+		}
+	}
+	return nil
+}
+
+// ConfirmTransactionHandler handles POST /user/{userId}/transaction/confirm - executes a transaction held for step-up
+func ConfirmTransactionHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userID, err := helpers.ValidateID(vars["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	var request models.TransactionConfirmRequest
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &request); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	// The transaction runs on the account the token was issued for, which need not be the user's first
+	account, err := confirmationAccount(r.Context(), database.DBClient.Queries, request.ConfirmationToken, userID)
+	if errors.Is(err, helpers.ErrConfirmationNotFound) {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+	if err != nil {
+		handleAccountError(w, err)
+		return
+	}
+
+	release, err := accountSlots.Acquire(r.Context(), account.ID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transaction")
+		return
+	}
+	defer release()
+
+	var transaction models.Transaction
+	err = runInTx(r.Context(), database.DBClient, func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		transaction, err = consumeConfirmation(ctx, queries, request.ConfirmationToken, userID, time.Now())
+		if err != nil {
+			return err
+		}
+		if err := authorizePrincipal(r, helpers.OperationCreateTransaction, transaction.Source, transaction.TransactionType); err != nil {
+			return err
+		}
+		transaction, err = applyTransactionInTx(ctx, queries, transaction)
+		return err
+	})
+
+	if confirmationErr := confirmationError(err); confirmationErr != nil {
+		helpers.HandleAPIError(w, confirmationErr)
+		return
+	}
+
+	respondTransactionResult(w, r, userID, account, transaction, err)
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func confirmationRow(confirmation sqlc.TransactionConfirmation) fakeRow {
+	return fakeRow{values: []interface{}{
+		confirmation.TokenHash,
+		confirmation.UserID,
+		confirmation.AccountID,
+		confirmation.TransactionID,
+		confirmation.Source,
+		confirmation.Type,
+		confirmation.Memo,
+		confirmation.ClientReference,
+		confirmation.ExpiresAt,
+		confirmation.UsedAt,
+		confirmation.InsertedAt,
+		confirmation.AmountMinor,
+	}}
+}
+
+func TestRequiresStepUp(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold string
+		amount    int64
+		expected  bool
+	}{
+		{name: "Disabled when unset", threshold: "", amount: 100000000, expected: false},
+		{name: "Disabled when zero", threshold: "0", amount: 100000000, expected: false},
+		{name: "Below threshold", threshold: "500", amount: 49999, expected: false},
+		{name: "At threshold", threshold: "500", amount: 50000, expected: false},
+		{name: "Above threshold", threshold: "500", amount: 50001, expected: true},
+		{name: "Invalid threshold disables", threshold: "lots", amount: 100000000, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STEP_UP_THRESHOLD", tt.threshold)
+			assert.Equal(t, tt.expected, requiresStepUp(tt.amount, "EUR"))
+		})
+	}
+}
+
+func TestCreateConfirmation(t *testing.T) {
+	t.Setenv("STEP_UP_TOKEN_TTL_SECONDS", "60")
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	db := &fakeDB{
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			if name != "CreateTransactionConfirmation" {
+				return fakeRow{}, false
+			}
+			return confirmationRow(sqlc.TransactionConfirmation{
+				TokenHash: args[0].(string),
+				ExpiresAt: args[9].(models.Timestamp),
+			}), true
+		},
+	}
+
+	transaction := models.Transaction{
+		ID:              "tx-large",
+		AccountID:       1,
+		MinorAmount:     500000,
+		Source:          "payment",
+		TransactionType: "withdrawal",
+	}
+
+	firstToken, first, err := createConfirmation(context.Background(), sqlc.New(db), 7, transaction, now)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, firstToken)
+	assert.Equal(t, now.Add(time.Minute), first.ExpiresAt.Time)
+
+	// Only the hash of the token is stored
+	args := db.args["CreateTransactionConfirmation"]
+	assert.Equal(t, helpers.HashConfirmationToken(firstToken), args[0])
+	assert.NotEqual(t, firstToken, args[0])
+	assert.Equal(t, int64(7), args[1])
+	assert.Equal(t, "tx-large", args[3])
+	assert.Equal(t, int64(500000), args[4])
+
+	secondToken, _, err := createConfirmation(context.Background(), sqlc.New(db), 7, transaction, now)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstToken, secondToken)
+}
+
+func TestConfirmationAccount(t *testing.T) {
+	// User 7 has accounts 5 and 6; the token was issued on the second
+	db := &fakeDB{
+		rows: map[string]fakeRow{
+			"GetTransactionConfirmation": confirmationRow(sqlc.TransactionConfirmation{TokenHash: helpers.HashConfirmationToken("token-1"), UserID: 7, AccountID: 6}),
+			"GetAccountByUser":           accountRow(sqlc.Account{ID: 5, UserID: 7, Currency: "EUR"}),
+			"GetAccount":                 accountRow(sqlc.Account{ID: 6, UserID: 7, Currency: "USD"}),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		userID      int64
+		expectedErr error
+	}{
+		{name: "Token on the user's second account", userID: 7},
+		{name: "Another user's token", userID: 8, expectedErr: helpers.ErrConfirmationNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.queries = nil
+			account, err := confirmationAccount(context.Background(), sqlc.New(db), "token-1", tt.userID)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.NotContains(t, db.queries, "GetAccount")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int64(6), account.ID)
+			assert.Equal(t, "USD", account.Currency)
+			assert.Equal(t, []interface{}{helpers.HashConfirmationToken("token-1")}, db.args["GetTransactionConfirmation"])
+			assert.Equal(t, []interface{}{int64(6)}, db.args["GetAccount"])
+			assert.NotContains(t, db.queries, "GetAccountByUser")
+		})
+	}
+
+	// Unknown tokens are not found either
+	_, err := confirmationAccount(context.Background(), sqlc.New(&fakeDB{}), "token-2", 7)
+	assert.ErrorIs(t, err, helpers.ErrConfirmationNotFound)
+}
+
+func TestConsumeConfirmation(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	pending := sqlc.TransactionConfirmation{
+		TokenHash:     helpers.HashConfirmationToken("token-1"),
+		UserID:        7,
+		AccountID:     1,
+		TransactionID: "tx-large",
+		AmountMinor:   500000,
+		Source:        "payment",
+		Type:          "withdrawal",
+		ExpiresAt:     models.NewTimestamp(now.Add(time.Minute)),
+	}
+	used := pending
+	used.UsedAt = models.NewTimestamp(now.Add(-time.Second))
+	expired := pending
+	expired.ExpiresAt = models.NewTimestamp(now)
+
+	tests := []struct {
+		name         string
+		confirmation *sqlc.TransactionConfirmation
+		userID       int64
+		expectedErr  error
+	}{
+		{name: "Valid token", confirmation: &pending, userID: 7},
+		{name: "Unknown token", userID: 7, expectedErr: helpers.ErrConfirmationNotFound},
+		{name: "Another user's token", confirmation: &pending, userID: 8, expectedErr: helpers.ErrConfirmationNotFound},
+		{name: "Token already used", confirmation: &used, userID: 7, expectedErr: helpers.ErrConfirmationUsed},
+		{name: "Token expired", confirmation: &expired, userID: 7, expectedErr: helpers.ErrConfirmationExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{rows: map[string]fakeRow{}}
+			if tt.confirmation != nil {
+				db.rows["GetTransactionConfirmationForUpdate"] = confirmationRow(*tt.confirmation)
+			}
+
+			transaction, err := consumeConfirmation(context.Background(), sqlc.New(db), "token-1", tt.userID, now)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.NotContains(t, db.queries, "MarkTransactionConfirmationUsed")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, db.queries, "MarkTransactionConfirmationUsed")
+			assert.Equal(t, []interface{}{helpers.HashConfirmationToken("token-1")}, db.args["GetTransactionConfirmationForUpdate"])
+			assert.Equal(t, []interface{}{helpers.HashConfirmationToken("token-1")}, db.args["MarkTransactionConfirmationUsed"])
+			assert.Equal(t, "tx-large", transaction.ID)
+			assert.Equal(t, int64(1), transaction.AccountID)
+			assert.Equal(t, int64(500000), transaction.MinorAmount)
+			assert.Equal(t, "withdrawal", transaction.TransactionType)
+		})
+	}
+}
+
+func TestConfirmedTransactionFailureKeepsToken(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	db := &fakeDB{
+		rows: map[string]fakeRow{
+			"GetTransactionConfirmationForUpdate": confirmationRow(sqlc.TransactionConfirmation{
+				TokenHash:     helpers.HashConfirmationToken("token-1"),
+				UserID:        7,
+				AccountID:     1,
+				TransactionID: "tx-large",
+				AmountMinor:   500000,
+				Source:        "payment",
+				Type:          "withdrawal",
+				ExpiresAt:     models.NewTimestamp(now.Add(time.Minute)),
+			}),
+			"GetAccountForUpdate": accountRow(sqlc.Account{ID: 1, BalanceMinor: 10000, AccountType: "checking"}),
+		},
+	}
+	starter := &fakeStarter{db: db}
+
+	err := runInTxWith(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+		transaction, err := consumeConfirmation(ctx, queries, "token-1", 7, now)
+		if err != nil {
+			return err
+		}
+		_, err = applyTransactionInTx(ctx, queries, transaction)
+		return err
+	})
+
+	// The insufficient balance rolls back the used marker along with everything else
+	assert.ErrorIs(t, err, helpers.ErrInsufficientBalance)
+	assert.True(t, starter.txs[0].rolledBack)
+}
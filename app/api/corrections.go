@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// Source recorded on corrective transactions booked by admins
+const correctionSource = "server"
+
+// applyCorrection books a reversal or adjustment on the account and audits it in the same database
+// transaction. It returns the persisted transaction and the account it was booked on.
+func applyCorrection(ctx context.Context, queries *sqlc.Queries, actor string, accountID int64, request models.CorrectionRequest) (models.Transaction, sqlc.Account, error) {
+	account, err := queries.GetAccount(ctx, accountID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return models.Transaction{}, sqlc.Account{}, helpers.ErrAccountNotFound
+	}
+	if err != nil {
+		return models.Transaction{}, sqlc.Account{}, err
+	}
+
+	transaction, err := validateAndParseTransactionAmount(models.Transaction{
+		ID:              uuid.NewString(),
+		AccountID:       account.ID,
+		Amount:          request.Amount,
+		Source:          correctionSource,
+		TransactionType: request.Type,
+		Memo:            request.Memo,
+	}, account.Currency)
+	if err != nil {
+		return models.Transaction{}, sqlc.Account{}, err
+	}
+
+	transaction, err = applyTransactionInTx(ctx, queries, transaction)
+	if err != nil {
+		return models.Transaction{}, sqlc.Account{}, err
+	}
+
+	err = recordAdminAudit(ctx, queries, adminAuditEntry{
+		Actor:           actor,
+		Action:          "correction",
+		TargetUserID:    account.UserID,
+		TargetAccountID: account.ID,
+		Params: map[string]string{
+			"type":           request.Type,
+			"amount":         request.Amount,
+			"memo":           request.Memo,
+			"transaction_id": transaction.ID,
+		},
+	})
+	if err != nil {
+		return models.Transaction{}, sqlc.Account{}, err
+	}
+
+	return transaction, account, nil
+}
+
+// CreateCorrectionHandler handles POST /admin/accounts/{accountId}/corrections - books a reversal or
+// adjustment. These may drive the balance negative, so they are not accepted on the client routes.
+func CreateCorrectionHandler(w http.ResponseWriter, r *http.Request) {
+	actor, err := adminActor(r)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	accountID, err := helpers.ValidateID(mux.Vars(r)["accountId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	var request models.CorrectionRequest
+
+	// Validate and decode JSON request body using enhanced validation
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &request); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	var transaction models.Transaction
+	var account sqlc.Account
+	err = runInTx(r.Context(), database.DBClient, func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		transaction, account, err = applyCorrection(ctx, queries, actor, accountID, request)
+		return err
+	})
+	if message, isAmountErr := helpers.AmountErrorMessage(err); isAmountErr {
+		helpers.RespondValidationError(w, map[string]string{"amount": message})
+		return
+	}
+	if ruleErr := transactionRuleError(err); ruleErr != nil {
+		helpers.HandleAPIError(w, ruleErr)
+		return
+	}
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Correction")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Correction booked successfully", buildTransactionResponse(account.UserID, transaction, account.Currency))
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyCorrection(t *testing.T) {
+	account := sqlc.Account{ID: 10, UserID: 1, BalanceMinor: 500, Currency: "EUR", Status: "active", AccountType: "checking"}
+
+	tests := []struct {
+		name            string
+		accountID       int64
+		request         models.CorrectionRequest
+		expectedBalance int64
+		expectedErr     error
+	}{
+		{
+			name:            "Reversal of a spent deposit goes negative",
+			accountID:       10,
+			request:         models.CorrectionRequest{Type: "reversal", Amount: "20.00", Memo: "chargeback"},
+			expectedBalance: -1500,
+		},
+		{
+			name:            "Adjustment",
+			accountID:       10,
+			request:         models.CorrectionRequest{Type: "adjustment", Amount: "2.50"},
+			expectedBalance: 250,
+		},
+		{
+			name:        "Unknown account",
+			accountID:   11,
+			request:     models.CorrectionRequest{Type: "reversal", Amount: "1.00"},
+			expectedErr: helpers.ErrAccountNotFound,
+		},
+		{
+			name:        "Invalid amount",
+			accountID:   10,
+			request:     models.CorrectionRequest{Type: "reversal", Amount: "ten"},
+			expectedErr: helpers.ErrInvalidAmount,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var balance int64
+			var auditArgs []interface{}
+			db := &fakeDB{
+				rows: map[string]fakeRow{
+					"GetAccountForUpdate": accountRow(account),
+					"CreateTransaction":   transactionRow(sqlc.Transaction{ID: "tx", AccountID: account.ID, Type: tt.request.Type, Source: correctionSource}),
+				},
+				rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+					switch name {
+					case "GetAccount":
+						if args[0].(int64) != account.ID {
+							return fakeRow{err: pgx.ErrNoRows}, true
+						}
+						return accountRow(account), true
+					case "UpdateAccount":
+						balance = args[2].(int64)
+						updated := account
+						updated.BalanceMinor = balance
+						return accountRow(updated), true
+					case "CreateAdminAudit":
+						auditArgs = args
+						return adminAuditRow(sqlc.AdminAudit{ID: 1}), true
+					}
+					return fakeRow{}, false
+				},
+			}
+			starter := &fakeStarter{db: db}
+
+			var transaction models.Transaction
+			var booked sqlc.Account
+			err := runInTxWith(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+				var err error
+				transaction, booked, err = applyCorrection(ctx, queries, "alice", tt.accountID, tt.request)
+				return err
+			})
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.True(t, starter.txs[0].rolledBack)
+				assert.NotContains(t, db.queries, "CreateAdminAudit")
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, starter.txs[0].committed)
+			assert.Equal(t, tt.expectedBalance, balance)
+			assert.Equal(t, account.ID, booked.ID)
+			assert.Equal(t, tt.request.Type, transaction.TransactionType)
+
+			assert.Equal(t, "alice", auditArgs[0])
+			assert.Equal(t, "correction", auditArgs[1])
+			var params map[string]string
+			assert.NoError(t, json.Unmarshal(auditArgs[4].([]byte), &params))
+			assert.Equal(t, tt.request.Type, params["type"])
+			assert.Equal(t, tt.request.Amount, params["amount"])
+		})
+	}
+}
+
+func TestCreateCorrectionHandlerValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		body           string
+		anonymous      bool
+		expectedStatus int
+	}{
+		{
+			name:           "Without an authenticated admin",
+			url:            "/admin/accounts/10/corrections",
+			body:           `{"type":"reversal","amount":"1.00"}`,
+			anonymous:      true,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Invalid account ID",
+			url:            "/admin/accounts/abc/corrections",
+			body:           `{"type":"reversal","amount":"1.00"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Ordinary transaction type",
+			url:            "/admin/accounts/10/corrections",
+			body:           `{"type":"win","amount":"1.00"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Missing amount",
+			url:            "/admin/accounts/10/corrections",
+			body:           `{"type":"adjustment"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.HandleFunc("/admin/accounts/{accountId}/corrections", CreateCorrectionHandler).Methods("POST")
+
+			req := httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if !tt.anonymous {
+				req = req.WithContext(helpers.WithAdmin(req.Context(), "alice"))
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// accountExport is the document produced by the export endpoint
+type accountExport struct {
+	ExportedAt       models.Timestamp   `json:"exported_at"`
+	Since            *models.Timestamp  `json:"since,omitempty"`
+	Account          sqlc.Account       `json:"account"`
+	Transactions     []sqlc.Transaction `json:"transactions"`
+	TransactionCount int                `json:"transaction_count"`
+}
+
+// exportPageSize is how many transactions the export reads per query
+const exportPageSize = 500
+
+// writeAccountExport streams the export document. Transactions are read in keyset pages from since
+// on, and each page is flushed before the next one is read, so the history is never held in memory.
+func writeAccountExport(ctx context.Context, w io.Writer, queries *sqlc.Queries, pageSize int32, exportedAt models.Timestamp, since *models.Timestamp, account sqlc.Account) error {
+	header := struct {
+		ExportedAt models.Timestamp  `json:"exported_at"`
+		Since      *models.Timestamp `json:"since,omitempty"`
+		Account    sqlc.Account      `json:"account"`
+	}{exportedAt, since, account}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// Reopen the header object so the transactions can be appended to it
+	if _, err := w.Write(headerJSON[:len(headerJSON)-1]); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, `,"transactions":[`); err != nil {
+		return err
+	}
+
+	// Every transaction ID is non-empty, so the first page starts with the transactions at since itself.
+	// Without since the cursor time is NULL, which the query reads as the start of time.
+	params := sqlc.ListTransactionsByAccountAfterParams{AccountID: account.ID, PageSize: pageSize}
+	if since != nil {
+		params.AfterInsertedAt = *since
+	}
+
+	count := 0
+	for {
+		transactions, err := queries.ListTransactionsByAccountAfter(ctx, params)
+		if err != nil {
+			return err
+		}
+
+		for _, transaction := range transactions {
+			if count > 0 {
+				if _, err := io.WriteString(w, ","); err != nil {
+					return err
+				}
+			}
+
+			transactionJSON, err := json.Marshal(transaction)
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(transactionJSON); err != nil {
+				return err
+			}
+			count++
+		}
+
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+
+		if len(transactions) < int(pageSize) {
+			break
+		}
+		last := transactions[len(transactions)-1]
+		params.AfterInsertedAt = last.InsertedAt
+		params.AfterID = last.ID
+	}
+
+	_, err = io.WriteString(w, `],"transaction_count":`+strconv.Itoa(count)+"}\n")
+	return err
+}
+
+// ExportAccountHandler handles GET /user/{userId}/account/{accountId}/export - exports an account snapshot
+func ExportAccountHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	// Validate user and account IDs using helper function
+	userID, err := helpers.ValidateID(vars["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	accountID, err := helpers.ValidateID(vars["accountId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	// Optional lower bound for incremental exports
+	sinceParam := r.URL.Query().Get("since")
+	since, err := helpers.ParseTimestamp(sinceParam)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	account, err := GetUserAccount(r.Context(), userID, accountID)
+	if err == helpers.ErrAccountNotFound {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Account")
+		return
+	}
+
+	var sincePtr *models.Timestamp
+	if sinceParam != "" {
+		sinceTimestamp := models.NewTimestamp(since)
+		sincePtr = &sinceTimestamp
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	err = writeAccountExport(r.Context(), w, database.DBClient.Queries, exportPageSize, models.NewTimestamp(time.Now()), sincePtr, account)
+	if err != nil {
+		// The status is already sent, so abort the connection rather than end a truncated document cleanly
+		log.Printf("Export of account %d failed: %v", account.ID, err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportAccountHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{
+			name:           "Invalid user ID format",
+			url:            "/user/invalid/account/1/export",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid account ID format",
+			url:            "/user/1/account/invalid/export",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid since timestamp",
+			url:            "/user/1/account/1/export?since=yesterday",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.HandleFunc("/user/{userId}/account/{accountId}/export", ExportAccountHandler).Methods("GET")
+
+			req, err := http.NewRequest("GET", tt.url, nil)
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+
+			var response map[string]interface{}
+			err = json.Unmarshal(recorder.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Contains(t, response, "error")
+		})
+	}
+}
+
+func TestWriteAccountExportRoundTrip(t *testing.T) {
+	insertedAt := models.NewTimestamp(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	account := sqlc.Account{ID: 1, UserID: 1, Balance: helpers.NumericFromMinorUnits(4250, "EUR"), BalanceMinor: 4250, Currency: "EUR", Status: "active", InsertedAt: insertedAt, Metadata: json.RawMessage(`{"tier":2}`)}
+	transactions := []sqlc.Transaction{
+		{ID: "tx-1", AccountID: 1, Amount: helpers.NumericFromMinorUnits(5000, "EUR"), AmountMinor: 5000, Source: "game", Type: "win", InsertedAt: insertedAt},
+		{ID: "tx-2", AccountID: 1, Amount: helpers.NumericFromMinorUnits(750, "EUR"), AmountMinor: 750, Source: "payment", Type: "lose", InsertedAt: insertedAt},
+		{ID: "tx-3", AccountID: 1, Amount: helpers.NumericFromMinorUnits(1250, "EUR"), AmountMinor: 1250, Source: "game", Type: "win", InsertedAt: insertedAt},
+	}
+	exportedAt := models.NewTimestamp(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC))
+	since := models.NewTimestamp(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	// Each page holds the transactions after the cursor ID, as the keyset query would return them
+	var cursors []interface{}
+	db := &fakeDB{resultsFunc: func(name string, args []interface{}) ([]fakeRow, bool) {
+		cursors = append(cursors, args[2])
+		page := []fakeRow{}
+		for _, transaction := range transactions {
+			if transaction.ID > args[2].(string) && len(page) < int(args[3].(int32)) {
+				page = append(page, transactionRow(transaction))
+			}
+		}
+		return page, true
+	}}
+
+	recorder := httptest.NewRecorder()
+	err := writeAccountExport(context.Background(), recorder, sqlc.New(db), 2, exportedAt, &since, account)
+	assert.NoError(t, err)
+
+	// A full page asks for the next one after its last transaction, a short page ends the export
+	assert.Equal(t, []interface{}{"", "tx-2"}, cursors)
+	assert.Equal(t, insertedAt, db.args["ListTransactionsByAccountAfter"][1])
+	assert.True(t, recorder.Flushed)
+
+	var export accountExport
+	err = json.Unmarshal(recorder.Body.Bytes(), &export)
+	assert.NoError(t, err)
+
+	assert.Equal(t, exportedAt, export.ExportedAt)
+	assert.Equal(t, since, *export.Since)
+	assert.Equal(t, account, export.Account)
+	assert.Equal(t, transactions, export.Transactions)
+	assert.Equal(t, 3, export.TransactionCount)
+}
+
+func TestWriteAccountExportWithoutTransactions(t *testing.T) {
+	db := &fakeDB{results: map[string][]fakeRow{"ListTransactionsByAccountAfter": {}}}
+
+	var buffer bytes.Buffer
+	err := writeAccountExport(context.Background(), &buffer, sqlc.New(db), exportPageSize, models.NewTimestamp(time.Now()), nil, sqlc.Account{ID: 1})
+	assert.NoError(t, err)
+
+	// Without since the export starts at the first transaction
+	assert.Equal(t, models.Timestamp{}, db.args["ListTransactionsByAccountAfter"][1])
+
+	var export accountExport
+	err = json.Unmarshal(buffer.Bytes(), &export)
+	assert.NoError(t, err)
+	assert.Nil(t, export.Since)
+	assert.Empty(t, export.Transactions)
+	assert.Equal(t, 0, export.TransactionCount)
+}
+
+// failingWriter accepts limit bytes and then fails, like a client that went away
+type failingWriter struct {
+	limit int
+}
+
+func (w *failingWriter) Write(body []byte) (int, error) {
+	if len(body) > w.limit {
+		return 0, errors.New("connection reset by peer")
+	}
+	w.limit -= len(body)
+	return len(body), nil
+}
+
+func TestWriteAccountExportErrors(t *testing.T) {
+	transactions := map[string][]fakeRow{"ListTransactionsByAccountAfter": {
+		transactionRow(sqlc.Transaction{ID: "tx-1", AccountID: 1, AmountMinor: 5000, Source: "game", Type: "win"}),
+	}}
+
+	t.Run("Write error stops the export", func(t *testing.T) {
+		db := &fakeDB{results: transactions}
+		err := writeAccountExport(context.Background(), &failingWriter{limit: 200}, sqlc.New(db), exportPageSize, models.NewTimestamp(time.Now()), nil, sqlc.Account{ID: 1})
+		assert.EqualError(t, err, "connection reset by peer")
+	})
+
+	t.Run("Query error stops the export", func(t *testing.T) {
+		db := &fakeDB{}
+		var buffer bytes.Buffer
+		err := writeAccountExport(context.Background(), &buffer, sqlc.New(db), exportPageSize, models.NewTimestamp(time.Now()), nil, sqlc.Account{ID: 1})
+		assert.Error(t, err)
+		assert.NotContains(t, buffer.String(), "transaction_count")
+	})
+}
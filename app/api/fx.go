@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// convertAmount converts an amount in minor units of from with the shared converter, returning the result
+// in minor units of to and the rate used
+func convertAmount(amount int64, from, to string) (int64, float64, error) {
+	rate, err := converter.Rate(from, to)
+	if err != nil {
+		return 0, 0, err
+	}
+	converted, err := helpers.ConvertMinorUnits(amount, from, to, rate)
+	if err != nil {
+		return 0, 0, err
+	}
+	return converted, rate, nil
+}
+
+// FXRateHandler handles GET /fx/rate?from=USD&to=EUR&amount=100 - previews a conversion without side effects
+func FXRateHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from := strings.ToUpper(query.Get("from"))
+	to := strings.ToUpper(query.Get("to"))
+
+	// Default to the rate for a single unit when no amount is given
+	amountStr := query.Get("amount")
+	if amountStr == "" {
+		amountStr = "1"
+	}
+	amount, err := helpers.ParseAmount(amountStr, from)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	converted, rate, err := convertAmount(amount, from, to)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	responseData := models.FXRate{
+		From:      from,
+		To:        to,
+		Rate:      rate,
+		Amount:    helpers.FormatMoney(amount, from),
+		Converted: helpers.FormatMoney(converted, to),
+	}
+	helpers.RespondSuccess(w, "Exchange rate retrieved successfully", responseData)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFXRateHandler(t *testing.T) {
+	tests := []struct {
+		name              string
+		url               string
+		expectedStatus    int
+		expectedRate      float64
+		expectedConverted string
+	}{
+		{name: "USD to EUR", url: "/fx/rate?from=USD&to=EUR&amount=100", expectedStatus: http.StatusOK, expectedRate: 1 / 1.08, expectedConverted: "92.59"},
+		{name: "Lower case currencies", url: "/fx/rate?from=eur&to=gbp&amount=10", expectedStatus: http.StatusOK, expectedRate: 0.85, expectedConverted: "8.50"},
+		{name: "Amount defaults to one unit", url: "/fx/rate?from=EUR&to=USD", expectedStatus: http.StatusOK, expectedRate: 1.08, expectedConverted: "1.08"},
+		{name: "Unsupported pair", url: "/fx/rate?from=USD&to=JPY&amount=100", expectedStatus: http.StatusBadRequest},
+		{name: "Missing currencies", url: "/fx/rate?amount=100", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid amount", url: "/fx/rate?from=USD&to=EUR&amount=abc", expectedStatus: http.StatusBadRequest},
+		{name: "Negative amount", url: "/fx/rate?from=USD&to=EUR&amount=-5", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.HandleFunc("/fx/rate", FXRateHandler).Methods("GET")
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest("GET", tt.url, nil))
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response models.FXRate
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.InDelta(t, tt.expectedRate, response.Rate, 1e-9)
+			assert.Equal(t, tt.expectedConverted, response.Converted)
+		})
+	}
+}
+
+func TestConvertMinorUnits(t *testing.T) {
+	tests := []struct {
+		name        string
+		amount      int64
+		from        string
+		to          string
+		rate        float64
+		expected    int64
+		expectedErr error
+	}{
+		{name: "Rounds to cents", amount: 10000, from: "USD", to: "EUR", rate: 1 / 1.08, expected: 9259},
+		{name: "Rounds to whole yen", amount: 1005, from: "USD", to: "JPY", rate: 150, expected: 1508},
+		{name: "Keeps eight decimals for BTC", amount: 1005, from: "USD", to: "BTC", rate: 0.00001, expected: 10050},
+		{name: "From a currency without decimals", amount: 1500, from: "JPY", to: "USD", rate: 1.0 / 150, expected: 1000},
+		{name: "Beyond the supported range", amount: math.MaxInt64, from: "EUR", to: "BTC", rate: 1, expectedErr: helpers.ErrBalanceOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converted, err := helpers.ConvertMinorUnits(tt.amount, tt.from, tt.to, tt.rate)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, converted)
+		})
+	}
+}
+
+func TestFXRatePreviewMatchesTransaction(t *testing.T) {
+	t.Setenv("ALLOW_CROSS_CURRENCY_TRANSACTIONS", "true")
+
+	router := mux.NewRouter()
+	router.HandleFunc("/fx/rate", FXRateHandler).Methods("GET")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/fx/rate?from=USD&to=EUR&amount=123.45", nil))
+
+	var preview models.FXRate
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &preview))
+
+	// A USD transaction on a EUR account is credited exactly the previewed amount
+	transaction, err := applyTransactionCurrency(models.Transaction{MinorAmount: 12345, Currency: "USD"}, "EUR")
+	assert.NoError(t, err)
+
+	rate, err := converter.Rate("USD", "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, rate, preview.Rate)
+	assert.Equal(t, "114.31", preview.Converted)
+	assert.Equal(t, int64(11431), transaction.MinorAmount)
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/helpers"
+)
+
+// healthCheckTimeout bounds the database ping, so probes fail fast instead of hanging
+const healthCheckTimeout = 2 * time.Second
+
+// pinger is the part of the connection pool the health check needs
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+type healthStatus struct {
+	Status string `json:"status"`
+}
+
+// checkHealth pings the database within healthCheckTimeout
+func checkHealth(ctx context.Context, db pinger) error {
+	if db == nil {
+		return errors.New("database is not initialized")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return db.Ping(ctx)
+}
+
+// HealthHandler handles GET /health - 200 when the database answers a ping, 503 otherwise.
+// Used by liveness and readiness probes.
+func HealthHandler(w http.ResponseWriter, r *http.Request) {
+	var db pinger
+	if database.DBClient != nil && database.DBClient.Pool != nil {
+		db = database.DBClient.Pool
+	}
+
+	respondHealth(w, checkHealth(r.Context(), db))
+}
+
+func respondHealth(w http.ResponseWriter, err error) {
+	if err != nil {
+		helpers.RespondJSON(w, http.StatusServiceUnavailable, healthStatus{Status: "unavailable"})
+		return
+	}
+	helpers.RespondJSON(w, http.StatusOK, healthStatus{Status: "ok"})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePinger fails with err, or blocks until the context ends when hang is set
+type fakePinger struct {
+	err  error
+	hang bool
+}
+
+func (p fakePinger) Ping(ctx context.Context) error {
+	if p.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.err
+}
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name           string
+		db             pinger
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "Database reachable", db: fakePinger{}, expectedStatus: http.StatusOK, expectedBody: `{"status":"ok"}`},
+		{name: "Ping fails", db: fakePinger{err: errors.New("connection refused")}, expectedStatus: http.StatusServiceUnavailable, expectedBody: `{"status":"unavailable"}`},
+		{name: "Database not initialized", db: nil, expectedStatus: http.StatusServiceUnavailable, expectedBody: `{"status":"unavailable"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+
+			respondHealth(recorder, checkHealth(context.Background(), tt.db))
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.JSONEq(t, tt.expectedBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestHealthPingTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// A hanging database is reported once the context ends
+	err := checkHealth(ctx, fakePinger{hang: true})
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+)
+
+// ledgerDivergence is a row whose legacy and minor-unit columns disagree
+type ledgerDivergence struct {
+	Kind     string `json:"kind"`
+	ID       string `json:"id"`
+	Currency string `json:"currency"`
+	Legacy   string `json:"legacy"`
+	Minor    int64  `json:"minor"`
+}
+
+// ledgerReport is the result of comparing both ledger columns of every account and transaction
+type ledgerReport struct {
+	AccountsChecked     int                `json:"accounts_checked"`
+	TransactionsChecked int                `json:"transactions_checked"`
+	Divergences         []ledgerDivergence `json:"divergences"`
+}
+
+// checkLedgerValue compares a legacy NUMERIC value with its minor units exactly, so a value with more
+// decimals than the currency diverges instead of being rounded into agreement
+func checkLedgerValue(kind, id, currency string, value pgtype.Numeric, minor int64) (ledgerDivergence, bool) {
+	if legacyMinor, ok := helpers.MinorUnitsFromNumeric(value, currency); ok && legacyMinor == minor {
+		return ledgerDivergence{}, false
+	}
+
+	legacy, _ := value.Value()
+	return ledgerDivergence{
+		Kind:     kind,
+		ID:       id,
+		Currency: currency,
+		Legacy:   fmt.Sprint(legacy),
+		Minor:    minor,
+	}, true
+}
+
+// verifyLedger flags every account and transaction whose minor-unit column does not match the
+// legacy column; the legacy columns can only be dropped once it reports no divergences
+func verifyLedger(ctx context.Context, queries *sqlc.Queries) (ledgerReport, error) {
+	report := ledgerReport{Divergences: []ledgerDivergence{}}
+
+	accounts, err := queries.ListAccountLedgerBalances(ctx)
+	if err != nil {
+		return ledgerReport{}, err
+	}
+	for _, account := range accounts {
+		id := strconv.FormatInt(account.ID, 10)
+		if divergence, ok := checkLedgerValue("account", id, account.Currency, account.Balance, account.BalanceMinor); ok {
+			report.Divergences = append(report.Divergences, divergence)
+		}
+	}
+	report.AccountsChecked = len(accounts)
+
+	transactions, err := queries.ListTransactionLedgerAmounts(ctx)
+	if err != nil {
+		return ledgerReport{}, err
+	}
+	for _, transaction := range transactions {
+		if divergence, ok := checkLedgerValue("transaction", transaction.ID, transaction.Currency, transaction.Amount, transaction.AmountMinor); ok {
+			report.Divergences = append(report.Divergences, divergence)
+		}
+	}
+	report.TransactionsChecked = len(transactions)
+
+	return report, nil
+}
+
+// VerifyLedgerHandler handles GET /admin/ledger/verify - compares the legacy and minor-unit ledger columns
+func VerifyLedgerHandler(w http.ResponseWriter, r *http.Request) {
+	report, err := verifyLedger(r.Context(), database.DBClient.Queries)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Ledger")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Ledger verified successfully", report)
+}
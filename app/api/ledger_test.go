@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDualWriteKeepsLedgerColumnsConsistent(t *testing.T) {
+	account := sqlc.Account{ID: 1, UserID: 1, BalanceMinor: 5010, Currency: "EUR", Status: "active", AccountType: "general"}
+	db := &fakeDB{rows: map[string]fakeRow{
+		"GetAccountForUpdate": accountRow(account),
+		"UpdateAccount":       accountRow(account),
+		"CreateTransaction":   transactionRow(sqlc.Transaction{ID: "tx-1", AccountID: 1}),
+	}}
+	queries := sqlc.New(db)
+
+	_, err := updateBalanceInTx(context.Background(), queries, 1, 20, "deposit", "payment")
+	assert.NoError(t, err)
+
+	// Both balance columns describe the same amount
+	args := db.args["UpdateAccount"]
+	assert.Equal(t, helpers.NumericFromMinorUnits(5030, "EUR"), args[1])
+	assert.Equal(t, int64(5030), args[2])
+
+	transaction := models.Transaction{ID: "tx-1", AccountID: 1, MinorAmount: 20, Source: "payment", TransactionType: "deposit"}
+	_, err = createTransactionInTx(context.Background(), queries, transaction, "EUR")
+	assert.NoError(t, err)
+
+	args = db.args["CreateTransaction"]
+	assert.Equal(t, helpers.NumericFromMinorUnits(20, "EUR"), args[2])
+	assert.Equal(t, int64(20), args[8])
+}
+
+func TestVerifyLedger(t *testing.T) {
+	numeric := func(value string) pgtype.Numeric {
+		var result pgtype.Numeric
+		assert.NoError(t, result.Scan(value))
+		return result
+	}
+	accountLedgerRow := func(id int64, currency, balance string, minor int64) fakeRow {
+		return fakeRow{values: []interface{}{id, currency, numeric(balance), minor}}
+	}
+	transactionLedgerRow := func(id, currency, amount string, minor int64) fakeRow {
+		return fakeRow{values: []interface{}{id, currency, numeric(amount), minor}}
+	}
+
+	db := &fakeDB{results: map[string][]fakeRow{
+		"ListAccountLedgerBalances": {
+			accountLedgerRow(1, "EUR", "100.10000000", 10010),
+			accountLedgerRow(2, "JPY", "1500", 1500),
+			// Injected divergence: the legacy column drifted from the integer column
+			accountLedgerRow(3, "EUR", "20.00", 2001),
+			// Sub-minor digits cannot be carried in minor units
+			accountLedgerRow(4, "EUR", "20.005", 2001),
+		},
+		"ListTransactionLedgerAmounts": {
+			transactionLedgerRow("tx-1", "EUR", "0.30", 30),
+			// A BTC amount keeps all 8 decimals in the widened legacy column
+			transactionLedgerRow("tx-2", "BTC", "0.12345678", 12345678),
+			transactionLedgerRow("tx-3", "EUR", "5.00", 499),
+		},
+	}}
+
+	report, err := verifyLedger(context.Background(), sqlc.New(db))
+
+	assert.NoError(t, err)
+	assert.Equal(t, 4, report.AccountsChecked)
+	assert.Equal(t, 3, report.TransactionsChecked)
+
+	assert.Equal(t, []ledgerDivergence{
+		{Kind: "account", ID: "3", Currency: "EUR", Legacy: "20.00", Minor: 2001},
+		{Kind: "account", ID: "4", Currency: "EUR", Legacy: "20.005", Minor: 2001},
+		{Kind: "transaction", ID: "tx-3", Currency: "EUR", Legacy: "5.00", Minor: 499},
+	}, report.Divergences)
+}
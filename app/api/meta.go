@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// buildMeta describes the limits clients are subject to, with amounts in their currency's precision
+func buildMeta(caps map[string]float64) models.Meta {
+	meta := models.Meta{
+		DailyDebitCaps: map[string]string{},
+		CappedTypes:    helpers.CappedDebitTypes(),
+	}
+	for currency, limit := range caps {
+		meta.DailyDebitCaps[currency] = helpers.FormatAmount(limit, currency)
+	}
+	return meta
+}
+
+// MetaHandler handles GET /meta - lists the configured per-currency daily debit caps
+func MetaHandler(w http.ResponseWriter, r *http.Request) {
+	helpers.RespondSuccess(w, "Meta retrieved successfully", buildMeta(helpers.DailyDebitCaps()))
+}
+
+// SourceTypesHandler handles GET /meta/source-types - lists the transaction types allowed per source
+// and account type
+func SourceTypesHandler(w http.ResponseWriter, r *http.Request) {
+	helpers.RespondSuccess(w, "Source types retrieved successfully", models.SourceTypes{Sources: helpers.SourceTypeMatrix()})
+}
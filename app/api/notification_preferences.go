@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// defaultNotificationPreferences applies to users who have not stored preferences: every alert is on
+func defaultNotificationPreferences(userID int64) sqlc.NotificationPreference {
+	return sqlc.NotificationPreference{
+		UserID:                 userID,
+		LowBalanceAlerts:       true,
+		LargeTransactionAlerts: true,
+		Channel:                helpers.DefaultNotificationChannel,
+	}
+}
+
+// getNotificationPreferences returns the stored preferences of an existing user, or the defaults
+func getNotificationPreferences(ctx context.Context, queries *sqlc.Queries, userID int64) (sqlc.NotificationPreference, error) {
+	if _, err := findUser(ctx, queries, userID); err != nil {
+		return sqlc.NotificationPreference{}, err
+	}
+
+	preferences, err := queries.GetNotificationPreferences(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return defaultNotificationPreferences(userID), nil
+	}
+	return preferences, err
+}
+
+// updateNotificationPreferences replaces the preferences of an existing user
+func updateNotificationPreferences(ctx context.Context, queries *sqlc.Queries, userID int64, update models.NotificationPreferencesUpdate) (sqlc.NotificationPreference, error) {
+	if _, err := findUser(ctx, queries, userID); err != nil {
+		return sqlc.NotificationPreference{}, err
+	}
+
+	return queries.UpsertNotificationPreferences(ctx, sqlc.UpsertNotificationPreferencesParams{
+		UserID:                 userID,
+		LowBalanceAlerts:       *update.LowBalanceAlerts,
+		LargeTransactionAlerts: *update.LargeTransactionAlerts,
+		Channel:                update.Channel,
+	})
+}
+
+// resetNotificationPreferences drops the stored preferences so the user is back on the defaults
+func resetNotificationPreferences(ctx context.Context, queries *sqlc.Queries, userID int64) (sqlc.NotificationPreference, error) {
+	if _, err := findUser(ctx, queries, userID); err != nil {
+		return sqlc.NotificationPreference{}, err
+	}
+
+	if err := queries.DeleteNotificationPreferences(ctx, userID); err != nil {
+		return sqlc.NotificationPreference{}, err
+	}
+	return defaultNotificationPreferences(userID), nil
+}
+
+// notificationChannel is consulted before dispatching an alert of the given kind. It returns the
+// channel to deliver on, or false when the user has opted out of that kind.
+func notificationChannel(ctx context.Context, queries *sqlc.Queries, userID int64, kind string) (string, bool, error) {
+	preferences, err := getNotificationPreferences(ctx, queries, userID)
+	if err != nil {
+		return "", false, err
+	}
+
+	switch kind {
+	case helpers.NotificationLowBalance:
+		return preferences.Channel, preferences.LowBalanceAlerts, nil
+	case helpers.NotificationLargeTransaction:
+		return preferences.Channel, preferences.LargeTransactionAlerts, nil
+	default:
+		return "", false, nil
+	}
+}
+
+// GetNotificationPreferencesHandler handles GET /user/{userId}/notification-preferences
+func GetNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := helpers.ValidateID(mux.Vars(r)["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	preferences, err := getNotificationPreferences(r.Context(), database.DBClient.Queries, userID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "User")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Notification preferences retrieved successfully", preferences)
+}
+
+// UpdateNotificationPreferencesHandler handles PUT /user/{userId}/notification-preferences - replaces
+// the preferences with {"low_balance_alerts": true, "large_transaction_alerts": false, "channel": "sms"}
+func UpdateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := helpers.ValidateID(mux.Vars(r)["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	var update models.NotificationPreferencesUpdate
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &update); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	preferences, err := updateNotificationPreferences(r.Context(), database.DBClient.Queries, userID, update)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "User")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Notification preferences updated successfully", preferences)
+}
+
+// DeleteNotificationPreferencesHandler handles DELETE /user/{userId}/notification-preferences - restores
+// the defaults
+func DeleteNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := helpers.ValidateID(mux.Vars(r)["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	preferences, err := resetNotificationPreferences(r.Context(), database.DBClient.Queries, userID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "User")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Notification preferences reset successfully", preferences)
+}
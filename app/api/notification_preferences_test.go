@@ -0,0 +1,114 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func notificationPreferenceRow(preferences sqlc.NotificationPreference) fakeRow {
+	return fakeRow{values: []interface{}{
+		preferences.UserID,
+		preferences.LowBalanceAlerts,
+		preferences.LargeTransactionAlerts,
+		preferences.Channel,
+		preferences.UpdatedAt,
+	}}
+}
+
+func TestNotificationChannel(t *testing.T) {
+	stored := notificationPreferenceRow(sqlc.NotificationPreference{UserID: 1, LowBalanceAlerts: false, LargeTransactionAlerts: true, Channel: "sms"})
+
+	tests := []struct {
+		name            string
+		rows            map[string]fakeRow
+		kind            string
+		expectedChannel string
+		expectedSend    bool
+	}{
+		{
+			name:            "Defaults deliver low-balance alerts by email",
+			rows:            map[string]fakeRow{"GetUser": userRow(sqlc.User{ID: 1})},
+			kind:            helpers.NotificationLowBalance,
+			expectedChannel: "email",
+			expectedSend:    true,
+		},
+		{
+			name:         "Disabled low-balance alerts are suppressed",
+			rows:         map[string]fakeRow{"GetUser": userRow(sqlc.User{ID: 1}), "GetNotificationPreferences": stored},
+			kind:         helpers.NotificationLowBalance,
+			expectedSend: false,
+		},
+		{
+			name:            "Enabled large-transaction alerts use the stored channel",
+			rows:            map[string]fakeRow{"GetUser": userRow(sqlc.User{ID: 1}), "GetNotificationPreferences": stored},
+			kind:            helpers.NotificationLargeTransaction,
+			expectedChannel: "sms",
+			expectedSend:    true,
+		},
+		{
+			name:         "Unknown alert kinds are never sent",
+			rows:         map[string]fakeRow{"GetUser": userRow(sqlc.User{ID: 1})},
+			kind:         "marketing",
+			expectedSend: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := sqlc.New(&fakeDB{rows: tt.rows})
+
+			channel, send, err := notificationChannel(context.Background(), queries, 1, tt.kind)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedSend, send)
+			if tt.expectedSend {
+				assert.Equal(t, tt.expectedChannel, channel)
+			}
+		})
+	}
+}
+
+func TestNotificationPreferencesCRUD(t *testing.T) {
+	enabled, disabled := true, false
+	db := &fakeDB{rows: map[string]fakeRow{
+		"GetUser": userRow(sqlc.User{ID: 1}),
+		"UpsertNotificationPreferences": notificationPreferenceRow(sqlc.NotificationPreference{
+			UserID: 1, LowBalanceAlerts: true, LargeTransactionAlerts: false, Channel: "push",
+		}),
+	}}
+	queries := sqlc.New(db)
+
+	preferences, err := getNotificationPreferences(context.Background(), queries, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultNotificationPreferences(1), preferences)
+
+	preferences, err = updateNotificationPreferences(context.Background(), queries, 1, models.NotificationPreferencesUpdate{
+		LowBalanceAlerts:       &enabled,
+		LargeTransactionAlerts: &disabled,
+		Channel:                "push",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "push", preferences.Channel)
+	assert.Equal(t, []interface{}{int64(1), true, false, "push"}, db.args["UpsertNotificationPreferences"])
+
+	preferences, err = resetNotificationPreferences(context.Background(), queries, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultNotificationPreferences(1), preferences)
+	assert.Contains(t, db.queries, "DeleteNotificationPreferences")
+
+	// Unknown users are rejected without touching their preferences
+	missing := &fakeDB{}
+	_, err = updateNotificationPreferences(context.Background(), sqlc.New(missing), 77001, models.NotificationPreferencesUpdate{
+		LowBalanceAlerts:       &enabled,
+		LargeTransactionAlerts: &enabled,
+		Channel:                "email",
+	})
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	assert.NotContains(t, missing.queries, "UpsertNotificationPreferences")
+}
@@ -0,0 +1,201 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+const (
+	payoutModeAtomic  = "atomic"
+	payoutModePartial = "partial"
+
+	payoutStatusApplied    = "applied"
+	payoutStatusFailed     = "failed"
+	payoutStatusRolledBack = "rolled_back"
+)
+
+// applyPayouts credits every entry under one batch ID. In atomic mode all entries share a
+// single database transaction; in partial mode each entry commits or fails on its own.
+func applyPayouts(ctx context.Context, starter txStarter, queries *sqlc.Queries, actor, batchID string, entries []models.PayoutEntry, atomic bool) []models.PayoutResult {
+	results := make([]models.PayoutResult, len(entries))
+	for i, entry := range entries {
+		results[i] = models.PayoutResult{Index: i, UserID: entry.UserID, Amount: entry.Amount}
+	}
+
+	if atomic {
+		failed := -1
+		err := runInTxWith(ctx, starter, queries, func(ctx context.Context, queries *sqlc.Queries) error {
+			for i, entry := range entries {
+				transactionID, err := creditPayoutEntry(ctx, queries, actor, batchID, entry)
+				if err != nil {
+					failed = i
+					return err
+				}
+				results[i].TransactionID = transactionID
+			}
+			return nil
+		})
+
+		for i := range results {
+			switch {
+			case err == nil:
+				results[i].Status = payoutStatusApplied
+			case i == failed:
+				results[i].Status = payoutStatusFailed
+				results[i].Error = payoutErrorMessage(err)
+			default:
+				results[i].Status = payoutStatusRolledBack
+			}
+			if err != nil {
+				results[i].TransactionID = ""
+			}
+		}
+		return results
+	}
+
+	for i, entry := range entries {
+		var transactionID string
+		err := runInTxWith(ctx, starter, queries, func(ctx context.Context, queries *sqlc.Queries) error {
+			var err error
+			transactionID, err = creditPayoutEntry(ctx, queries, actor, batchID, entry)
+			return err
+		})
+
+		if err != nil {
+			results[i].Status = payoutStatusFailed
+			results[i].Error = payoutErrorMessage(err)
+			continue
+		}
+		results[i].Status = payoutStatusApplied
+		results[i].TransactionID = transactionID
+	}
+	return results
+}
+
+func creditPayoutEntry(ctx context.Context, queries *sqlc.Queries, actor, batchID string, entry models.PayoutEntry) (string, error) {
+	account, err := queries.GetAccountByUser(ctx, entry.UserID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", helpers.ErrAccountNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The amount is in the currency of the user's account
+	amount, err := helpers.ParseAmount(entry.Amount, account.Currency)
+	if err != nil {
+		return "", err
+	}
+
+	updated, err := updateBalanceInTx(ctx, queries, account.ID, amount, "deposit", "payment")
+	if err != nil {
+		return "", err
+	}
+
+	transaction := models.Transaction{
+		ID:                uuid.NewString(),
+		AccountID:         account.ID,
+		MinorAmount:       amount,
+		Source:            "payment",
+		TransactionType:   "deposit",
+		Memo:              entry.Memo,
+		PayoutBatchID:     batchID,
+		BalanceAfterMinor: &updated.BalanceMinor,
+	}
+	if _, err := createTransactionInTx(ctx, queries, transaction, account.Currency); err != nil {
+		return "", err
+	}
+
+	err = recordAdminAudit(ctx, queries, adminAuditEntry{
+		Actor:           actor,
+		Action:          "payout",
+		TargetUserID:    entry.UserID,
+		TargetAccountID: account.ID,
+		Params: map[string]string{
+			"amount":         entry.Amount,
+			"memo":           entry.Memo,
+			"batch_id":       batchID,
+			"transaction_id": transaction.ID,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return transaction.ID, nil
+}
+
+// payoutErrorMessage keeps per-entry errors informative without leaking database details
+func payoutErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, helpers.ErrAccountNotFound):
+		return "User account not found"
+	case errors.Is(err, helpers.ErrInvalidAmount):
+		return "Invalid amount specified"
+	case errors.Is(err, helpers.ErrAmountMustBePositive):
+		return "Amount must be a positive number"
+	default:
+		log.Printf("Payout entry failed: %v", err)
+		return "Payout could not be applied"
+	}
+}
+
+// CreatePayoutsHandler handles POST /admin/payouts?mode=atomic|partial - credits many accounts in one batch
+func CreatePayoutsHandler(w http.ResponseWriter, r *http.Request) {
+	actor, err := adminActor(r)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = payoutModeAtomic
+	}
+	if mode != payoutModeAtomic && mode != payoutModePartial {
+		helpers.RespondError(w, http.StatusBadRequest, "Mode must be one of: atomic partial")
+		return
+	}
+
+	var payout models.PayoutRequest
+
+	// Validate and decode JSON request body using enhanced validation
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &payout); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	batchID := uuid.NewString()
+	results := applyPayouts(r.Context(), database.DBClient.Pool, database.DBClient.Queries, actor, batchID, payout.Entries, mode == payoutModeAtomic)
+
+	response := models.PayoutResponse{
+		BatchID: batchID,
+		Mode:    mode,
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Status == payoutStatusApplied {
+			response.Applied++
+		} else {
+			response.Failed++
+		}
+	}
+
+	switch {
+	case response.Failed == 0:
+		helpers.RespondSuccess(w, "Payouts applied successfully", response)
+	case mode == payoutModeAtomic:
+		helpers.RespondJSON(w, http.StatusUnprocessableEntity, response)
+	default:
+		helpers.RespondJSON(w, http.StatusMultiStatus, response)
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// payoutDB knows accounts for users 1 and 3; user 2 has no account
+func payoutDB() *fakeDB {
+	account := sqlc.Account{ID: 10, UserID: 1, BalanceMinor: 500, Currency: "EUR", Status: "active", AccountType: "general"}
+
+	return &fakeDB{
+		rows: map[string]fakeRow{
+			"GetAccountForUpdate": accountRow(account),
+			"UpdateAccount":       accountRow(account),
+			"CreateTransaction":   transactionRow(sqlc.Transaction{ID: "tx", AccountID: account.ID}),
+			"CreateAdminAudit":    adminAuditRow(sqlc.AdminAudit{ID: 1}),
+		},
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			if name == "GetAccountByUser" && args[0].(int64) == 2 {
+				return fakeRow{err: pgx.ErrNoRows}, true
+			}
+			return fakeRow{}, false
+		},
+	}
+}
+
+func TestApplyPayoutsAtomic(t *testing.T) {
+	db := payoutDB()
+	starter := &fakeStarter{db: db}
+	db.rows["GetAccountByUser"] = accountRow(sqlc.Account{ID: 10, UserID: 1})
+
+	entries := []models.PayoutEntry{
+		{UserID: 1, Amount: "10.00", Memo: "March payroll"},
+		{UserID: 2, Amount: "20.00"},
+		{UserID: 3, Amount: "30.00"},
+	}
+
+	results := applyPayouts(context.Background(), starter, sqlc.New(db), "ops@example.com", "batch-1", entries, true)
+
+	assert.Len(t, starter.txs, 1)
+	assert.False(t, starter.txs[0].committed)
+	assert.True(t, starter.txs[0].rolledBack)
+
+	assert.Equal(t, payoutStatusRolledBack, results[0].Status)
+	assert.Empty(t, results[0].TransactionID)
+	assert.Equal(t, payoutStatusFailed, results[1].Status)
+	assert.Equal(t, "User account not found", results[1].Error)
+	assert.Equal(t, payoutStatusRolledBack, results[2].Status)
+}
+
+func TestApplyPayoutsAtomicAllApplied(t *testing.T) {
+	db := payoutDB()
+	starter := &fakeStarter{db: db}
+	db.rows["GetAccountByUser"] = accountRow(sqlc.Account{ID: 10, UserID: 1})
+
+	entries := []models.PayoutEntry{
+		{UserID: 1, Amount: "10.00"},
+		{UserID: 3, Amount: "30.00"},
+	}
+
+	results := applyPayouts(context.Background(), starter, sqlc.New(db), "ops@example.com", "batch-1", entries, true)
+
+	assert.Len(t, starter.txs, 1)
+	assert.True(t, starter.txs[0].committed)
+	for _, result := range results {
+		assert.Equal(t, payoutStatusApplied, result.Status)
+		assert.NotEmpty(t, result.TransactionID)
+	}
+}
+
+func TestApplyPayoutsPartial(t *testing.T) {
+	db := payoutDB()
+	starter := &fakeStarter{db: db}
+	db.rows["GetAccountByUser"] = accountRow(sqlc.Account{ID: 10, UserID: 1})
+
+	entries := []models.PayoutEntry{
+		{UserID: 1, Amount: "10.00"},
+		{UserID: 2, Amount: "20.00"},
+		{UserID: 3, Amount: "not-a-number"},
+	}
+
+	results := applyPayouts(context.Background(), starter, sqlc.New(db), "ops@example.com", "batch-1", entries, false)
+
+	assert.Len(t, starter.txs, 3)
+	assert.True(t, starter.txs[0].committed)
+	assert.True(t, starter.txs[1].rolledBack)
+	assert.True(t, starter.txs[2].rolledBack)
+
+	assert.Equal(t, payoutStatusApplied, results[0].Status)
+	assert.NotEmpty(t, results[0].TransactionID)
+	assert.Equal(t, payoutStatusFailed, results[1].Status)
+	assert.Equal(t, "User account not found", results[1].Error)
+	assert.Equal(t, payoutStatusFailed, results[2].Status)
+	assert.Equal(t, "Invalid amount specified", results[2].Error)
+}
+
+func TestCreatePayoutsHandlerValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            string
+		body           interface{}
+		anonymous      bool
+		expectedStatus int
+	}{
+		{
+			name:           "Without an authenticated admin",
+			url:            "/admin/payouts",
+			body:           models.PayoutRequest{Entries: []models.PayoutEntry{{UserID: 1, Amount: "1.00"}}},
+			anonymous:      true,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown mode",
+			url:            "/admin/payouts?mode=eventually",
+			body:           models.PayoutRequest{Entries: []models.PayoutEntry{{UserID: 1, Amount: "1.00"}}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "No entries",
+			url:            "/admin/payouts",
+			body:           models.PayoutRequest{},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "Entry without amount",
+			url:            "/admin/payouts?mode=partial",
+			body:           models.PayoutRequest{Entries: []models.PayoutEntry{{UserID: 1}}},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.HandleFunc("/admin/payouts", CreatePayoutsHandler).Methods("POST")
+
+			body, err := json.Marshal(tt.body)
+			assert.NoError(t, err)
+
+			req, err := http.NewRequest("POST", tt.url, bytes.NewBuffer(body))
+			assert.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			if !tt.anonymous {
+				req = req.WithContext(helpers.WithAdmin(req.Context(), "alice"))
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
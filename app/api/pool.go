@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// poolStat is the part of pgxpool.Stat reported by GET /debug/pool
+type poolStat interface {
+	MaxConns() int32
+	TotalConns() int32
+	AcquiredConns() int32
+	IdleConns() int32
+	ConstructingConns() int32
+	AcquireCount() int64
+	EmptyAcquireCount() int64
+	CanceledAcquireCount() int64
+	AcquireDuration() time.Duration
+	NewConnsCount() int64
+	MaxLifetimeDestroyCount() int64
+	MaxIdleDestroyCount() int64
+}
+
+func buildPoolStats(stat poolStat) models.PoolStats {
+	return models.PoolStats{
+		MaxConns:                stat.MaxConns(),
+		TotalConns:              stat.TotalConns(),
+		AcquiredConns:           stat.AcquiredConns(),
+		IdleConns:               stat.IdleConns(),
+		ConstructingConns:       stat.ConstructingConns(),
+		AcquireCount:            stat.AcquireCount(),
+		EmptyAcquireCount:       stat.EmptyAcquireCount(),
+		CanceledAcquireCount:    stat.CanceledAcquireCount(),
+		AcquireDuration:         stat.AcquireDuration().String(),
+		NewConnsCount:           stat.NewConnsCount(),
+		MaxLifetimeDestroyCount: stat.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     stat.MaxIdleDestroyCount(),
+	}
+}
+
+// PoolStatsHandler handles GET /debug/pool - connection pool usage, to diagnose connection exhaustion
+func PoolStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if database.DBClient == nil || database.DBClient.Pool == nil {
+		helpers.RespondError(w, http.StatusServiceUnavailable, "Database is not initialized")
+		return
+	}
+
+	helpers.RespondJSON(w, http.StatusOK, buildPoolStats(database.DBClient.Pool.Stat()))
+}
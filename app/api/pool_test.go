@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePoolStat reports a pool with every connection in use and callers waiting
+type fakePoolStat struct{}
+
+func (fakePoolStat) MaxConns() int32                { return 10 }
+func (fakePoolStat) TotalConns() int32              { return 10 }
+func (fakePoolStat) AcquiredConns() int32           { return 10 }
+func (fakePoolStat) IdleConns() int32               { return 0 }
+func (fakePoolStat) ConstructingConns() int32       { return 0 }
+func (fakePoolStat) AcquireCount() int64            { return 250 }
+func (fakePoolStat) EmptyAcquireCount() int64       { return 40 }
+func (fakePoolStat) CanceledAcquireCount() int64    { return 3 }
+func (fakePoolStat) AcquireDuration() time.Duration { return 1500 * time.Millisecond }
+func (fakePoolStat) NewConnsCount() int64           { return 12 }
+func (fakePoolStat) MaxLifetimeDestroyCount() int64 { return 1 }
+func (fakePoolStat) MaxIdleDestroyCount() int64     { return 1 }
+
+func TestBuildPoolStats(t *testing.T) {
+	stats := buildPoolStats(fakePoolStat{})
+
+	assert.Equal(t, models.PoolStats{
+		MaxConns:                10,
+		TotalConns:              10,
+		AcquiredConns:           10,
+		IdleConns:               0,
+		ConstructingConns:       0,
+		AcquireCount:            250,
+		EmptyAcquireCount:       40,
+		CanceledAcquireCount:    3,
+		AcquireDuration:         "1.5s",
+		NewConnsCount:           12,
+		MaxLifetimeDestroyCount: 1,
+		MaxIdleDestroyCount:     1,
+	}, stats)
+
+	body, err := json.Marshal(stats)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"acquired_conns":10`)
+	assert.Contains(t, string(body), `"idle_conns":0`)
+}
+
+func TestPoolStatsHandlerWithoutDatabase(t *testing.T) {
+	recorder := httptest.NewRecorder()
+
+	PoolStatsHandler(recorder, httptest.NewRequest("GET", "/debug/pool", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+}
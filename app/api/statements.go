@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// buildStatement lists the transactions of a statement period oldest-first, each with the balance
+// recorded right after it was applied
+func buildStatement(account sqlc.Account, transactions []sqlc.Transaction, statementRange helpers.StatementRange) models.Statement {
+	statement := models.Statement{
+		UserID:       account.UserID,
+		AccountID:    account.ID,
+		Currency:     account.Currency,
+		From:         models.NewTimestamp(statementRange.From),
+		To:           models.NewTimestamp(statementRange.To),
+		Transactions: []models.StatementEntry{},
+	}
+
+	for _, transaction := range transactions {
+		entry := models.StatementEntry{
+			TransactionID: transaction.ID,
+			Type:          transaction.Type,
+			Source:        transaction.Source,
+			Amount:        helpers.FormatMoney(transaction.AmountMinor, account.Currency),
+			InsertedAt:    transaction.InsertedAt,
+		}
+		if transaction.BalanceAfterMinor.Valid {
+			balanceAfter := helpers.FormatMoney(transaction.BalanceAfterMinor.Int64, account.Currency)
+			entry.BalanceAfter = &balanceAfter
+		}
+		statement.Transactions = append(statement.Transactions, entry)
+	}
+
+	return statement
+}
+
+// StatementHandler handles GET /user/{userId}/statement?from=&to= - the transactions of a period,
+// by default the last 30 days, with the balance after each
+func StatementHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := helpers.ValidateID(mux.Vars(r)["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	statementRange, err := helpers.ParseStatementRange(r.URL.Query(), time.Now())
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	account, err := GetAccountByUser(r.Context(), userID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Account")
+		return
+	}
+
+	transactions, err := database.DBClient.Queries.ListTransactionsByAccountBetween(r.Context(), sqlc.ListTransactionsByAccountBetweenParams{
+		AccountID: account.ID,
+		FromTime:  models.NewTimestamp(statementRange.From),
+		ToTime:    models.NewTimestamp(statementRange.To),
+	})
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transaction")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Statement retrieved successfully", buildStatement(account, transactions, statementRange))
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildStatement(t *testing.T) {
+	at := func(d int) models.Timestamp {
+		return models.NewTimestamp(time.Date(2025, 3, d, 12, 0, 0, 0, time.UTC))
+	}
+	minor := func(value int64) pgtype.Int8 { return pgtype.Int8{Int64: value, Valid: true} }
+	balance := func(value string) *string { return &value }
+
+	account := sqlc.Account{ID: 1, UserID: 7, Currency: "EUR"}
+	transactions := []sqlc.Transaction{
+		{ID: "tx-1", Type: "deposit", Source: "payment", AmountMinor: 5000, InsertedAt: at(2)},
+		{ID: "tx-2", Type: "withdrawal", Source: "payment", AmountMinor: 2000, BalanceAfterMinor: minor(13000), InsertedAt: at(3)},
+		{ID: "tx-3", Type: "lose", Source: "game", AmountMinor: 525, BalanceAfterMinor: minor(12475), InsertedAt: at(4)},
+	}
+	statementRange := helpers.StatementRange{From: at(1).Time, To: at(5).Time}
+
+	statement := buildStatement(account, transactions, statementRange)
+
+	assert.Equal(t, models.Statement{
+		UserID:    7,
+		AccountID: 1,
+		Currency:  "EUR",
+		From:      at(1),
+		To:        at(5),
+		Transactions: []models.StatementEntry{
+			{TransactionID: "tx-1", Type: "deposit", Source: "payment", Amount: "50.00", InsertedAt: at(2)},
+			{TransactionID: "tx-2", Type: "withdrawal", Source: "payment", Amount: "20.00", BalanceAfter: balance("130.00"), InsertedAt: at(3)},
+			{TransactionID: "tx-3", Type: "lose", Source: "game", Amount: "5.25", BalanceAfter: balance("124.75"), InsertedAt: at(4)},
+		},
+	}, statement)
+
+	// A period without transactions still lists them as an empty array
+	assert.Equal(t, []models.StatementEntry{}, buildStatement(account, nil, statementRange).Transactions)
+}
+
+func TestParseStatementRange(t *testing.T) {
+	now := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		query       url.Values
+		expected    helpers.StatementRange
+		expectedErr error
+	}{
+		{
+			name:     "Default range covers the last 30 days",
+			query:    url.Values{},
+			expected: helpers.StatementRange{From: time.Date(2025, 2, 10, 15, 30, 0, 0, time.UTC), To: now},
+		},
+		{
+			name:     "From defaults to 30 days before to",
+			query:    url.Values{"to": {"2025-03-01T00:00:00Z"}},
+			expected: helpers.StatementRange{From: time.Date(2025, 1, 30, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "Explicit range is kept as given",
+			query:    url.Values{"from": {"2025-03-10T08:00:00+02:00"}, "to": {"2025-03-11T00:00:00Z"}},
+			expected: helpers.StatementRange{From: time.Date(2025, 3, 10, 6, 0, 0, 0, time.UTC), To: time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)},
+		},
+		{name: "Range ending before it starts", query: url.Values{"from": {"2025-03-12T00:00:00Z"}, "to": {"2025-03-11T00:00:00Z"}}, expectedErr: helpers.ErrInvalidRange},
+		{name: "Invalid timestamp", query: url.Values{"from": {"2025-03-01"}}, expectedErr: helpers.ErrInvalidTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statementRange, err := helpers.ParseStatementRange(tt.query, now)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, statementRange)
+		})
+	}
+}
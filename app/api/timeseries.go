@@ -0,0 +1,100 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+func listTransactionsSince(ctx context.Context, accountID int64, since time.Time) ([]sqlc.Transaction, error) {
+	params := sqlc.ListTransactionsByAccountSinceParams{
+		AccountID: accountID,
+		Since:     models.NewTimestamp(since),
+	}
+	return database.DBClient.Queries.ListTransactionsByAccountSince(ctx, params)
+}
+
+// buildBalanceTimeseries replays the account's transactions since the start of the range and returns
+// the closing balance of every period. The opening balance is derived from the current balance, so
+// transactions must cover everything from the range start up to now. Sums run in minor units.
+func buildBalanceTimeseries(account sqlc.Account, transactions []sqlc.Transaction, timeseriesRange helpers.TimeseriesRange) []models.BalancePoint {
+	signedMinor := func(transaction sqlc.Transaction) int64 {
+		transactionType, ok := helpers.LookupTransactionType(transaction.Type)
+		if !ok {
+			return 0
+		}
+		amount := transaction.AmountMinor
+		if transactionType.Sign < 0 {
+			return -amount
+		}
+		return amount
+	}
+
+	balance := account.BalanceMinor
+	for _, transaction := range transactions {
+		balance -= signedMinor(transaction)
+	}
+
+	points := []models.BalancePoint{}
+	next := 0
+	for start := timeseriesRange.From; start.Before(timeseriesRange.To); start = helpers.NextPeriod(start, timeseriesRange.Interval) {
+		end := helpers.NextPeriod(start, timeseriesRange.Interval)
+		for next < len(transactions) && transactions[next].InsertedAt.Time.Before(end) {
+			balance += signedMinor(transactions[next])
+			next++
+		}
+		points = append(points, models.BalancePoint{
+			Period:  models.NewTimestamp(start),
+			Balance: helpers.FormatMoney(balance, account.Currency),
+		})
+	}
+
+	return points
+}
+
+// BalanceTimeseriesHandler handles GET /user/{userId}/balance/timeseries?interval=day&from=&to= -
+// closing balances per hour, day or week
+func BalanceTimeseriesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := helpers.ValidateID(mux.Vars(r)["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	if err := authorizePrincipal(r, helpers.OperationReadBalance, "", ""); err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	timeseriesRange, err := helpers.ParseTimeseriesRange(r.URL.Query(), time.Now())
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	account, err := GetAccountByUser(r.Context(), userID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Account")
+		return
+	}
+
+	transactions, err := listTransactionsSince(r.Context(), account.ID, timeseriesRange.From)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transaction")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Balance timeseries retrieved successfully", models.BalanceTimeseries{
+		UserID:    userID,
+		AccountID: account.ID,
+		Currency:  account.Currency,
+		Interval:  timeseriesRange.Interval,
+		Points:    buildBalanceTimeseries(account, transactions, timeseriesRange),
+	})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildBalanceTimeseries(t *testing.T) {
+	day := func(d, hour int) models.Timestamp {
+		return models.NewTimestamp(time.Date(2025, 3, d, hour, 0, 0, 0, time.UTC))
+	}
+
+	// 100.00 on March 1st, then +50.00, -20.00 on the 2nd, nothing on the 3rd, -5.25 on the 4th and
+	// +10.00 on the 6th, after the range
+	account := sqlc.Account{ID: 1, UserID: 7, BalanceMinor: 13475, Currency: "EUR"}
+	transactions := []sqlc.Transaction{
+		{ID: "tx-1", Type: "deposit", AmountMinor: 5000, InsertedAt: day(2, 9)},
+		{ID: "tx-2", Type: "withdrawal", AmountMinor: 2000, InsertedAt: day(2, 18)},
+		{ID: "tx-3", Type: "lose", AmountMinor: 525, InsertedAt: day(4, 0)},
+		{ID: "tx-4", Type: "win", AmountMinor: 1000, InsertedAt: day(6, 12)},
+	}
+	timeseriesRange := helpers.TimeseriesRange{
+		Interval: helpers.IntervalDay,
+		From:     day(2, 0).Time,
+		To:       day(5, 0).Time,
+	}
+
+	points := buildBalanceTimeseries(account, transactions, timeseriesRange)
+
+	assert.Equal(t, []models.BalancePoint{
+		{Period: day(2, 0), Balance: "130.00"},
+		{Period: day(3, 0), Balance: "130.00"},
+		{Period: day(4, 0), Balance: "124.75"},
+	}, points)
+}
+
+func TestParseTimeseriesRange(t *testing.T) {
+	now := time.Date(2025, 3, 12, 15, 30, 0, 0, time.UTC) // a Wednesday
+
+	tests := []struct {
+		name        string
+		query       url.Values
+		expected    helpers.TimeseriesRange
+		expectedErr error
+	}{
+		{
+			name:  "From is aligned to the start of its day",
+			query: url.Values{"from": {"2025-03-10T08:00:00Z"}, "to": {"2025-03-12T00:00:00Z"}},
+			expected: helpers.TimeseriesRange{
+				Interval: "day",
+				From:     time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+				To:       time.Date(2025, 3, 12, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name:  "Weeks start on Monday",
+			query: url.Values{"interval": {"week"}, "from": {"2025-03-12T08:00:00Z"}},
+			expected: helpers.TimeseriesRange{
+				Interval: "week",
+				From:     time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+				To:       now,
+			},
+		},
+		{
+			name:  "Default range covers the last 30 periods",
+			query: url.Values{"interval": {"hour"}},
+			expected: helpers.TimeseriesRange{
+				Interval: "hour",
+				From:     time.Date(2025, 3, 11, 10, 0, 0, 0, time.UTC),
+				To:       now,
+			},
+		},
+		{name: "Interval outside the allowlist", query: url.Values{"interval": {"minute"}}, expectedErr: helpers.ErrInvalidInterval},
+		{name: "Range ending before it starts", query: url.Values{"from": {"2025-03-12T00:00:00Z"}, "to": {"2025-03-11T00:00:00Z"}}, expectedErr: helpers.ErrInvalidRange},
+		{name: "Too many buckets", query: url.Values{"interval": {"hour"}, "from": {"2025-01-01T00:00:00Z"}}, expectedErr: helpers.ErrTooManyBuckets},
+		{name: "Invalid timestamp", query: url.Values{"to": {"yesterday"}}, expectedErr: helpers.ErrInvalidTimestamp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeseriesRange, err := helpers.ParseTimeseriesRange(tt.query, now)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, timeseriesRange)
+		})
+	}
+}
@@ -3,17 +3,36 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/rathorevk/GoBanking/app/database"
 	"github.com/rathorevk/GoBanking/app/database/sqlc"
 	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/metrics"
 	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/rathorevk/GoBanking/app/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// CreateTransactionHandler handles POST /user/{user_id}/transaction - creates a new transaction
+// accountSlots queues transactions on accounts that already have ACCOUNT_MAX_CONCURRENT_TRANSACTIONS in flight
+var accountSlots = helpers.NewAccountSlots(helpers.AccountMaxConcurrentTransactions)
+
+// CreateTransactionHandler handles POST /user/{user_id}/transaction and
+// /user/{userId}/account/{accountId}/transaction - creates a new transaction on the user's first or
+// the given account
 func CreateTransactionHandler(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userIDStr := vars["userId"]
@@ -25,91 +44,469 @@ func CreateTransactionHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get user account
-	account, err := GetAccountByUser(userID)
+	account, err := requestAccount(r.Context(), database.DBClient.Queries, userID, vars["accountId"])
 	if err != nil {
-		helpers.HandleDatabaseError(w, err, "Account")
+		handleAccountError(w, err)
 		return
 	}
 
-	// Get source from header
-	source := r.Header.Get("Source-Type")
+	transaction, validationErrors, err := decodeTransaction(r, account)
+	if validationErrors != nil {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
 
-	transaction := models.Transaction{
-		AccountID: account.ID,
-		Source:    source,
+	// Clients that do not bring their own transactionId get a generated one
+	if transaction.ID == "" {
+		transaction.ID = uuid.NewString()
 	}
 
-	// Validate and decode JSON request body using enhanced validation
-	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &transaction); !ok {
-		helpers.RespondValidationError(w, validationErrors)
+	// The header decides the source; a source repeated in the body must agree with it
+	transaction.Source, err = helpers.ResolveSource(r.Header.Get("Source-Type"), transaction.Source)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
 		return
 	}
 
-	transaction, err = validateAndParseTransactionAmount(transaction)
+	transaction, err = applyTransactionCurrency(transaction, account.Currency)
 	if err != nil {
 		helpers.HandleAPIError(w, err)
 		return
 	}
 
-	// Execute transaction creation and balance update in a single database transaction
-	err = runInTx(database.DBClient, func(queries *sqlc.Queries) error {
-		// Create transaction within the transaction
-		_, err := createTransactionInTx(queries, transaction)
+	trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("transaction.type", transaction.TransactionType))
+
+	// The API key's source decides which transaction types it may create
+	if err := authorizePrincipal(r, helpers.OperationCreateTransaction, transaction.Source, transaction.TransactionType); err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	// Large amounts wait for a second confirmation step instead of executing now
+	if requiresStepUp(transaction.MinorAmount, account.Currency) {
+		token, confirmation, err := createConfirmation(r.Context(), database.DBClient.Queries, userID, transaction, time.Now())
 		if err != nil {
-			return err
+			helpers.HandleDatabaseError(w, err, "Transaction")
+			return
 		}
 
-		// Update balance within the same transaction
-		_, err = updateBalanceInTx(queries, account.ID, transaction.AmountFloat, transaction.TransactionType)
-		if err != nil {
-			return err
+		helpers.RespondJSON(w, http.StatusAccepted, models.ConfirmationRequired{
+			Status:            confirmationRequiredStatus,
+			ConfirmationToken: token,
+			ExpiresAt:         confirmation.ExpiresAt,
+			Amount:            helpers.FormatMoney(transaction.MinorAmount, account.Currency),
+			Type:              transaction.TransactionType,
+		})
+		return
+	}
+
+	release, err := accountSlots.Acquire(r.Context(), account.ID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transaction")
+		return
+	}
+	defer release()
+
+	// Execute balance update and transaction creation in a single database transaction
+	err = runInTx(r.Context(), database.DBClient, func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		transaction, err = applyTransactionInTx(ctx, queries, transaction)
+		return err
+	})
+
+	respondTransactionResult(w, r, userID, account, transaction, err)
+}
+
+// decodeTransaction decodes and validates a transaction request on the account and parses its amount.
+// An invalid amount is reported under "amount" together with the other field errors, so every problem
+// with the body gets the same 422 response; other errors, such as an unknown account type, are returned.
+func decodeTransaction(r *http.Request, account sqlc.Account) (models.Transaction, map[string]string, error) {
+	transaction := models.Transaction{
+		AccountID: account.ID,
+	}
+
+	ok, validationErrors := helpers.ValidateBodyWithDetails(r, &transaction)
+	if _, invalidBody := validationErrors["body"]; invalidBody {
+		return models.Transaction{}, validationErrors, nil
+	}
+
+	// amount_minor counts minor units of the body currency when given, else of the account currency
+	currency := account.Currency
+	if transaction.Currency != "" {
+		currency = strings.ToUpper(transaction.Currency)
+	}
+
+	// A missing type only passes validation in signed amount mode
+	var parsed models.Transaction
+	var err error
+	if transaction.TransactionType == "" {
+		parsed, err = deriveSignedTransaction(transaction, account.AccountType, currency)
+	} else {
+		parsed, err = validateAndParseTransactionAmount(transaction, currency)
+	}
+
+	if message, isAmountErr := helpers.AmountErrorMessage(err); isAmountErr {
+		if validationErrors == nil {
+			validationErrors = map[string]string{}
+		}
+		if _, exists := validationErrors["amount"]; !exists {
+			validationErrors["amount"] = message
 		}
+		ok = false
+	}
+	if !ok {
+		return models.Transaction{}, validationErrors, nil
+	}
+	return parsed, nil, err
+}
+
+// applyTransactionInTx updates the balance and records the transaction, returning it as persisted
+func applyTransactionInTx(ctx context.Context, queries *sqlc.Queries, transaction models.Transaction) (models.Transaction, error) {
+	// Update balance first so the account is re-validated and locked inside the transaction
+	updatedAccount, err := updateBalanceInTx(ctx, queries, transaction.AccountID, transaction.MinorAmount, transaction.TransactionType, transaction.Source)
+	if err != nil {
+		return transaction, err
+	}
+
+	// The account row is locked now, so concurrent requests cannot slip past the velocity check
+	if err := checkVelocity(ctx, queries, transaction.AccountID, time.Now()); err != nil {
+		return transaction, err
+	}
+
+	if err := checkDailyCaps(ctx, queries, transaction, updatedAccount.Currency, time.Now()); err != nil {
+		return transaction, err
+	}
+
+	// Create transaction within the same transaction
+	transaction.BalanceAfterMinor = &updatedAccount.BalanceMinor
+	created, err := createTransactionInTx(ctx, queries, transaction, updatedAccount.Currency)
+	if err != nil {
+		return transaction, err
+	}
+
+	persisted := transactionFromRow(created)
+	persisted.BalanceAfterMinor = &updatedAccount.BalanceMinor
+	persisted.BalanceVersion = &updatedAccount.Version
+	return persisted, nil
+}
+
+// checkVelocity rejects a transaction when the account already had the maximum number of
+// transactions within the rolling window ending at now
+func checkVelocity(ctx context.Context, queries *sqlc.Queries, accountID int64, now time.Time) error {
+	maxTransactions, window := helpers.VelocityLimit()
+	if maxTransactions == 0 {
+		return nil
+	}
 
+	count, err := queries.CountRecentTransactions(ctx, sqlc.CountRecentTransactionsParams{
+		AccountID: accountID,
+		Since:     models.NewTimestamp(now.Add(-window)),
+	})
+	if err != nil {
+		return err
+	}
+
+	if count >= int64(maxTransactions) {
+		return helpers.ErrVelocityExceeded
+	}
+	return nil
+}
+
+// checkDailyCaps rejects a transaction that would take the account's total of the current UTC day
+// above a daily cap of the account currency. The amount is already in the account currency. It runs
+// in the transaction that inserts the new one, after the account row is locked, so concurrent requests
+// cannot exceed a cap together. One query sums the day's minor units of every type the caps count.
+func checkDailyCaps(ctx context.Context, queries *sqlc.Queries, transaction models.Transaction, currency string, now time.Time) error {
+	var caps []helpers.DailyCap
+	var types []string
+	for _, dailyCap := range helpers.DailyCaps() {
+		if !dailyCap.Applies(currency, transaction.TransactionType) {
+			continue
+		}
+		caps = append(caps, dailyCap)
+		for _, name := range dailyCap.Types {
+			if !slices.Contains(types, name) {
+				types = append(types, name)
+			}
+		}
+	}
+	if len(caps) == 0 {
 		return nil
+	}
+	slices.Sort(types)
+
+	totals, err := queries.SumTransactionsByAccountSince(ctx, sqlc.SumTransactionsByAccountSinceParams{
+		AccountID: transaction.AccountID,
+		Since:     models.NewTimestamp(helpers.StartOfDay(now)),
+		Types:     types,
 	})
+	if err != nil {
+		return err
+	}
+	totalByType := map[string]int64{}
+	for _, total := range totals {
+		totalByType[total.Type] = total.TotalMinor
+	}
+
+	for _, dailyCap := range caps {
+		var totalToday int64
+		for _, name := range dailyCap.Types {
+			totalToday += totalByType[name]
+		}
+		if err := dailyCap.Check(currency, totalToday, transaction.MinorAmount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// transactionFromRow converts a stored transaction into the API model
+func transactionFromRow(row sqlc.Transaction) models.Transaction {
+	return models.Transaction{
+		ID:              row.ID,
+		AccountID:       row.AccountID,
+		MinorAmount:     row.AmountMinor,
+		Source:          row.Source,
+		TransactionType: row.Type,
+		InsertedAt:      row.InsertedAt,
+		Memo:            row.Memo.String,
+		PayoutBatchID:   row.PayoutBatchID.String,
+		ClientReference: row.ClientReference.String,
+	}
+}
+
+// respondTransactionResult writes the response for an executed (or rejected) transaction
+func respondTransactionResult(w http.ResponseWriter, r *http.Request, userID int64, account sqlc.Account, transaction models.Transaction, err error) {
+	metrics.TransactionsTotal.WithLabelValues(transaction.TransactionType, transactionOutcome(err)).Inc()
 
+	// The whole transaction was rolled back, so reply with the original one instead
+	if errors.Is(err, helpers.ErrDuplicateReference) {
+		original, err := replayedTransaction(r.Context(), database.DBClient.Queries, account, transaction)
+		if errors.Is(err, helpers.ErrReferenceMismatch) {
+			helpers.HandleAPIError(w, err)
+			return
+		}
+		if err != nil {
+			helpers.HandleDatabaseError(w, err, "Transaction")
+			return
+		}
+
+		helpers.RespondSuccess(w, "Transaction already processed", buildTransactionResponse(userID, original, account.Currency))
+		return
+	}
+
+	// The account may have been removed after the initial lookup, or reject the transaction by type
+	if ruleErr := transactionRuleError(err); ruleErr != nil {
+		helpers.HandleAPIError(w, ruleErr)
+		return
+	}
 	if err != nil {
 		helpers.HandleDatabaseError(w, err, "Transaction")
 		return
 	}
 
-	// Return success response
-	responseData := map[string]interface{}{
+	// Return the transaction as persisted, including the server-assigned fields
+	responseData := buildTransactionResponse(userID, transaction, account.Currency)
+	helpers.RespondSuccess(w, "Transaction created successfully", responseData)
+}
+
+// transactionOutcome labels an executed transaction for the metrics; duplicates and rule violations
+// count as rejected
+func transactionOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.Is(err, helpers.ErrInsufficientBalance):
+		return metrics.OutcomeInsufficientBalance
+	default:
+		return metrics.OutcomeRejected
+	}
+}
+
+// transactionRuleError returns the business error that rejected a transaction, if any
+func transactionRuleError(err error) error {
+	for _, ruleErr := range []error{
+		helpers.ErrAccountNotFound,
+		helpers.ErrAccountFrozen,
+		helpers.ErrAccountClosed,
+		helpers.ErrInvalidAccountType,
+		helpers.ErrTransactionTypeNotAllowed,
+		helpers.ErrSourceNotAllowed,
+		helpers.ErrWithdrawalLimitExceeded,
+		helpers.ErrVelocityExceeded,
+		helpers.ErrDailyCapExceeded,
+		helpers.ErrDailyLimitExceeded,
+		helpers.ErrReferenceInUse,
+		helpers.ErrBalanceOverflow,
+	} {
+		if errors.Is(err, ruleErr) {
+			return ruleErr
+		}
+	}
+	return nil
+}
+
+func buildTransactionResponse(userID int64, transaction models.Transaction, currency string) map[string]interface{} {
+	response := map[string]interface{}{
 		"user_account_id": userID,
 		"transaction_id":  transaction.ID,
-		"amount":          transaction.AmountFloat,
+		"account_id":      transaction.AccountID,
+		"amount":          helpers.MoneyJSON(transaction.MinorAmount, currency),
 		"type":            transaction.TransactionType,
 		"source":          transaction.Source,
+		"inserted_at":     transaction.InsertedAt,
+		"_links": map[string]helpers.Link{
+			"self":    {Href: helpers.APIPath("transactions", transaction.ID)},
+			"balance": {Href: helpers.APIPath("user", strconv.FormatInt(userID, 10), "balance")},
+		},
 	}
-	helpers.RespondSuccess(w, "Transaction created successfully", responseData)
+
+	if transaction.Memo != "" {
+		response["memo"] = transaction.Memo
+	}
+	if transaction.ClientReference != "" {
+		response["client_reference"] = transaction.ClientReference
+	}
+	// A replayed transaction has no balance_after, as later transactions may have changed the balance since
+	if transaction.BalanceAfterMinor != nil {
+		response["balance_after"] = helpers.MoneyJSON(*transaction.BalanceAfterMinor, currency)
+		// The same value formatted as in the balance response, so clients can skip GET /balance
+		response["balance"] = helpers.FormatMoney(*transaction.BalanceAfterMinor, currency)
+	}
+	if transaction.BalanceVersion != nil {
+		response["balance_version"] = *transaction.BalanceVersion
+	}
+
+	return response
+}
+
+func validateAndParseTransactionAmount(transaction models.Transaction, currency string) (models.Transaction, error) {
+	amount, err := transactionAmount(transaction, currency, false)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	transaction.MinorAmount = amount
+	return transaction, nil
+}
+
+// transactionAmount returns the requested amount in the currency's minor units, taken from amount_minor
+// when given instead of parsing the decimal amount. Exactly one of them must be set; signed amounts may
+// be negative.
+func transactionAmount(transaction models.Transaction, currency string, signed bool) (int64, error) {
+	if (transaction.Amount == "") == (transaction.AmountMinor == nil) {
+		return 0, helpers.ErrAmountInputConflict
+	}
+
+	if transaction.AmountMinor == nil {
+		if signed {
+			return helpers.ParseSignedAmount(transaction.Amount, currency)
+		}
+		return helpers.ParseAmount(transaction.Amount, currency)
+	}
+
+	minor := *transaction.AmountMinor
+	if signed && minor == 0 {
+		return 0, helpers.ErrAmountCannotBeZero
+	}
+	// The debit of the most negative int64 has no positive amount
+	if minor == math.MinInt64 {
+		return 0, helpers.ErrInvalidAmount
+	}
+	if !signed && minor <= 0 {
+		return 0, helpers.ErrAmountMustBePositive
+	}
+	return minor, nil
+}
+
+// applyTransactionCurrency checks an explicit body currency against the account currency, converting
+// the amount when cross-currency transactions are enabled
+func applyTransactionCurrency(transaction models.Transaction, accountCurrency string) (models.Transaction, error) {
+	currency := strings.ToUpper(transaction.Currency)
+	if currency == "" || currency == accountCurrency {
+		return transaction, nil
+	}
+
+	if !helpers.CrossCurrencyTransactionsEnabled() {
+		return models.Transaction{}, helpers.ErrCurrencyMismatch
+	}
+
+	converted, _, err := convertAmount(transaction.MinorAmount, currency, accountCurrency)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	transaction.MinorAmount = converted
+	return transaction, nil
 }
 
-func validateAndParseTransactionAmount(transaction models.Transaction) (models.Transaction, error) {
-	// Use helper function to validate amount
-	amount, err := helpers.ParseAmount(transaction.Amount)
+// clientReferenceIndex enforces at most one transaction per (account_id, client_reference)
+const clientReferenceIndex = "idx_transactions_account_client_reference"
+
+// deriveSignedTransaction maps a signed amount to the account type's credit or debit transaction type
+func deriveSignedTransaction(transaction models.Transaction, accountTypeName, currency string) (models.Transaction, error) {
+	amount, err := transactionAmount(transaction, currency, true)
 	if err != nil {
 		return models.Transaction{}, err
 	}
 
-	// Add the parsed float amount to the transaction struct
-	transaction.AmountFloat = amount
+	accountType, ok := helpers.LookupAccountType(accountTypeName)
+	if !ok {
+		return models.Transaction{}, helpers.ErrInvalidAccountType
+	}
+
+	transaction.TransactionType = accountType.CreditType
+	if amount < 0 {
+		transaction.TransactionType = accountType.DebitType
+		amount = -amount
+	}
+	transaction.MinorAmount = amount
 	return transaction, nil
 }
 
-func runInTx(db *database.DB, fn func(queries *sqlc.Queries) error) error {
-	tx, err := db.Pool.Begin(context.Background())
+// txStarter begins database transactions; it is satisfied by *pgxpool.Pool
+type txStarter interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+func runInTx(ctx context.Context, db *database.DB, fn func(ctx context.Context, queries *sqlc.Queries) error) error {
+	return runInTxWith(ctx, db.Pool, db.Queries, fn)
+}
+
+func runInTxWith(ctx context.Context, starter txStarter, baseQueries *sqlc.Queries, fn func(ctx context.Context, queries *sqlc.Queries) error) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "runInTx")
+	defer func() {
+		tracing.EndSpan(span, err)
+	}()
+
+	tx, err := starter.Begin(ctx)
 	if err != nil {
 		return err
 	}
 
-	queries := db.Queries.WithTx(tx)
-	err = fn(queries)
+	// Fail fast instead of queueing forever behind a stuck holder of a row lock
+	if timeout := helpers.LockTimeoutMillis(); timeout > 0 {
+		if _, err = tx.Exec(ctx, fmt.Sprintf("SET LOCAL lock_timeout = %d", timeout)); err != nil {
+			return errors.Join(err, tx.Rollback(ctx))
+		}
+	}
+
+	queries := baseQueries.WithTx(tx)
+	err = fn(ctx, queries)
 	if err == nil {
-		return tx.Commit(context.Background())
+		return tx.Commit(ctx)
 	}
 
-	rollbackErr := tx.Rollback(context.Background())
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == helpers.PgLockNotAvailable {
+		err = errors.Join(helpers.ErrLockTimeout, err)
+	}
+
+	rollbackErr := tx.Rollback(ctx)
 	if rollbackErr != nil {
 		return errors.Join(err, rollbackErr)
 	}
@@ -117,72 +514,310 @@ func runInTx(db *database.DB, fn func(queries *sqlc.Queries) error) error {
 	return err
 }
 
-func createTransactionInTx(queries *sqlc.Queries, transaction models.Transaction) (sqlc.Transaction, error) {
+// createTransactionInTx inserts the transaction, writing the amount in both the legacy and the
+// minor-unit column of the account currency while the ledger migration is in progress
+func createTransactionInTx(ctx context.Context, queries *sqlc.Queries, transaction models.Transaction, currency string) (sqlc.Transaction, error) {
 	log.Println("Creating transaction in TX:", transaction)
 
+	if transaction.ClientReference != "" && helpers.IdempotencyScope() == helpers.IdempotencyScopeGlobal {
+		if err := checkGlobalReference(ctx, queries, transaction); err != nil {
+			return sqlc.Transaction{}, err
+		}
+	}
+
 	params := sqlc.CreateTransactionParams{
-		ID:        transaction.ID,
-		AccountID: transaction.AccountID,
-		Amount:    transaction.AmountFloat,
-		Source:    transaction.Source,
-		Type:      transaction.TransactionType,
+		ID:              transaction.ID,
+		AccountID:       transaction.AccountID,
+		Amount:          helpers.NumericFromMinorUnits(transaction.MinorAmount, currency),
+		Source:          transaction.Source,
+		Type:            transaction.TransactionType,
+		PayoutBatchID:   pgtype.Text{String: transaction.PayoutBatchID, Valid: transaction.PayoutBatchID != ""},
+		Memo:            pgtype.Text{String: transaction.Memo, Valid: transaction.Memo != ""},
+		ClientReference: pgtype.Text{String: transaction.ClientReference, Valid: transaction.ClientReference != ""},
+		AmountMinor:     transaction.MinorAmount,
+	}
+	if transaction.BalanceAfterMinor != nil {
+		params.BalanceAfterMinor = pgtype.Int8{Int64: *transaction.BalanceAfterMinor, Valid: true}
+	}
+
+	created, err := queries.CreateTransaction(ctx, params)
+
+	// A reused client reference means this request was already processed
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == helpers.PgUniqueViolation && pgErr.ConstraintName == clientReferenceIndex {
+		return sqlc.Transaction{}, helpers.ErrDuplicateReference
 	}
-	return queries.CreateTransaction(context.Background(), params)
+
+	return created, err
 }
 
-func updateBalanceInTx(queries *sqlc.Queries, accountID int64, amount float64, transactionType string) (sqlc.Account, error) {
-	log.Printf("Updating balance for account ID: %d, amount: %.2f, type: %s", accountID, amount, transactionType)
+// checkGlobalReference enforces system-wide uniqueness of a client reference. The advisory lock
+// serializes concurrent requests using the same reference until their transactions end; the unique
+// index still catches a replay on the same account.
+func checkGlobalReference(ctx context.Context, queries *sqlc.Queries, transaction models.Transaction) error {
+	if err := queries.LockClientReference(ctx, transaction.ClientReference); err != nil {
+		return err
+	}
+
+	existing, err := queries.GetTransactionByGlobalClientReference(ctx, pgtype.Text{String: transaction.ClientReference, Valid: true})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
 
-	// Fetch current balance
-	account, err := queries.GetAccount(context.Background(), accountID)
+	if existing.AccountID == transaction.AccountID {
+		return helpers.ErrDuplicateReference
+	}
+	return helpers.ErrReferenceInUse
+}
+
+// findTransactionByReference returns the transaction previously created with a client reference
+func findTransactionByReference(ctx context.Context, queries *sqlc.Queries, accountID int64, clientReference string) (models.Transaction, error) {
+	original, err := queries.GetTransactionByClientReference(ctx, sqlc.GetTransactionByClientReferenceParams{
+		AccountID:       accountID,
+		ClientReference: pgtype.Text{String: clientReference, Valid: true},
+	})
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	return transactionFromRow(original), nil
+}
+
+// replayedTransaction returns the transaction originally created with the client reference of a
+// retried request. A retry must repeat the original amount and type; anything else reusing the
+// reference is a different transaction and gets ErrReferenceMismatch instead of the original.
+func replayedTransaction(ctx context.Context, queries *sqlc.Queries, account sqlc.Account, transaction models.Transaction) (models.Transaction, error) {
+	original, err := findTransactionByReference(ctx, queries, account.ID, transaction.ClientReference)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+
+	if original.TransactionType != transaction.TransactionType || original.MinorAmount != transaction.MinorAmount {
+		return models.Transaction{}, helpers.ErrReferenceMismatch
+	}
+	return original, nil
+}
+
+// updateBalanceInTx locks the account and applies an amount in minor units of the account currency
+func updateBalanceInTx(ctx context.Context, queries *sqlc.Queries, accountID int64, amount int64, transactionType, source string) (sqlc.Account, error) {
+	log.Printf("Updating balance for account ID: %d, amount: %d minor units, type: %s, source: %s", accountID, amount, transactionType, source)
+
+	// Fetch and lock the account, which may have disappeared since the handler looked it up
+	account, err := queries.GetAccountForUpdate(ctx, accountID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Account{}, helpers.ErrAccountNotFound
+	}
 	if err != nil {
 		return sqlc.Account{}, err
 	}
 
-	currentBalance := account.Balance
-	var newBalance float64
+	// Frozen and closed accounts accept no money movement
+	if err := helpers.CheckAccountActive(account.Status); err != nil {
+		return sqlc.Account{}, err
+	}
 
-	// Calculate new balance based on transaction type
-	switch transactionType {
-	case "win", "deposit":
-		newBalance = currentBalance + amount
-	case "lose", "withdrawal":
-		newBalance = currentBalance - amount
-		if newBalance < 0 {
-			return sqlc.Account{}, helpers.ErrInsufficientBalance
-		}
-	default:
-		return sqlc.Account{}, helpers.ErrInvalidTransactionType
+	// The account type decides which transactions it accepts
+	if err := helpers.CheckAccountRules(account.AccountType, transactionType, source, amount, account.Currency); err != nil {
+		return sqlc.Account{}, err
+	}
+
+	// The minor-unit column is authoritative; the legacy column is only kept in sync for rollback
+	currentBalance := account.BalanceMinor
+
+	// Calculate new balance based on the transaction type registry, in integer minor units
+	newBalance, err := helpers.ApplyTransaction(currentBalance, amount, transactionType)
+	if err != nil {
+		return sqlc.Account{}, err
 	}
 
 	// Update the account balance
 	params := sqlc.UpdateAccountParams{
-		ID:      accountID,
-		Balance: newBalance,
+		ID:           accountID,
+		Balance:      helpers.NumericFromMinorUnits(newBalance, account.Currency),
+		BalanceMinor: newBalance,
 	}
 
-	updatedAccount, err := queries.UpdateAccount(context.Background(), params)
+	updatedAccount, err := queries.UpdateAccount(ctx, params)
 	if err != nil {
 		return sqlc.Account{}, err
 	}
 
-	log.Printf("Balance updated successfully from %.2f to %.2f", currentBalance, newBalance)
+	log.Printf("Balance updated successfully from %s to %s", helpers.FormatMoney(currentBalance, account.Currency), helpers.FormatMoney(newBalance, account.Currency))
 	return updatedAccount, nil
 }
 
+// findTransaction fetches a transaction by ID. A user authenticated by a bearer token only sees the
+// transactions of their own accounts; those of other users are reported as not found, so their IDs
+// cannot be probed.
+func findTransaction(ctx context.Context, queries *sqlc.Queries, transactionID string) (sqlc.Transaction, error) {
+	transaction, err := queries.GetTransaction(ctx, transactionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Transaction{}, helpers.ErrTransactionNotFound
+	}
+	if err != nil {
+		return sqlc.Transaction{}, err
+	}
+
+	if userID, ok := helpers.UserIDFromContext(ctx); ok {
+		account, err := queries.GetAccount(ctx, transaction.AccountID)
+		if errors.Is(err, pgx.ErrNoRows) || err == nil && account.UserID != userID {
+			return sqlc.Transaction{}, helpers.ErrTransactionNotFound
+		}
+		if err != nil {
+			return sqlc.Transaction{}, err
+		}
+	}
+
+	return transaction, nil
+}
+
 // GetTransaction handles GET /transactions/{transactionId} - returns specific transaction
 func GetTransaction(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	transactionID := vars["transactionId"]
 
-	if transactionID == "" {
-		helpers.HandleAPIError(w, helpers.ErrInvalidID)
+	if err := helpers.ValidateTransactionID(transactionID); err != nil {
+		helpers.HandleAPIError(w, err)
 		return
 	}
 
-	// TODO: Implement GetTransaction in SQLC
-	log.Printf("Fetching transaction with ID: %s", transactionID)
+	transaction, err := findTransaction(r.Context(), database.DBClient.Queries, transactionID)
+	if errors.Is(err, helpers.ErrTransactionNotFound) {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transaction")
+		return
+	}
 
-	// Placeholder response
-	helpers.RespondError(w, http.StatusNotImplemented, "Get transaction by ID not yet implemented")
+	helpers.RespondSuccess(w, "Transaction retrieved successfully", transaction)
+}
+
+// batchGetTransactions fetches the transactions with the given IDs in one query and lists the IDs
+// that do not exist, in request order and without duplicates
+func batchGetTransactions(ctx context.Context, queries *sqlc.Queries, ids []string) ([]sqlc.Transaction, []string, error) {
+	unique := make([]string, 0, len(ids))
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	transactions, err := queries.ListTransactionsByIDs(ctx, unique)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	found := map[string]bool{}
+	for _, transaction := range transactions {
+		found[transaction.ID] = true
+	}
+	notFound := []string{}
+	for _, id := range unique {
+		if !found[id] {
+			notFound = append(notFound, id)
+		}
+	}
+
+	return transactions, notFound, nil
+}
+
+// BatchGetTransactionsHandler handles POST /transactions/batch-get - returns the transactions with the
+// listed IDs (at most 100) and the IDs that were not found
+func BatchGetTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.TransactionBatchGetRequest
+
+	// Validate and decode JSON request body using enhanced validation
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &request); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	transactions, notFound, err := batchGetTransactions(r.Context(), database.DBClient.Queries, request.IDs)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transaction")
+		return
+	}
+
+	responseData := map[string]interface{}{
+		"transactions": transactions,
+		"not_found":    notFound,
+	}
+	helpers.RespondSuccess(w, "Transactions retrieved successfully", responseData)
+}
+
+// listAccountTransactions returns a page of an account's transactions, newest first, and their total count
+func listAccountTransactions(ctx context.Context, queries *sqlc.Queries, accountID int64, limit, offset int32) ([]sqlc.Transaction, int64, error) {
+	transactions, err := queries.ListTransactionsByAccount(ctx, sqlc.ListTransactionsByAccountParams{
+		AccountID: accountID,
+		Limit:     limit,
+		Offset:    offset,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := queries.CountTransactionsByAccount(ctx, accountID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return transactions, total, nil
+}
+
+// buildTransactionList maps stored transactions of an account to the transaction response, so amounts
+// follow MONEY_JSON_FORMAT and the account currency's precision
+func buildTransactionList(userID int64, currency string, rows []sqlc.Transaction) []map[string]interface{} {
+	transactions := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		transactions = append(transactions, buildTransactionResponse(userID, transactionFromRow(row), currency))
+	}
+	return transactions
+}
+
+// ListTransactionsHandler handles GET /user/{userId}/transactions?limit=20&offset=0 and
+// /user/{userId}/account/{accountId}/transactions - lists the transactions of the user's first or the
+// given account, newest first
+func ListTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	userID, err := helpers.ValidateID(vars["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	limit, offset, err := helpers.ParsePaginationWithDefault(r.URL.Query(), helpers.DefaultTransactionPageLimit)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	account, err := requestAccount(r.Context(), database.DBClient.Queries, userID, vars["accountId"])
+	if err != nil {
+		handleAccountError(w, err)
+		return
+	}
+
+	transactions, total, err := listAccountTransactions(r.Context(), database.DBClient.Queries, account.ID, limit, offset)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transaction")
+		return
+	}
+
+	responseData := map[string]interface{}{
+		"account_id":   account.ID,
+		"currency":     account.Currency,
+		"transactions": buildTransactionList(userID, account.Currency, transactions),
+		"total":        total,
+		"limit":        limit,
+		"offset":       offset,
+	}
+	helpers.RespondSuccess(w, "Transactions retrieved successfully", responseData)
 }
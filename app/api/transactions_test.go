@@ -2,13 +2,27 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
 	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/metrics"
+	"github.com/rathorevk/GoBanking/app/middleware"
 	"github.com/rathorevk/GoBanking/app/models"
 	"github.com/stretchr/testify/assert"
 )
@@ -148,7 +162,7 @@ func TestValidateAndParseTransactionAmount(t *testing.T) {
 		name           string
 		transaction    models.Transaction
 		expectError    bool
-		expectedAmount float64
+		expectedAmount int64
 	}{
 		{
 			name: "Valid amount",
@@ -158,7 +172,7 @@ func TestValidateAndParseTransactionAmount(t *testing.T) {
 				TransactionType: "win",
 			},
 			expectError:    false,
-			expectedAmount: 100.50,
+			expectedAmount: 10050,
 		},
 		{
 			name: "Invalid amount format",
@@ -208,44 +222,228 @@ func TestValidateAndParseTransactionAmount(t *testing.T) {
 				TransactionType: "win",
 			},
 			expectError:    false,
-			expectedAmount: 999999.99,
+			expectedAmount: 99999999,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := validateAndParseTransactionAmount(tt.transaction)
+			result, err := validateAndParseTransactionAmount(tt.transaction, "USD")
 
 			if tt.expectError {
 				assert.Error(t, err)
-				assert.Equal(t, float64(0), result.AmountFloat)
+				assert.Equal(t, int64(0), result.MinorAmount)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.expectedAmount, result.AmountFloat)
+				assert.Equal(t, tt.expectedAmount, result.MinorAmount)
 				assert.Equal(t, tt.transaction.TransactionType, result.TransactionType)
 			}
 		})
 	}
 }
 
+func TestTransactionAmountInput(t *testing.T) {
+	minor := func(value int64) *int64 { return &value }
+
+	tests := []struct {
+		name           string
+		amount         string
+		amountMinor    *int64
+		currency       string
+		signed         bool
+		expectedAmount int64
+		expectedErr    error
+	}{
+		{name: "Decimal amount", amount: "10.15", expectedAmount: 1015},
+		{name: "Minor units", amountMinor: minor(1015), expectedAmount: 1015},
+		{name: "Minor units of a whole amount", amountMinor: minor(500), expectedAmount: 500},
+		{name: "Minor units of a currency without decimals", amountMinor: minor(1015), currency: "JPY", expectedAmount: 1015},
+		{name: "Minor units of a currency with eight decimals", amountMinor: minor(150000000), currency: "BTC", expectedAmount: 150000000},
+		{name: "Zero minor units", amountMinor: minor(0), expectedErr: helpers.ErrAmountMustBePositive},
+		{name: "Negative minor units", amountMinor: minor(-100), expectedErr: helpers.ErrAmountMustBePositive},
+		{name: "Signed negative minor units", amountMinor: minor(-250), signed: true, expectedAmount: -250},
+		{name: "Signed zero minor units", amountMinor: minor(0), signed: true, expectedErr: helpers.ErrAmountCannotBeZero},
+		{name: "Both amount and minor units", amount: "10.15", amountMinor: minor(1015), expectedErr: helpers.ErrAmountInputConflict},
+		{name: "Neither amount nor minor units", expectedErr: helpers.ErrAmountInputConflict},
+		{name: "Decimal amount of a currency without decimals", amount: "10.5", currency: "JPY", expectedErr: helpers.ErrTooManyDecimals},
+		{name: "Decimal amount of a currency with eight decimals", amount: "0.00000001", currency: "BTC", expectedAmount: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currency := tt.currency
+			if currency == "" {
+				currency = "USD"
+			}
+			amount, err := transactionAmount(models.Transaction{Amount: tt.amount, AmountMinor: tt.amountMinor}, currency, tt.signed)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedAmount, amount)
+		})
+	}
+}
+
+func TestDecodeTransactionAmountErrors(t *testing.T) {
+	account := sqlc.Account{ID: 1, AccountType: helpers.AccountTypeGeneral}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "Both amount and amount_minor",
+			body:           `{"transactionId":"tx-1","state":"win","amount":"10.15","amount_minor":1015}`,
+			expectedErrors: map[string]string{"amount": "Exactly one of amount or amount_minor is required"},
+		},
+		{
+			name:           "Neither amount nor amount_minor",
+			body:           `{"transactionId":"tx-1","state":"win"}`,
+			expectedErrors: map[string]string{"amount": "Exactly one of amount or amount_minor is required"},
+		},
+		{
+			name:           "Unparsable amount",
+			body:           `{"transactionId":"tx-1","state":"win","amount":"ten"}`,
+			expectedErrors: map[string]string{"amount": "Invalid amount specified"},
+		},
+		{
+			name:           "Too many decimals",
+			body:           `{"transactionId":"tx-1","state":"win","amount":"1.005"}`,
+			expectedErrors: map[string]string{"amount": "Amount has more decimal places than the currency allows"},
+		},
+		{
+			name:           "Reversals are booked by admins only",
+			body:           `{"transactionId":"tx-1","state":"reversal","amount":"10.00"}`,
+			expectedErrors: map[string]string{"state": "The state must be one of: win lose deposit withdrawal"},
+		},
+		{
+			name: "Amount reported with the other field errors",
+			body: `{"transactionId":"tx-1","state":"jackpot","amount":"-5"}`,
+			expectedErrors: map[string]string{
+				"state":  "The state must be one of: win lose deposit withdrawal",
+				"amount": "Amount must be a positive number",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/user/1/transaction", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			_, validationErrors, err := decodeTransaction(req, account)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedErrors, validationErrors)
+
+			// Amount errors share the 422 validation response
+			recorder := httptest.NewRecorder()
+			helpers.RespondValidationError(recorder, validationErrors)
+			assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		})
+	}
+
+	// Invalid JSON is not parsed any further
+	req := httptest.NewRequest("POST", "/user/1/transaction", strings.NewReader(`{"amount":`))
+	_, validationErrors, _ := decodeTransaction(req, account)
+	assert.Contains(t, validationErrors, "body")
+	assert.NotContains(t, validationErrors, "amount")
+
+	// A valid body comes back parsed
+	req = httptest.NewRequest("POST", "/user/1/transaction", strings.NewReader(`{"transactionId":"tx-1","state":"win","amount":"10.15"}`))
+	transaction, validationErrors, err := decodeTransaction(req, account)
+	assert.NoError(t, err)
+	assert.Nil(t, validationErrors)
+	assert.Equal(t, int64(1), transaction.AccountID)
+	assert.Equal(t, int64(1015), transaction.MinorAmount)
+}
+
+func TestDeriveSignedTransaction(t *testing.T) {
+	tests := []struct {
+		name           string
+		amount         string
+		accountType    string
+		expectedErr    error
+		expectedType   string
+		expectedAmount int64
+	}{
+		{name: "Positive amount is a credit", amount: "25.50", accountType: "general", expectedType: "win", expectedAmount: 2550},
+		{name: "Negative amount is a debit", amount: "-10.00", accountType: "general", expectedType: "lose", expectedAmount: 1000},
+		{name: "Explicit plus sign", amount: "+3", accountType: "game", expectedType: "win", expectedAmount: 300},
+		{name: "Savings credit is a deposit", amount: "100", accountType: "savings", expectedType: "deposit", expectedAmount: 10000},
+		{name: "Checking debit is a withdrawal", amount: "-42.42", accountType: "checking", expectedType: "withdrawal", expectedAmount: 4242},
+		{name: "Zero amount", amount: "0.00", accountType: "general", expectedErr: helpers.ErrAmountCannotBeZero},
+		{name: "Sub-cent amount is rejected", amount: "-0.001", accountType: "general", expectedErr: helpers.ErrTooManyDecimals},
+		{name: "Invalid amount", amount: "ten", accountType: "general", expectedErr: helpers.ErrInvalidAmount},
+		{name: "Unknown account type", amount: "5", accountType: "brokerage", expectedErr: helpers.ErrInvalidAccountType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transaction := models.Transaction{ID: "tx-1", Amount: tt.amount, Source: "game"}
+
+			result, err := deriveSignedTransaction(transaction, tt.accountType, "USD")
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.TransactionType)
+			assert.Equal(t, tt.expectedAmount, result.MinorAmount)
+		})
+	}
+}
+
+func TestTransactionTypeRequirementByAmountMode(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		body        string
+		expectValid bool
+	}{
+		{name: "Strict mode requires a type", mode: "", body: `{"transactionId":"tx-1","account_id":1,"amount":"-5.00","source":"game"}`, expectValid: false},
+		{name: "Signed mode accepts a signed amount without type", mode: "signed", body: `{"transactionId":"tx-1","account_id":1,"amount":"-5.00","source":"game"}`, expectValid: true},
+		{name: "Signed mode still validates an explicit type", mode: "signed", body: `{"transactionId":"tx-1","account_id":1,"amount":"5.00","source":"game","state":"jackpot"}`, expectValid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRANSACTION_AMOUNT_MODE", tt.mode)
+
+			req, err := http.NewRequest("POST", "/test", strings.NewReader(tt.body))
+			assert.NoError(t, err)
+
+			var transaction models.Transaction
+			valid, validationErrors := helpers.ValidateBodyWithDetails(req, &transaction)
+
+			assert.Equal(t, tt.expectValid, valid, validationErrors)
+		})
+	}
+}
+
 func TestParseAmount(t *testing.T) {
 	tests := []struct {
 		name           string
 		amountStr      string
+		currency       string
 		expectError    bool
-		expectedAmount float64
+		expectedAmount int64
 	}{
 		{
 			name:           "Valid amount",
 			amountStr:      "123.45",
 			expectError:    false,
-			expectedAmount: 123.45,
+			expectedAmount: 12345,
 		},
 		{
 			name:           "Integer amount",
 			amountStr:      "100",
 			expectError:    false,
-			expectedAmount: 100.0,
+			expectedAmount: 10000,
 		},
 		{
 			name:        "Invalid format",
@@ -267,15 +465,37 @@ func TestParseAmount(t *testing.T) {
 			amountStr:   "",
 			expectError: true,
 		},
+		{
+			name:           "Currency without decimals",
+			amountStr:      "1500",
+			currency:       "JPY",
+			expectedAmount: 1500,
+		},
+		{
+			name:        "Decimals on a currency without decimals",
+			amountStr:   "10.5",
+			currency:    "JPY",
+			expectError: true,
+		},
+		{
+			name:           "Currency with eight decimals",
+			amountStr:      "0.12345678",
+			currency:       "BTC",
+			expectedAmount: 12345678,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			amount, err := helpers.ParseAmount(tt.amountStr)
+			currency := tt.currency
+			if currency == "" {
+				currency = "EUR"
+			}
+			amount, err := helpers.ParseAmount(tt.amountStr, currency)
 
 			if tt.expectError {
 				assert.Error(t, err)
-				assert.Equal(t, float64(0), amount)
+				assert.Equal(t, int64(0), amount)
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedAmount, amount)
@@ -284,6 +504,70 @@ func TestParseAmount(t *testing.T) {
 	}
 }
 
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		name        string
+		amountStr   string
+		options     helpers.MoneyOptions
+		expected    int64
+		expectedErr error
+	}{
+		{name: "Zero rejected by default", amountStr: "0", expectedErr: helpers.ErrAmountMustBePositive},
+		{name: "Zero with decimals rejected by default", amountStr: "0.00", expectedErr: helpers.ErrAmountMustBePositive},
+		{name: "Zero allowed", amountStr: "0", options: helpers.MoneyOptions{AllowZero: true}, expected: 0},
+		{name: "Zero with decimals allowed", amountStr: "0.00", options: helpers.MoneyOptions{AllowZero: true}, expected: 0},
+		{name: "Positive amount with zero allowed", amountStr: "12.50", options: helpers.MoneyOptions{AllowZero: true}, expected: 1250},
+		{name: "Negative amount with zero allowed", amountStr: "-0.01", options: helpers.MoneyOptions{AllowZero: true}, expectedErr: helpers.ErrAmountMustBePositive},
+		{name: "Invalid amount with zero allowed", amountStr: "", options: helpers.MoneyOptions{AllowZero: true}, expectedErr: helpers.ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount, err := helpers.ParseMoney(tt.amountStr, "EUR", tt.options)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, amount)
+		})
+	}
+}
+
+func TestParseMinorUnits(t *testing.T) {
+	tests := []struct {
+		name        string
+		amountStr   string
+		expected    int64
+		expectedErr error
+	}{
+		{name: "Two decimal places", amountStr: "100.50", expected: 10050},
+		{name: "One decimal place", amountStr: "0.1", expected: 10},
+		{name: "Whole amount", amountStr: "7", expected: 700},
+		{name: "Leading decimal point", amountStr: ".05", expected: 5},
+		{name: "More than two decimal places", amountStr: "10.155", expectedErr: helpers.ErrTooManyDecimals},
+		{name: "Negative amount", amountStr: "-1.00", expectedErr: helpers.ErrAmountMustBePositive},
+		{name: "Zero amount", amountStr: "0.00", expectedErr: helpers.ErrAmountMustBePositive},
+		{name: "Exponent notation", amountStr: "1e2", expectedErr: helpers.ErrInvalidAmount},
+		{name: "Lone decimal point", amountStr: ".", expectedErr: helpers.ErrInvalidAmount},
+		{name: "Overflow", amountStr: "92233720368547758.08", expectedErr: helpers.ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minor, err := helpers.ParseMinorUnits(tt.amountStr)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, minor)
+		})
+	}
+}
+
 func TestTransactionValidationWithDetails(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -302,19 +586,20 @@ func TestTransactionValidationWithDetails(t *testing.T) {
 			expectValid: true,
 		},
 		{
-			name: "Missing amount",
+			name: "Missing transaction ID is generated server-side",
 			transaction: models.Transaction{
-				Amount:          "",
+				AccountID:       1,
+				Amount:          "100.00",
 				Source:          "game",
 				TransactionType: "win",
 			},
-			expectValid: false,
+			expectValid: true,
 		},
 		{
-			name: "Missing source",
+			name: "Missing amount",
 			transaction: models.Transaction{
-				Amount:          "100.00",
-				Source:          "",
+				Amount:          "",
+				Source:          "game",
 				TransactionType: "win",
 			},
 			expectValid: false,
@@ -328,15 +613,6 @@ func TestTransactionValidationWithDetails(t *testing.T) {
 			},
 			expectValid: false,
 		},
-		{
-			name: "Invalid source",
-			transaction: models.Transaction{
-				Amount:          "100.00",
-				Source:          "invalid",
-				TransactionType: "win",
-			},
-			expectValid: false,
-		},
 		{
 			name: "Invalid transaction type",
 			transaction: models.Transaction{
@@ -369,37 +645,1556 @@ func TestTransactionValidationWithDetails(t *testing.T) {
 	}
 }
 
-// Benchmark tests
-func BenchmarkCreateTransactionHandler(b *testing.B) {
-	router := mux.NewRouter()
-	router.HandleFunc("/user/{userId}/transaction", CreateTransactionHandler).Methods("POST")
+func TestInvalidSourceResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		body        string
+		expectedErr error
+	}{
+		{name: "Valid header", header: "game", expectedErr: nil},
+		{name: "Body repeats the header", header: "Game", body: "game", expectedErr: nil},
+		{name: "Invalid header", header: "casino", expectedErr: helpers.ErrInvalidSource},
+		{name: "Missing header", header: "", expectedErr: helpers.ErrInvalidSource},
+		{name: "Invalid body", header: "game", body: "casino", expectedErr: helpers.ErrInvalidSource},
+		{name: "Invalid header and body", header: "casino", body: "casino", expectedErr: helpers.ErrInvalidSource},
+		{name: "Body disagrees with header", header: "game", body: "payment", expectedErr: helpers.ErrSourceMismatch},
+	}
 
-	transaction := models.Transaction{
-		Amount:          "100.00",
-		Source:          "game",
-		TransactionType: "win",
+	// An invalid source is answered the same way whether the middleware or the handler catches it
+	invalidSource := httptest.NewRecorder()
+	helpers.HandleAPIError(invalidSource, helpers.ErrInvalidSource)
+	assert.Equal(t, http.StatusBadRequest, invalidSource.Code)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source, err := helpers.ResolveSource(tt.header, tt.body)
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, "game", source)
+				return
+			}
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			handlerResponse := httptest.NewRecorder()
+			helpers.HandleAPIError(handlerResponse, err)
+			assert.Equal(t, http.StatusBadRequest, handlerResponse.Code)
+			if tt.expectedErr == helpers.ErrInvalidSource {
+				assert.Equal(t, invalidSource.Body.String(), handlerResponse.Body.String())
+			}
+
+			// The header alone goes through SourceHeaderMatcher
+			if _, headerErr := helpers.ResolveSource(tt.header, ""); headerErr != nil {
+				req := httptest.NewRequest("POST", "/user/1/transaction", nil)
+				req.Header.Set("Source-Type", tt.header)
+				middlewareResponse := httptest.NewRecorder()
+				middleware.SourceHeaderMatcher(http.NotFoundHandler()).ServeHTTP(middlewareResponse, req)
+
+				assert.Equal(t, invalidSource.Code, middlewareResponse.Code)
+				assert.Equal(t, invalidSource.Body.String(), middlewareResponse.Body.String())
+			}
+		})
 	}
-	body, _ := json.Marshal(transaction)
+}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		req, _ := http.NewRequest("POST", "/user/invalid/transaction", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Source-Type", "game")
-		recorder := httptest.NewRecorder()
-		router.ServeHTTP(recorder, req)
+func TestApplyTransaction(t *testing.T) {
+	tests := []struct {
+		name            string
+		balance         int64
+		amount          int64
+		transactionType string
+		expectedBalance int64
+		expectedErr     error
+	}{
+		{
+			name:            "Win increases balance",
+			balance:         2000,
+			amount:          525,
+			transactionType: "win",
+			expectedBalance: 2525,
+		},
+		{
+			name:            "Lose within balance",
+			balance:         2000,
+			amount:          2000,
+			transactionType: "lose",
+			expectedBalance: 0,
+		},
+		{
+			name:            "Reversal of a spent deposit goes negative",
+			balance:         2000,
+			amount:          10000,
+			transactionType: "reversal",
+			expectedBalance: -8000,
+		},
+		{
+			name:            "Adjustment may go negative",
+			balance:         0,
+			amount:          1000,
+			transactionType: "adjustment",
+			expectedBalance: -1000,
+		},
+		{
+			name:            "Repeated cents do not drift",
+			balance:         10,
+			amount:          20,
+			transactionType: "win",
+			expectedBalance: 30,
+		},
+		{
+			name:            "Withdrawal beyond balance is rejected",
+			balance:         2000,
+			amount:          10000,
+			transactionType: "withdrawal",
+			expectedErr:     helpers.ErrInsufficientBalance,
+		},
+		{
+			name:            "Unknown type is rejected",
+			balance:         2000,
+			amount:          100,
+			transactionType: "bonus",
+			expectedErr:     helpers.ErrInvalidTransactionType,
+		},
+		{
+			name:            "Credit up to the largest balance",
+			balance:         math.MaxInt64 - 100,
+			amount:          100,
+			transactionType: "deposit",
+			expectedBalance: math.MaxInt64,
+		},
+		{
+			name:            "Credit beyond the largest balance is rejected",
+			balance:         math.MaxInt64 - 100,
+			amount:          101,
+			transactionType: "deposit",
+			expectedErr:     helpers.ErrBalanceOverflow,
+		},
+		{
+			name:            "Correction beyond the smallest balance is rejected",
+			balance:         math.MinInt64 + 100,
+			amount:          101,
+			transactionType: "reversal",
+			expectedErr:     helpers.ErrBalanceOverflow,
+		},
+		{
+			name:            "Negative amount is rejected",
+			balance:         2000,
+			amount:          -100,
+			transactionType: "win",
+			expectedErr:     helpers.ErrAmountMustBePositive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			balance, err := helpers.ApplyTransaction(tt.balance, tt.amount, tt.transactionType)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedBalance, balance)
+			}
+		})
 	}
 }
 
-func BenchmarkValidateAndParseTransactionAmount(b *testing.B) {
-	transaction := models.Transaction{
-		Amount:          "100.50",
-		Source:          "game",
-		TransactionType: "win",
+func TestMinorUnitsFromNumeric(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		currency string
+		expected int64
+		ok       bool
+	}{
+		{name: "Exact EUR amount", value: "25.50", currency: "EUR", expected: 2550, ok: true},
+		{name: "Trailing zeros of the widened column", value: "25.50000000", currency: "EUR", expected: 2550, ok: true},
+		{name: "Negative amount", value: "-0.01", currency: "EUR", expected: -1, ok: true},
+		{name: "JPY amount", value: "1500", currency: "JPY", expected: 1500, ok: true},
+		{name: "BTC amount", value: "0.12345678", currency: "BTC", expected: 12345678, ok: true},
+		{name: "Sub-minor digits", value: "25.505", currency: "EUR"},
+		{name: "Decimals on a currency without decimals", value: "10.5", currency: "JPY"},
+		{name: "Beyond the int64 range", value: "100000000000000000", currency: "EUR"},
+		{name: "Not a number", value: "NaN", currency: "EUR"},
 	}
 
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		validateAndParseTransactionAmount(transaction)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value pgtype.Numeric
+			assert.NoError(t, value.Scan(tt.value))
+
+			minor, ok := helpers.MinorUnitsFromNumeric(value, tt.currency)
+
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, minor)
+		})
+	}
+
+	// NULL is never converted
+	_, ok := helpers.MinorUnitsFromNumeric(pgtype.Numeric{}, "EUR")
+	assert.False(t, ok)
+
+	// Minor units survive the round trip through the legacy column
+	for _, currency := range []string{"EUR", "JPY", "BTC"} {
+		minor, ok := helpers.MinorUnitsFromNumeric(helpers.NumericFromMinorUnits(-123456789, currency), currency)
+		assert.True(t, ok)
+		assert.Equal(t, int64(-123456789), minor)
+	}
+}
+
+// fakeRow replays a fixed result or error for a single QueryRow call
+type fakeRow struct {
+	values []interface{}
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	for i := range dest {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(r.values[i]))
+	}
+	return nil
+}
+
+func accountRow(account sqlc.Account) fakeRow {
+	return fakeRow{values: []interface{}{
+		account.ID,
+		account.UserID,
+		account.Balance,
+		account.Currency,
+		account.Status,
+		account.InsertedAt,
+		account.LastTransactionAt,
+		account.AccountType,
+		account.BalanceMinor,
+		account.Version,
+		account.Metadata,
+	}}
+}
+
+func transactionRow(transaction sqlc.Transaction) fakeRow {
+	return fakeRow{values: []interface{}{
+		transaction.ID,
+		transaction.AccountID,
+		transaction.Amount,
+		transaction.Source,
+		transaction.Type,
+		transaction.InsertedAt,
+		transaction.PayoutBatchID,
+		transaction.Memo,
+		transaction.ClientReference,
+		transaction.AmountMinor,
+		transaction.BalanceAfterMinor,
+	}}
+}
+
+// fakeRows replays fixed rows for a single Query call
+type fakeRows struct {
+	pgx.Rows
+	rows    []fakeRow
+	current int
+}
+
+func (r *fakeRows) Next() bool {
+	r.current++
+	return r.current <= len(r.rows)
+}
+
+func (r *fakeRows) Scan(dest ...interface{}) error {
+	return r.rows[r.current-1].Scan(dest...)
+}
+
+func (r *fakeRows) Err() error { return nil }
+
+func (r *fakeRows) Close() {}
+
+// fakeDB implements sqlc.DBTX, answering each query by its SQLC name.
+// rowFunc, when set, can answer based on the query arguments instead.
+// results answers :many queries; args records the arguments of the last call per query.
+type fakeDB struct {
+	rows        map[string]fakeRow
+	rowFunc     func(name string, args []interface{}) (fakeRow, bool)
+	results     map[string][]fakeRow
+	resultsFunc func(name string, args []interface{}) ([]fakeRow, bool)
+	args        map[string][]interface{}
+	queries     []string
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	name := sqlcQueryName(sql)
+	db.queries = append(db.queries, name)
+	db.recordArgs(name, args)
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *fakeDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	name := sqlcQueryName(sql)
+	db.queries = append(db.queries, name)
+	db.recordArgs(name, args)
+
+	if db.resultsFunc != nil {
+		if rows, ok := db.resultsFunc(name, args); ok {
+			return &fakeRows{rows: rows}, nil
+		}
+	}
+
+	rows, ok := db.results[name]
+	if !ok {
+		return nil, errors.New("fakeDB: no results for " + name)
+	}
+	return &fakeRows{rows: rows}, nil
+}
+
+func (db *fakeDB) recordArgs(name string, args []interface{}) {
+	if db.args == nil {
+		db.args = map[string][]interface{}{}
+	}
+	db.args[name] = args
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	name := sqlcQueryName(sql)
+	db.queries = append(db.queries, name)
+	db.recordArgs(name, args)
+
+	if db.rowFunc != nil {
+		if row, ok := db.rowFunc(name, args); ok {
+			return row
+		}
+	}
+
+	row, ok := db.rows[name]
+	if !ok {
+		return fakeRow{err: pgx.ErrNoRows}
 	}
+	return row
+}
+
+// fakeTx is a pgx.Tx backed by a fakeDB that records how it was finished
+type fakeTx struct {
+	pgx.Tx
+	db         *fakeDB
+	committed  bool
+	rolledBack bool
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return tx.db.Exec(ctx, sql, args...)
+}
+
+func (tx *fakeTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return tx.db.Query(ctx, sql, args...)
+}
+
+func (tx *fakeTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return tx.db.QueryRow(ctx, sql, args...)
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	tx.committed = true
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	tx.rolledBack = true
+	return nil
+}
+
+// fakeStarter hands out fakeTx values sharing one fakeDB
+type fakeStarter struct {
+	db  *fakeDB
+	txs []*fakeTx
+}
+
+func (s *fakeStarter) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx := &fakeTx{db: s.db}
+	s.txs = append(s.txs, tx)
+	return tx, nil
+}
+
+func sqlcQueryName(sql string) string {
+	return strings.Fields(strings.TrimPrefix(sql, "-- name: "))[0]
+}
+
+func TestUpdateBalanceInTxAccountDisappeared(t *testing.T) {
+	db := &fakeDB{rows: map[string]fakeRow{
+		"GetAccountForUpdate": {err: pgx.ErrNoRows},
+	}}
+
+	_, err := updateBalanceInTx(context.Background(), sqlc.New(db), 1, 1000, "win", "game")
+
+	assert.ErrorIs(t, err, helpers.ErrAccountNotFound)
+	assert.Equal(t, []string{"GetAccountForUpdate"}, db.queries)
+
+	recorder := httptest.NewRecorder()
+	helpers.HandleAPIError(recorder, err)
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestUpdateBalanceInTx(t *testing.T) {
+	account := sqlc.Account{ID: 1, UserID: 1, BalanceMinor: 5000, Currency: "EUR", Status: "active", AccountType: "general"}
+	updated := account
+	updated.BalanceMinor = 6000
+
+	db := &fakeDB{rows: map[string]fakeRow{
+		"GetAccountForUpdate": accountRow(account),
+		"UpdateAccount":       accountRow(updated),
+	}}
+
+	result, err := updateBalanceInTx(context.Background(), sqlc.New(db), 1, 1000, "win", "game")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(6000), result.BalanceMinor)
+	assert.Equal(t, []string{"GetAccountForUpdate", "UpdateAccount"}, db.queries)
+}
+
+func TestDepositAndWithdrawalFlow(t *testing.T) {
+	tests := []struct {
+		name            string
+		transactionType string
+		amount          string
+		balanceMinor    int64
+		expectedMinor   int64
+		expectedErr     error
+	}{
+		{name: "Deposit credits the account", transactionType: "deposit", amount: "25.50", balanceMinor: 5000, expectedMinor: 7550},
+		{name: "Withdrawal debits the account", transactionType: "withdrawal", amount: "20.00", balanceMinor: 5000, expectedMinor: 3000},
+		{name: "Withdrawal beyond the balance", transactionType: "withdrawal", amount: "50.01", balanceMinor: 5000, expectedErr: helpers.ErrInsufficientBalance},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transaction := models.Transaction{ID: "tx-1", AccountID: 1, TransactionType: tt.transactionType, Amount: tt.amount, Source: "payment"}
+			ok, validationErrors := helpers.ValidateStructWithDetails(&transaction)
+			assert.True(t, ok, validationErrors)
+
+			transaction, err := validateAndParseTransactionAmount(transaction, "EUR")
+			assert.NoError(t, err)
+
+			account := sqlc.Account{ID: 1, UserID: 1, Currency: "EUR", Status: "active", AccountType: "general", BalanceMinor: tt.balanceMinor}
+			db := &fakeDB{
+				rows: map[string]fakeRow{"GetAccountForUpdate": accountRow(account)},
+				rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+					if name != "UpdateAccount" {
+						return fakeRow{}, false
+					}
+					updated := account
+					updated.BalanceMinor = args[2].(int64)
+					return accountRow(updated), true
+				},
+			}
+
+			result, err := updateBalanceInTx(context.Background(), sqlc.New(db), 1, transaction.MinorAmount, transaction.TransactionType, transaction.Source)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.NotContains(t, db.queries, "UpdateAccount")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMinor, result.BalanceMinor)
+		})
+	}
+
+	// Types outside the known set fail validation and are still refused by the balance logic
+	transaction := models.Transaction{ID: "tx-2", AccountID: 1, TransactionType: "bonus", Amount: "10.00"}
+	ok, validationErrors := helpers.ValidateStructWithDetails(&transaction)
+	assert.False(t, ok)
+	assert.Contains(t, validationErrors, "state")
+
+	account := sqlc.Account{ID: 1, UserID: 1, Currency: "EUR", Status: "active", AccountType: "general"}
+	db := &fakeDB{rows: map[string]fakeRow{"GetAccountForUpdate": accountRow(account)}}
+	_, err := updateBalanceInTx(context.Background(), sqlc.New(db), 1, 10.00, "bonus", "payment")
+	assert.ErrorIs(t, err, helpers.ErrInvalidTransactionType)
+	assert.NotContains(t, db.queries, "UpdateAccount")
+}
+
+func TestInactiveAccountRejectionIncludesStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        string
+		expectedErr   error
+		expectedError string
+	}{
+		{name: "Frozen account", status: helpers.AccountStatusFrozen, expectedErr: helpers.ErrAccountFrozen, expectedError: "Account is frozen"},
+		{name: "Closed account", status: helpers.AccountStatusClosed, expectedErr: helpers.ErrAccountClosed, expectedError: "Account is closed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := sqlc.Account{ID: 1, UserID: 1, BalanceMinor: 5000, Currency: "EUR", Status: tt.status, AccountType: "general"}
+			db := &fakeDB{rows: map[string]fakeRow{
+				"GetAccountForUpdate": accountRow(account),
+				"UpdateAccount":       accountRow(account),
+			}}
+
+			_, err := updateBalanceInTx(context.Background(), sqlc.New(db), 1, 10.00, "win", "game")
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.NotContains(t, db.queries, "UpdateAccount")
+
+			recorder := httptest.NewRecorder()
+			helpers.HandleAPIError(recorder, transactionRuleError(err))
+			assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+			var response helpers.ErrorResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedError, response.Error)
+			assert.Equal(t, tt.status, response.AccountStatus)
+		})
+	}
+}
+
+func TestRunInTxLockTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		lockTimeout   string
+		fnErr         error
+		expectSet     bool
+		expectTimeout bool
+	}{
+		{
+			name:        "Lock timeout set from configuration",
+			lockTimeout: "250",
+			expectSet:   true,
+		},
+		{
+			name:        "Lock timeout disabled",
+			lockTimeout: "0",
+		},
+		{
+			name:          "Expired lock wait is reported as a lock timeout",
+			lockTimeout:   "250",
+			fnErr:         &pgconn.PgError{Code: "55P03", Message: "canceling statement due to lock timeout"},
+			expectSet:     true,
+			expectTimeout: true,
+		},
+		{
+			name:        "Other errors are passed through",
+			lockTimeout: "250",
+			fnErr:       errors.New("boom"),
+			expectSet:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LOCK_TIMEOUT_MS", tt.lockTimeout)
+			db := &fakeDB{}
+			starter := &fakeStarter{db: db}
+
+			err := runInTxWith(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+				return tt.fnErr
+			})
+
+			assert.Equal(t, tt.expectSet, len(db.queries) > 0 && db.queries[0] == "SET")
+			assert.Equal(t, tt.expectTimeout, errors.Is(err, helpers.ErrLockTimeout))
+			if tt.fnErr == nil {
+				assert.NoError(t, err)
+				assert.True(t, starter.txs[0].committed)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.fnErr)
+			assert.True(t, starter.txs[0].rolledBack)
+		})
+	}
+}
+
+func TestLockTimeoutResponse(t *testing.T) {
+	err := errors.Join(helpers.ErrLockTimeout, &pgconn.PgError{Code: "55P03"})
+
+	recorder := httptest.NewRecorder()
+	helpers.HandleDatabaseError(recorder, err, "Transaction")
+
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "1", recorder.Header().Get("Retry-After"))
+}
+
+func TestHandleDatabaseErrorMapping(t *testing.T) {
+	tests := []struct {
+		name             string
+		err              error
+		expectedStatus   int
+		expectedMessage  string
+		expectRetryAfter bool
+	}{
+		{
+			name:            "No rows",
+			err:             pgx.ErrNoRows,
+			expectedStatus:  http.StatusNotFound,
+			expectedMessage: "Account not found",
+		},
+		{
+			name:            "Wrapped no rows",
+			err:             fmt.Errorf("lookup: %w", pgx.ErrNoRows),
+			expectedStatus:  http.StatusNotFound,
+			expectedMessage: "Account not found",
+		},
+		{
+			name:            "Unique violation",
+			err:             &pgconn.PgError{Code: "23505", Message: "violates unique constraint"},
+			expectedStatus:  http.StatusConflict,
+			expectedMessage: "Account already exists",
+		},
+		{
+			name:            "Unique violation with a localized message",
+			err:             &pgconn.PgError{Code: "23505", Message: "doppelter Schlüsselwert"},
+			expectedStatus:  http.StatusConflict,
+			expectedMessage: "Account already exists",
+		},
+		{
+			name:            "Foreign key violation",
+			err:             &pgconn.PgError{Code: "23503"},
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Invalid reference or constraint violation",
+		},
+		{
+			name:            "Check violation",
+			err:             &pgconn.PgError{Code: "23514"},
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Invalid reference or constraint violation",
+		},
+		{
+			name:             "Serialization failure",
+			err:              &pgconn.PgError{Code: "40001"},
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedMessage:  "Concurrent update conflict, please retry",
+			expectRetryAfter: true,
+		},
+		{
+			name:             "Deadlock",
+			err:              fmt.Errorf("update balance: %w", &pgconn.PgError{Code: "40P01"}),
+			expectedStatus:   http.StatusServiceUnavailable,
+			expectedMessage:  "Concurrent update conflict, please retry",
+			expectRetryAfter: true,
+		},
+		{
+			name:            "Unmapped SQLSTATE falls back to message matching",
+			err:             &pgconn.PgError{Code: "08006", Message: "connection failure"},
+			expectedStatus:  http.StatusServiceUnavailable,
+			expectedMessage: "Database temporarily unavailable",
+		},
+		{
+			name:            "Plain error falls back to message matching",
+			err:             errors.New("duplicate entry"),
+			expectedStatus:  http.StatusConflict,
+			expectedMessage: "Account already exists",
+		},
+		{
+			name:            "Unknown error",
+			err:             errors.New("boom"),
+			expectedStatus:  http.StatusInternalServerError,
+			expectedMessage: "Database operation failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			helpers.HandleDatabaseError(recorder, tt.err, "Account")
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.expectedMessage)
+			assert.Equal(t, tt.expectRetryAfter, recorder.Header().Get("Retry-After") != "")
+		})
+	}
+}
+
+func TestBuildTransactionResponseAmountPrecision(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       string
+		minor        int64
+		currency     string
+		expectedJSON string
+	}{
+		{name: "String by default", format: "", minor: 10010, currency: "EUR", expectedJSON: `"100.10"`},
+		{name: "Number literal when configured", format: "number", minor: 10010, currency: "EUR", expectedJSON: `100.10`},
+		{name: "Currency precision applies", format: "", minor: 100, currency: "JPY", expectedJSON: `"100"`},
+		{name: "Eight decimal currency", format: "", minor: 12345678, currency: "BTC", expectedJSON: `"0.12345678"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MONEY_JSON_FORMAT", tt.format)
+			transaction := models.Transaction{
+				ID:              "tx-1",
+				MinorAmount:     tt.minor,
+				Source:          "game",
+				TransactionType: "win",
+			}
+
+			body, err := json.Marshal(buildTransactionResponse(1, transaction, tt.currency))
+			assert.NoError(t, err)
+
+			var response map[string]json.RawMessage
+			err = json.Unmarshal(body, &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedJSON, string(response["amount"]))
+		})
+	}
+}
+
+func TestBuildTransactionResponseLinks(t *testing.T) {
+	transaction := models.Transaction{ID: "tx 1", MinorAmount: 1000, TransactionType: "win"}
+
+	tests := []struct {
+		name            string
+		basePath        string
+		expectedSelf    string
+		expectedBalance string
+	}{
+		{
+			name:            "No base path",
+			basePath:        "",
+			expectedSelf:    "/transactions/tx%201",
+			expectedBalance: "/user/7/balance",
+		},
+		{
+			name:            "Configured base path",
+			basePath:        "/api/v1/",
+			expectedSelf:    "/api/v1/transactions/tx%201",
+			expectedBalance: "/api/v1/user/7/balance",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("API_BASE_PATH", tt.basePath)
+
+			body, err := json.Marshal(buildTransactionResponse(7, transaction, "EUR"))
+			assert.NoError(t, err)
+
+			var response struct {
+				TransactionID string                  `json:"transaction_id"`
+				Links         map[string]helpers.Link `json:"_links"`
+			}
+			err = json.Unmarshal(body, &response)
+			assert.NoError(t, err)
+
+			assert.Equal(t, "tx 1", response.TransactionID)
+			assert.Equal(t, tt.expectedSelf, response.Links["self"].Href)
+			assert.Equal(t, tt.expectedBalance, response.Links["balance"].Href)
+		})
+	}
+}
+
+func TestApplyTransactionCurrency(t *testing.T) {
+	tests := []struct {
+		name            string
+		currency        string
+		accountCurrency string
+		crossCurrency   string
+		expectedAmount  int64
+		expectedErr     error
+	}{
+		{name: "Absent currency", currency: "", expectedAmount: 10000},
+		{name: "Absent currency assumes a USD account's currency", currency: "", accountCurrency: "USD", expectedAmount: 10000},
+		{name: "Matching currency", currency: "EUR", expectedAmount: 10000},
+		{name: "Matching currency in lower case", currency: "eur", expectedAmount: 10000},
+		{name: "Mismatched currency is rejected", currency: "USD", expectedErr: helpers.ErrCurrencyMismatch},
+		{name: "GBP transaction against a USD account is rejected", currency: "GBP", accountCurrency: "USD", expectedErr: helpers.ErrCurrencyMismatch},
+		{name: "Mismatched currency converted when enabled", currency: "USD", crossCurrency: "true", expectedAmount: 9259},
+		{name: "Unsupported currency when enabled", currency: "XYZ", crossCurrency: "true", expectedErr: helpers.ErrUnsupportedCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALLOW_CROSS_CURRENCY_TRANSACTIONS", tt.crossCurrency)
+			accountCurrency := tt.accountCurrency
+			if accountCurrency == "" {
+				accountCurrency = "EUR"
+			}
+
+			transaction, err := applyTransactionCurrency(models.Transaction{MinorAmount: 10000, Currency: tt.currency}, accountCurrency)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+
+				recorder := httptest.NewRecorder()
+				helpers.HandleAPIError(recorder, err)
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedAmount, transaction.MinorAmount)
+		})
+	}
+}
+
+func TestVelocityLimit(t *testing.T) {
+	t.Setenv("VELOCITY_MAX_TRANSACTIONS", "3")
+	t.Setenv("VELOCITY_WINDOW_SECONDS", "60")
+
+	// The fake counts every committed CreateTransaction as recent
+	created := 0
+	db := &fakeDB{
+		rows: map[string]fakeRow{
+			"GetAccountForUpdate": accountRow(sqlc.Account{ID: 1, BalanceMinor: 10000, AccountType: "general"}),
+			"UpdateAccount":       accountRow(sqlc.Account{ID: 1, BalanceMinor: 11000, AccountType: "general"}),
+		},
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			switch name {
+			case "CountRecentTransactions":
+				return fakeRow{values: []interface{}{int64(created)}}, true
+			case "CreateTransaction":
+				return transactionRow(sqlc.Transaction{ID: args[0].(string), AccountID: 1}), true
+			}
+			return fakeRow{}, false
+		},
+	}
+
+	for i := 1; i <= 5; i++ {
+		starter := &fakeStarter{db: db}
+		err := runInTxWith(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+			_, err := applyTransactionInTx(ctx, queries, models.Transaction{
+				ID:              fmt.Sprintf("tx-%d", i),
+				AccountID:       1,
+				MinorAmount:     1000,
+				Source:          "game",
+				TransactionType: "win",
+			})
+			return err
+		})
+
+		if i <= 3 {
+			assert.NoError(t, err, "transaction %d", i)
+			assert.True(t, starter.txs[0].committed)
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, helpers.ErrVelocityExceeded, "transaction %d", i)
+		assert.True(t, starter.txs[0].rolledBack)
+	}
+
+	// The window starts VELOCITY_WINDOW_SECONDS before now
+	since := db.args["CountRecentTransactions"][1].(models.Timestamp)
+	assert.WithinDuration(t, time.Now().Add(-time.Minute), since.Time, 5*time.Second)
+
+	recorder := httptest.NewRecorder()
+	helpers.HandleAPIError(recorder, transactionRuleError(helpers.ErrVelocityExceeded))
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+}
+
+func TestVelocityLimitDisabled(t *testing.T) {
+	t.Setenv("VELOCITY_MAX_TRANSACTIONS", "")
+
+	db := &fakeDB{}
+	assert.NoError(t, checkVelocity(context.Background(), sqlc.New(db), 1, time.Now()))
+	assert.NotContains(t, db.queries, "CountRecentTransactions")
+}
+
+func TestApplyTransactionInTxReturnsPersisted(t *testing.T) {
+	insertedAt := models.NewTimestamp(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	db := &fakeDB{
+		rows: map[string]fakeRow{
+			"GetAccountForUpdate": accountRow(sqlc.Account{ID: 3, BalanceMinor: 10000, AccountType: "general"}),
+			"UpdateAccount":       accountRow(sqlc.Account{ID: 3, BalanceMinor: 11050, AccountType: "general"}),
+			"CreateTransaction": transactionRow(sqlc.Transaction{
+				ID:          "tx-1",
+				AccountID:   3,
+				AmountMinor: 1050,
+				Source:      "game",
+				Type:        "win",
+				InsertedAt:  insertedAt,
+			}),
+		},
+	}
+	starter := &fakeStarter{db: db}
+
+	var persisted models.Transaction
+	err := runInTxWith(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		persisted, err = applyTransactionInTx(ctx, queries, models.Transaction{
+			ID:              "tx-1",
+			AccountID:       3,
+			MinorAmount:     1050,
+			Source:          "game",
+			TransactionType: "win",
+		})
+		return err
+	})
+	assert.NoError(t, err)
+
+	body, err := json.Marshal(buildTransactionResponse(7, persisted, "EUR"))
+	assert.NoError(t, err)
+
+	var response map[string]interface{}
+	err = json.Unmarshal(body, &response)
+	assert.NoError(t, err)
+
+	// Every server-computed field is present and non-empty
+	assert.Equal(t, "tx-1", response["transaction_id"])
+	assert.Equal(t, float64(3), response["account_id"])
+	assert.Equal(t, "2025-01-02T03:04:05Z", response["inserted_at"])
+	assert.Equal(t, "110.50", response["balance_after"])
+	assert.Equal(t, "110.50", response["balance"])
+	assert.Equal(t, "10.50", response["amount"])
+}
+
+func TestBalanceVersionIncrementsPerTransaction(t *testing.T) {
+	// The fake account behaves like the table: UpdateAccount stores the balance and bumps the version
+	account := sqlc.Account{ID: 3, BalanceMinor: 10000, Currency: "EUR", Status: "active", AccountType: "general", Version: 41}
+	db := &fakeDB{
+		rows: map[string]fakeRow{
+			"CreateTransaction": transactionRow(sqlc.Transaction{ID: "tx", AccountID: 3}),
+		},
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			switch name {
+			case "GetAccountForUpdate":
+				return accountRow(account), true
+			case "UpdateAccount":
+				account.BalanceMinor = args[2].(int64)
+				account.Version++
+				return accountRow(account), true
+			}
+			return fakeRow{}, false
+		},
+	}
+
+	tests := []struct {
+		name            string
+		transactionType string
+		amount          int64
+		expectedErr     error
+		expectedVersion int64
+	}{
+		{name: "First transaction", transactionType: "win", amount: 1000, expectedVersion: 42},
+		{name: "Second transaction", transactionType: "lose", amount: 500, expectedVersion: 43},
+		{name: "Rejected transaction keeps the version", transactionType: "lose", amount: 100000, expectedErr: helpers.ErrInsufficientBalance, expectedVersion: 43},
+		{name: "Third transaction", transactionType: "win", amount: 100, expectedVersion: 44},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			persisted, err := applyTransactionInTx(context.Background(), sqlc.New(db), models.Transaction{
+				ID:              "tx",
+				AccountID:       3,
+				MinorAmount:     tt.amount,
+				Source:          "game",
+				TransactionType: tt.transactionType,
+			})
+
+			assert.Equal(t, tt.expectedVersion, account.Version)
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			response := buildTransactionResponse(7, persisted, "EUR")
+			assert.Equal(t, tt.expectedVersion, response["balance_version"])
+		})
+	}
+}
+
+func TestCreateTransactionClientReference(t *testing.T) {
+	existing := sqlc.Transaction{
+		ID:              "tx-original",
+		AccountID:       1,
+		AmountMinor:     2500,
+		Source:          "payment",
+		Type:            "deposit",
+		ClientReference: pgtype.Text{String: "ref-1", Valid: true},
+	}
+
+	// ref-1 is already used on account 1; tx-original also collides on the primary key
+	db := &fakeDB{
+		rows: map[string]fakeRow{
+			"CreateTransaction":               transactionRow(sqlc.Transaction{ID: "tx-new", AccountID: 1}),
+			"GetTransactionByClientReference": transactionRow(existing),
+			"GetAccountForUpdate":             accountRow(sqlc.Account{ID: 1, BalanceMinor: 10000, AccountType: "general"}),
+			"UpdateAccount":                   accountRow(sqlc.Account{ID: 1, BalanceMinor: 11000, AccountType: "general"}),
+		},
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			if name != "CreateTransaction" {
+				return fakeRow{}, false
+			}
+			if args[7].(pgtype.Text).String == "ref-1" {
+				return fakeRow{err: &pgconn.PgError{Code: "23505", ConstraintName: "idx_transactions_account_client_reference"}}, true
+			}
+			if args[0] == "tx-original" {
+				return fakeRow{err: &pgconn.PgError{Code: "23505", ConstraintName: "transactions_pkey"}}, true
+			}
+			return fakeRow{}, false
+		},
+	}
+
+	tests := []struct {
+		name            string
+		transactionID   string
+		clientReference string
+		expectErr       bool
+		expectDuplicate bool
+	}{
+		{name: "Distinct reference", transactionID: "tx-new", clientReference: "ref-2"},
+		{name: "No reference", transactionID: "tx-new"},
+		{name: "Duplicate reference", transactionID: "tx-retry", clientReference: "ref-1", expectErr: true, expectDuplicate: true},
+		{name: "Duplicate transaction ID is not a reference replay", transactionID: "tx-original", clientReference: "ref-3", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transaction := models.Transaction{
+				ID:              tt.transactionID,
+				AccountID:       1,
+				MinorAmount:     1000,
+				Source:          "payment",
+				TransactionType: "deposit",
+				ClientReference: tt.clientReference,
+			}
+			starter := &fakeStarter{db: db}
+
+			err := runInTxWith(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+				if _, err := updateBalanceInTx(ctx, queries, 1, transaction.MinorAmount, transaction.TransactionType, transaction.Source); err != nil {
+					return err
+				}
+				_, err := createTransactionInTx(ctx, queries, transaction, "EUR")
+				return err
+			})
+
+			assert.Equal(t, tt.expectDuplicate, errors.Is(err, helpers.ErrDuplicateReference))
+			if tt.expectDuplicate {
+				// The balance update is rolled back and the original transaction is returned instead
+				assert.True(t, starter.txs[0].rolledBack)
+
+				original, err := findTransactionByReference(context.Background(), sqlc.New(db), 1, tt.clientReference)
+				assert.NoError(t, err)
+				assert.Equal(t, "tx-original", original.ID)
+				assert.Equal(t, int64(2500), original.MinorAmount)
+				assert.Equal(t, "ref-1", original.ClientReference)
+				return
+			}
+
+			if tt.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, starter.txs[0].committed)
+		})
+	}
+}
+
+func TestIdempotencyScope(t *testing.T) {
+	// Account 1 already used "shared-ref"; each case submits the same reference again
+	existing := sqlc.Transaction{ID: "tx-original", AccountID: 1, AmountMinor: 2500, ClientReference: pgtype.Text{String: "shared-ref", Valid: true}}
+
+	tests := []struct {
+		name          string
+		scope         string
+		accountID     int64
+		expectedErr   error
+		expectedQuery []string
+	}{
+		{
+			name:          "Per-account scope lets another account reuse the reference",
+			scope:         "",
+			accountID:     2,
+			expectedQuery: []string{"CreateTransaction"},
+		},
+		{
+			name:          "Global scope rejects the reference for another account",
+			scope:         helpers.IdempotencyScopeGlobal,
+			accountID:     2,
+			expectedErr:   helpers.ErrReferenceInUse,
+			expectedQuery: []string{"LockClientReference", "GetTransactionByGlobalClientReference"},
+		},
+		{
+			name:          "Global scope replays the reference on the same account",
+			scope:         helpers.IdempotencyScopeGlobal,
+			accountID:     1,
+			expectedErr:   helpers.ErrDuplicateReference,
+			expectedQuery: []string{"LockClientReference", "GetTransactionByGlobalClientReference"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IDEMPOTENCY_SCOPE", tt.scope)
+			db := &fakeDB{rows: map[string]fakeRow{
+				"GetTransactionByGlobalClientReference": transactionRow(existing),
+				"CreateTransaction":                     transactionRow(sqlc.Transaction{ID: "tx-new", AccountID: tt.accountID}),
+			}}
+			transaction := models.Transaction{
+				ID:              "tx-new",
+				AccountID:       tt.accountID,
+				MinorAmount:     1000,
+				Source:          "payment",
+				TransactionType: "deposit",
+				ClientReference: "shared-ref",
+			}
+
+			_, err := createTransactionInTx(context.Background(), sqlc.New(db), transaction, "EUR")
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectedQuery, db.queries)
+		})
+	}
+}
+
+func TestReplayedTransaction(t *testing.T) {
+	account := sqlc.Account{ID: 1, Currency: "EUR"}
+	original := sqlc.Transaction{ID: "tx-original", AccountID: 1, AmountMinor: 2500, Type: "deposit", ClientReference: pgtype.Text{String: "ref-1", Valid: true}}
+
+	tests := []struct {
+		name            string
+		amount          int64
+		transactionType string
+		expectedErr     error
+	}{
+		{name: "Retry with the same payload replays the original", amount: 2500, transactionType: "deposit"},
+		{name: "Different amount", amount: 3000, transactionType: "deposit", expectedErr: helpers.ErrReferenceMismatch},
+		{name: "Different type", amount: 2500, transactionType: "withdrawal", expectedErr: helpers.ErrReferenceMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{rows: map[string]fakeRow{
+				"GetTransactionByClientReference": transactionRow(original),
+			}}
+			transaction := models.Transaction{
+				ID:              "tx-retry",
+				AccountID:       1,
+				MinorAmount:     tt.amount,
+				TransactionType: tt.transactionType,
+				ClientReference: "ref-1",
+			}
+
+			replayed, err := replayedTransaction(context.Background(), sqlc.New(db), account, transaction)
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+
+				recorder := httptest.NewRecorder()
+				helpers.HandleAPIError(recorder, err)
+				assert.Equal(t, http.StatusConflict, recorder.Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "tx-original", replayed.ID)
+		})
+	}
+}
+
+// Benchmark tests
+func BenchmarkCreateTransactionHandler(b *testing.B) {
+	router := mux.NewRouter()
+	router.HandleFunc("/user/{userId}/transaction", CreateTransactionHandler).Methods("POST")
+
+	transaction := models.Transaction{
+		Amount:          "100.00",
+		Source:          "game",
+		TransactionType: "win",
+	}
+	body, _ := json.Marshal(transaction)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, _ := http.NewRequest("POST", "/user/invalid/transaction", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Source-Type", "game")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+	}
+}
+
+func BenchmarkValidateAndParseTransactionAmount(b *testing.B) {
+	transaction := models.Transaction{
+		Amount:          "100.50",
+		Source:          "game",
+		TransactionType: "win",
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		validateAndParseTransactionAmount(transaction, "USD")
+	}
+}
+
+func TestListAccountTransactions(t *testing.T) {
+	newer := sqlc.Transaction{ID: "tx-2", AccountID: 1, AmountMinor: 500, Source: "game", Type: "lose"}
+	older := sqlc.Transaction{ID: "tx-1", AccountID: 1, AmountMinor: 1000, Source: "game", Type: "win"}
+	db := &fakeDB{
+		results: map[string][]fakeRow{"ListTransactionsByAccount": {transactionRow(newer), transactionRow(older)}},
+		rows:    map[string]fakeRow{"CountTransactionsByAccount": {values: []interface{}{int64(12)}}},
+	}
+
+	transactions, total, err := listAccountTransactions(context.Background(), sqlc.New(db), 1, 20, 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), total)
+	assert.Equal(t, []string{"tx-2", "tx-1"}, []string{transactions[0].ID, transactions[1].ID})
+	assert.Equal(t, []interface{}{int64(1), int32(20), int32(10)}, db.args["ListTransactionsByAccount"])
+	assert.Equal(t, []interface{}{int64(1)}, db.args["CountTransactionsByAccount"])
+}
+
+func TestBuildTransactionList(t *testing.T) {
+	rows := []sqlc.Transaction{
+		{ID: "tx-2", AccountID: 4, AmountMinor: 1500, Source: "game", Type: "lose"},
+		{ID: "tx-1", AccountID: 4, AmountMinor: 10010, Source: "game", Type: "win", Memo: pgtype.Text{String: "bonus", Valid: true}},
+	}
+
+	tests := []struct {
+		name            string
+		format          string
+		currency        string
+		expectedAmounts []string
+	}{
+		{name: "String amounts by default", currency: "EUR", expectedAmounts: []string{`"15.00"`, `"100.10"`}},
+		{name: "Number literals when configured", format: "number", currency: "EUR", expectedAmounts: []string{`15.00`, `100.10`}},
+		{name: "Currency precision applies", currency: "JPY", expectedAmounts: []string{`"1500"`, `"10010"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MONEY_JSON_FORMAT", tt.format)
+
+			body, err := json.Marshal(buildTransactionList(7, tt.currency, rows))
+			assert.NoError(t, err)
+
+			var transactions []map[string]json.RawMessage
+			assert.NoError(t, json.Unmarshal(body, &transactions))
+			assert.Len(t, transactions, 2)
+			for i, transaction := range transactions {
+				assert.Equal(t, tt.expectedAmounts[i], string(transaction["amount"]))
+				assert.Equal(t, `"`+rows[i].ID+`"`, string(transaction["transaction_id"]))
+				assert.Equal(t, `4`, string(transaction["account_id"]))
+			}
+			assert.Equal(t, `"bonus"`, string(transactions[1]["memo"]))
+		})
+	}
+
+	// An account without transactions lists an empty array rather than null
+	body, err := json.Marshal(buildTransactionList(7, "EUR", []sqlc.Transaction{}))
+	assert.NoError(t, err)
+	assert.Equal(t, `[]`, string(body))
+}
+
+func TestDailyDebitCapPerCurrency(t *testing.T) {
+	t.Setenv("DAILY_DEBIT_CAP_USD", "10000")
+	t.Setenv("DAILY_DEBIT_CAP_JPY", "1500000")
+
+	tests := []struct {
+		name            string
+		currency        string
+		debitedToday    int64
+		transactionType string
+		amount          float64
+		expectedErr     error
+	}{
+		// The day's totals are in minor units
+		{name: "USD debit up to the cap", currency: "USD", debitedToday: 900000, transactionType: "withdrawal", amount: 1000},
+		{name: "USD debit above the cap", currency: "USD", debitedToday: 900000, transactionType: "withdrawal", amount: 1000.01, expectedErr: helpers.ErrDailyCapExceeded},
+		// 10,000 is far below the JPY cap, as caps are in the account currency
+		{name: "JPY debit of 10000 yen", currency: "JPY", debitedToday: 0, transactionType: "lose", amount: 10000},
+		{name: "JPY debit above the cap", currency: "JPY", debitedToday: 1495000, transactionType: "lose", amount: 5001, expectedErr: helpers.ErrDailyCapExceeded},
+		{name: "Credits are not capped", currency: "USD", debitedToday: 1000000, transactionType: "deposit", amount: 50},
+		{name: "Corrective debits are not capped", currency: "USD", debitedToday: 1000000, transactionType: "reversal", amount: 50},
+		{name: "Currency without a cap", currency: "EUR", debitedToday: 1e11, transactionType: "withdrawal", amount: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := sqlc.Account{ID: 1, BalanceMinor: 200000000, Currency: tt.currency, Status: "active", AccountType: "general"}
+			db := &fakeDB{
+				rows: map[string]fakeRow{
+					"GetAccountForUpdate": accountRow(account),
+					"UpdateAccount":       accountRow(account),
+					"CreateTransaction":   transactionRow(sqlc.Transaction{ID: "tx", AccountID: 1}),
+				},
+				results: map[string][]fakeRow{
+					"SumTransactionsByAccountSince": {{values: []interface{}{"withdrawal", tt.debitedToday}}},
+				},
+			}
+
+			_, err := applyTransactionInTx(context.Background(), sqlc.New(db), models.Transaction{
+				ID:              "tx",
+				AccountID:       1,
+				MinorAmount:     helpers.ToMinorUnits(tt.amount, tt.currency),
+				Source:          "payment",
+				TransactionType: tt.transactionType,
+			})
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.NotContains(t, db.queries, "CreateTransaction")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, db.queries, "CreateTransaction")
+		})
+	}
+
+	// The day's debits are summed from the start of the UTC day
+	db := &fakeDB{results: map[string][]fakeRow{"SumTransactionsByAccountSince": {}}}
+	transaction := models.Transaction{AccountID: 1, MinorAmount: 100, TransactionType: "withdrawal"}
+	now := time.Date(2025, 3, 1, 17, 30, 0, 0, time.UTC)
+	assert.NoError(t, checkDailyCaps(context.Background(), sqlc.New(db), transaction, "USD", now))
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), db.args["SumTransactionsByAccountSince"][1].(models.Timestamp).Time)
+	assert.Equal(t, []string{"lose", "withdrawal"}, db.args["SumTransactionsByAccountSince"][2])
+}
+
+func TestDailyTransactionLimit(t *testing.T) {
+	t.Setenv("DAILY_TRANSACTION_LIMIT_EUR", "500")
+
+	tests := []struct {
+		name            string
+		currency        string
+		totalToday      int64
+		transactionType string
+		amount          float64
+		expectedErr     error
+	}{
+		{name: "Win up to the limit", currency: "EUR", totalToday: 40000, transactionType: "win", amount: 100},
+		{name: "Win above the limit", currency: "EUR", totalToday: 40000, transactionType: "win", amount: 100.01, expectedErr: helpers.ErrDailyLimitExceeded},
+		{name: "Withdrawal above the limit", currency: "EUR", totalToday: 49999, transactionType: "withdrawal", amount: 0.02, expectedErr: helpers.ErrDailyLimitExceeded},
+		{name: "Deposits are not limited", currency: "EUR", totalToday: 50000, transactionType: "deposit", amount: 50},
+		{name: "Losses are not limited", currency: "EUR", totalToday: 50000, transactionType: "lose", amount: 50},
+		{name: "Currency without a limit", currency: "USD", totalToday: 1e11, transactionType: "win", amount: 50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account := sqlc.Account{ID: 1, BalanceMinor: 200000000, Currency: tt.currency, Status: "active", AccountType: "general"}
+			db := &fakeDB{
+				rows: map[string]fakeRow{
+					"GetAccountForUpdate": accountRow(account),
+					"UpdateAccount":       accountRow(account),
+					"CreateTransaction":   transactionRow(sqlc.Transaction{ID: "tx", AccountID: 1}),
+				},
+				results: map[string][]fakeRow{
+					"SumTransactionsByAccountSince": {{values: []interface{}{"win", tt.totalToday}}},
+				},
+			}
+
+			_, err := applyTransactionInTx(context.Background(), sqlc.New(db), models.Transaction{
+				ID:              "tx",
+				AccountID:       1,
+				MinorAmount:     helpers.ToMinorUnits(tt.amount, tt.currency),
+				Source:          "payment",
+				TransactionType: tt.transactionType,
+			})
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.NotContains(t, db.queries, "CreateTransaction")
+
+				recorder := httptest.NewRecorder()
+				helpers.HandleAPIError(recorder, transactionRuleError(err))
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Contains(t, db.queries, "CreateTransaction")
+		})
+	}
+
+	// Wins and withdrawals are summed together from the start of the UTC day
+	db := &fakeDB{results: map[string][]fakeRow{"SumTransactionsByAccountSince": {}}}
+	transaction := models.Transaction{AccountID: 1, MinorAmount: 100, TransactionType: "win"}
+	now := time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC)
+	assert.NoError(t, checkDailyCaps(context.Background(), sqlc.New(db), transaction, "EUR", now))
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), db.args["SumTransactionsByAccountSince"][1].(models.Timestamp).Time)
+	assert.Equal(t, []string{"win", "withdrawal"}, db.args["SumTransactionsByAccountSince"][2])
+}
+
+func TestDailyCapsShareOneQuery(t *testing.T) {
+	t.Setenv("DAILY_DEBIT_CAP_USD", "1000")
+	t.Setenv("DAILY_TRANSACTION_LIMIT_USD", "500")
+
+	tests := []struct {
+		name        string
+		amount      float64
+		expectedErr error
+	}{
+		// 300 lost and 450 withdrawn: 750 of debits and 450 of withdrawals so far
+		{name: "Withdrawal within both caps", amount: 50},
+		{name: "Withdrawal above the transaction limit", amount: 50.01, expectedErr: helpers.ErrDailyLimitExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{results: map[string][]fakeRow{
+				"SumTransactionsByAccountSince": {
+					{values: []interface{}{"lose", int64(30000)}},
+					{values: []interface{}{"withdrawal", int64(45000)}},
+				},
+			}}
+			transaction := models.Transaction{AccountID: 1, MinorAmount: helpers.ToMinorUnits(tt.amount, "USD"), TransactionType: "withdrawal"}
+
+			err := checkDailyCaps(context.Background(), sqlc.New(db), transaction, "USD", time.Now())
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, []string{"SumTransactionsByAccountSince"}, db.queries)
+			assert.Equal(t, []string{"lose", "win", "withdrawal"}, db.args["SumTransactionsByAccountSince"][2])
+		})
+	}
+
+	// The debit cap counts losses too
+	db := &fakeDB{results: map[string][]fakeRow{
+		"SumTransactionsByAccountSince": {
+			{values: []interface{}{"lose", int64(60000)}},
+			{values: []interface{}{"withdrawal", int64(45000)}},
+		},
+	}}
+	transaction := models.Transaction{AccountID: 1, MinorAmount: 100, TransactionType: "withdrawal"}
+	assert.ErrorIs(t, checkDailyCaps(context.Background(), sqlc.New(db), transaction, "USD", time.Now()), helpers.ErrDailyCapExceeded)
+}
+
+func TestValidateTransactionID(t *testing.T) {
+	tests := []struct {
+		name          string
+		transactionID string
+		expectedErr   error
+	}{
+		{name: "Generated UUID", transactionID: "3f1c2a9e-7b4d-4e8a-9c61-2d5f0b8e7a13"},
+		{name: "Truncated UUID is a valid client reference", transactionID: "3f1c2a9e-7b4d"},
+		{name: "Client reference", transactionID: "integration-win-001"},
+		{name: "Transfer leg", transactionID: "3f1c2a9e-7b4d-4e8a-9c61-2d5f0b8e7a13-debit"},
+		{name: "Longest allowed", transactionID: strings.Repeat("a", 128)},
+		{name: "Empty", transactionID: "", expectedErr: helpers.ErrInvalidTransactionID},
+		{name: "Too long", transactionID: strings.Repeat("a", 129), expectedErr: helpers.ErrInvalidTransactionID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectedErr, helpers.ValidateTransactionID(tt.transactionID))
+		})
+	}
+}
+
+func TestGetTransactionRejectsInvalidID(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/transactions/{transactionId}", GetTransaction).Methods("GET")
+
+	req := httptest.NewRequest("GET", "/transactions/"+strings.Repeat("a", 129), nil)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Invalid transaction ID, expected 1 to 128 characters")
+}
+
+func TestFindTransaction(t *testing.T) {
+	transaction := sqlc.Transaction{ID: "tx-1", AccountID: 5, AmountMinor: 1000, Source: "game", Type: "win"}
+	found := map[string]fakeRow{
+		"GetTransaction": transactionRow(transaction),
+		"GetAccount":     accountRow(sqlc.Account{ID: 5, UserID: 7, Currency: "USD"}),
+	}
+
+	tests := []struct {
+		name        string
+		rows        map[string]fakeRow
+		userID      int64
+		expectedErr error
+	}{
+		{name: "Without an authenticated user", rows: found},
+		{name: "Own transaction", rows: found, userID: 7},
+		{name: "Another user's transaction", rows: found, userID: 8, expectedErr: helpers.ErrTransactionNotFound},
+		{name: "Unknown transaction", rows: map[string]fakeRow{}, expectedErr: helpers.ErrTransactionNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.userID != 0 {
+				ctx = helpers.WithUserID(ctx, tt.userID)
+			}
+
+			got, err := findTransaction(ctx, sqlc.New(&fakeDB{rows: tt.rows}), "tx-1")
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, transaction.ID, got.ID)
+		})
+	}
+}
+
+func TestBatchGetTransactions(t *testing.T) {
+	db := &fakeDB{results: map[string][]fakeRow{
+		"ListTransactionsByIDs": {
+			transactionRow(sqlc.Transaction{ID: "tx-1", AccountID: 5, AmountMinor: 1000, Source: "game", Type: "win"}),
+			transactionRow(sqlc.Transaction{ID: "tx-3", AccountID: 6, AmountMinor: 250, Source: "payment", Type: "deposit"}),
+		},
+	}}
+
+	transactions, notFound, err := batchGetTransactions(context.Background(), sqlc.New(db), []string{"tx-3", "missing-1", "tx-1", "missing-1", "missing-2"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tx-1", "tx-3"}, []string{transactions[0].ID, transactions[1].ID})
+	assert.Equal(t, []string{"missing-1", "missing-2"}, notFound)
+	// One query for all IDs, duplicates removed
+	assert.Equal(t, []string{"ListTransactionsByIDs"}, db.queries)
+	assert.Equal(t, []interface{}{[]string{"tx-3", "missing-1", "tx-1", "missing-2"}}, db.args["ListTransactionsByIDs"])
+}
+
+func TestBatchGetTransactionsHandlerValidation(t *testing.T) {
+	tooMany := make([]string, 101)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tx-%d", i)
+	}
+	tooManyBody, _ := json.Marshal(map[string][]string{"ids": tooMany})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "Missing IDs", body: `{}`},
+		{name: "Empty list", body: `{"ids":[]}`},
+		{name: "Too many IDs", body: string(tooManyBody)},
+		{name: "Empty ID", body: `{"ids":["tx-1",""]}`},
+		{name: "ID too long", body: `{"ids":["` + strings.Repeat("a", 129) + `"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/transactions/batch-get", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			BatchGetTransactionsHandler(recorder, req)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		})
+	}
+}
+
+func TestTransactionOutcomeMetric(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{name: "Applied", err: nil, expected: metrics.OutcomeSuccess},
+		{name: "Insufficient balance", err: fmt.Errorf("debit: %w", helpers.ErrInsufficientBalance), expected: metrics.OutcomeInsufficientBalance},
+		{name: "Frozen account", err: helpers.ErrAccountFrozen, expected: metrics.OutcomeRejected},
+		{name: "Duplicate reference", err: helpers.ErrDuplicateReference, expected: metrics.OutcomeRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, transactionOutcome(tt.err))
+		})
+	}
+
+	// Every answered transaction is counted by type and outcome
+	rejected := metrics.TransactionsTotal.WithLabelValues("withdrawal", metrics.OutcomeRejected)
+	before := testutil.ToFloat64(rejected)
+	recorder := httptest.NewRecorder()
+	respondTransactionResult(recorder, httptest.NewRequest("POST", "/user/1/transaction", nil), 1, sqlc.Account{ID: 1, Currency: "EUR"}, models.Transaction{TransactionType: "withdrawal"}, helpers.ErrAccountFrozen)
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Equal(t, before+1, testutil.ToFloat64(rejected))
 }
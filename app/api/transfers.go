@@ -0,0 +1,220 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+// Source recorded on both legs of a transfer
+const transferSource = "payment"
+
+// validateTransfer rejects the most common client mistakes before any balance is touched. The amount
+// is in minor units of the source account currency.
+func validateTransfer(from, to sqlc.Account, amount int64) error {
+	if amount <= 0 {
+		return helpers.ErrTransferAmountZero
+	}
+
+	if from.ID == to.ID {
+		return helpers.ErrSelfTransfer
+	}
+
+	for _, account := range []sqlc.Account{from, to} {
+		if err := helpers.CheckAccountActive(account.Status); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// transferAccount fetches the account of a transfer party
+func transferAccount(ctx context.Context, queries *sqlc.Queries, userID int64) (sqlc.Account, error) {
+	account, err := queries.GetAccountByUser(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return sqlc.Account{}, helpers.ErrAccountNotFound
+	}
+	return account, err
+}
+
+// acquireTransferSlots queues on the accounts of both users before the database transaction opens, so
+// waiting does not hold a connection. Missing accounts are skipped and reported by transferInTx.
+func acquireTransferSlots(ctx context.Context, queries *sqlc.Queries, fromUserID, toUserID int64) (func(), error) {
+	if !accountSlots.Enabled() {
+		return func() {}, nil
+	}
+
+	var accountIDs []int64
+	for _, userID := range []int64{fromUserID, toUserID} {
+		account, err := queries.GetAccountByUser(ctx, userID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		accountIDs = append(accountIDs, account.ID)
+	}
+	return accountSlots.Acquire(ctx, accountIDs...)
+}
+
+// transferInTx debits the source account and credits the destination, writing a withdrawal and a
+// deposit linked by the transfer ID. The decimal amount is parsed in the source account currency. It
+// must run inside runInTx so that a failing leg rolls back both.
+func transferInTx(ctx context.Context, queries *sqlc.Queries, transferID string, fromUserID, toUserID int64, amountStr, memo string) (models.Transfer, error) {
+	from, err := transferAccount(ctx, queries, fromUserID)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	to, err := transferAccount(ctx, queries, toUserID)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+
+	amount, err := helpers.ParseMoney(amountStr, from.Currency, helpers.MoneyOptions{AllowZero: true})
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	if err := validateTransfer(from, to, amount); err != nil {
+		return models.Transfer{}, err
+	}
+	if from.Currency != to.Currency {
+		return models.Transfer{}, helpers.ErrCurrencyMismatch
+	}
+
+	debit := models.Transaction{
+		ID:              transferID + "-debit",
+		AccountID:       from.ID,
+		MinorAmount:     amount,
+		Source:          transferSource,
+		TransactionType: "withdrawal",
+		Memo:            memo,
+	}
+	credit := models.Transaction{
+		ID:              transferID + "-credit",
+		AccountID:       to.ID,
+		MinorAmount:     amount,
+		Source:          transferSource,
+		TransactionType: "deposit",
+		Memo:            memo,
+	}
+
+	// Lock both accounts in ID order, so opposite transfers between the same accounts cannot deadlock
+	legs := []models.Transaction{debit, credit}
+	if to.ID < from.ID {
+		legs = []models.Transaction{credit, debit}
+	}
+	balances := map[int64]int64{}
+	for _, leg := range legs {
+		updated, err := updateBalanceInTx(ctx, queries, leg.AccountID, leg.MinorAmount, leg.TransactionType, leg.Source)
+		if err != nil {
+			return models.Transfer{}, err
+		}
+		balances[leg.AccountID] = updated.BalanceMinor
+	}
+
+	// Transfers count towards the daily debit cap and withdrawal limit of the source account
+	if err := checkDailyCaps(ctx, queries, debit, from.Currency, time.Now()); err != nil {
+		return models.Transfer{}, err
+	}
+
+	for _, leg := range []models.Transaction{debit, credit} {
+		balance := balances[leg.AccountID]
+		leg.BalanceAfterMinor = &balance
+		if _, err := createTransactionInTx(ctx, queries, leg, from.Currency); err != nil {
+			return models.Transfer{}, err
+		}
+	}
+
+	return models.Transfer{
+		TransferID:          transferID,
+		FromUserID:          fromUserID,
+		ToUserID:            toUserID,
+		Amount:              helpers.FormatMoney(amount, from.Currency),
+		Currency:            from.Currency,
+		DebitTransactionID:  debit.ID,
+		CreditTransactionID: credit.ID,
+	}, nil
+}
+
+// transferRuleError returns the business error that rejected a transfer, if any
+func transferRuleError(err error) error {
+	if ruleErr := transactionRuleError(err); ruleErr != nil {
+		return ruleErr
+	}
+	if _, isAmountErr := helpers.AmountErrorMessage(err); isAmountErr {
+		return err
+	}
+	for _, ruleErr := range []error{
+		helpers.ErrTransferAmountZero,
+		helpers.ErrSelfTransfer,
+		helpers.ErrCurrencyMismatch,
+		helpers.ErrInsufficientBalance,
+	} {
+		if errors.Is(err, ruleErr) {
+			return ruleErr
+		}
+	}
+	return nil
+}
+
+// TransferHandler handles POST /transfer - moves money from one user's account to another's in a
+// single database transaction
+func TransferHandler(w http.ResponseWriter, r *http.Request) {
+	var request models.TransferRequest
+
+	// Validate and decode JSON request body using enhanced validation
+	if ok, validationErrors := helpers.ValidateBodyWithDetails(r, &request); !ok {
+		helpers.RespondValidationError(w, validationErrors)
+		return
+	}
+
+	fromUserID, err := helpers.ValidateID(request.FromUserID.String())
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+	// Only the sender may move money out of their account
+	if err := helpers.AuthorizeUser(r.Context(), fromUserID); err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+	toUserID, err := helpers.ValidateID(request.ToUserID.String())
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	release, err := acquireTransferSlots(r.Context(), database.DBClient.Queries, fromUserID, toUserID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transfer")
+		return
+	}
+	defer release()
+
+	var transfer models.Transfer
+	err = runInTx(r.Context(), database.DBClient, func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		transfer, err = transferInTx(ctx, queries, uuid.NewString(), fromUserID, toUserID, request.Amount, request.Memo)
+		return err
+	})
+	if ruleErr := transferRuleError(err); ruleErr != nil {
+		helpers.HandleAPIError(w, ruleErr)
+		return
+	}
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "Transfer")
+		return
+	}
+
+	helpers.RespondSuccess(w, "Transfer completed successfully", transfer)
+}
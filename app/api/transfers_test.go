@@ -0,0 +1,338 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTransfer(t *testing.T) {
+	active := sqlc.Account{ID: 1, Status: helpers.AccountStatusActive}
+	other := sqlc.Account{ID: 2, Status: helpers.AccountStatusActive}
+	frozen := sqlc.Account{ID: 3, Status: helpers.AccountStatusFrozen}
+	closed := sqlc.Account{ID: 4, Status: helpers.AccountStatusClosed}
+
+	tests := []struct {
+		name           string
+		from           sqlc.Account
+		to             sqlc.Account
+		amount         int64
+		expectedErr    error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:   "Valid transfer",
+			from:   active,
+			to:     other,
+			amount: 1000,
+		},
+		{
+			name:           "Zero amount",
+			from:           active,
+			to:             other,
+			amount:         0,
+			expectedErr:    helpers.ErrTransferAmountZero,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Transfer amount must be at least 0.01",
+		},
+		{
+			name:           "Source equals destination",
+			from:           active,
+			to:             active,
+			amount:         1000,
+			expectedErr:    helpers.ErrSelfTransfer,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Cannot transfer to the same account",
+		},
+		{
+			name:           "Frozen source",
+			from:           frozen,
+			to:             other,
+			amount:         1000,
+			expectedErr:    helpers.ErrAccountFrozen,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   "Account is frozen",
+		},
+		{
+			name:           "Closed destination",
+			from:           active,
+			to:             closed,
+			amount:         1000,
+			expectedErr:    helpers.ErrAccountClosed,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   "Account is closed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTransfer(tt.from, tt.to, tt.amount)
+
+			if tt.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			recorder := httptest.NewRecorder()
+			helpers.HandleAPIError(recorder, err)
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestTransferInTx(t *testing.T) {
+	tests := []struct {
+		name         string
+		fromUserID   int64
+		toUserID     int64
+		amount       string
+		toCurrency   string
+		toStatus     string
+		expectedErr  error
+		expectedLock []int64
+	}{
+		{name: "Transfer to a higher account ID", fromUserID: 1, toUserID: 2, amount: "30.00", expectedLock: []int64{10, 20}},
+		{name: "Locks accounts in ID order", fromUserID: 2, toUserID: 1, amount: "30.00", expectedLock: []int64{10, 20}},
+		{name: "Insufficient balance rolls back both legs", fromUserID: 1, toUserID: 2, amount: "100.01", expectedErr: helpers.ErrInsufficientBalance},
+		{name: "Currency mismatch", fromUserID: 1, toUserID: 2, amount: "30.00", toCurrency: "USD", expectedErr: helpers.ErrCurrencyMismatch},
+		{name: "Unknown destination", fromUserID: 1, toUserID: 3, amount: "30.00", expectedErr: helpers.ErrAccountNotFound},
+		{name: "Zero amount", fromUserID: 1, toUserID: 2, amount: "0", expectedErr: helpers.ErrTransferAmountZero},
+		{name: "Sub-cent amount", fromUserID: 1, toUserID: 2, amount: "0.004", expectedErr: helpers.ErrTooManyDecimals},
+		{name: "Invalid amount", fromUserID: 1, toUserID: 2, amount: "ten", expectedErr: helpers.ErrInvalidAmount},
+		{name: "Transfer to self", fromUserID: 1, toUserID: 1, amount: "30.00", expectedErr: helpers.ErrSelfTransfer},
+		{name: "Frozen destination", fromUserID: 1, toUserID: 2, amount: "30.00", toStatus: helpers.AccountStatusFrozen, expectedErr: helpers.ErrAccountFrozen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			toCurrency := tt.toCurrency
+			if toCurrency == "" {
+				toCurrency = "EUR"
+			}
+			toStatus := tt.toStatus
+			if toStatus == "" {
+				toStatus = helpers.AccountStatusActive
+			}
+			accounts := map[int64]sqlc.Account{
+				10: {ID: 10, UserID: 1, BalanceMinor: 10000, Currency: "EUR", Status: "active", AccountType: "general"},
+				20: {ID: 20, UserID: 2, BalanceMinor: 10000, Currency: toCurrency, Status: toStatus, AccountType: "general"},
+			}
+
+			var locked []int64
+			balances := map[int64]int64{}
+			created := map[string]string{}
+			db := &fakeDB{
+				rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+					switch name {
+					case "GetAccountByUser":
+						for _, account := range accounts {
+							if account.UserID == args[0] {
+								return accountRow(account), true
+							}
+						}
+					case "GetAccountForUpdate":
+						locked = append(locked, args[0].(int64))
+						return accountRow(accounts[args[0].(int64)]), true
+					case "UpdateAccount":
+						account := accounts[args[0].(int64)]
+						account.BalanceMinor = args[2].(int64)
+						balances[account.ID] = account.BalanceMinor
+						return accountRow(account), true
+					case "CreateTransaction":
+						created[args[0].(string)] = args[4].(string)
+						return transactionRow(sqlc.Transaction{ID: args[0].(string), AccountID: args[1].(int64)}), true
+					}
+					return fakeRow{}, false
+				},
+			}
+			starter := &fakeStarter{db: db}
+
+			var transfer models.Transfer
+			err := runInTxWith(context.Background(), starter, sqlc.New(db), func(ctx context.Context, queries *sqlc.Queries) error {
+				var err error
+				transfer, err = transferInTx(ctx, queries, "tr-1", tt.fromUserID, tt.toUserID, tt.amount, "rent")
+				return err
+			})
+
+			if tt.expectedErr != nil {
+				assert.ErrorIs(t, err, tt.expectedErr)
+				assert.Equal(t, tt.expectedErr, transferRuleError(err))
+				assert.Empty(t, created)
+				assert.True(t, starter.txs[0].rolledBack)
+				assert.False(t, starter.txs[0].committed)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, starter.txs[0].committed)
+			assert.Equal(t, tt.expectedLock, locked)
+
+			from, to := accounts[tt.fromUserID*10], accounts[tt.toUserID*10]
+			assert.Equal(t, from.BalanceMinor-3000, balances[from.ID])
+			assert.Equal(t, to.BalanceMinor+3000, balances[to.ID])
+			assert.Equal(t, map[string]string{"tr-1-debit": "withdrawal", "tr-1-credit": "deposit"}, created)
+			assert.Equal(t, models.Transfer{
+				TransferID:          "tr-1",
+				FromUserID:          tt.fromUserID,
+				ToUserID:            tt.toUserID,
+				Amount:              "30.00",
+				Currency:            "EUR",
+				DebitTransactionID:  "tr-1-debit",
+				CreditTransactionID: "tr-1-credit",
+			}, transfer)
+		})
+	}
+}
+
+func TestTransferHandlerValidatesIDs(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Zero source ID", body: `{"from_user_id":0,"to_user_id":2,"amount":"10.00"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Negative destination ID", body: `{"from_user_id":1,"to_user_id":-2,"amount":"10.00"}`, expectedStatus: http.StatusBadRequest},
+		{name: "ID as a string", body: `{"from_user_id":"1","to_user_id":"0","amount":"10.00"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Non-numeric ID", body: `{"from_user_id":"abc","to_user_id":2,"amount":"10.00"}`, expectedStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/transfer", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+
+			TransferHandler(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestAccountSlotsHotAccount(t *testing.T) {
+	const limit = 2
+	slots := helpers.NewAccountSlots(func() int { return limit })
+
+	var mu sync.Mutex
+	balances := map[int64]int64{1: 100000, 2: 0, 3: 0}
+	inFlight := map[int64]int{}
+	maxInFlight := map[int64]int{}
+
+	run := func(accountIDs []int64, apply func()) error {
+		release, err := slots.Acquire(context.Background(), accountIDs...)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		mu.Lock()
+		for _, id := range accountIDs {
+			inFlight[id]++
+			maxInFlight[id] = max(maxInFlight[id], inFlight[id])
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		apply()
+		for _, id := range accountIDs {
+			inFlight[id]--
+		}
+		mu.Unlock()
+		return nil
+	}
+
+	// Transactions on the hot account 1 mixed with transfers in both directions; transfers list the
+	// accounts in transfer order, not ID order
+	operations := []struct {
+		accountIDs []int64
+		apply      func()
+	}{
+		{accountIDs: []int64{1}, apply: func() { balances[1] += 10 }},
+		{accountIDs: []int64{1, 2}, apply: func() { balances[1] -= 5; balances[2] += 5 }},
+		{accountIDs: []int64{2, 1}, apply: func() { balances[2] -= 3; balances[1] += 3 }},
+		{accountIDs: []int64{3, 1}, apply: func() { balances[1] -= 1; balances[3] += 1 }},
+	}
+
+	errs := make(chan error, 50*len(operations))
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, operation := range operations {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- run(operation.accountIDs, operation.apply)
+			}()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transactions deadlocked on the account slots")
+	}
+
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, map[int64]int64{1: 100350, 2: 100, 3: 50}, balances)
+	for id, count := range maxInFlight {
+		assert.LessOrEqual(t, count, limit, "account %d", id)
+	}
+}
+
+func TestAccountSlotsWait(t *testing.T) {
+	slots := helpers.NewAccountSlots(func() int { return 1 })
+
+	releaseHot, err := slots.Acquire(context.Background(), 2)
+	assert.NoError(t, err)
+
+	// A request waiting for a busy account gives up with its context and frees the slots it already took
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = slots.Acquire(ctx, 1, 2)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	release, err := slots.Acquire(ctx, 1)
+	assert.NoError(t, err)
+	release()
+
+	// Once released, the busy account is available again
+	releaseHot()
+	release, err = slots.Acquire(ctx, 1, 2)
+	assert.NoError(t, err)
+	release()
+}
+
+func TestAccountSlotsDisabled(t *testing.T) {
+	slots := helpers.NewAccountSlots(func() int { return 0 })
+	assert.False(t, slots.Enabled())
+
+	// Without a limit any number of requests run at once
+	for i := 0; i < 10; i++ {
+		_, err := slots.Acquire(context.Background(), 1)
+		assert.NoError(t, err)
+	}
+}
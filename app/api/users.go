@@ -2,10 +2,16 @@ package api
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/rathorevk/GoBanking/app/database"
 	"github.com/rathorevk/GoBanking/app/database/sqlc"
@@ -13,25 +19,109 @@ import (
 	"github.com/rathorevk/GoBanking/app/models"
 )
 
+// missingUsers short-circuits repeated lookups of user IDs recently found not to exist
+var missingUsers = helpers.NewNegativeCache(helpers.UserNegativeCacheTTL, time.Now)
+
 // Database service functions
-func getUserByID(userID int64) (sqlc.User, error) {
-	user, err := database.DBClient.Queries.GetUser(context.Background(), userID)
+func getUserByID(ctx context.Context, userID int64) (sqlc.User, error) {
+	return findUser(ctx, database.DBClient.Queries, userID)
+}
+
+// findUser answers from the negative cache for IDs recently confirmed missing and caches new misses
+func findUser(ctx context.Context, queries *sqlc.Queries, userID int64) (sqlc.User, error) {
+	if missingUsers.Contains(userID) {
+		return sqlc.User{}, pgx.ErrNoRows
+	}
+
+	generation := missingUsers.Generation()
+	user, err := queries.GetUser(ctx, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		missingUsers.Add(userID, generation)
+	}
 	return user, err
 }
 
-func createUserInDB(user models.User) (sqlc.User, error) {
+func createUserInDB(ctx context.Context, queries *sqlc.Queries, user models.User) (sqlc.User, error) {
+	// Only the hash of the password is kept, and the password is never logged
+	var passwordHash pgtype.Text
+	if user.Password != "" {
+		hash, err := helpers.HashPassword(user.Password)
+		if err != nil {
+			return sqlc.User{}, err
+		}
+		passwordHash = pgtype.Text{String: hash, Valid: true}
+		user.Password = ""
+	}
+
 	log.Println("Creating user:", user)
 
 	params := sqlc.CreateUserParams{
-		FullName: user.FullName,
-		Email:    user.Email,
-		Username: user.Username,
+		FullName:     user.FullName,
+		Email:        user.Email,
+		Username:     user.Username,
+		PasswordHash: passwordHash,
+	}
+
+	// KYC fields left out by the client are stored as NULL; sent ones were validated against helpers.DateLayout
+	if user.DateOfBirth != "" {
+		dateOfBirth, err := time.Parse(helpers.DateLayout, user.DateOfBirth)
+		if err != nil {
+			return sqlc.User{}, err
+		}
+		params.DateOfBirth = pgtype.Date{Time: dateOfBirth, Valid: true}
+	}
+	if user.Country != "" {
+		params.Country = pgtype.Text{String: user.Country, Valid: true}
 	}
 
-	userCreated, err := database.DBClient.Queries.CreateUser(context.Background(), params)
+	userCreated, err := queries.CreateUser(ctx, params)
 	return userCreated, err
 }
 
+// createUserWithAccount creates the user and its default account in one database transaction,
+// so a failed account insert never leaves a user without an account behind
+func createUserWithAccount(ctx context.Context, starter txStarter, baseQueries *sqlc.Queries, user models.User) (sqlc.User, sqlc.Account, error) {
+	var userCreated sqlc.User
+	var accountCreated sqlc.Account
+
+	err := runInTxWith(ctx, starter, baseQueries, func(ctx context.Context, queries *sqlc.Queries) error {
+		var err error
+		userCreated, err = createUserInDB(ctx, queries, user)
+		if err != nil {
+			return err
+		}
+
+		accountCreated, err = createAccountInDB(ctx, queries, userCreated.ID, helpers.AccountTypeGeneral, "", "", nil)
+		return err
+	})
+	if err != nil {
+		return sqlc.User{}, sqlc.Account{}, err
+	}
+
+	// The ID may have been probed before it was assigned; never keep serving 404 for it
+	missingUsers.Invalidate(userCreated.ID)
+
+	return userCreated, accountCreated, nil
+}
+
+// createUserWithoutAccount creates only the user, for workflows that provision accounts later
+// through POST /accounts
+func createUserWithoutAccount(ctx context.Context, queries *sqlc.Queries, user models.User) (sqlc.User, error) {
+	userCreated, err := createUserInDB(ctx, queries, user)
+	if err != nil {
+		return sqlc.User{}, err
+	}
+
+	missingUsers.Invalidate(userCreated.ID)
+
+	return userCreated, nil
+}
+
+// userLocation is the absolute URL of a user resource as seen by the client
+func userLocation(r *http.Request, userID int64) string {
+	return helpers.AbsoluteURL(r, helpers.APIPath("user", strconv.FormatInt(userID, 10)))
+}
+
 // HTTP Handlers
 func GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract and validate user ID from URL
@@ -45,7 +135,7 @@ func GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch user from database
-	user, err := getUserByID(userID)
+	user, err := getUserByID(r.Context(), userID)
 	if err != nil {
 		helpers.HandleDatabaseError(w, err, "User")
 		return
@@ -55,7 +145,166 @@ func GetUserHandler(w http.ResponseWriter, r *http.Request) {
 	helpers.RespondSuccess(w, "User retrieved successfully", user)
 }
 
+// deleteUser soft-deletes a user by setting deleted_at. The rows stay for the ledger, but the user and
+// their accounts are no longer found. Unknown and already deleted users return pgx.ErrNoRows.
+func deleteUser(ctx context.Context, queries *sqlc.Queries, userID int64) (sqlc.User, error) {
+	return queries.SoftDeleteUser(ctx, userID)
+}
+
+// DeleteUserHandler handles DELETE /user/{userId} - deactivates the user
+func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := helpers.ValidateID(mux.Vars(r)["userId"])
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	user, err := deleteUser(r.Context(), database.DBClient.Queries, userID)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "User")
+		return
+	}
+
+	helpers.RespondSuccess(w, "User deleted successfully", user)
+}
+
+// lookupUser finds a user by email, or by username when email is empty
+func lookupUser(ctx context.Context, queries *sqlc.Queries, email, username string) (sqlc.User, error) {
+	if email != "" {
+		return queries.GetUserByEmail(ctx, email)
+	}
+	return queries.GetUserByUsername(ctx, username)
+}
+
+// LookupUserHandler handles GET /users/lookup?email=... or ?username=... - returns the matching user.
+// Exactly one of the parameters must be given.
+func LookupUserHandler(w http.ResponseWriter, r *http.Request) {
+	email, username := r.URL.Query().Get("email"), r.URL.Query().Get("username")
+	if (email == "") == (username == "") {
+		helpers.HandleAPIError(w, helpers.ErrInvalidUserLookup)
+		return
+	}
+
+	user, err := lookupUser(r.Context(), database.DBClient.Queries, email, username)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "User")
+		return
+	}
+
+	helpers.RespondSuccess(w, "User retrieved successfully", user)
+}
+
+// listUsers returns a page of users, oldest first, and the total count. A non-empty query keeps only
+// users whose username or full name starts with it, ignoring case.
+func listUsers(ctx context.Context, queries *sqlc.Queries, query string, limit, offset int32) ([]models.UserSummary, int64, error) {
+	users := []models.UserSummary{}
+	if query == "" {
+		rows, err := queries.ListUsers(ctx, sqlc.ListUsersParams{Limit: limit, Offset: offset})
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, row := range rows {
+			users = append(users, models.UserSummary(row))
+		}
+
+		total, err := queries.CountUsers(ctx)
+		return users, total, err
+	}
+
+	pattern := helpers.PrefixPattern(query)
+	rows, err := queries.SearchUsers(ctx, sqlc.SearchUsersParams{Pattern: pattern, PageLimit: limit, PageOffset: offset})
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, row := range rows {
+		users = append(users, models.UserSummary(row))
+	}
+
+	total, err := queries.CountSearchUsers(ctx, pattern)
+	return users, total, err
+}
+
+// ListUsersHandler handles GET /users?limit=20&offset=0&q=... - lists users with their total count,
+// optionally only those whose username or full name starts with q
+func ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := helpers.ParsePaginationWithDefault(r.URL.Query(), helpers.DefaultUserPageLimit)
+	if err != nil {
+		helpers.HandleAPIError(w, err)
+		return
+	}
+
+	users, total, err := listUsers(r.Context(), database.DBClient.Queries, r.URL.Query().Get("q"), limit, offset)
+	if err != nil {
+		helpers.HandleDatabaseError(w, err, "User")
+		return
+	}
+
+	responseData := map[string]interface{}{
+		"users":  users,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	}
+	helpers.RespondSuccess(w, "Users retrieved successfully", responseData)
+}
+
+// upsertHeader asks POST /user for create-or-get semantics keyed on email
+const upsertHeader = "Upsert"
+
+// userEmailConstraint is the unique constraint on users.email
+const userEmailConstraint = "users_email_key"
+
+// existingUserByEmail returns the user already registered with the email when creating a user failed
+// on the email's unique constraint. Any other failure, or an email held by a deleted user, returns createErr.
+func existingUserByEmail(ctx context.Context, queries *sqlc.Queries, email string, createErr error) (sqlc.User, error) {
+	var pgErr *pgconn.PgError
+	if !errors.As(createErr, &pgErr) || pgErr.Code != helpers.PgUniqueViolation || pgErr.ConstraintName != userEmailConstraint {
+		return sqlc.User{}, createErr
+	}
+
+	user, err := queries.GetUserByEmail(ctx, email)
+	if err != nil {
+		return sqlc.User{}, createErr
+	}
+	return user, nil
+}
+
+// respondCreateUserError answers a failed user creation, returning the existing user for upserts
+func respondCreateUserError(w http.ResponseWriter, r *http.Request, upsert bool, email string, err error) {
+	if upsert {
+		if existing, lookupErr := existingUserByEmail(r.Context(), database.DBClient.Queries, email, err); lookupErr == nil {
+			w.Header().Set("Location", userLocation(r, existing.ID))
+			helpers.RespondSuccess(w, "User already exists", map[string]interface{}{"user": existing})
+			return
+		}
+	}
+	helpers.HandleDatabaseError(w, err, "User")
+}
+
+// CreateUserHandler handles POST /user?create_account=true|false - creates a user and, unless
+// create_account is false, its default account
 func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	createAccount := true
+	if value := r.URL.Query().Get("create_account"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			helpers.RespondError(w, http.StatusBadRequest, "create_account must be true or false")
+			return
+		}
+		createAccount = parsed
+	}
+
+	// With Upsert: true an already registered email returns the existing user instead of 409
+	upsert := false
+	if value := r.Header.Get(upsertHeader); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			helpers.RespondError(w, http.StatusBadRequest, "Upsert header must be true or false")
+			return
+		}
+		upsert = parsed
+	}
+
 	var user models.User
 
 	// Validate and decode JSON request body using enhanced validation
@@ -64,24 +313,31 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create user in database
-	userCreated, err := createUserInDB(user)
-	if err != nil {
-		helpers.HandleDatabaseError(w, err, "User")
+	if !createAccount {
+		userCreated, err := createUserWithoutAccount(r.Context(), database.DBClient.Queries, user)
+		if err != nil {
+			respondCreateUserError(w, r, upsert, user.Email, err)
+			return
+		}
+
+		w.Header().Set("Location", userLocation(r, userCreated.ID))
+		helpers.RespondSuccess(w, "User created successfully", map[string]interface{}{"user": userCreated})
 		return
 	}
 
-	// Create account for the newly created user
-	_, err = CreateAccount(userCreated.ID)
+	// Create user and account together; on failure nothing is persisted and the request can be retried
+	userCreated, accountCreated, err := createUserWithAccount(r.Context(), database.DBClient.Pool, database.DBClient.Queries, user)
 	if err != nil {
-		// User was created but account creation failed - this is a partial success
-		helpers.RespondError(w, http.StatusInternalServerError, "User created but failed to create account")
+		respondCreateUserError(w, r, upsert, user.Email, err)
 		return
 	}
 
+	w.Header().Set("Location", userLocation(r, userCreated.ID))
+
 	// Return successful response with both user and account data
 	responseData := map[string]interface{}{
-		"user": userCreated,
+		"user":    userCreated,
+		"account": accountCreated,
 	}
 	helpers.RespondSuccess(w, "User and account created successfully", responseData)
 }
@@ -2,12 +2,21 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
 	"github.com/rathorevk/GoBanking/app/helpers"
 	"github.com/rathorevk/GoBanking/app/models"
 	"github.com/stretchr/testify/assert"
@@ -61,9 +70,11 @@ func TestCreateUserHandler(t *testing.T) {
 		{
 			name: "Invalid email format",
 			requestBody: models.User{
-				Username: "testuser",
-				FullName: "Test User",
-				Email:    "invalid-email",
+				Username:    "testuser",
+				FullName:    "Test User",
+				Email:       "invalid-email",
+				DateOfBirth: "1990-01-01",
+				Country:     "DE",
 			},
 			expectedStatus: http.StatusUnprocessableEntity,
 			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
@@ -74,6 +85,23 @@ func TestCreateUserHandler(t *testing.T) {
 				assert.Contains(t, response, "errors")
 			},
 		},
+		{
+			name: "Under-age user",
+			requestBody: models.User{
+				Username:    "testuser",
+				FullName:    "Test User",
+				Email:       "test@example.com",
+				DateOfBirth: time.Now().AddDate(-17, 0, 0).Format(helpers.DateLayout),
+				Country:     "DE",
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				var response helpers.ValidationErrorResponse
+				err := json.Unmarshal(recorder.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "The date_of_birth must indicate an age of at least 18 years", response.Errors["date_of_birth"])
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -107,6 +135,76 @@ func TestCreateUserHandler(t *testing.T) {
 	}
 }
 
+func TestCreateUserKYCFields(t *testing.T) {
+	tests := []struct {
+		name           string
+		required       string
+		user           models.User
+		expectedErrors map[string]string
+	}{
+		{
+			name:     "Optional by default for clients that predate them",
+			required: "",
+			user:     models.User{Username: "legacy", FullName: "Legacy Client", Email: "legacy@example.com"},
+		},
+		{
+			name:     "Sent values are validated even when optional",
+			required: "",
+			user:     models.User{Username: "legacy", FullName: "Legacy Client", Email: "legacy@example.com", DateOfBirth: "17/05/1990", Country: "Germany"},
+			expectedErrors: map[string]string{
+				"date_of_birth": "The date_of_birth must be a valid date in 2006-01-02 format",
+				"country":       "The country must be a valid ISO 3166-1 alpha-2 country code",
+			},
+		},
+		{
+			name:     "Missing fields rejected when required",
+			required: "true",
+			user:     models.User{Username: "newuser", FullName: "New User", Email: "newuser@example.com"},
+			expectedErrors: map[string]string{
+				"date_of_birth": "The date_of_birth field is required",
+				"country":       "The country field is required",
+			},
+		},
+		{
+			name:     "Complete fields accepted when required",
+			required: "true",
+			user:     models.User{Username: "newuser", FullName: "New User", Email: "newuser@example.com", DateOfBirth: "1990-05-17", Country: "DE"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUIRE_KYC_FIELDS", tt.required)
+
+			body, err := json.Marshal(tt.user)
+			assert.NoError(t, err)
+			req := httptest.NewRequest("POST", "/user", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+
+			var user models.User
+			ok, validationErrors := helpers.ValidateBodyWithDetails(req, &user)
+
+			assert.Equal(t, tt.expectedErrors == nil, ok)
+			if tt.expectedErrors != nil {
+				assert.Equal(t, tt.expectedErrors, validationErrors)
+			}
+		})
+	}
+}
+
+func TestCreateUserWithoutKYCFields(t *testing.T) {
+	db := &fakeDB{rows: map[string]fakeRow{"CreateUser": userRow(sqlc.User{ID: 4, Username: "legacy"})}}
+	user := models.User{Username: "legacy", FullName: "Legacy Client", Email: "legacy@example.com"}
+
+	_, err := createUserWithoutAccount(context.Background(), sqlc.New(db), user)
+	assert.NoError(t, err)
+
+	// Fields the client did not send are stored as NULL rather than as zero values
+	args := db.args["CreateUser"]
+	assert.Equal(t, pgtype.Date{}, args[3])
+	assert.Equal(t, pgtype.Text{}, args[4])
+}
+
 func TestGetUserHandler(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -175,6 +273,7 @@ func TestValidateID(t *testing.T) {
 		idStr       string
 		expectError bool
 		expectedID  int64
+		expectedErr error
 	}{
 		{
 			name:        "Valid ID",
@@ -206,6 +305,30 @@ func TestValidateID(t *testing.T) {
 			expectError: true,
 			expectedID:  0,
 		},
+		{
+			name:        "Largest int64 ID",
+			idStr:       "9223372036854775807",
+			expectError: false,
+			expectedID:  9223372036854775807,
+		},
+		{
+			name:        "ID overflowing int64",
+			idStr:       "99999999999999999999",
+			expectError: true,
+			expectedErr: helpers.ErrIDTooLarge,
+		},
+		{
+			name:        "Non-numeric ID",
+			idStr:       "12abc",
+			expectError: true,
+			expectedErr: helpers.ErrInvalidID,
+		},
+		{
+			name:        "Negative ID overflowing int64",
+			idStr:       "-99999999999999999999",
+			expectError: true,
+			expectedErr: helpers.ErrInvalidID,
+		},
 	}
 
 	for _, tt := range tests {
@@ -215,6 +338,9 @@ func TestValidateID(t *testing.T) {
 			if tt.expectError {
 				assert.Error(t, err)
 				assert.Equal(t, int64(0), id)
+				if tt.expectedErr != nil {
+					assert.ErrorIs(t, err, tt.expectedErr)
+				}
 			} else {
 				assert.NoError(t, err)
 				assert.Equal(t, tt.expectedID, id)
@@ -223,6 +349,34 @@ func TestValidateID(t *testing.T) {
 	}
 }
 
+func TestInvalidIDResponses(t *testing.T) {
+	tests := []struct {
+		name            string
+		userID          string
+		expectedMessage string
+	}{
+		{name: "Overflowing ID", userID: "99999999999999999999", expectedMessage: "ID is too large, the maximum is 9223372036854775807"},
+		{name: "Non-numeric ID", userID: "abc", expectedMessage: "Invalid ID format, expected a positive integer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.HandleFunc("/user/{userId}/balance", GetBalanceHandler).Methods("GET")
+
+			req := httptest.NewRequest("GET", "/user/"+tt.userID+"/balance", nil)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+			var response helpers.ErrorResponse
+			assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+			assert.Equal(t, tt.expectedMessage, response.Error)
+		})
+	}
+}
+
 func TestValidateBodyWithDetails(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -232,12 +386,58 @@ func TestValidateBodyWithDetails(t *testing.T) {
 		{
 			name: "Valid user",
 			user: models.User{
-				Username: "testuser",
-				FullName: "Test User",
-				Email:    "test@example.com",
+				Username:    "testuser",
+				FullName:    "Test User",
+				Email:       "test@example.com",
+				DateOfBirth: "1990-05-17",
+				Country:     "DE",
+			},
+			expectValid: true,
+		},
+		{
+			name: "User exactly at minimum age",
+			user: models.User{
+				Username:    "testuser",
+				FullName:    "Test User",
+				Email:       "test@example.com",
+				DateOfBirth: time.Now().AddDate(-18, 0, 0).Format(helpers.DateLayout),
+				Country:     "GB",
 			},
 			expectValid: true,
 		},
+		{
+			name: "Under-age user",
+			user: models.User{
+				Username:    "testuser",
+				FullName:    "Test User",
+				Email:       "test@example.com",
+				DateOfBirth: time.Now().AddDate(-18, 0, 1).Format(helpers.DateLayout),
+				Country:     "DE",
+			},
+			expectValid: false,
+		},
+		{
+			name: "Invalid date of birth format",
+			user: models.User{
+				Username:    "testuser",
+				FullName:    "Test User",
+				Email:       "test@example.com",
+				DateOfBirth: "17/05/1990",
+				Country:     "DE",
+			},
+			expectValid: false,
+		},
+		{
+			name: "Invalid country code",
+			user: models.User{
+				Username:    "testuser",
+				FullName:    "Test User",
+				Email:       "test@example.com",
+				DateOfBirth: "1990-05-17",
+				Country:     "XX",
+			},
+			expectValid: false,
+		},
 		{
 			name: "Missing username",
 			user: models.User{
@@ -320,3 +520,453 @@ func BenchmarkGetUserHandler(b *testing.B) {
 		router.ServeHTTP(recorder, req)
 	}
 }
+
+func userRow(user sqlc.User) fakeRow {
+	return fakeRow{values: []interface{}{
+		user.ID,
+		user.Username,
+		user.FullName,
+		user.Email,
+		user.InsertedAt,
+		user.DateOfBirth,
+		user.Country,
+		user.DeletedAt,
+		user.PasswordHash,
+	}}
+}
+
+func TestCreateUserWithAccount(t *testing.T) {
+	user := models.User{
+		Username:    "newuser",
+		FullName:    "New User",
+		Email:       "newuser@example.com",
+		DateOfBirth: "1990-05-17",
+		Country:     "DE",
+	}
+
+	tests := []struct {
+		name        string
+		rows        map[string]fakeRow
+		expectErr   bool
+		expectQuery []string
+	}{
+		{
+			name: "User and account created",
+			rows: map[string]fakeRow{
+				"CreateUser":    userRow(sqlc.User{ID: 4, Username: "newuser"}),
+				"CreateAccount": accountRow(sqlc.Account{ID: 7, UserID: 4}),
+			},
+			expectQuery: []string{"SET", "CreateUser", "CreateAccount"},
+		},
+		{
+			name: "Account creation fails",
+			rows: map[string]fakeRow{
+				"CreateUser":    userRow(sqlc.User{ID: 4, Username: "newuser"}),
+				"CreateAccount": {err: errors.New("connection reset")},
+			},
+			expectErr:   true,
+			expectQuery: []string{"SET", "CreateUser", "CreateAccount"},
+		},
+		{
+			name: "User creation fails",
+			rows: map[string]fakeRow{
+				"CreateUser": {err: errors.New("duplicate key value violates unique constraint")},
+			},
+			expectErr:   true,
+			expectQuery: []string{"SET", "CreateUser"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{rows: tt.rows}
+			starter := &fakeStarter{db: db}
+
+			userCreated, account, err := createUserWithAccount(context.Background(), starter, sqlc.New(db), user)
+
+			assert.Len(t, starter.txs, 1)
+			assert.Equal(t, tt.expectQuery, db.queries)
+			if tt.expectErr {
+				assert.Error(t, err)
+				assert.True(t, starter.txs[0].rolledBack)
+				assert.False(t, starter.txs[0].committed)
+				assert.Empty(t, userCreated.ID)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.True(t, starter.txs[0].committed)
+			assert.Equal(t, int64(4), userCreated.ID)
+			assert.Equal(t, int64(4), account.UserID)
+		})
+	}
+}
+
+func TestExistingUserByEmail(t *testing.T) {
+	existing := sqlc.User{ID: 7, Username: "jdoe", Email: "jane@example.com"}
+	duplicateEmail := &pgconn.PgError{Code: helpers.PgUniqueViolation, ConstraintName: "users_email_key"}
+
+	tests := []struct {
+		name        string
+		rows        map[string]fakeRow
+		createErr   error
+		expectedID  int64
+		expectedErr error
+	}{
+		{
+			name:       "Duplicate email returns the existing user",
+			rows:       map[string]fakeRow{"GetUserByEmail": userRow(existing)},
+			createErr:  duplicateEmail,
+			expectedID: 7,
+		},
+		{
+			name:        "Duplicate username keeps the conflict",
+			rows:        map[string]fakeRow{"GetUserByEmail": userRow(existing)},
+			createErr:   &pgconn.PgError{Code: helpers.PgUniqueViolation, ConstraintName: "users_username_key"},
+			expectedErr: &pgconn.PgError{Code: helpers.PgUniqueViolation, ConstraintName: "users_username_key"},
+		},
+		{
+			name:        "Email held by a deleted user keeps the conflict",
+			createErr:   duplicateEmail,
+			expectedErr: duplicateEmail,
+		},
+		{
+			name:        "Other errors are returned as is",
+			createErr:   errors.New("connection reset"),
+			expectedErr: errors.New("connection reset"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fakeDB{rows: tt.rows}
+
+			user, err := existingUserByEmail(context.Background(), sqlc.New(db), "jane@example.com", tt.createErr)
+
+			if tt.expectedErr != nil {
+				assert.Equal(t, tt.expectedErr, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedID, user.ID)
+			assert.Equal(t, []interface{}{"jane@example.com"}, db.args["GetUserByEmail"])
+		})
+	}
+
+	// Without a valid Upsert header the request is rejected before touching the database
+	req := httptest.NewRequest("POST", "/user", strings.NewReader(`{}`))
+	req.Header.Set("Upsert", "sometimes")
+	recorder := httptest.NewRecorder()
+	CreateUserHandler(recorder, req)
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "Upsert header must be true or false")
+}
+
+func TestCreateUserWithoutAccount(t *testing.T) {
+	user := models.User{
+		Username:    "newuser",
+		FullName:    "New User",
+		Email:       "newuser@example.com",
+		DateOfBirth: "1990-05-17",
+		Country:     "DE",
+	}
+	db := &fakeDB{rows: map[string]fakeRow{
+		"CreateUser":    userRow(sqlc.User{ID: 4, Username: "newuser"}),
+		"CreateAccount": accountRow(sqlc.Account{ID: 7, UserID: 4, Currency: "GBP", AccountType: "savings"}),
+	}}
+	queries := sqlc.New(db)
+
+	userCreated, err := createUserWithoutAccount(context.Background(), queries, user)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), userCreated.ID)
+	assert.Equal(t, []string{"CreateUser"}, db.queries)
+
+	// The account is provisioned later through the separate endpoint
+	account, err := createAccountInDB(context.Background(), queries, userCreated.ID, "savings", "", "", nil)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), account.UserID)
+	assert.Equal(t, []string{"CreateUser", "CreateAccount"}, db.queries)
+	assert.Equal(t, int64(4), db.args["CreateAccount"][0])
+}
+
+func TestCreateUserAccountFlag(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/user", CreateUserHandler).Methods("POST")
+
+	req := httptest.NewRequest("POST", "/user?create_account=maybe", bytes.NewBufferString(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "create_account must be true or false")
+}
+
+func TestUserNegativeCache(t *testing.T) {
+	t.Setenv("USER_NEGATIVE_CACHE_TTL_SECONDS", "30")
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	original := missingUsers
+	missingUsers = helpers.NewNegativeCache(helpers.UserNegativeCacheTTL, func() time.Time { return now })
+	defer func() { missingUsers = original }()
+
+	created := false
+	db := &fakeDB{
+		rows: map[string]fakeRow{
+			"CreateUser":    userRow(sqlc.User{ID: 4, Username: "newuser"}),
+			"CreateAccount": accountRow(sqlc.Account{ID: 7, UserID: 4}),
+		},
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			if name == "GetUser" && created && args[0] == int64(4) {
+				return userRow(sqlc.User{ID: 4, Username: "newuser"}), true
+			}
+			return fakeRow{}, false
+		},
+	}
+	queries := sqlc.New(db)
+	countLookups := func() int {
+		count := 0
+		for _, query := range db.queries {
+			if query == "GetUser" {
+				count++
+			}
+		}
+		return count
+	}
+
+	// The first miss reaches the database, the repeated probe is answered from the cache
+	_, err := findUser(context.Background(), queries, 4)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	_, err = findUser(context.Background(), queries, 4)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	assert.Equal(t, 1, countLookups())
+
+	// Creating the user invalidates the cached miss
+	_, _, err = createUserWithAccount(context.Background(), &fakeStarter{db: db}, queries, models.User{
+		Username:    "newuser",
+		FullName:    "New User",
+		Email:       "newuser@example.com",
+		DateOfBirth: "1990-05-17",
+		Country:     "DE",
+	})
+	assert.NoError(t, err)
+	created = true
+
+	user, err := findUser(context.Background(), queries, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, "newuser", user.Username)
+	assert.Equal(t, 2, countLookups())
+
+	// Misses expire after the TTL
+	_, err = findUser(context.Background(), queries, 5)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	now = now.Add(31 * time.Second)
+	_, err = findUser(context.Background(), queries, 5)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+	assert.Equal(t, 4, countLookups())
+
+	// A miss observed before a concurrent creation is not cached
+	generation := missingUsers.Generation()
+	missingUsers.Invalidate(6)
+	missingUsers.Add(6, generation)
+	assert.False(t, missingUsers.Contains(6))
+}
+
+func TestUserNegativeCacheDisabled(t *testing.T) {
+	t.Setenv("USER_NEGATIVE_CACHE_TTL_SECONDS", "0")
+	cache := helpers.NewNegativeCache(helpers.UserNegativeCacheTTL, time.Now)
+
+	cache.Add(4, cache.Generation())
+
+	assert.False(t, cache.Contains(4))
+}
+
+func TestUserLocation(t *testing.T) {
+	tests := []struct {
+		name             string
+		trustProxy       string
+		tls              bool
+		forwardedHeaders map[string]string
+		expected         string
+	}{
+		{
+			name:     "Plain HTTP",
+			expected: "http://api.internal:8000/user/4",
+		},
+		{
+			name:     "Direct TLS",
+			tls:      true,
+			expected: "https://api.internal:8000/user/4",
+		},
+		{
+			name:             "Forwarded headers ignored when proxy is untrusted",
+			forwardedHeaders: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "bank.example.com"},
+			expected:         "http://api.internal:8000/user/4",
+		},
+		{
+			name:             "Forwarded headers used when proxy is trusted",
+			trustProxy:       "true",
+			forwardedHeaders: map[string]string{"X-Forwarded-Proto": "https", "X-Forwarded-Host": "bank.example.com"},
+			expected:         "https://bank.example.com/user/4",
+		},
+		{
+			name:             "First hop of a proxy chain wins",
+			trustProxy:       "true",
+			forwardedHeaders: map[string]string{"X-Forwarded-Proto": "https, http", "X-Forwarded-Host": "bank.example.com, lb.internal"},
+			expected:         "https://bank.example.com/user/4",
+		},
+		{
+			name:             "Unknown forwarded scheme is ignored",
+			trustProxy:       "true",
+			forwardedHeaders: map[string]string{"X-Forwarded-Proto": "gopher"},
+			expected:         "http://api.internal:8000/user/4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY_HEADERS", tt.trustProxy)
+			t.Setenv("API_BASE_PATH", "")
+
+			req := httptest.NewRequest("POST", "http://api.internal:8000/user", nil)
+			if tt.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for key, value := range tt.forwardedHeaders {
+				req.Header.Set(key, value)
+			}
+
+			assert.Equal(t, tt.expected, userLocation(req, 4))
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	deletedAt := models.NewTimestamp(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC))
+	db := &fakeDB{rows: map[string]fakeRow{"SoftDeleteUser": userRow(sqlc.User{ID: 1, Username: "user1", DeletedAt: deletedAt})}}
+
+	user, err := deleteUser(context.Background(), sqlc.New(db), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, deletedAt, user.DeletedAt)
+	assert.Equal(t, []interface{}{int64(1)}, db.args["SoftDeleteUser"])
+
+	// Unknown and already deleted users are not found
+	_, err = deleteUser(context.Background(), sqlc.New(&fakeDB{}), 1)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	recorder := httptest.NewRecorder()
+	helpers.HandleDatabaseError(recorder, err, "User")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Contains(t, recorder.Body.String(), "User not found")
+}
+
+func TestLookupUser(t *testing.T) {
+	db := &fakeDB{
+		rowFunc: func(name string, args []interface{}) (fakeRow, bool) {
+			switch {
+			case name == "GetUserByEmail" && args[0] == "user1@example.com",
+				name == "GetUserByUsername" && args[0] == "user1":
+				return userRow(sqlc.User{ID: 1, Username: "user1", Email: "user1@example.com"}), true
+			}
+			return fakeRow{}, false
+		},
+	}
+	queries := sqlc.New(db)
+
+	user, err := lookupUser(context.Background(), queries, "user1@example.com", "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), user.ID)
+
+	user, err = lookupUser(context.Background(), queries, "", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), user.ID)
+	assert.Equal(t, []string{"GetUserByEmail", "GetUserByUsername"}, db.queries)
+
+	_, err = lookupUser(context.Background(), queries, "", "nobody")
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+	// Not found maps to 404
+	recorder := httptest.NewRecorder()
+	helpers.HandleDatabaseError(recorder, err, "User")
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
+
+func TestListUsers(t *testing.T) {
+	summaryRow := func(id int64, username string) fakeRow {
+		return fakeRow{values: []interface{}{id, username, "Full " + username, username + "@example.com"}}
+	}
+	db := &fakeDB{
+		rows: map[string]fakeRow{
+			"CountUsers":       {values: []interface{}{int64(42)}},
+			"CountSearchUsers": {values: []interface{}{int64(1)}},
+		},
+		results: map[string][]fakeRow{
+			"ListUsers":   {summaryRow(21, "alice"), summaryRow(22, "bob")},
+			"SearchUsers": {summaryRow(5, "a_b")},
+		},
+	}
+	queries := sqlc.New(db)
+
+	users, total, err := listUsers(context.Background(), queries, "", 20, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), total)
+	assert.Equal(t, []models.UserSummary{
+		{ID: 21, Username: "alice", FullName: "Full alice", Email: "alice@example.com"},
+		{ID: 22, Username: "bob", FullName: "Full bob", Email: "bob@example.com"},
+	}, users)
+	assert.Equal(t, []interface{}{int32(20), int32(20)}, db.args["ListUsers"])
+
+	// Wildcards in the query match literally
+	users, total, err = listUsers(context.Background(), queries, "a_b", 10, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Len(t, users, 1)
+	assert.Equal(t, []interface{}{`a\_b%`, int32(10), int32(0)}, db.args["SearchUsers"])
+	assert.Equal(t, []interface{}{`a\_b%`}, db.args["CountSearchUsers"])
+}
+
+func TestListUsersHandlerParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "Limit above the maximum", query: "?limit=101"},
+		{name: "Negative offset", query: "?offset=-1"},
+		{name: "Non-numeric limit", query: "?limit=ten"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			ListUsersHandler(recorder, req)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		})
+	}
+}
+
+func TestLookupUserHandlerParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "No parameter", query: ""},
+		{name: "Empty parameters", query: "?email=&username="},
+		{name: "Both parameters", query: "?email=user1@example.com&username=user1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users/lookup"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			LookupUserHandler(recorder, req)
+
+			assert.Equal(t, http.StatusBadRequest, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), "Exactly one of email or username is required")
+		})
+	}
+}
@@ -1,17 +1,28 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rathorevk/GoBanking/app/api"
 	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/metrics"
 	"github.com/rathorevk/GoBanking/app/middleware"
+	"github.com/rathorevk/GoBanking/app/tracing"
 )
 
 func StartServer() {
@@ -21,6 +32,21 @@ func StartServer() {
 		log.Fatalf("Error loading .env file: %v", err)
 	}
 
+	helpers.LoadValidSources()
+
+	config := loadStartupConfig()
+	logStartupConfig(config)
+	if !config.Features.JWTAuth && !config.Features.APIKeyAuth {
+		log.Println("Neither JWT_SECRET nor API_KEY_AUTH is set: user, account and transaction routes will answer 401")
+	}
+
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
 	// Run database migrations
 	if err = database.RunMigrations(); err != nil {
 		log.Fatalf("Failed to run database migrations: %v", err)
@@ -31,37 +57,219 @@ func StartServer() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
-	// Get server address and port from environment variables
-	address := os.Getenv("SERVER_ADDRESS")
-	port := os.Getenv("SERVER_PORT")
+	if err = metrics.Register(prometheus.DefaultRegisterer); err != nil {
+		log.Fatalf("Failed to register metrics: %v", err)
+	}
 
-	// Create a new router
+	// Create a new router. Probes and scrapers hit /health, /metrics and /debug/pool constantly, so
+	// they sit outside the API middleware and do not flood the request log. Pool stats reveal the
+	// database sizing and load, so they need an admin token like the /admin routes.
 	router := mux.NewRouter()
+	router.HandleFunc("/health", api.HealthHandler).Methods("GET")
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	router.Handle("/debug/pool", middleware.AdminToken(helpers.AdminTokens())(http.HandlerFunc(api.PoolStatsHandler))).Methods("GET")
+	RegisterRoutes(router.PathPrefix("/").Subrouter())
+
+	srv := &http.Server{
+		Addr: config.Address,
+		// Set timeouts to avoid Slowloris attacks.
+		WriteTimeout: writeTimeout,
+		ReadTimeout:  readTimeout,
+		IdleTimeout:  idleTimeout,
+		Handler:      router, // Pass our instance of gorilla/mux in.
+	}
+
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		log.Fatalf("Failed to listen on %s: %v", config.Address, err)
+	}
+	log.Println("Server listening on:", config.Address)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := serve(ctx, srv, listener, helpers.ShutdownTimeout()); err != nil {
+		log.Printf("Server stopped with error: %v", err)
+	}
+
+	// Close waits for acquired connections, which the finished or cancelled requests have released
+	database.DBClient.Pool.Close()
+	log.Println("Server stopped")
+}
+
+// serve handles requests until ctx is cancelled, then stops accepting connections and lets in-flight
+// requests finish within shutdownTimeout. Requests still running after that are cut off by closing
+// their connections, so a stuck request cannot block shutdown.
+func serve(ctx context.Context, srv *http.Server, listener net.Listener, shutdownTimeout time.Duration) error {
+	var inFlight atomic.Int64
+	handler := srv.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		handler.ServeHTTP(w, r)
+	})
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// Drop the remaining connections so their request contexts are cancelled and queries give back the pool
+		log.Printf("Shutdown timed out after %s, forcing %d in-flight requests closed", shutdownTimeout, inFlight.Load())
+		srv.Close()
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// RegisterRoutes installs the middleware and every route of the API on router. The database
+// must be initialized before the router serves requests.
+func RegisterRoutes(router *mux.Router) {
+	// Misconfigured limits would leave request bodies unbounded, so they stop the server from starting
+	jsonLimits, err := helpers.JSONLimitsFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid JSON limits: %v", err)
+	}
+	miniStatementSize, err := helpers.MiniStatementSize()
+	if err != nil {
+		log.Fatalf("Invalid mini-statement size: %v", err)
+	}
 
 	// Apply middleware
 	router.Use(middleware.PanicHandler)
-	router.Use(middleware.LoggingMiddleware)
+	router.Use(middleware.RequestID)
+	router.Use(middleware.Tracing)
+	router.Use(middleware.MetricsMiddleware)
+	router.Use(middleware.LoggingMiddleware(helpers.LogFormat()))
+	router.Use(middleware.StrictTransportSecurity)
+	router.Use(middleware.RequireJSON(helpers.JSONContentTypesFromEnv()))
+	router.Use(middleware.JSONLimitsGuard(jsonLimits))
+	router.Use(middleware.Gzip(helpers.GzipLevel()))
+	if helpers.ServerTimingEnabled() {
+		router.Use(middleware.ServerTiming)
+	}
 
-	// Define routes
-	router.HandleFunc("/user", api.CreateUserHandler).Methods("POST")
-	router.HandleFunc("/user/{userId}", api.GetUserHandler).Methods("GET")
-	router.HandleFunc("/user/{userId}/balance", api.GetBalanceHandler).Methods("GET")
+	// Idempotency records are namespaced by the caller, so the store runs after authentication on
+	// every route and is not a router middleware
+	idempotent := middleware.NewIdempotencyStore(helpers.IdempotencyKeyTTL(), jsonLimits.MaxBodyBytes).Middleware
 
-	// transaction route with Source header validation
-	tx_router := router.PathPrefix("/user/{userId}/transaction").Subrouter()
-	tx_router.Use(middleware.SourceHeaderMatcher)
-	tx_router.HandleFunc("", api.CreateTransactionHandler).Methods("POST")
+	// Users authenticate with a bearer JWT and may only act on their own userId. Without JWT_SECRET
+	// these routes answer 401 rather than staying open.
+	userAuth := func(handler http.Handler) http.Handler {
+		return middleware.AuthMiddleware(helpers.JWTSecret())(idempotent(handler))
+	}
 
-	srv := &http.Server{
-		Addr: fmt.Sprintf("%s:%v", address, port),
-		// Set timeouts to avoid Slowloris attacks.
-		WriteTimeout: time.Second * 15,
-		ReadTimeout:  time.Second * 15,
-		IdleTimeout:  time.Second * 60,
-		Handler:      router, // Pass our instance of gorilla/mux in.
+	// Routes also open to server-to-server integrations accept a scoped X-API-Key, when enabled, in
+	// place of the user's bearer JWT
+	userOrAPIKeyAuth := func(scope string) mux.MiddlewareFunc {
+		var lookup middleware.APIKeyLookup
+		if helpers.APIKeyAuthEnabled() {
+			lookup = api.LookupAPIKey
+		}
+		auth := middleware.UserOrAPIKeyAuth(helpers.JWTSecret(), lookup, scope)
+		return func(handler http.Handler) http.Handler {
+			return auth(idempotent(handler))
+		}
+	}
+
+	// Each expensive endpoint gets its own concurrency limit
+	expensive := func(handler http.Handler) http.Handler {
+		return middleware.ConcurrencyLimit(helpers.ExpensiveEndpointConcurrency())(handler)
+	}
+
+	// User profiles omit PII for API keys without the users:pii scope
+	redactPII := middleware.RedactPII(helpers.ScopeUsersPII, helpers.PIIFields())
+	userRead := func(handler http.HandlerFunc) http.Handler {
+		return userOrAPIKeyAuth(helpers.ScopeUsersRead)(redactPII(handler))
+	}
+
+	// Routes returning data across users need a scoped API key; user tokens are not enough
+	crossUser := func(scope string, handler http.Handler) http.Handler {
+		return userOrAPIKeyAuth(scope)(middleware.RequireAPIKey(handler))
 	}
 
-	log.Println("Server listening on:", fmt.Sprintf("%s:%v", address, port))
+	// Define routes
+	router.Handle("/user", idempotent(http.HandlerFunc(api.CreateUserHandler))).Methods("POST")
+	router.Handle("/auth/login", idempotent(http.HandlerFunc(api.LoginHandler))).Methods("POST")
+	router.Handle("/auth/refresh", idempotent(http.HandlerFunc(api.RefreshHandler))).Methods("POST")
+	router.Handle("/user/{userId}", userRead(api.GetUserHandler)).Methods("GET")
+	router.Handle("/user/{userId}", userAuth(http.HandlerFunc(api.DeleteUserHandler))).Methods("DELETE")
+	router.Handle("/users/lookup", crossUser(helpers.ScopeUsersRead, redactPII(http.HandlerFunc(api.LookupUserHandler)))).Methods("GET")
+	router.Handle("/users", crossUser(helpers.ScopeUsersRead, redactPII(http.HandlerFunc(api.ListUsersHandler)))).Methods("GET")
+	router.Handle("/accounts", userAuth(http.HandlerFunc(api.CreateAccountHandler))).Methods("POST")
+	router.Handle("/transfer", userOrAPIKeyAuth(helpers.ScopeTransactionsWrite)(http.HandlerFunc(api.TransferHandler))).Methods("POST")
+	router.Handle("/user/{userId}/balance", userOrAPIKeyAuth(helpers.ScopeBalancesRead)(http.HandlerFunc(api.GetBalanceHandler))).Methods("GET")
+	router.Handle("/user/{userId}/account/{accountId}/balance", userOrAPIKeyAuth(helpers.ScopeBalancesRead)(http.HandlerFunc(api.GetBalanceHandler))).Methods("GET")
+	router.Handle("/user/{userId}/balance/timeseries", userOrAPIKeyAuth(helpers.ScopeBalancesRead)(http.HandlerFunc(api.BalanceTimeseriesHandler))).Methods("GET")
+	router.Handle("/user/{userId}/transactions", userAuth(http.HandlerFunc(api.ListTransactionsHandler))).Methods("GET")
+	router.Handle("/user/{userId}/account/{accountId}/transactions", userAuth(http.HandlerFunc(api.ListTransactionsHandler))).Methods("GET")
+	router.Handle("/transactions/{transactionId}", userOrAPIKeyAuth(helpers.ScopeTransactionsRead)(http.HandlerFunc(api.GetTransaction))).Methods("GET")
+	router.Handle("/transactions/batch-get", crossUser(helpers.ScopeTransactionsRead, http.HandlerFunc(api.BatchGetTransactionsHandler))).Methods("POST")
+	router.Handle("/user/{userId}/networth", userAuth(http.HandlerFunc(api.NetWorthHandler))).Methods("GET")
+	router.Handle("/user/{userId}/mini-statement", userAuth(api.MiniStatementHandler(miniStatementSize))).Methods("GET")
+	router.Handle("/user/{userId}/account/{accountId}/mini-statement", userAuth(api.MiniStatementHandler(miniStatementSize))).Methods("GET")
+	router.Handle("/user/{userId}/statement", userAuth(http.HandlerFunc(api.StatementHandler))).Methods("GET")
+	router.Handle("/user/{userId}/close-all", userAuth(http.HandlerFunc(api.CloseAllAccountsHandler))).Methods("POST")
+	router.Handle("/user/{userId}/accounts", userAuth(http.HandlerFunc(api.ListAccountsHandler))).Methods("GET")
+	router.Handle("/user/{userId}/account/{accountId}", userAuth(http.HandlerFunc(api.GetAccountHandler))).Methods("GET")
+	router.Handle("/user/{userId}/account/status", userAuth(http.HandlerFunc(api.UpdateAccountStatusHandler))).Methods("PATCH")
+	router.Handle("/user/{userId}/account/{accountId}/status", userAuth(http.HandlerFunc(api.UpdateAccountStatusHandler))).Methods("PATCH")
+	router.Handle("/user/{userId}/account/{accountId}/metadata", userAuth(http.HandlerFunc(api.UpdateAccountMetadataHandler))).Methods("PUT")
+	router.Handle("/user/{userId}/account/{accountId}/export", userAuth(expensive(http.HandlerFunc(api.ExportAccountHandler)))).Methods("GET")
+	router.Handle("/user/{userId}/notification-preferences", userAuth(http.HandlerFunc(api.GetNotificationPreferencesHandler))).Methods("GET")
+	router.Handle("/user/{userId}/notification-preferences", userAuth(http.HandlerFunc(api.UpdateNotificationPreferencesHandler))).Methods("PUT")
+	router.Handle("/user/{userId}/notification-preferences", userAuth(http.HandlerFunc(api.DeleteNotificationPreferencesHandler))).Methods("DELETE")
+	router.HandleFunc("/fx/rate", api.FXRateHandler).Methods("GET")
+	router.HandleFunc("/meta", api.MetaHandler).Methods("GET")
+	router.HandleFunc("/meta/source-types", api.SourceTypesHandler).Methods("GET")
+
+	registerAdminRoutes(router, idempotent, expensive)
+
+	// confirmation replays the stored source, so it skips the Source header check
+	router.Handle("/user/{userId}/transaction/confirm", userOrAPIKeyAuth(helpers.ScopeTransactionsWrite)(http.HandlerFunc(api.ConfirmTransactionHandler))).Methods("POST")
+
+	// transaction routes with Source header validation, on the user's first account or a given one.
+	// Both share the rate limiters so the limits hold across them.
+	ipRateLimiter := middleware.NewIPRateLimiter(helpers.IPRateLimit())
+	sourceRateLimiter := middleware.NewSourceRateLimiter(helpers.SourceRateLimits())
+	for _, prefix := range []string{"/user/{userId}/transaction", "/user/{userId}/account/{accountId}/transaction"} {
+		tx_router := router.PathPrefix(prefix).Subrouter()
+		tx_router.Use(ipRateLimiter.Middleware)
+		tx_router.Use(middleware.SourceHeaderMatcher)
+		tx_router.Use(sourceRateLimiter.Middleware)
+		tx_router.Use(userOrAPIKeyAuth(helpers.ScopeTransactionsWrite))
+		tx_router.HandleFunc("", api.CreateTransactionHandler).Methods("POST")
+	}
+}
 
-	log.Fatal(srv.ListenAndServe())
+// registerAdminRoutes installs the /admin routes behind the admin token guard. Admin routes are only
+// registered here, so none can be reached before the guard is in place. They are disabled unless
+// ADMIN_TOKEN or ADMIN_TOKENS is set.
+func registerAdminRoutes(router *mux.Router, idempotent mux.MiddlewareFunc, expensive func(http.Handler) http.Handler) {
+	admin_router := router.PathPrefix("/admin").Subrouter()
+	admin_router.Use(middleware.AdminToken(helpers.AdminTokens()))
+	admin_router.Use(idempotent)
+	admin_router.HandleFunc("/payouts", api.CreatePayoutsHandler).Methods("POST")
+	admin_router.HandleFunc("/accounts/{accountId}/corrections", api.CreateCorrectionHandler).Methods("POST")
+	admin_router.HandleFunc("/audit", api.ListAdminAuditHandler).Methods("GET")
+	admin_router.HandleFunc("/transactions", api.ListAdminTransactionsHandler).Methods("GET")
+	admin_router.HandleFunc("/api-keys", api.CreateAPIKeyHandler).Methods("POST")
+	admin_router.Handle("/ledger/verify", expensive(http.HandlerFunc(api.VerifyLedgerHandler))).Methods("GET")
 }
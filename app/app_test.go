@@ -1,13 +1,22 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/rathorevk/GoBanking/app/api"
+	"github.com/rathorevk/GoBanking/app/helpers"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestServerRoutes(t *testing.T) {
@@ -53,3 +62,254 @@ func TestRouterConfiguration(t *testing.T) {
 	// Test that router is properly initialized
 	assert.NotNil(t, router)
 }
+
+func TestTransactionListRoute(t *testing.T) {
+	const secret = "test-secret"
+	t.Setenv("JWT_SECRET", secret)
+	router := mux.NewRouter()
+	RegisterRoutes(router)
+
+	now := time.Now()
+	token, err := helpers.NewAccessToken(1, secret, now, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Invalid user ID", url: "/user/abc/transactions", expectedStatus: http.StatusBadRequest, expectedError: "Invalid ID format"},
+		{name: "Limit above the maximum", url: "/user/1/transactions?limit=101", expectedStatus: http.StatusBadRequest, expectedError: "Limit must be between 1 and 100"},
+		{name: "Negative offset", url: "/user/1/transactions?offset=-1", expectedStatus: http.StatusBadRequest, expectedError: "offset must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			// Served by the list handler, not the transaction subrouter sharing its prefix
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Contains(t, recorder.Body.String(), tt.expectedError)
+		})
+	}
+}
+
+func TestCrossUserRoutesRequireAuthentication(t *testing.T) {
+	const secret = "test-secret"
+	t.Setenv("JWT_SECRET", secret)
+	router := mux.NewRouter()
+	RegisterRoutes(router)
+
+	now := time.Now()
+	token, err := helpers.NewAccessToken(7, secret, now, now.Add(time.Hour))
+	require.NoError(t, err)
+
+	routes := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{method: "GET", url: "/users"},
+		{method: "GET", url: "/users/lookup?username=alice"},
+		{method: "POST", url: "/transactions/batch-get", body: `{"ids":["1"]}`},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.url, func(t *testing.T) {
+			for _, tt := range []struct {
+				name           string
+				authorization  string
+				expectedStatus int
+			}{
+				{name: "without a bearer token", expectedStatus: http.StatusUnauthorized},
+				{name: "with a user's bearer token", authorization: "Bearer " + token, expectedStatus: http.StatusForbidden},
+			} {
+				req := httptest.NewRequest(route.method, route.url, strings.NewReader(route.body))
+				req.Header.Set("Content-Type", "application/json")
+				if tt.authorization != "" {
+					req.Header.Set("Authorization", tt.authorization)
+				}
+
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+
+				assert.Equal(t, tt.expectedStatus, recorder.Code, tt.name)
+			}
+		})
+	}
+}
+
+func TestRoutesFailClosedWithoutAuthentication(t *testing.T) {
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("API_KEY_AUTH", "false")
+	router := mux.NewRouter()
+	RegisterRoutes(router)
+
+	routes := []struct {
+		method string
+		url    string
+		body   string
+	}{
+		{method: "GET", url: "/user/1"},
+		{method: "GET", url: "/user/1/balance"},
+		{method: "GET", url: "/user/1/transactions"},
+		{method: "GET", url: "/user/1/account/2"},
+		{method: "POST", url: "/accounts", body: `{"user_id":"1","currency":"EUR"}`},
+		{method: "POST", url: "/transfer", body: `{}`},
+		{method: "GET", url: "/users"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.url, func(t *testing.T) {
+			req := httptest.NewRequest(route.method, route.url, strings.NewReader(route.body))
+			req.Header.Set("Content-Type", "application/json")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		})
+	}
+}
+
+// adminRoutes lists the method and path of every route registered under /admin.
+func adminRoutes(t *testing.T, router *mux.Router) [][2]string {
+	var routes [][2]string
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/admin/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		for _, method := range methods {
+			routes = append(routes, [2]string{method, path})
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, routes)
+	return routes
+}
+
+func TestAdminRoutesRequireAdminToken(t *testing.T) {
+	t.Run("Disabled without tokens", func(t *testing.T) {
+		t.Setenv("ADMIN_TOKEN", "")
+		t.Setenv("ADMIN_TOKENS", "")
+		router := mux.NewRouter()
+		RegisterRoutes(router)
+
+		for _, route := range adminRoutes(t, router) {
+			req := httptest.NewRequest(route[0], route[1], nil)
+			req.Header.Set("Authorization", "Bearer anything")
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, http.StatusNotFound, recorder.Code, route[0]+" "+route[1])
+		}
+	})
+
+	t.Run("Wrong token is rejected", func(t *testing.T) {
+		t.Setenv("ADMIN_TOKEN", "")
+		t.Setenv("ADMIN_TOKENS", "alice:s3cret")
+		router := mux.NewRouter()
+		RegisterRoutes(router)
+
+		for _, route := range adminRoutes(t, router) {
+			for _, header := range []string{"", "Bearer wrong"} {
+				req := httptest.NewRequest(route[0], route[1], nil)
+				if header != "" {
+					req.Header.Set("Authorization", header)
+				}
+				recorder := httptest.NewRecorder()
+				router.ServeHTTP(recorder, req)
+
+				assert.Equal(t, http.StatusUnauthorized, recorder.Code, route[0]+" "+route[1]+" "+header)
+			}
+		}
+	})
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, listener, time.Second)
+	}()
+
+	responded := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responded <- 0
+			return
+		}
+		resp.Body.Close()
+		responded <- resp.StatusCode
+	}()
+
+	// Shut down while the request is in flight; it still completes
+	<-started
+	cancel()
+
+	assert.Equal(t, http.StatusOK, <-responded)
+	assert.NoError(t, <-served)
+
+	// No new connections are accepted after shutdown
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestServeTimesOutSlowRequests(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, srv, listener, 50*time.Millisecond)
+	}()
+
+	go func() {
+		if resp, err := http.Get("http://" + listener.Addr().String()); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	<-started
+	cancel()
+
+	// The handler never returns on its own, yet shutdown completes shortly after the drain window
+	select {
+	case err := <-served:
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not complete within the drain window")
+	}
+	assert.Contains(t, output.String(), "forcing 1 in-flight requests closed")
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/helpers"
+)
+
+// Version is the build version, set with -ldflags "-X github.com/rathorevk/GoBanking/app.Version=<version>"
+var Version = "dev"
+
+// Server timeouts, set to avoid Slowloris attacks
+const (
+	readTimeout  = time.Second * 15
+	writeTimeout = time.Second * 15
+	idleTimeout  = time.Second * 60
+)
+
+// redactedValue replaces secrets in the logged startup config
+const redactedValue = "[REDACTED]"
+
+type poolConfig struct {
+	MaxConns        int32  `json:"max_conns"`
+	MinConns        int32  `json:"min_conns"`
+	MaxConnLifetime string `json:"max_conn_lifetime"`
+	MaxConnIdleTime string `json:"max_conn_idle_time"`
+}
+
+type featureConfig struct {
+	APIKeyAuth              bool           `json:"api_key_auth"`
+	JWTAuth                 bool           `json:"jwt_auth"`
+	AdminRoutes             bool           `json:"admin_routes"`
+	ServerTiming            bool           `json:"server_timing"`
+	CrossCurrency           bool           `json:"cross_currency_transactions"`
+	TrustProxyHeaders       bool           `json:"trust_proxy_headers"`
+	SignedAmounts           bool           `json:"signed_amounts"`
+	Tracing                 bool           `json:"tracing"`
+	ValidSources            []string       `json:"valid_sources"`
+	IdempotencyScope        string         `json:"idempotency_scope"`
+	GzipLevel               int            `json:"gzip_level"`
+	StepUpThreshold         float64        `json:"step_up_threshold"`
+	LockTimeoutMillis       int            `json:"lock_timeout_ms"`
+	VelocityMaxTransactions int            `json:"velocity_max_transactions"`
+	VelocityWindow          string         `json:"velocity_window"`
+	RateLimitsPerMinute     map[string]int `json:"rate_limits_per_minute"`
+	RateLimitIPPerSecond    int            `json:"rate_limit_ip_per_second"`
+	RateLimitIPBurst        int            `json:"rate_limit_ip_burst"`
+	UserNegativeCacheTTL    string         `json:"user_negative_cache_ttl"`
+	ExpensiveConcurrency    int            `json:"expensive_endpoint_concurrency"`
+	AccountConcurrency      int            `json:"account_max_concurrent_transactions"`
+	SlowQueryThreshold      string         `json:"slow_query_threshold"`
+}
+
+// startupConfig is the configuration the process resolved at startup
+type startupConfig struct {
+	Version      string        `json:"version"`
+	Address      string        `json:"address"`
+	ReadTimeout  string        `json:"read_timeout"`
+	WriteTimeout string        `json:"write_timeout"`
+	IdleTimeout  string        `json:"idle_timeout"`
+	DatabaseURL  string        `json:"database_url"`
+	DBHost       string        `json:"db_host"`
+	DBPort       string        `json:"db_port"`
+	DBUser       string        `json:"db_user"`
+	DBPassword   string        `json:"db_password"`
+	DBName       string        `json:"db_name"`
+	Pool         poolConfig    `json:"pool"`
+	Features     featureConfig `json:"features"`
+}
+
+func loadStartupConfig() startupConfig {
+	maxTransactions, window := helpers.VelocityLimit()
+	ipPerSecond, ipBurst := helpers.IPRateLimit()
+	pool := database.PoolSettingsFromEnv()
+
+	return startupConfig{
+		Version:      Version,
+		Address:      fmt.Sprintf("%s:%v", os.Getenv("SERVER_ADDRESS"), os.Getenv("SERVER_PORT")),
+		ReadTimeout:  readTimeout.String(),
+		WriteTimeout: writeTimeout.String(),
+		IdleTimeout:  idleTimeout.String(),
+		DatabaseURL:  os.Getenv("DATABASE_URL"),
+		DBHost:       os.Getenv("DB_HOST"),
+		DBPort:       os.Getenv("DB_PORT"),
+		DBUser:       os.Getenv("DB_USER"),
+		DBPassword:   os.Getenv("DB_PASSWORD"),
+		DBName:       os.Getenv("DB_NAME"),
+		Pool: poolConfig{
+			MaxConns:        pool.MaxConns,
+			MinConns:        pool.MinConns,
+			MaxConnLifetime: pool.MaxConnLifetime.String(),
+			MaxConnIdleTime: pool.MaxConnIdleTime.String(),
+		},
+		Features: featureConfig{
+			APIKeyAuth:              helpers.APIKeyAuthEnabled(),
+			JWTAuth:                 helpers.JWTSecret() != "",
+			AdminRoutes:             len(helpers.AdminTokens()) > 0,
+			ServerTiming:            helpers.ServerTimingEnabled(),
+			CrossCurrency:           helpers.CrossCurrencyTransactionsEnabled(),
+			TrustProxyHeaders:       helpers.TrustProxyHeaders(),
+			SignedAmounts:           helpers.SignedAmountMode(),
+			Tracing:                 os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "",
+			ValidSources:            helpers.ValidSources(),
+			IdempotencyScope:        helpers.IdempotencyScope(),
+			GzipLevel:               helpers.GzipLevel(),
+			StepUpThreshold:         helpers.StepUpThreshold(),
+			LockTimeoutMillis:       helpers.LockTimeoutMillis(),
+			VelocityMaxTransactions: maxTransactions,
+			VelocityWindow:          window.String(),
+			RateLimitsPerMinute:     helpers.SourceRateLimits(),
+			RateLimitIPPerSecond:    ipPerSecond,
+			RateLimitIPBurst:        ipBurst,
+			UserNegativeCacheTTL:    helpers.UserNegativeCacheTTL().String(),
+			ExpensiveConcurrency:    helpers.ExpensiveEndpointConcurrency(),
+			AccountConcurrency:      helpers.AccountMaxConcurrentTransactions(),
+			SlowQueryThreshold:      helpers.SlowQueryThreshold().String(),
+		},
+	}
+}
+
+// redacted returns a copy that is safe to log: the DB password is masked, including inside DATABASE_URL
+func (c startupConfig) redacted() startupConfig {
+	if c.DBPassword != "" {
+		c.DBPassword = redactedValue
+	}
+	c.DatabaseURL = redactURL(c.DatabaseURL)
+	return c
+}
+
+// redactURL masks the password of a connection URL, including a password query parameter,
+// or the whole value when it cannot be parsed as one
+func redactURL(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return redactedValue
+	}
+	if _, ok := parsed.User.Password(); ok {
+		parsed.User = url.UserPassword(parsed.User.Username(), "REDACTED")
+	}
+	if query := parsed.Query(); query.Has("password") {
+		query.Set("password", "REDACTED")
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.String()
+}
+
+// logStartupConfig writes the resolved configuration as a single JSON log record
+func logStartupConfig(c startupConfig) {
+	record, err := json.Marshal(c.redacted())
+	if err != nil {
+		log.Printf("Failed to encode startup config: %v", err)
+		return
+	}
+	log.Printf("Startup config: %s", record)
+}
@@ -12,6 +12,7 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
 )
 
 type DB struct {
@@ -21,11 +22,58 @@ type DB struct {
 
 var DBClient *DB
 
+// Default connection pool settings
+const (
+	DefaultMaxConns        = 10 // Max concurrent DB connections
+	DefaultMinConns        = 1  // Keep minimum connections alive
+	DefaultMaxConnLifetime = time.Hour
+	DefaultMaxConnIdleTime = time.Minute * 30
+)
+
+// PoolSettings are the limits of the connection pool
+type PoolSettings struct {
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
+}
+
+// PoolSettingsFromEnv reads DB_MAX_CONNS, DB_MIN_CONNS, DB_MAX_CONN_LIFETIME_SECONDS and
+// DB_MAX_CONN_IDLE_TIME_SECONDS, falling back to the defaults. MinConns never exceeds MaxConns.
+func PoolSettingsFromEnv() PoolSettings {
+	maxConns := helpers.GetEnvInt("DB_MAX_CONNS", DefaultMaxConns)
+	if maxConns == 0 {
+		maxConns = DefaultMaxConns
+	}
+	minConns := min(helpers.GetEnvInt("DB_MIN_CONNS", DefaultMinConns), maxConns)
+
+	return PoolSettings{
+		MaxConns:        int32(maxConns),
+		MinConns:        int32(minConns),
+		MaxConnLifetime: envSeconds("DB_MAX_CONN_LIFETIME_SECONDS", DefaultMaxConnLifetime),
+		MaxConnIdleTime: envSeconds("DB_MAX_CONN_IDLE_TIME_SECONDS", DefaultMaxConnIdleTime),
+	}
+}
+
+// envSeconds reads a positive number of seconds, falling back when it is unset or zero
+func envSeconds(key string, fallback time.Duration) time.Duration {
+	seconds := helpers.GetEnvInt(key, 0)
+	if seconds == 0 {
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// MigrationsSource is the location of the migrations relative to the repository root
+const MigrationsSource = "file://./app/database/migrations"
+
 func RunMigrations() error {
-	m, err := migrate.New(
-		"file://./app/database/migrations",
-		os.Getenv("DATABASE_URL"),
-	)
+	return RunMigrationsFrom(MigrationsSource, os.Getenv("DATABASE_URL"))
+}
+
+// RunMigrationsFrom applies the migrations at sourceURL to the database at databaseURL
+func RunMigrationsFrom(sourceURL, databaseURL string) error {
+	m, err := migrate.New(sourceURL, databaseURL)
 	if err != nil {
 		return fmt.Errorf("failed to initialize migrations: %v", err)
 	}
@@ -45,10 +93,14 @@ func getDB(url string) (*pgxpool.Pool, error) {
 	}
 
 	// Configure connection pool for concurrency
-	config.MaxConns = 10 // Max concurrent DB connections
-	config.MinConns = 1  // Keep minimum connections alive
-	config.MaxConnLifetime = time.Hour
-	config.MaxConnIdleTime = time.Minute * 30
+	settings := PoolSettingsFromEnv()
+	config.MaxConns = settings.MaxConns
+	config.MinConns = settings.MinConns
+	config.MaxConnLifetime = settings.MaxConnLifetime
+	config.MaxConnIdleTime = settings.MaxConnIdleTime
+
+	// Trace every query as a child of the calling request's span
+	config.ConnConfig.Tracer = queryTracer{slowQueryThreshold: helpers.SlowQueryThreshold()}
 
 	pool, err := pgxpool.NewWithConfig(context.Background(), config)
 	if err != nil {
@@ -73,6 +125,11 @@ func Init() (*DB, error) {
 
 	log.Println("Successfully created query objects for database:", currentDB)
 
+	// Minor units are only meaningful with the precision the ledger was written in
+	if err := loadCurrencyPrecision(context.Background(), queries); err != nil {
+		return nil, fmt.Errorf("failed to load currencies: %w", err)
+	}
+
 	// Assign to global variable
 	DBClient = &DB{
 		Pool:    pool,
@@ -81,3 +138,18 @@ func Init() (*DB, error) {
 
 	return DBClient, nil
 }
+
+// loadCurrencyPrecision reads the decimals of every currency from the currencies table
+func loadCurrencyPrecision(ctx context.Context, queries *sqlc.Queries) error {
+	currencies, err := queries.ListCurrencies(ctx)
+	if err != nil {
+		return err
+	}
+
+	precision := make(map[string]int, len(currencies))
+	for _, currency := range currencies {
+		precision[currency.Code] = int(currency.Decimals)
+	}
+	helpers.SetCurrencyPrecision(precision)
+	return nil
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolSettingsFromEnv(t *testing.T) {
+	defaults := PoolSettings{MaxConns: 10, MinConns: 1, MaxConnLifetime: time.Hour, MaxConnIdleTime: 30 * time.Minute}
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected PoolSettings
+	}{
+		{name: "Defaults", expected: defaults},
+		{
+			name: "Configured settings",
+			env: map[string]string{
+				"DB_MAX_CONNS":                  "50",
+				"DB_MIN_CONNS":                  "5",
+				"DB_MAX_CONN_LIFETIME_SECONDS":  "1800",
+				"DB_MAX_CONN_IDLE_TIME_SECONDS": "120",
+			},
+			expected: PoolSettings{MaxConns: 50, MinConns: 5, MaxConnLifetime: 30 * time.Minute, MaxConnIdleTime: 2 * time.Minute},
+		},
+		{name: "Minimum capped at the maximum", env: map[string]string{"DB_MAX_CONNS": "4", "DB_MIN_CONNS": "8"}, expected: PoolSettings{MaxConns: 4, MinConns: 4, MaxConnLifetime: time.Hour, MaxConnIdleTime: 30 * time.Minute}},
+		{name: "Zero minimum keeps no idle connections", env: map[string]string{"DB_MIN_CONNS": "0"}, expected: PoolSettings{MaxConns: 10, MinConns: 0, MaxConnLifetime: time.Hour, MaxConnIdleTime: 30 * time.Minute}},
+		{
+			name: "Zero and invalid values fall back to the defaults",
+			env: map[string]string{
+				"DB_MAX_CONNS":                  "0",
+				"DB_MIN_CONNS":                  "-1",
+				"DB_MAX_CONN_LIFETIME_SECONDS":  "0",
+				"DB_MAX_CONN_IDLE_TIME_SECONDS": "soon",
+			},
+			expected: defaults,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"DB_MAX_CONNS", "DB_MIN_CONNS", "DB_MAX_CONN_LIFETIME_SECONDS", "DB_MAX_CONN_IDLE_TIME_SECONDS"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			assert.Equal(t, tt.expected, PoolSettingsFromEnv())
+		})
+	}
+}
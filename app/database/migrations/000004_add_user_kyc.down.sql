@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP COLUMN IF EXISTS date_of_birth,
+    DROP COLUMN IF EXISTS country;
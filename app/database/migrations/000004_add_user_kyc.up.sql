@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN date_of_birth DATE,
+    ADD COLUMN country VARCHAR(2);
@@ -0,0 +1,4 @@
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_type_check;
+
+ALTER TABLE transactions ADD CONSTRAINT transactions_type_check
+    CHECK (type IN ('win', 'lose'));
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transactions_payout_batch_id;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS payout_batch_id,
+    DROP COLUMN IF EXISTS memo;
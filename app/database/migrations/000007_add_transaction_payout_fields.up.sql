@@ -0,0 +1,6 @@
+ALTER TABLE transactions
+    ADD COLUMN payout_batch_id TEXT,
+    ADD COLUMN memo TEXT;
+
+-- Index to quickly find all transactions of a payout batch
+CREATE INDEX idx_transactions_payout_batch_id ON transactions(payout_batch_id);
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS admin_audit_no_update_delete ON admin_audit;
+DROP FUNCTION IF EXISTS admin_audit_immutable();
+DROP TABLE IF EXISTS admin_audit;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS admin_audit (
+    id BIGSERIAL PRIMARY KEY,
+    actor TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target_user_id BIGINT,
+    target_account_id BIGINT,
+    params JSONB NOT NULL DEFAULT '{}',
+    inserted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_admin_audit_inserted_at ON admin_audit(inserted_at);
+
+-- Audit records are append-only
+CREATE OR REPLACE FUNCTION admin_audit_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'admin_audit records are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER admin_audit_no_update_delete
+    BEFORE UPDATE OR DELETE ON admin_audit
+    FOR EACH ROW EXECUTE FUNCTION admin_audit_immutable();
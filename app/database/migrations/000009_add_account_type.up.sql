@@ -0,0 +1,3 @@
+ALTER TABLE accounts
+    ADD COLUMN account_type VARCHAR(20) NOT NULL DEFAULT 'general'
+    CHECK (account_type IN ('general', 'checking', 'savings', 'game'));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transactions_account_client_reference;
+
+ALTER TABLE transactions
+    DROP COLUMN IF EXISTS client_reference;
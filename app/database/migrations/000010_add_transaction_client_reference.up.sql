@@ -0,0 +1,7 @@
+ALTER TABLE transactions
+    ADD COLUMN client_reference TEXT;
+
+-- At-most-once creation for clients that supply their own dedupe key
+CREATE UNIQUE INDEX idx_transactions_account_client_reference
+    ON transactions(account_id, client_reference)
+    WHERE client_reference IS NOT NULL;
@@ -0,0 +1,15 @@
+-- Large transactions wait here until the client confirms them with the token
+CREATE TABLE IF NOT EXISTS transaction_confirmations (
+    token TEXT PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id),
+    account_id BIGINT NOT NULL REFERENCES accounts(id),
+    transaction_id TEXT NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    memo TEXT,
+    client_reference TEXT,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    inserted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,9 @@
+-- Static API keys for server-to-server integrations; only the SHA-256 of a key is stored
+CREATE TABLE IF NOT EXISTS api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    sources TEXT[] NOT NULL DEFAULT '{}',
+    inserted_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
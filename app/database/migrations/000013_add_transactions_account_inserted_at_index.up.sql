@@ -0,0 +1,2 @@
+-- Supports counting an account's recent transactions for velocity limits
+CREATE INDEX IF NOT EXISTS idx_transactions_account_inserted_at ON transactions(account_id, inserted_at);
@@ -0,0 +1,2 @@
+-- Supports the admin transaction browser, which pages across all accounts by (inserted_at, id)
+CREATE INDEX IF NOT EXISTS idx_transactions_inserted_at_id ON transactions(inserted_at DESC, id DESC);
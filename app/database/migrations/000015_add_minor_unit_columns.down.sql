@@ -0,0 +1,2 @@
+ALTER TABLE transactions DROP COLUMN IF EXISTS amount_minor;
+ALTER TABLE accounts DROP COLUMN IF EXISTS balance_minor;
@@ -0,0 +1,12 @@
+-- Integer minor-unit ledger columns, written alongside the float columns during
+-- the dual-write period. They stay nullable until the verifier reports no divergences.
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS balance_minor BIGINT DEFAULT 0;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS amount_minor BIGINT;
+
+UPDATE accounts
+SET balance_minor = ROUND(balance * POWER(10, CASE currency WHEN 'JPY' THEN 0 WHEN 'BTC' THEN 8 ELSE 2 END))::BIGINT;
+
+UPDATE transactions t
+SET amount_minor = ROUND(t.amount * POWER(10, CASE a.currency WHEN 'JPY' THEN 0 WHEN 'BTC' THEN 8 ELSE 2 END))::BIGINT
+FROM accounts a
+WHERE a.id = t.account_id;
@@ -0,0 +1,4 @@
+-- Supports looking up a client reference across all accounts under the global idempotency scope
+CREATE INDEX IF NOT EXISTS idx_transactions_client_reference
+    ON transactions(client_reference)
+    WHERE client_reference IS NOT NULL;
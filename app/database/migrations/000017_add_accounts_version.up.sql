@@ -0,0 +1,2 @@
+-- Monotonic counter bumped on every balance change, returned to clients as balance_version
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Partner-defined key-value attributes such as an external partner ID or tier
+ALTER TABLE accounts ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}'::jsonb;
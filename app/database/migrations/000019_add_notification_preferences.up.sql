@@ -0,0 +1,8 @@
+-- Per-user alert opt-ins; users without a row get the defaults
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id BIGINT PRIMARY KEY REFERENCES users(id),
+    low_balance_alerts BOOLEAN NOT NULL DEFAULT TRUE,
+    large_transaction_alerts BOOLEAN NOT NULL DEFAULT TRUE,
+    channel VARCHAR(20) NOT NULL DEFAULT 'email',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
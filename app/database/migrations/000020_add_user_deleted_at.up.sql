@@ -0,0 +1,2 @@
+-- Soft-deleted users keep their rows, accounts and history but are hidden from the API
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMPTZ;
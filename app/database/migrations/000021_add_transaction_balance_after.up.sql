@@ -0,0 +1,2 @@
+-- Account balance right after each transaction, in minor units like amount_minor. Earlier rows stay NULL.
+ALTER TABLE transactions ADD COLUMN balance_after_minor BIGINT;
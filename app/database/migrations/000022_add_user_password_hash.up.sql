@@ -0,0 +1,2 @@
+-- bcrypt hash of the login password; users created without a password cannot log in
+ALTER TABLE users ADD COLUMN password_hash TEXT;
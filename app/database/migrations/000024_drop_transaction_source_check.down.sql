@@ -0,0 +1,2 @@
+ALTER TABLE transactions ADD CONSTRAINT transactions_source_check
+    CHECK (source IN ('game', 'server', 'payment'));
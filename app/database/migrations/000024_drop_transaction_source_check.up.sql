@@ -0,0 +1,2 @@
+-- Valid sources are configured with VALID_SOURCES and checked by the application
+ALTER TABLE transactions DROP CONSTRAINT IF EXISTS transactions_source_check;
@@ -0,0 +1,8 @@
+ALTER TABLE transactions ALTER COLUMN amount_minor DROP NOT NULL;
+ALTER TABLE accounts ALTER COLUMN balance_minor DROP NOT NULL;
+
+ALTER TABLE transactions ALTER COLUMN amount TYPE DECIMAL(10, 2);
+ALTER TABLE accounts ALTER COLUMN balance TYPE DECIMAL(10, 2);
+
+ALTER TABLE accounts DROP CONSTRAINT IF EXISTS accounts_currency_fkey;
+DROP TABLE IF EXISTS currencies;
@@ -0,0 +1,38 @@
+-- One table holds the minor-unit precision of every currency. The backfill below and the application
+-- both read it, so a precision is never repeated per query.
+CREATE TABLE IF NOT EXISTS currencies (
+    code VARCHAR(3) PRIMARY KEY,
+    decimals SMALLINT NOT NULL CHECK (decimals BETWEEN 0 AND 8)
+);
+
+INSERT INTO currencies (code, decimals) VALUES
+    ('EUR', 2),
+    ('USD', 2),
+    ('GBP', 2),
+    ('JPY', 0),
+    ('BTC', 8)
+ON CONFLICT (code) DO NOTHING;
+
+ALTER TABLE accounts ADD CONSTRAINT accounts_currency_fkey FOREIGN KEY (currency) REFERENCES currencies (code);
+
+-- The legacy columns are widened to 8 decimals, so they can hold every currency's minor units and
+-- the ledger verifier does not report BTC rounding as a divergence
+ALTER TABLE accounts ALTER COLUMN balance TYPE NUMERIC(28, 8);
+ALTER TABLE transactions ALTER COLUMN amount TYPE NUMERIC(28, 8);
+
+-- Rows that missed the dual write are backfilled before the minor-unit columns become mandatory
+UPDATE accounts a
+SET balance_minor = ROUND(a.balance * POWER(10, c.decimals))::BIGINT
+FROM currencies c
+WHERE c.code = a.currency
+  AND a.balance_minor IS NULL;
+
+UPDATE transactions t
+SET amount_minor = ROUND(t.amount * POWER(10, c.decimals))::BIGINT
+FROM accounts a
+JOIN currencies c ON c.code = a.currency
+WHERE a.id = t.account_id
+  AND t.amount_minor IS NULL;
+
+ALTER TABLE accounts ALTER COLUMN balance_minor SET NOT NULL;
+ALTER TABLE transactions ALTER COLUMN amount_minor SET NOT NULL;
@@ -0,0 +1,6 @@
+-- Hashed tokens cannot be turned back into the tokens clients hold, so outstanding confirmations are dropped
+DELETE FROM transaction_confirmations;
+
+ALTER TABLE transaction_confirmations DROP COLUMN amount_minor;
+ALTER TABLE transaction_confirmations ADD COLUMN amount DECIMAL(10, 2) NOT NULL;
+ALTER TABLE transaction_confirmations RENAME COLUMN token_hash TO token;
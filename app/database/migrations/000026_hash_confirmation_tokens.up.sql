@@ -0,0 +1,17 @@
+-- Confirmation tokens are stored as their SHA-256, like API keys and refresh tokens, so a leaked table
+-- cannot confirm anything. Amounts move to minor units, as DECIMAL(10, 2) cannot hold every currency.
+ALTER TABLE transaction_confirmations RENAME COLUMN token TO token_hash;
+
+UPDATE transaction_confirmations
+SET token_hash = encode(sha256(convert_to(token_hash, 'UTF8')), 'hex');
+
+ALTER TABLE transaction_confirmations ADD COLUMN amount_minor BIGINT;
+
+UPDATE transaction_confirmations tc
+SET amount_minor = ROUND(tc.amount * POWER(10, c.decimals))::BIGINT
+FROM accounts a
+JOIN currencies c ON c.code = a.currency
+WHERE a.id = tc.account_id;
+
+ALTER TABLE transaction_confirmations ALTER COLUMN amount_minor SET NOT NULL;
+ALTER TABLE transaction_confirmations DROP COLUMN amount;
@@ -1,38 +1,125 @@
 -- name: CreateAccount :one
 INSERT INTO accounts (
   user_id, 
-  balance
+  balance,
+  balance_minor,
+  currency,
+  account_type,
+  metadata
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4, $5, $6
 )
 RETURNING *;
 
 -- name: GetAccount :one
 SELECT * FROM accounts
-WHERE id = $1 LIMIT 1;
+WHERE id = $1
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+LIMIT 1;
 
 -- name: GetAccountForUpdate :one
 SELECT * FROM accounts
-WHERE id = $1 LIMIT 1;
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
 
 -- name: GetAccountByUser :one
 SELECT * FROM accounts
-WHERE user_id = $1;
+WHERE user_id = $1
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+ORDER BY id
+LIMIT 1;
 
 -- name: UpdateAccount :one
 UPDATE accounts
-SET balance = $2
+SET balance = $2,
+    balance_minor = $3,
+    last_transaction_at = NOW(),
+    version = version + 1
 WHERE id = $1
 RETURNING *;
 
 -- name: AddAccountBalance :one
 UPDATE accounts
-SET balance = balance + sqlc.arg(amount)
+SET balance = balance + sqlc.arg(amount),
+    balance_minor = balance_minor + sqlc.arg(amount_minor)::bigint,
+    last_transaction_at = NOW(),
+    version = version + 1
 WHERE id = sqlc.arg(id)
 RETURNING *;
 
+-- name: ListAccountLedgerBalances :many
+SELECT id, currency, balance, balance_minor FROM accounts
+ORDER BY id;
+
 -- name: ListAccounts :many
 SELECT * FROM accounts
 ORDER BY id
 LIMIT $1
-OFFSET $2;
\ No newline at end of file
+OFFSET $2;
+
+-- name: ListAccountsByUser :many
+SELECT * FROM accounts
+WHERE user_id = $1
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+ORDER BY id;
+
+-- name: ListAccountsByUserForUpdate :many
+SELECT * FROM accounts
+WHERE user_id = $1
+ORDER BY id
+FOR UPDATE;
+
+-- name: ListAccountsByUserMetadata :many
+SELECT * FROM accounts
+WHERE user_id = sqlc.arg(user_id)
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+  AND metadata ->> sqlc.arg(key)::text IS NOT NULL
+  AND (sqlc.narg(value)::text IS NULL OR metadata ->> sqlc.arg(key)::text = sqlc.narg(value)::text)
+ORDER BY id;
+
+-- name: UpdateAccountMetadata :one
+UPDATE accounts
+SET metadata = $2
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateAccountStatus :one
+UPDATE accounts
+SET status = $2
+WHERE id = $1 AND status <> 'closed'
+RETURNING *;
+
+-- name: CloseAccount :one
+UPDATE accounts
+SET status = 'closed'
+WHERE id = $1
+RETURNING *;
+
+
+-- name: GetMiniStatement :many
+WITH account AS (
+  SELECT id, user_id, balance_minor, currency FROM accounts
+  WHERE accounts.user_id = sqlc.arg(user_id)
+    AND (sqlc.narg(account_id)::bigint IS NULL OR accounts.id = sqlc.narg(account_id))
+    AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+  ORDER BY accounts.id
+  LIMIT 1
+)
+SELECT
+  a.id AS account_id,
+  a.user_id,
+  a.balance_minor,
+  a.currency,
+  t.id AS transaction_id,
+  t.amount_minor,
+  t.type,
+  t.source,
+  t.inserted_at
+FROM account a
+LEFT JOIN LATERAL (
+  SELECT id, amount_minor, type, source, inserted_at FROM transactions
+  WHERE transactions.account_id = a.id
+  ORDER BY inserted_at DESC, id DESC
+  LIMIT sqlc.arg(size)
+) t ON true
+ORDER BY t.inserted_at DESC, t.id DESC;
@@ -0,0 +1,17 @@
+-- name: CreateAdminAudit :one
+INSERT INTO admin_audit (
+  actor,
+  action,
+  target_user_id,
+  target_account_id,
+  params
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: ListAdminAudit :many
+SELECT * FROM admin_audit
+ORDER BY id DESC
+LIMIT $1
+OFFSET $2;
@@ -0,0 +1,14 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (
+  name,
+  key_hash,
+  scopes,
+  sources
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetAPIKeyByHash :one
+SELECT * FROM api_keys
+WHERE key_hash = $1 LIMIT 1;
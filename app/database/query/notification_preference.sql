@@ -0,0 +1,23 @@
+-- name: GetNotificationPreferences :one
+SELECT * FROM notification_preferences
+WHERE user_id = $1 LIMIT 1;
+
+-- name: UpsertNotificationPreferences :one
+INSERT INTO notification_preferences (
+  user_id,
+  low_balance_alerts,
+  large_transaction_alerts,
+  channel
+) VALUES (
+  $1, $2, $3, $4
+)
+ON CONFLICT (user_id) DO UPDATE
+SET low_balance_alerts = EXCLUDED.low_balance_alerts,
+    large_transaction_alerts = EXCLUDED.large_transaction_alerts,
+    channel = EXCLUDED.channel,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteNotificationPreferences :exec
+DELETE FROM notification_preferences
+WHERE user_id = $1;
@@ -0,0 +1,25 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (
+  token_hash,
+  user_id,
+  family_id,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4
+)
+RETURNING *;
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: MarkRefreshTokenRotated :exec
+UPDATE refresh_tokens
+SET rotated_at = NOW()
+WHERE token_hash = $1;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
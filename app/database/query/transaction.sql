@@ -4,9 +4,14 @@ INSERT INTO transactions (
   account_id,
   amount,
   source,
-  type
+  type,
+  payout_batch_id,
+  memo,
+  client_reference,
+  amount_minor,
+  balance_after_minor
 ) VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 )
 RETURNING *;
 
@@ -14,16 +19,107 @@ RETURNING *;
 -- name: ListTransactionsByAccount :many
 SELECT * FROM transactions
 WHERE account_id = $1
-ORDER BY id
+ORDER BY inserted_at DESC, id DESC
 LIMIT $2
 OFFSET $3;
 
+-- name: CountTransactionsByAccount :one
+SELECT COUNT(*) FROM transactions
+WHERE account_id = $1;
+
 -- name: GetTransaction :one
 SELECT * FROM transactions
 WHERE id = $1 LIMIT 1;
 
+-- name: GetTransactionByClientReference :one
+SELECT * FROM transactions
+WHERE account_id = $1
+  AND client_reference = $2
+LIMIT 1;
+
+-- name: ListTransactionLedgerAmounts :many
+SELECT t.id, a.currency, t.amount, t.amount_minor
+FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+ORDER BY t.inserted_at, t.id;
+
+-- name: GetTransactionByGlobalClientReference :one
+SELECT * FROM transactions
+WHERE client_reference = $1
+LIMIT 1;
+
+-- name: LockClientReference :exec
+SELECT pg_advisory_xact_lock(hashtext(sqlc.arg(client_reference)::text));
+
 -- name: ListTransactions :many
 SELECT * FROM transactions
 ORDER BY id
 LIMIT $1
 OFFSET $2;
+
+-- name: ListTransactionsByAccountAfter :many
+SELECT * FROM transactions
+WHERE account_id = sqlc.arg(account_id)
+  AND (inserted_at, id) > (COALESCE(sqlc.narg(after_inserted_at)::timestamptz, '-infinity'), sqlc.arg(after_id)::text)
+ORDER BY inserted_at, id
+LIMIT sqlc.arg(page_size);
+
+-- name: ListTransactionsByAccountBetween :many
+SELECT * FROM transactions
+WHERE account_id = sqlc.arg(account_id)
+  AND inserted_at >= sqlc.arg(from_time)
+  AND inserted_at < sqlc.arg(to_time)
+ORDER BY inserted_at, id;
+
+-- name: ListTransactionsByAccountSince :many
+SELECT * FROM transactions
+WHERE account_id = sqlc.arg(account_id)
+  AND inserted_at >= sqlc.arg(since)
+ORDER BY inserted_at, id;
+
+-- name: CountRecentTransactions :one
+SELECT COUNT(*) FROM transactions
+WHERE account_id = sqlc.arg(account_id)
+  AND inserted_at >= sqlc.arg(since);
+
+-- name: SumTransactionsByAccountSince :many
+SELECT type, COALESCE(SUM(amount_minor), 0)::bigint AS total_minor FROM transactions
+WHERE account_id = sqlc.arg(account_id)
+  AND inserted_at >= sqlc.arg(since)
+  AND type = ANY(sqlc.arg(types)::text[])
+GROUP BY type;
+
+-- name: ListAdminTransactions :many
+SELECT t.* FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+WHERE (sqlc.narg(account_id)::bigint IS NULL OR t.account_id = sqlc.narg(account_id))
+  AND (sqlc.narg(user_id)::bigint IS NULL OR a.user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(source)::text IS NULL OR t.source = sqlc.narg(source))
+  AND (sqlc.narg(type)::text IS NULL OR t.type = sqlc.narg(type))
+  AND (sqlc.narg(min_amount)::float8 IS NULL OR t.amount >= sqlc.narg(min_amount))
+  AND (sqlc.narg(max_amount)::float8 IS NULL OR t.amount <= sqlc.narg(max_amount))
+  AND (sqlc.narg(inserted_from)::timestamptz IS NULL OR t.inserted_at >= sqlc.narg(inserted_from))
+  AND (sqlc.narg(inserted_to)::timestamptz IS NULL OR t.inserted_at < sqlc.narg(inserted_to))
+  AND (t.inserted_at, t.id) < (COALESCE(sqlc.narg(after_inserted_at)::timestamptz, 'infinity'), COALESCE(sqlc.narg(after_id)::text, ''))
+ORDER BY t.inserted_at DESC, t.id DESC
+LIMIT sqlc.arg(page_size)::int;
+
+-- name: ListAdminTransactionsAscending :many
+SELECT t.* FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+WHERE (sqlc.narg(account_id)::bigint IS NULL OR t.account_id = sqlc.narg(account_id))
+  AND (sqlc.narg(user_id)::bigint IS NULL OR a.user_id = sqlc.narg(user_id))
+  AND (sqlc.narg(source)::text IS NULL OR t.source = sqlc.narg(source))
+  AND (sqlc.narg(type)::text IS NULL OR t.type = sqlc.narg(type))
+  AND (sqlc.narg(min_amount)::float8 IS NULL OR t.amount >= sqlc.narg(min_amount))
+  AND (sqlc.narg(max_amount)::float8 IS NULL OR t.amount <= sqlc.narg(max_amount))
+  AND (sqlc.narg(inserted_from)::timestamptz IS NULL OR t.inserted_at >= sqlc.narg(inserted_from))
+  AND (sqlc.narg(inserted_to)::timestamptz IS NULL OR t.inserted_at < sqlc.narg(inserted_to))
+  AND (t.inserted_at, t.id) > (COALESCE(sqlc.narg(after_inserted_at)::timestamptz, '-infinity'), COALESCE(sqlc.narg(after_id)::text, ''))
+ORDER BY t.inserted_at, t.id
+LIMIT sqlc.arg(page_size)::int;
+
+-- name: ListTransactionsByIDs :many
+SELECT * FROM transactions
+WHERE id = ANY(sqlc.arg(ids)::text[])
+ORDER BY id;
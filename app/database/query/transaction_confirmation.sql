@@ -0,0 +1,34 @@
+-- name: CreateTransactionConfirmation :one
+INSERT INTO transaction_confirmations (
+  token_hash,
+  user_id,
+  account_id,
+  transaction_id,
+  amount_minor,
+  source,
+  type,
+  memo,
+  client_reference,
+  expires_at
+) VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+RETURNING *;
+
+-- name: GetTransactionConfirmation :one
+SELECT * FROM transaction_confirmations
+WHERE token_hash = $1 LIMIT 1;
+
+-- name: GetTransactionConfirmationForUpdate :one
+SELECT * FROM transaction_confirmations
+WHERE token_hash = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: MarkTransactionConfirmationUsed :exec
+UPDATE transaction_confirmations
+SET used_at = NOW()
+WHERE token_hash = $1;
+
+-- name: ListPendingConfirmationsByAccount :many
+SELECT type, amount_minor FROM transaction_confirmations
+WHERE account_id = $1 AND used_at IS NULL AND expires_at > NOW();
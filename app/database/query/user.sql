@@ -2,16 +2,51 @@
 INSERT INTO users (
   username,
   full_name,
-  email
+  email,
+  date_of_birth,
+  country,
+  password_hash
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4, $5, $6
 )
 RETURNING *;
 
 -- name: GetUser :one
 SELECT * FROM users
-WHERE id = $1 LIMIT 1;
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1;
 
--- name: ListUsers :many
+-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING *;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: GetUserByUsername :one
 SELECT * FROM users
-ORDER BY id;
\ No newline at end of file
+WHERE username = $1 AND deleted_at IS NULL LIMIT 1;
+
+-- name: ListUsers :many
+SELECT id, username, full_name, email FROM users
+WHERE deleted_at IS NULL
+ORDER BY id
+LIMIT $1
+OFFSET $2;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE deleted_at IS NULL;
+
+-- name: SearchUsers :many
+SELECT id, username, full_name, email FROM users
+WHERE deleted_at IS NULL AND (username ILIKE sqlc.arg(pattern) OR full_name ILIKE sqlc.arg(pattern))
+ORDER BY id
+LIMIT sqlc.arg(page_limit)::int
+OFFSET sqlc.arg(page_offset)::int;
+
+-- name: CountSearchUsers :one
+SELECT COUNT(*) FROM users
+WHERE deleted_at IS NULL AND (username ILIKE sqlc.arg(pattern) OR full_name ILIKE sqlc.arg(pattern);)
\ No newline at end of file
@@ -7,22 +7,30 @@ package sqlc
 
 import (
 	"context"
+	"encoding/json"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/models"
 )
 
 const addAccountBalance = `-- name: AddAccountBalance :one
 UPDATE accounts
-SET balance = balance + $1
-WHERE id = $2
-RETURNING id, user_id, balance, currency, status, inserted_at
+SET balance = balance + $1,
+    balance_minor = balance_minor + $2::bigint,
+    last_transaction_at = NOW(),
+    version = version + 1
+WHERE id = $3
+RETURNING id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata
 `
 
 type AddAccountBalanceParams struct {
-	Amount float64 `json:"amount"`
-	ID     int64   `json:"id"`
+	Amount      pgtype.Numeric `json:"amount"`
+	AmountMinor int64          `json:"amount_minor"`
+	ID          int64          `json:"id"`
 }
 
 func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalanceParams) (Account, error) {
-	row := q.db.QueryRow(ctx, addAccountBalance, arg.Amount, arg.ID)
+	row := q.db.QueryRow(ctx, addAccountBalance, arg.Amount, arg.AmountMinor, arg.ID)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -31,6 +39,37 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 		&i.Currency,
 		&i.Status,
 		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
+	)
+	return i, err
+}
+
+const closeAccount = `-- name: CloseAccount :one
+UPDATE accounts
+SET status = 'closed'
+WHERE id = $1
+RETURNING id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata
+`
+
+func (q *Queries) CloseAccount(ctx context.Context, id int64) (Account, error) {
+	row := q.db.QueryRow(ctx, closeAccount, id)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Balance,
+		&i.Currency,
+		&i.Status,
+		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
 	)
 	return i, err
 }
@@ -38,20 +77,35 @@ func (q *Queries) AddAccountBalance(ctx context.Context, arg AddAccountBalancePa
 const createAccount = `-- name: CreateAccount :one
 INSERT INTO accounts (
   user_id, 
-  balance
+  balance,
+  balance_minor,
+  currency,
+  account_type,
+  metadata
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4, $5, $6
 )
-RETURNING id, user_id, balance, currency, status, inserted_at
+RETURNING id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata
 `
 
 type CreateAccountParams struct {
-	UserID  int64   `json:"user_id"`
-	Balance float64 `json:"balance"`
+	UserID       int64           `json:"user_id"`
+	Balance      pgtype.Numeric  `json:"balance"`
+	BalanceMinor int64           `json:"balance_minor"`
+	Currency     string          `json:"currency"`
+	AccountType  string          `json:"account_type"`
+	Metadata     json.RawMessage `json:"metadata"`
 }
 
 func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (Account, error) {
-	row := q.db.QueryRow(ctx, createAccount, arg.UserID, arg.Balance)
+	row := q.db.QueryRow(ctx, createAccount,
+		arg.UserID,
+		arg.Balance,
+		arg.BalanceMinor,
+		arg.Currency,
+		arg.AccountType,
+		arg.Metadata,
+	)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -60,13 +114,20 @@ func (q *Queries) CreateAccount(ctx context.Context, arg CreateAccountParams) (A
 		&i.Currency,
 		&i.Status,
 		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
 	)
 	return i, err
 }
 
 const getAccount = `-- name: GetAccount :one
-SELECT id, user_id, balance, currency, status, inserted_at FROM accounts
-WHERE id = $1 LIMIT 1
+SELECT id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata FROM accounts
+WHERE id = $1
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+LIMIT 1
 `
 
 func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
@@ -79,13 +140,21 @@ func (q *Queries) GetAccount(ctx context.Context, id int64) (Account, error) {
 		&i.Currency,
 		&i.Status,
 		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
 	)
 	return i, err
 }
 
 const getAccountByUser = `-- name: GetAccountByUser :one
-SELECT id, user_id, balance, currency, status, inserted_at FROM accounts
+SELECT id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata FROM accounts
 WHERE user_id = $1
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+ORDER BY id
+LIMIT 1
 `
 
 func (q *Queries) GetAccountByUser(ctx context.Context, userID int64) (Account, error) {
@@ -98,13 +167,19 @@ func (q *Queries) GetAccountByUser(ctx context.Context, userID int64) (Account,
 		&i.Currency,
 		&i.Status,
 		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
 	)
 	return i, err
 }
 
 const getAccountForUpdate = `-- name: GetAccountForUpdate :one
-SELECT id, user_id, balance, currency, status, inserted_at FROM accounts
+SELECT id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata FROM accounts
 WHERE id = $1 LIMIT 1
+FOR UPDATE
 `
 
 func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, error) {
@@ -117,12 +192,131 @@ func (q *Queries) GetAccountForUpdate(ctx context.Context, id int64) (Account, e
 		&i.Currency,
 		&i.Status,
 		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
 	)
 	return i, err
 }
 
+const getMiniStatement = `-- name: GetMiniStatement :many
+WITH account AS (
+  SELECT id, user_id, balance_minor, currency FROM accounts
+  WHERE accounts.user_id = $1
+    AND ($2::bigint IS NULL OR accounts.id = $2)
+    AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+  ORDER BY accounts.id
+  LIMIT 1
+)
+SELECT
+  a.id AS account_id,
+  a.user_id,
+  a.balance_minor,
+  a.currency,
+  t.id AS transaction_id,
+  t.amount_minor,
+  t.type,
+  t.source,
+  t.inserted_at
+FROM account a
+LEFT JOIN LATERAL (
+  SELECT id, amount_minor, type, source, inserted_at FROM transactions
+  WHERE transactions.account_id = a.id
+  ORDER BY inserted_at DESC, id DESC
+  LIMIT $3
+) t ON true
+ORDER BY t.inserted_at DESC, t.id DESC
+`
+
+type GetMiniStatementParams struct {
+	UserID    int64       `json:"user_id"`
+	AccountID pgtype.Int8 `json:"account_id"`
+	Size      int32       `json:"size"`
+}
+
+type GetMiniStatementRow struct {
+	AccountID     int64            `json:"account_id"`
+	UserID        int64            `json:"user_id"`
+	BalanceMinor  int64            `json:"balance_minor"`
+	Currency      string           `json:"currency"`
+	TransactionID pgtype.Text      `json:"transaction_id"`
+	AmountMinor   pgtype.Int8      `json:"amount_minor"`
+	Type          pgtype.Text      `json:"type"`
+	Source        pgtype.Text      `json:"source"`
+	InsertedAt    models.Timestamp `json:"inserted_at"`
+}
+
+func (q *Queries) GetMiniStatement(ctx context.Context, arg GetMiniStatementParams) ([]GetMiniStatementRow, error) {
+	rows, err := q.db.Query(ctx, getMiniStatement, arg.UserID, arg.AccountID, arg.Size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []GetMiniStatementRow{}
+	for rows.Next() {
+		var i GetMiniStatementRow
+		if err := rows.Scan(
+			&i.AccountID,
+			&i.UserID,
+			&i.BalanceMinor,
+			&i.Currency,
+			&i.TransactionID,
+			&i.AmountMinor,
+			&i.Type,
+			&i.Source,
+			&i.InsertedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountLedgerBalances = `-- name: ListAccountLedgerBalances :many
+SELECT id, currency, balance, balance_minor FROM accounts
+ORDER BY id
+`
+
+type ListAccountLedgerBalancesRow struct {
+	ID           int64          `json:"id"`
+	Currency     string         `json:"currency"`
+	Balance      pgtype.Numeric `json:"balance"`
+	BalanceMinor int64          `json:"balance_minor"`
+}
+
+func (q *Queries) ListAccountLedgerBalances(ctx context.Context) ([]ListAccountLedgerBalancesRow, error) {
+	rows, err := q.db.Query(ctx, listAccountLedgerBalances)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListAccountLedgerBalancesRow{}
+	for rows.Next() {
+		var i ListAccountLedgerBalancesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Currency,
+			&i.Balance,
+			&i.BalanceMinor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listAccounts = `-- name: ListAccounts :many
-SELECT id, user_id, balance, currency, status, inserted_at FROM accounts
+SELECT id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata FROM accounts
 ORDER BY id
 LIMIT $1
 OFFSET $2
@@ -149,6 +343,136 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 			&i.Currency,
 			&i.Status,
 			&i.InsertedAt,
+			&i.LastTransactionAt,
+			&i.AccountType,
+			&i.BalanceMinor,
+			&i.Version,
+			&i.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsByUser = `-- name: ListAccountsByUser :many
+SELECT id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata FROM accounts
+WHERE user_id = $1
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+ORDER BY id
+`
+
+func (q *Queries) ListAccountsByUser(ctx context.Context, userID int64) ([]Account, error) {
+	rows, err := q.db.Query(ctx, listAccountsByUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Balance,
+			&i.Currency,
+			&i.Status,
+			&i.InsertedAt,
+			&i.LastTransactionAt,
+			&i.AccountType,
+			&i.BalanceMinor,
+			&i.Version,
+			&i.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsByUserForUpdate = `-- name: ListAccountsByUserForUpdate :many
+SELECT id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata FROM accounts
+WHERE user_id = $1
+ORDER BY id
+FOR UPDATE
+`
+
+func (q *Queries) ListAccountsByUserForUpdate(ctx context.Context, userID int64) ([]Account, error) {
+	rows, err := q.db.Query(ctx, listAccountsByUserForUpdate, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Balance,
+			&i.Currency,
+			&i.Status,
+			&i.InsertedAt,
+			&i.LastTransactionAt,
+			&i.AccountType,
+			&i.BalanceMinor,
+			&i.Version,
+			&i.Metadata,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAccountsByUserMetadata = `-- name: ListAccountsByUserMetadata :many
+SELECT id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata FROM accounts
+WHERE user_id = $1
+  AND EXISTS (SELECT 1 FROM users WHERE users.id = accounts.user_id AND users.deleted_at IS NULL)
+  AND metadata ->> $2::text IS NOT NULL
+  AND ($3::text IS NULL OR metadata ->> $2::text = $3::text)
+ORDER BY id
+`
+
+type ListAccountsByUserMetadataParams struct {
+	UserID int64       `json:"user_id"`
+	Key    string      `json:"key"`
+	Value  pgtype.Text `json:"value"`
+}
+
+func (q *Queries) ListAccountsByUserMetadata(ctx context.Context, arg ListAccountsByUserMetadataParams) ([]Account, error) {
+	rows, err := q.db.Query(ctx, listAccountsByUserMetadata, arg.UserID, arg.Key, arg.Value)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Account{}
+	for rows.Next() {
+		var i Account
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Balance,
+			&i.Currency,
+			&i.Status,
+			&i.InsertedAt,
+			&i.LastTransactionAt,
+			&i.AccountType,
+			&i.BalanceMinor,
+			&i.Version,
+			&i.Metadata,
 		); err != nil {
 			return nil, err
 		}
@@ -162,18 +486,84 @@ func (q *Queries) ListAccounts(ctx context.Context, arg ListAccountsParams) ([]A
 
 const updateAccount = `-- name: UpdateAccount :one
 UPDATE accounts
-SET balance = $2
+SET balance = $2,
+    balance_minor = $3,
+    last_transaction_at = NOW(),
+    version = version + 1
 WHERE id = $1
-RETURNING id, user_id, balance, currency, status, inserted_at
+RETURNING id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata
 `
 
 type UpdateAccountParams struct {
-	ID      int64   `json:"id"`
-	Balance float64 `json:"balance"`
+	ID           int64          `json:"id"`
+	Balance      pgtype.Numeric `json:"balance"`
+	BalanceMinor int64          `json:"balance_minor"`
 }
 
 func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (Account, error) {
-	row := q.db.QueryRow(ctx, updateAccount, arg.ID, arg.Balance)
+	row := q.db.QueryRow(ctx, updateAccount, arg.ID, arg.Balance, arg.BalanceMinor)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Balance,
+		&i.Currency,
+		&i.Status,
+		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
+	)
+	return i, err
+}
+
+const updateAccountMetadata = `-- name: UpdateAccountMetadata :one
+UPDATE accounts
+SET metadata = $2
+WHERE id = $1
+RETURNING id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata
+`
+
+type UpdateAccountMetadataParams struct {
+	ID       int64           `json:"id"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+func (q *Queries) UpdateAccountMetadata(ctx context.Context, arg UpdateAccountMetadataParams) (Account, error) {
+	row := q.db.QueryRow(ctx, updateAccountMetadata, arg.ID, arg.Metadata)
+	var i Account
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.Balance,
+		&i.Currency,
+		&i.Status,
+		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
+	)
+	return i, err
+}
+
+const updateAccountStatus = `-- name: UpdateAccountStatus :one
+UPDATE accounts
+SET status = $2
+WHERE id = $1 AND status <> 'closed'
+RETURNING id, user_id, balance, currency, status, inserted_at, last_transaction_at, account_type, balance_minor, version, metadata
+`
+
+type UpdateAccountStatusParams struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+func (q *Queries) UpdateAccountStatus(ctx context.Context, arg UpdateAccountStatusParams) (Account, error) {
+	row := q.db.QueryRow(ctx, updateAccountStatus, arg.ID, arg.Status)
 	var i Account
 	err := row.Scan(
 		&i.ID,
@@ -182,6 +572,11 @@ func (q *Queries) UpdateAccount(ctx context.Context, arg UpdateAccountParams) (A
 		&i.Currency,
 		&i.Status,
 		&i.InsertedAt,
+		&i.LastTransactionAt,
+		&i.AccountType,
+		&i.BalanceMinor,
+		&i.Version,
+		&i.Metadata,
 	)
 	return i, err
 }
@@ -5,31 +5,105 @@
 package sqlc
 
 import (
+	"encoding/json"
+
 	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/models"
 )
 
 type Account struct {
-	ID         int64              `json:"id"`
-	UserID     int64              `json:"user_id"`
-	Balance    float64            `json:"balance"`
-	Currency   string             `json:"currency"`
-	Status     string             `json:"status"`
-	InsertedAt pgtype.Timestamptz `json:"inserted_at"`
+	ID                int64            `json:"id"`
+	UserID            int64            `json:"user_id"`
+	Balance           pgtype.Numeric   `json:"balance"`
+	Currency          string           `json:"currency"`
+	Status            string           `json:"status"`
+	InsertedAt        models.Timestamp `json:"inserted_at"`
+	LastTransactionAt models.Timestamp `json:"last_transaction_at"`
+	AccountType       string           `json:"account_type"`
+	BalanceMinor      int64            `json:"balance_minor"`
+	Version           int64            `json:"version"`
+	Metadata          json.RawMessage  `json:"metadata"`
+}
+
+type AdminAudit struct {
+	ID              int64            `json:"id"`
+	Actor           string           `json:"actor"`
+	Action          string           `json:"action"`
+	TargetUserID    pgtype.Int8      `json:"target_user_id"`
+	TargetAccountID pgtype.Int8      `json:"target_account_id"`
+	Params          []byte           `json:"params"`
+	InsertedAt      models.Timestamp `json:"inserted_at"`
+}
+
+type ApiKey struct {
+	ID         int64            `json:"id"`
+	Name       string           `json:"name"`
+	KeyHash    string           `json:"key_hash"`
+	Scopes     []string         `json:"scopes"`
+	Sources    []string         `json:"sources"`
+	InsertedAt models.Timestamp `json:"inserted_at"`
+}
+
+type Currency struct {
+	Code     string `json:"code"`
+	Decimals int16  `json:"decimals"`
+}
+
+type NotificationPreference struct {
+	UserID                 int64            `json:"user_id"`
+	LowBalanceAlerts       bool             `json:"low_balance_alerts"`
+	LargeTransactionAlerts bool             `json:"large_transaction_alerts"`
+	Channel                string           `json:"channel"`
+	UpdatedAt              models.Timestamp `json:"updated_at"`
+}
+
+type RefreshToken struct {
+	TokenHash  string           `json:"token_hash"`
+	UserID     int64            `json:"user_id"`
+	FamilyID   string           `json:"family_id"`
+	ExpiresAt  models.Timestamp `json:"expires_at"`
+	RotatedAt  models.Timestamp `json:"rotated_at"`
+	RevokedAt  models.Timestamp `json:"revoked_at"`
+	InsertedAt models.Timestamp `json:"inserted_at"`
 }
 
 type Transaction struct {
-	ID         string             `json:"id"`
-	AccountID  int64              `json:"account_id"`
-	Amount     float64            `json:"amount"`
-	Source     string             `json:"source"`
-	Type       string             `json:"type"`
-	InsertedAt pgtype.Timestamptz `json:"inserted_at"`
+	ID                string           `json:"id"`
+	AccountID         int64            `json:"account_id"`
+	Amount            pgtype.Numeric   `json:"amount"`
+	Source            string           `json:"source"`
+	Type              string           `json:"type"`
+	InsertedAt        models.Timestamp `json:"inserted_at"`
+	PayoutBatchID     pgtype.Text      `json:"payout_batch_id"`
+	Memo              pgtype.Text      `json:"memo"`
+	ClientReference   pgtype.Text      `json:"client_reference"`
+	AmountMinor       int64            `json:"amount_minor"`
+	BalanceAfterMinor pgtype.Int8      `json:"balance_after_minor"`
+}
+
+type TransactionConfirmation struct {
+	TokenHash       string           `json:"token_hash"`
+	UserID          int64            `json:"user_id"`
+	AccountID       int64            `json:"account_id"`
+	TransactionID   string           `json:"transaction_id"`
+	Source          string           `json:"source"`
+	Type            string           `json:"type"`
+	Memo            pgtype.Text      `json:"memo"`
+	ClientReference pgtype.Text      `json:"client_reference"`
+	ExpiresAt       models.Timestamp `json:"expires_at"`
+	UsedAt          models.Timestamp `json:"used_at"`
+	InsertedAt      models.Timestamp `json:"inserted_at"`
+	AmountMinor     int64            `json:"amount_minor"`
 }
 
 type User struct {
-	ID         int64              `json:"id"`
-	Username   string             `json:"username"`
-	FullName   string             `json:"full_name"`
-	Email      string             `json:"email"`
-	InsertedAt pgtype.Timestamptz `json:"inserted_at"`
+	ID           int64            `json:"id"`
+	Username     string           `json:"username"`
+	FullName     string           `json:"full_name"`
+	Email        string           `json:"email"`
+	InsertedAt   models.Timestamp `json:"inserted_at"`
+	DateOfBirth  pgtype.Date      `json:"date_of_birth"`
+	Country      pgtype.Text      `json:"country"`
+	DeletedAt    models.Timestamp `json:"deleted_at"`
+	PasswordHash pgtype.Text      `json:"-"`
 }
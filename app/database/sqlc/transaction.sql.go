@@ -7,27 +7,70 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/models"
 )
 
+const countRecentTransactions = `-- name: CountRecentTransactions :one
+SELECT COUNT(*) FROM transactions
+WHERE account_id = $1
+  AND inserted_at >= $2
+`
+
+type CountRecentTransactionsParams struct {
+	AccountID int64            `json:"account_id"`
+	Since     models.Timestamp `json:"since"`
+}
+
+func (q *Queries) CountRecentTransactions(ctx context.Context, arg CountRecentTransactionsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRecentTransactions, arg.AccountID, arg.Since)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countTransactionsByAccount = `-- name: CountTransactionsByAccount :one
+SELECT COUNT(*) FROM transactions
+WHERE account_id = $1
+`
+
+func (q *Queries) CountTransactionsByAccount(ctx context.Context, accountID int64) (int64, error) {
+	row := q.db.QueryRow(ctx, countTransactionsByAccount, accountID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createTransaction = `-- name: CreateTransaction :one
 INSERT INTO transactions (
   id,
   account_id,
   amount,
   source,
-  type
+  type,
+  payout_batch_id,
+  memo,
+  client_reference,
+  amount_minor,
+  balance_after_minor
 ) VALUES (
-  $1, $2, $3, $4, $5
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 )
-RETURNING id, account_id, amount, source, type, inserted_at
+RETURNING id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor
 `
 
 type CreateTransactionParams struct {
-	ID        string  `json:"id"`
-	AccountID int64   `json:"account_id"`
-	Amount    float64 `json:"amount"`
-	Source    string  `json:"source"`
-	Type      string  `json:"type"`
+	ID                string         `json:"id"`
+	AccountID         int64          `json:"account_id"`
+	Amount            pgtype.Numeric `json:"amount"`
+	Source            string         `json:"source"`
+	Type              string         `json:"type"`
+	PayoutBatchID     pgtype.Text    `json:"payout_batch_id"`
+	Memo              pgtype.Text    `json:"memo"`
+	ClientReference   pgtype.Text    `json:"client_reference"`
+	AmountMinor       int64          `json:"amount_minor"`
+	BalanceAfterMinor pgtype.Int8    `json:"balance_after_minor"`
 }
 
 func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionParams) (Transaction, error) {
@@ -37,6 +80,11 @@ func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionPa
 		arg.Amount,
 		arg.Source,
 		arg.Type,
+		arg.PayoutBatchID,
+		arg.Memo,
+		arg.ClientReference,
+		arg.AmountMinor,
+		arg.BalanceAfterMinor,
 	)
 	var i Transaction
 	err := row.Scan(
@@ -46,12 +94,17 @@ func (q *Queries) CreateTransaction(ctx context.Context, arg CreateTransactionPa
 		&i.Source,
 		&i.Type,
 		&i.InsertedAt,
+		&i.PayoutBatchID,
+		&i.Memo,
+		&i.ClientReference,
+		&i.AmountMinor,
+		&i.BalanceAfterMinor,
 	)
 	return i, err
 }
 
 const getTransaction = `-- name: GetTransaction :one
-SELECT id, account_id, amount, source, type, inserted_at FROM transactions
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
 WHERE id = $1 LIMIT 1
 `
 
@@ -65,12 +118,260 @@ func (q *Queries) GetTransaction(ctx context.Context, id string) (Transaction, e
 		&i.Source,
 		&i.Type,
 		&i.InsertedAt,
+		&i.PayoutBatchID,
+		&i.Memo,
+		&i.ClientReference,
+		&i.AmountMinor,
+		&i.BalanceAfterMinor,
+	)
+	return i, err
+}
+
+const getTransactionByClientReference = `-- name: GetTransactionByClientReference :one
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
+WHERE account_id = $1
+  AND client_reference = $2
+LIMIT 1
+`
+
+type GetTransactionByClientReferenceParams struct {
+	AccountID       int64       `json:"account_id"`
+	ClientReference pgtype.Text `json:"client_reference"`
+}
+
+func (q *Queries) GetTransactionByClientReference(ctx context.Context, arg GetTransactionByClientReferenceParams) (Transaction, error) {
+	row := q.db.QueryRow(ctx, getTransactionByClientReference, arg.AccountID, arg.ClientReference)
+	var i Transaction
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Amount,
+		&i.Source,
+		&i.Type,
+		&i.InsertedAt,
+		&i.PayoutBatchID,
+		&i.Memo,
+		&i.ClientReference,
+		&i.AmountMinor,
+		&i.BalanceAfterMinor,
+	)
+	return i, err
+}
+
+const getTransactionByGlobalClientReference = `-- name: GetTransactionByGlobalClientReference :one
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
+WHERE client_reference = $1
+LIMIT 1
+`
+
+func (q *Queries) GetTransactionByGlobalClientReference(ctx context.Context, clientReference pgtype.Text) (Transaction, error) {
+	row := q.db.QueryRow(ctx, getTransactionByGlobalClientReference, clientReference)
+	var i Transaction
+	err := row.Scan(
+		&i.ID,
+		&i.AccountID,
+		&i.Amount,
+		&i.Source,
+		&i.Type,
+		&i.InsertedAt,
+		&i.PayoutBatchID,
+		&i.Memo,
+		&i.ClientReference,
+		&i.AmountMinor,
+		&i.BalanceAfterMinor,
 	)
 	return i, err
 }
 
+const listAdminTransactions = `-- name: ListAdminTransactions :many
+SELECT t.id, t.account_id, t.amount, t.source, t.type, t.inserted_at, t.payout_batch_id, t.memo, t.client_reference, t.amount_minor, t.balance_after_minor FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+WHERE ($1::bigint IS NULL OR t.account_id = $1)
+  AND ($2::bigint IS NULL OR a.user_id = $2)
+  AND ($3::text IS NULL OR t.source = $3)
+  AND ($4::text IS NULL OR t.type = $4)
+  AND ($5::float8 IS NULL OR t.amount >= $5)
+  AND ($6::float8 IS NULL OR t.amount <= $6)
+  AND ($7::timestamptz IS NULL OR t.inserted_at >= $7)
+  AND ($8::timestamptz IS NULL OR t.inserted_at < $8)
+  AND (t.inserted_at, t.id) < (COALESCE($9::timestamptz, 'infinity'), COALESCE($10::text, ''))
+ORDER BY t.inserted_at DESC, t.id DESC
+LIMIT $11::int
+`
+
+type ListAdminTransactionsParams struct {
+	AccountID       pgtype.Int8      `json:"account_id"`
+	UserID          pgtype.Int8      `json:"user_id"`
+	Source          pgtype.Text      `json:"source"`
+	Type            pgtype.Text      `json:"type"`
+	MinAmount       pgtype.Float8    `json:"min_amount"`
+	MaxAmount       pgtype.Float8    `json:"max_amount"`
+	InsertedFrom    models.Timestamp `json:"inserted_from"`
+	InsertedTo      models.Timestamp `json:"inserted_to"`
+	AfterInsertedAt models.Timestamp `json:"after_inserted_at"`
+	AfterID         pgtype.Text      `json:"after_id"`
+	PageSize        int32            `json:"page_size"`
+}
+
+func (q *Queries) ListAdminTransactions(ctx context.Context, arg ListAdminTransactionsParams) ([]Transaction, error) {
+	rows, err := q.db.Query(ctx, listAdminTransactions,
+		arg.AccountID,
+		arg.UserID,
+		arg.Source,
+		arg.Type,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.InsertedFrom,
+		arg.InsertedTo,
+		arg.AfterInsertedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.Source,
+			&i.Type,
+			&i.InsertedAt,
+			&i.PayoutBatchID,
+			&i.Memo,
+			&i.ClientReference,
+			&i.AmountMinor,
+			&i.BalanceAfterMinor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAdminTransactionsAscending = `-- name: ListAdminTransactionsAscending :many
+SELECT t.id, t.account_id, t.amount, t.source, t.type, t.inserted_at, t.payout_batch_id, t.memo, t.client_reference, t.amount_minor, t.balance_after_minor FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+WHERE ($1::bigint IS NULL OR t.account_id = $1)
+  AND ($2::bigint IS NULL OR a.user_id = $2)
+  AND ($3::text IS NULL OR t.source = $3)
+  AND ($4::text IS NULL OR t.type = $4)
+  AND ($5::float8 IS NULL OR t.amount >= $5)
+  AND ($6::float8 IS NULL OR t.amount <= $6)
+  AND ($7::timestamptz IS NULL OR t.inserted_at >= $7)
+  AND ($8::timestamptz IS NULL OR t.inserted_at < $8)
+  AND (t.inserted_at, t.id) > (COALESCE($9::timestamptz, '-infinity'), COALESCE($10::text, ''))
+ORDER BY t.inserted_at, t.id
+LIMIT $11::int
+`
+
+type ListAdminTransactionsAscendingParams struct {
+	AccountID       pgtype.Int8      `json:"account_id"`
+	UserID          pgtype.Int8      `json:"user_id"`
+	Source          pgtype.Text      `json:"source"`
+	Type            pgtype.Text      `json:"type"`
+	MinAmount       pgtype.Float8    `json:"min_amount"`
+	MaxAmount       pgtype.Float8    `json:"max_amount"`
+	InsertedFrom    models.Timestamp `json:"inserted_from"`
+	InsertedTo      models.Timestamp `json:"inserted_to"`
+	AfterInsertedAt models.Timestamp `json:"after_inserted_at"`
+	AfterID         pgtype.Text      `json:"after_id"`
+	PageSize        int32            `json:"page_size"`
+}
+
+func (q *Queries) ListAdminTransactionsAscending(ctx context.Context, arg ListAdminTransactionsAscendingParams) ([]Transaction, error) {
+	rows, err := q.db.Query(ctx, listAdminTransactionsAscending,
+		arg.AccountID,
+		arg.UserID,
+		arg.Source,
+		arg.Type,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.InsertedFrom,
+		arg.InsertedTo,
+		arg.AfterInsertedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.Source,
+			&i.Type,
+			&i.InsertedAt,
+			&i.PayoutBatchID,
+			&i.Memo,
+			&i.ClientReference,
+			&i.AmountMinor,
+			&i.BalanceAfterMinor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionLedgerAmounts = `-- name: ListTransactionLedgerAmounts :many
+SELECT t.id, a.currency, t.amount, t.amount_minor
+FROM transactions t
+JOIN accounts a ON a.id = t.account_id
+ORDER BY t.inserted_at, t.id
+`
+
+type ListTransactionLedgerAmountsRow struct {
+	ID          string         `json:"id"`
+	Currency    string         `json:"currency"`
+	Amount      pgtype.Numeric `json:"amount"`
+	AmountMinor int64          `json:"amount_minor"`
+}
+
+func (q *Queries) ListTransactionLedgerAmounts(ctx context.Context) ([]ListTransactionLedgerAmountsRow, error) {
+	rows, err := q.db.Query(ctx, listTransactionLedgerAmounts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListTransactionLedgerAmountsRow{}
+	for rows.Next() {
+		var i ListTransactionLedgerAmountsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Currency,
+			&i.Amount,
+			&i.AmountMinor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTransactions = `-- name: ListTransactions :many
-SELECT id, account_id, amount, source, type, inserted_at FROM transactions
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
 ORDER BY id
 LIMIT $1
 OFFSET $2
@@ -97,6 +398,11 @@ func (q *Queries) ListTransactions(ctx context.Context, arg ListTransactionsPara
 			&i.Source,
 			&i.Type,
 			&i.InsertedAt,
+			&i.PayoutBatchID,
+			&i.Memo,
+			&i.ClientReference,
+			&i.AmountMinor,
+			&i.BalanceAfterMinor,
 		); err != nil {
 			return nil, err
 		}
@@ -109,9 +415,9 @@ func (q *Queries) ListTransactions(ctx context.Context, arg ListTransactionsPara
 }
 
 const listTransactionsByAccount = `-- name: ListTransactionsByAccount :many
-SELECT id, account_id, amount, source, type, inserted_at FROM transactions
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
 WHERE account_id = $1
-ORDER BY id
+ORDER BY inserted_at DESC, id DESC
 LIMIT $2
 OFFSET $3
 `
@@ -138,6 +444,191 @@ func (q *Queries) ListTransactionsByAccount(ctx context.Context, arg ListTransac
 			&i.Source,
 			&i.Type,
 			&i.InsertedAt,
+			&i.PayoutBatchID,
+			&i.Memo,
+			&i.ClientReference,
+			&i.AmountMinor,
+			&i.BalanceAfterMinor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionsByAccountAfter = `-- name: ListTransactionsByAccountAfter :many
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
+WHERE account_id = $1
+  AND (inserted_at, id) > (COALESCE($2::timestamptz, '-infinity'), $3::text)
+ORDER BY inserted_at, id
+LIMIT $4
+`
+
+type ListTransactionsByAccountAfterParams struct {
+	AccountID       int64            `json:"account_id"`
+	AfterInsertedAt models.Timestamp `json:"after_inserted_at"`
+	AfterID         string           `json:"after_id"`
+	PageSize        int32            `json:"page_size"`
+}
+
+func (q *Queries) ListTransactionsByAccountAfter(ctx context.Context, arg ListTransactionsByAccountAfterParams) ([]Transaction, error) {
+	rows, err := q.db.Query(ctx, listTransactionsByAccountAfter,
+		arg.AccountID,
+		arg.AfterInsertedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.Source,
+			&i.Type,
+			&i.InsertedAt,
+			&i.PayoutBatchID,
+			&i.Memo,
+			&i.ClientReference,
+			&i.AmountMinor,
+			&i.BalanceAfterMinor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionsByAccountBetween = `-- name: ListTransactionsByAccountBetween :many
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
+WHERE account_id = $1
+  AND inserted_at >= $2
+  AND inserted_at < $3
+ORDER BY inserted_at, id
+`
+
+type ListTransactionsByAccountBetweenParams struct {
+	AccountID int64            `json:"account_id"`
+	FromTime  models.Timestamp `json:"from_time"`
+	ToTime    models.Timestamp `json:"to_time"`
+}
+
+func (q *Queries) ListTransactionsByAccountBetween(ctx context.Context, arg ListTransactionsByAccountBetweenParams) ([]Transaction, error) {
+	rows, err := q.db.Query(ctx, listTransactionsByAccountBetween, arg.AccountID, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.Source,
+			&i.Type,
+			&i.InsertedAt,
+			&i.PayoutBatchID,
+			&i.Memo,
+			&i.ClientReference,
+			&i.AmountMinor,
+			&i.BalanceAfterMinor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionsByAccountSince = `-- name: ListTransactionsByAccountSince :many
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
+WHERE account_id = $1
+  AND inserted_at >= $2
+ORDER BY inserted_at, id
+`
+
+type ListTransactionsByAccountSinceParams struct {
+	AccountID int64            `json:"account_id"`
+	Since     models.Timestamp `json:"since"`
+}
+
+func (q *Queries) ListTransactionsByAccountSince(ctx context.Context, arg ListTransactionsByAccountSinceParams) ([]Transaction, error) {
+	rows, err := q.db.Query(ctx, listTransactionsByAccountSince, arg.AccountID, arg.Since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.Source,
+			&i.Type,
+			&i.InsertedAt,
+			&i.PayoutBatchID,
+			&i.Memo,
+			&i.ClientReference,
+			&i.AmountMinor,
+			&i.BalanceAfterMinor,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTransactionsByIDs = `-- name: ListTransactionsByIDs :many
+SELECT id, account_id, amount, source, type, inserted_at, payout_batch_id, memo, client_reference, amount_minor, balance_after_minor FROM transactions
+WHERE id = ANY($1::text[])
+ORDER BY id
+`
+
+func (q *Queries) ListTransactionsByIDs(ctx context.Context, ids []string) ([]Transaction, error) {
+	rows, err := q.db.Query(ctx, listTransactionsByIDs, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Transaction{}
+	for rows.Next() {
+		var i Transaction
+		if err := rows.Scan(
+			&i.ID,
+			&i.AccountID,
+			&i.Amount,
+			&i.Source,
+			&i.Type,
+			&i.InsertedAt,
+			&i.PayoutBatchID,
+			&i.Memo,
+			&i.ClientReference,
+			&i.AmountMinor,
+			&i.BalanceAfterMinor,
 		); err != nil {
 			return nil, err
 		}
@@ -148,3 +639,51 @@ func (q *Queries) ListTransactionsByAccount(ctx context.Context, arg ListTransac
 	}
 	return items, nil
 }
+
+const lockClientReference = `-- name: LockClientReference :exec
+SELECT pg_advisory_xact_lock(hashtext($1::text))
+`
+
+func (q *Queries) LockClientReference(ctx context.Context, clientReference string) error {
+	_, err := q.db.Exec(ctx, lockClientReference, clientReference)
+	return err
+}
+
+const sumTransactionsByAccountSince = `-- name: SumTransactionsByAccountSince :many
+SELECT type, COALESCE(SUM(amount_minor), 0)::bigint AS total_minor FROM transactions
+WHERE account_id = $1
+  AND inserted_at >= $2
+  AND type = ANY($3::text[])
+GROUP BY type
+`
+
+type SumTransactionsByAccountSinceParams struct {
+	AccountID int64            `json:"account_id"`
+	Since     models.Timestamp `json:"since"`
+	Types     []string         `json:"types"`
+}
+
+type SumTransactionsByAccountSinceRow struct {
+	Type       string `json:"type"`
+	TotalMinor int64  `json:"total_minor"`
+}
+
+func (q *Queries) SumTransactionsByAccountSince(ctx context.Context, arg SumTransactionsByAccountSinceParams) ([]SumTransactionsByAccountSinceRow, error) {
+	rows, err := q.db.Query(ctx, sumTransactionsByAccountSince, arg.AccountID, arg.Since, arg.Types)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []SumTransactionsByAccountSinceRow{}
+	for rows.Next() {
+		var i SumTransactionsByAccountSinceRow
+		if err := rows.Scan(&i.Type, &i.TotalMinor); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -7,27 +7,66 @@ package sqlc
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const countSearchUsers = `-- name: CountSearchUsers :one
+SELECT COUNT(*) FROM users
+WHERE deleted_at IS NULL AND (username ILIKE $1 OR full_name ILIKE $1)
+`
+
+func (q *Queries) CountSearchUsers(ctx context.Context, pattern string) (int64, error) {
+	row := q.db.QueryRow(ctx, countSearchUsers, pattern)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+WHERE deleted_at IS NULL
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRow(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
   username,
   full_name,
-  email
+  email,
+  date_of_birth,
+  country,
+  password_hash
 ) VALUES (
-  $1, $2, $3
+  $1, $2, $3, $4, $5, $6
 )
-RETURNING id, username, full_name, email, inserted_at
+RETURNING id, username, full_name, email, inserted_at, date_of_birth, country, deleted_at, password_hash
 `
 
 type CreateUserParams struct {
-	Username string `json:"username"`
-	FullName string `json:"full_name"`
-	Email    string `json:"email"`
+	Username     string      `json:"username"`
+	FullName     string      `json:"full_name"`
+	Email        string      `json:"email"`
+	DateOfBirth  pgtype.Date `json:"date_of_birth"`
+	Country      pgtype.Text `json:"country"`
+	PasswordHash pgtype.Text `json:"-"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRow(ctx, createUser, arg.Username, arg.FullName, arg.Email)
+	row := q.db.QueryRow(ctx, createUser,
+		arg.Username,
+		arg.FullName,
+		arg.Email,
+		arg.DateOfBirth,
+		arg.Country,
+		arg.PasswordHash,
+	)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -35,13 +74,17 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.FullName,
 		&i.Email,
 		&i.InsertedAt,
+		&i.DateOfBirth,
+		&i.Country,
+		&i.DeletedAt,
+		&i.PasswordHash,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, username, full_name, email, inserted_at FROM users
-WHERE id = $1 LIMIT 1
+SELECT id, username, full_name, email, inserted_at, date_of_birth, country, deleted_at, password_hash FROM users
+WHERE id = $1 AND deleted_at IS NULL LIMIT 1
 `
 
 func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
@@ -53,30 +96,138 @@ func (q *Queries) GetUser(ctx context.Context, id int64) (User, error) {
 		&i.FullName,
 		&i.Email,
 		&i.InsertedAt,
+		&i.DateOfBirth,
+		&i.Country,
+		&i.DeletedAt,
+		&i.PasswordHash,
+	)
+	return i, err
+}
+
+const getUserByEmail = `-- name: GetUserByEmail :one
+SELECT id, username, full_name, email, inserted_at, date_of_birth, country, deleted_at, password_hash FROM users
+WHERE email = $1 AND deleted_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByEmail, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Email,
+		&i.InsertedAt,
+		&i.DateOfBirth,
+		&i.Country,
+		&i.DeletedAt,
+		&i.PasswordHash,
+	)
+	return i, err
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, username, full_name, email, inserted_at, date_of_birth, country, deleted_at, password_hash FROM users
+WHERE username = $1 AND deleted_at IS NULL LIMIT 1
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRow(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Email,
+		&i.InsertedAt,
+		&i.DateOfBirth,
+		&i.Country,
+		&i.DeletedAt,
+		&i.PasswordHash,
 	)
 	return i, err
 }
 
 const listUsers = `-- name: ListUsers :many
-SELECT id, username, full_name, email, inserted_at FROM users
+SELECT id, username, full_name, email FROM users
+WHERE deleted_at IS NULL
+ORDER BY id
+LIMIT $1
+OFFSET $2
+`
+
+type ListUsersParams struct {
+	Limit  int32 `json:"limit"`
+	Offset int32 `json:"offset"`
+}
+
+type ListUsersRow struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) ListUsers(ctx context.Context, arg ListUsersParams) ([]ListUsersRow, error) {
+	rows, err := q.db.Query(ctx, listUsers, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListUsersRow{}
+	for rows.Next() {
+		var i ListUsersRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.FullName,
+			&i.Email,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchUsers = `-- name: SearchUsers :many
+SELECT id, username, full_name, email FROM users
+WHERE deleted_at IS NULL AND (username ILIKE $1 OR full_name ILIKE $1)
 ORDER BY id
+LIMIT $2::int
+OFFSET $3::int
 `
 
-func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
-	rows, err := q.db.Query(ctx, listUsers)
+type SearchUsersParams struct {
+	Pattern    string `json:"pattern"`
+	PageLimit  int32  `json:"page_limit"`
+	PageOffset int32  `json:"page_offset"`
+}
+
+type SearchUsersRow struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) SearchUsers(ctx context.Context, arg SearchUsersParams) ([]SearchUsersRow, error) {
+	rows, err := q.db.Query(ctx, searchUsers, arg.Pattern, arg.PageLimit, arg.PageOffset)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	items := []User{}
+	items := []SearchUsersRow{}
 	for rows.Next() {
-		var i User
+		var i SearchUsersRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.Username,
 			&i.FullName,
 			&i.Email,
-			&i.InsertedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -87,3 +238,27 @@ func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
 	}
 	return items, nil
 }
+
+const softDeleteUser = `-- name: SoftDeleteUser :one
+UPDATE users
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL
+RETURNING id, username, full_name, email, inserted_at, date_of_birth, country, deleted_at, password_hash
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id int64) (User, error) {
+	row := q.db.QueryRow(ctx, softDeleteUser, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.FullName,
+		&i.Email,
+		&i.InsertedAt,
+		&i.DateOfBirth,
+		&i.Country,
+		&i.DeletedAt,
+		&i.PasswordHash,
+	)
+	return i, err
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/rathorevk/GoBanking/app/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queryTracer creates a client span around every query issued through the pool, adds the
+// query time to the request's DBTimer, if any, and logs queries slower than slowQueryThreshold
+type queryTracer struct {
+	// slowQueryThreshold of zero disables slow-query logging
+	slowQueryThreshold time.Duration
+}
+
+// slowQuery is the log record of a query exceeding the slow-query threshold
+type slowQuery struct {
+	Query      string  `json:"query"`
+	DurationMS float64 `json:"duration_ms"`
+	RequestID  string  `json:"request_id,omitempty"`
+}
+
+type queryNameKey struct{}
+
+type queryStartKey struct{}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	name := queryName(data.SQL)
+	ctx, _ = tracing.Tracer().Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.operation.name", name),
+		),
+	)
+	ctx = context.WithValue(ctx, queryNameKey{}, name)
+	return context.WithValue(ctx, queryStartKey{}, time.Now())
+}
+
+func (t queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	if start, ok := ctx.Value(queryStartKey{}).(time.Time); ok {
+		duration := time.Since(start)
+		if timer := tracing.DBTimerFromContext(ctx); timer != nil {
+			timer.Add(duration)
+		}
+		if t.slowQueryThreshold > 0 && duration > t.slowQueryThreshold {
+			name, _ := ctx.Value(queryNameKey{}).(string)
+			logSlowQuery(slowQuery{
+				Query:      name,
+				DurationMS: float64(duration.Microseconds()) / 1000,
+				RequestID:  tracing.RequestIDFromContext(ctx),
+			})
+		}
+	}
+	tracing.EndSpan(trace.SpanFromContext(ctx), data.Err)
+}
+
+// logSlowQuery writes a slow query as a single JSON log record
+func logSlowQuery(query slowQuery) {
+	record, err := json.Marshal(query)
+	if err != nil {
+		log.Printf("Failed to encode slow query: %v", err)
+		return
+	}
+	log.Printf("Slow query: %s", record)
+}
+
+// queryName extracts the SQLC query name from the "-- name: GetUser :one" header
+func queryName(sql string) string {
+	const prefix = "-- name: "
+	if !strings.HasPrefix(sql, prefix) {
+		return "query"
+	}
+
+	fields := strings.Fields(sql[len(prefix):])
+	if len(fields) == 0 {
+		return "query"
+	}
+	return fields[0]
+}
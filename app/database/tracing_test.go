@@ -0,0 +1,74 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowDB runs every statement through the tracer the pool would use, taking delay to execute
+type slowDB struct {
+	tracer queryTracer
+	delay  time.Duration
+}
+
+func (db *slowDB) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx = db.tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: sql, Args: args})
+	time.Sleep(db.delay)
+	db.tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{})
+	return pgconn.CommandTag{}, nil
+}
+
+func (db *slowDB) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return nil, errors.New("slowDB: Query is not supported")
+}
+
+func (db *slowDB) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return nil
+}
+
+func TestSlowQueryLog(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold time.Duration
+		delay     time.Duration
+		expectLog bool
+	}{
+		{name: "Query above threshold is logged", threshold: 10 * time.Millisecond, delay: 30 * time.Millisecond, expectLog: true},
+		{name: "Query below threshold is not logged", threshold: time.Second, delay: 0, expectLog: false},
+		{name: "Zero threshold disables logging", threshold: 0, delay: 30 * time.Millisecond, expectLog: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			log.SetOutput(&output)
+			defer log.SetOutput(os.Stderr)
+
+			db := &slowDB{tracer: queryTracer{slowQueryThreshold: tt.threshold}, delay: tt.delay}
+			ctx := tracing.WithRequestID(context.Background(), "req-42")
+
+			err := sqlc.New(db).MarkTransactionConfirmationUsed(ctx, "token")
+			assert.NoError(t, err)
+
+			if !tt.expectLog {
+				assert.Empty(t, output.String())
+				return
+			}
+			assert.Contains(t, output.String(), "Slow query: ")
+			assert.Contains(t, output.String(), `"query":"MarkTransactionConfirmationUsed"`)
+			assert.Contains(t, output.String(), `"request_id":"req-42"`)
+			assert.Contains(t, output.String(), `"duration_ms":`)
+		})
+	}
+}
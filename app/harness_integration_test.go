@@ -0,0 +1,116 @@
+//go:build integration
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
+	"github.com/ory/dockertest/v3"
+	"github.com/ory/dockertest/v3/docker"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+// integrationMigrations is the migrations directory relative to this package
+const integrationMigrations = "file://database/migrations"
+
+// integrationJWTSecret signs the bearer tokens of the integration tests
+const integrationJWTSecret = "integration-secret"
+
+// startTestServer starts a throwaway Postgres container, applies the migrations and serves the real
+// router. The returned cleanup closes the server and the pool and removes the container.
+func startTestServer(t *testing.T) (*httptest.Server, func()) {
+	t.Helper()
+
+	pool, err := dockertest.NewPool("")
+	if err != nil {
+		t.Fatalf("Could not connect to Docker: %v", err)
+	}
+	pool.MaxWait = 2 * time.Minute
+
+	resource, err := pool.RunWithOptions(&dockertest.RunOptions{
+		Repository: "postgres",
+		Tag:        "16.4",
+		Env:        []string{"POSTGRES_USER=postgres", "POSTGRES_PASSWORD=postgres", "POSTGRES_DB=go_banking"},
+	}, func(config *docker.HostConfig) {
+		config.AutoRemove = true
+		config.RestartPolicy = docker.RestartPolicy{Name: "no"}
+	})
+	if err != nil {
+		t.Fatalf("Could not start Postgres: %v", err)
+	}
+	// Reap the container even if the test binary is killed before cleanup runs
+	_ = resource.Expire(300)
+
+	databaseURL := fmt.Sprintf("postgres://postgres:postgres@%s/go_banking?sslmode=disable", resource.GetHostPort("5432/tcp"))
+	err = pool.Retry(func() error {
+		conn, err := pgx.Connect(context.Background(), databaseURL)
+		if err != nil {
+			return err
+		}
+		defer conn.Close(context.Background())
+		return conn.Ping(context.Background())
+	})
+	if err != nil {
+		_ = pool.Purge(resource)
+		t.Fatalf("Postgres did not become ready: %v", err)
+	}
+
+	if err := database.RunMigrationsFrom(integrationMigrations, databaseURL); err != nil {
+		_ = pool.Purge(resource)
+		t.Fatalf("Failed to run migrations: %v", err)
+	}
+
+	t.Setenv("DATABASE_URL", databaseURL)
+	if _, err := database.Init(); err != nil {
+		_ = pool.Purge(resource)
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	// Routes are authenticated like in production; tests sign their own bearer tokens
+	t.Setenv("JWT_SECRET", integrationJWTSecret)
+	router := mux.NewRouter()
+	RegisterRoutes(router)
+	server := httptest.NewServer(router)
+
+	cleanup := func() {
+		server.Close()
+		database.DBClient.Pool.Close()
+		if err := pool.Purge(resource); err != nil {
+			t.Logf("Could not remove Postgres container: %v", err)
+		}
+	}
+	return server, cleanup
+}
+
+// bearerToken returns the Authorization header value of a user, signed with the integration test secret
+func bearerToken(t *testing.T, userID int64) string {
+	t.Helper()
+	now := time.Now()
+	token, err := helpers.NewAccessToken(userID, integrationJWTSecret, now, now.Add(time.Hour))
+	require.NoError(t, err)
+	return "Bearer " + token
+}
+
+// sendRequest sends a JSON request with the headers to the test server. The caller closes the body.
+func sendRequest(t *testing.T, server *httptest.Server, method, path string, headers map[string]string, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	return resp
+}
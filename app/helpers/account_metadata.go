@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Limits on the key-value metadata partners attach to accounts
+const (
+	MaxAccountMetadataKeys      = 20
+	MaxAccountMetadataKeyLength = 64
+	MaxAccountMetadataBytes     = 4096
+)
+
+var (
+	ErrInvalidMetadata  = errors.New("metadata must be an object of string or number values")
+	ErrMetadataTooLarge = errors.New("metadata exceeds the size limits")
+)
+
+// NormalizeAccountMetadata validates account metadata and returns it in compact form. It must be a
+// flat object whose values are strings or numbers; null or empty input yields an empty object.
+func NormalizeAccountMetadata(raw json.RawMessage) (json.RawMessage, error) {
+	if len(bytes.TrimSpace(raw)) == 0 || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return json.RawMessage("{}"), nil
+	}
+	if len(raw) > MaxAccountMetadataBytes {
+		return nil, ErrMetadataTooLarge
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var metadata map[string]interface{}
+	if err := decoder.Decode(&metadata); err != nil || metadata == nil {
+		return nil, ErrInvalidMetadata
+	}
+
+	if len(metadata) > MaxAccountMetadataKeys {
+		return nil, ErrMetadataTooLarge
+	}
+	for key, value := range metadata {
+		if key == "" {
+			return nil, ErrInvalidMetadata
+		}
+		if len(key) > MaxAccountMetadataKeyLength {
+			return nil, ErrMetadataTooLarge
+		}
+		switch value.(type) {
+		case string, json.Number:
+		default:
+			return nil, ErrInvalidMetadata
+		}
+	}
+
+	return json.Marshal(metadata)
+}
+
+// AccountMetadataLimitsMessage describes the metadata limits for error responses
+func AccountMetadataLimitsMessage() string {
+	return fmt.Sprintf("Metadata is limited to %d keys of up to %d characters and %d bytes in total",
+		MaxAccountMetadataKeys, MaxAccountMetadataKeyLength, MaxAccountMetadataBytes)
+}
@@ -0,0 +1,101 @@
+package helpers
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// AccountMaxConcurrentTransactions returns how many transactions may run at once against one account,
+// read from ACCOUNT_MAX_CONCURRENT_TRANSACTIONS. Further requests queue until a slot frees up instead
+// of piling up on the row lock. Zero (the default) disables the queue.
+func AccountMaxConcurrentTransactions() int {
+	return GetEnvInt("ACCOUNT_MAX_CONCURRENT_TRANSACTIONS", 0)
+}
+
+// accountSlot holds the tokens of one account and how many requests hold or wait for them
+type accountSlot struct {
+	tokens chan struct{}
+	users  int
+}
+
+// AccountSlots limits the transactions in flight per account. Accounts without holders or waiters
+// are forgotten, so memory follows the number of busy accounts.
+type AccountSlots struct {
+	mu    sync.Mutex
+	limit func() int
+	slots map[int64]*accountSlot
+}
+
+// NewAccountSlots creates a queue whose limit is read on every Acquire, so it follows the configuration
+// loaded after package initialization
+func NewAccountSlots(limit func() int) *AccountSlots {
+	return &AccountSlots{limit: limit, slots: map[int64]*accountSlot{}}
+}
+
+// Enabled reports whether a limit is configured
+func (s *AccountSlots) Enabled() bool {
+	return s.limit() > 0
+}
+
+// Acquire waits for a slot on every given account and returns the function releasing them. Slots are
+// taken in ascending account ID order, so requests touching the same accounts, such as opposite
+// transfers, cannot each hold one and wait for the other. Waiting ends with the context's error.
+func (s *AccountSlots) Acquire(ctx context.Context, accountIDs ...int64) (func(), error) {
+	limit := s.limit()
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	ids := slices.Clone(accountIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	var held []int64
+	release := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			s.leave(held[i], true)
+		}
+	}
+
+	for _, id := range ids {
+		slot := s.join(id, limit)
+		select {
+		case slot.tokens <- struct{}{}:
+			held = append(held, id)
+		case <-ctx.Done():
+			s.leave(id, false)
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
+
+func (s *AccountSlots) join(id int64, limit int) *accountSlot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot, ok := s.slots[id]
+	if !ok {
+		slot = &accountSlot{tokens: make(chan struct{}, limit)}
+		s.slots[id] = slot
+	}
+	slot.users++
+	return slot
+}
+
+func (s *AccountSlots) leave(id int64, holding bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slot := s.slots[id]
+	if holding {
+		<-slot.tokens
+	}
+	slot.users--
+	if slot.users == 0 {
+		delete(s.slots, id)
+	}
+}
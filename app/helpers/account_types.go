@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	"errors"
+	"slices"
+	"sort"
+)
+
+var (
+	ErrInvalidAccountType        = errors.New("invalid account type")
+	ErrTransactionTypeNotAllowed = errors.New("transaction type not allowed for account type")
+	ErrSourceNotAllowed          = errors.New("source not allowed for account type")
+	ErrWithdrawalLimitExceeded   = errors.New("withdrawal exceeds account limit")
+)
+
+const (
+	AccountTypeGeneral  = "general"
+	AccountTypeChecking = "checking"
+	AccountTypeSavings  = "savings"
+	AccountTypeGame     = "game"
+)
+
+// AccountType describes which transactions an account accepts
+type AccountType struct {
+	Name string
+	// TransactionTypes lists the allowed transaction types; nil allows every registered type
+	TransactionTypes []string
+	// Sources lists the allowed Source-Type values; nil allows every valid source
+	Sources []string
+	// WithdrawalLimit caps the amount of a single withdrawal in the account currency; zero means unlimited
+	WithdrawalLimit float64
+	// CreditType and DebitType are used for signed amounts submitted without a type
+	CreditType string
+	DebitType  string
+}
+
+// accountTypes is the registry of every account type; general keeps the original unrestricted behaviour
+var accountTypes = map[string]AccountType{
+	AccountTypeGeneral: {Name: AccountTypeGeneral, CreditType: "win", DebitType: "lose"},
+	AccountTypeChecking: {
+		Name:             AccountTypeChecking,
+		TransactionTypes: []string{"deposit", "withdrawal", "reversal", "adjustment"},
+		Sources:          []string{"server", "payment"},
+		CreditType:       "deposit",
+		DebitType:        "withdrawal",
+	},
+	AccountTypeSavings: {
+		Name:             AccountTypeSavings,
+		TransactionTypes: []string{"deposit", "withdrawal", "reversal", "adjustment"},
+		Sources:          []string{"server", "payment"},
+		WithdrawalLimit:  1000,
+		CreditType:       "deposit",
+		DebitType:        "withdrawal",
+	},
+	AccountTypeGame: {
+		Name:             AccountTypeGame,
+		TransactionTypes: []string{"win", "lose", "reversal", "adjustment"},
+		Sources:          []string{"game", "server"},
+		CreditType:       "win",
+		DebitType:        "lose",
+	},
+}
+
+// LookupAccountType returns the registered account type with the given name
+func LookupAccountType(name string) (AccountType, bool) {
+	accountType, ok := accountTypes[name]
+	return accountType, ok
+}
+
+// CheckAccountRules verifies that an account of the given type accepts the transaction, whose amount is
+// in minor units of the account currency
+func CheckAccountRules(accountTypeName, transactionType, source string, amountMinor int64, currency string) error {
+	accountType, ok := LookupAccountType(accountTypeName)
+	if !ok {
+		return ErrInvalidAccountType
+	}
+
+	if accountType.TransactionTypes != nil && !slices.Contains(accountType.TransactionTypes, transactionType) {
+		return ErrTransactionTypeNotAllowed
+	}
+
+	if accountType.Sources != nil && !slices.Contains(accountType.Sources, source) {
+		return ErrSourceNotAllowed
+	}
+
+	if transactionType == "withdrawal" && accountType.WithdrawalLimit > 0 && amountMinor > ToMinorUnits(accountType.WithdrawalLimit, currency) {
+		return ErrWithdrawalLimitExceeded
+	}
+
+	return nil
+}
+
+// SourceTypeMatrix lists, per source and account type, the transaction types CheckAccountRules
+// accepts, sorted by name. It is derived from the registries, so it always matches enforcement.
+func SourceTypeMatrix() map[string]map[string][]string {
+	matrix := map[string]map[string][]string{}
+	for _, source := range ValidSources() {
+		matrix[source] = map[string][]string{}
+		for accountTypeName := range accountTypes {
+			allowed := []string{}
+			for transactionType := range transactionTypes {
+				if CheckAccountRules(accountTypeName, transactionType, source, 0, DefaultAccountCurrency) == nil {
+					allowed = append(allowed, transactionType)
+				}
+			}
+			sort.Strings(allowed)
+			matrix[source][accountTypeName] = allowed
+		}
+	}
+	return matrix
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+var (
+	ErrInvalidAPIKey      = errors.New("invalid API key")
+	ErrInsufficientScope  = errors.New("API key scope does not allow this operation")
+	ErrSourceNotPermitted = errors.New("API key is not allowed to use this source")
+	ErrAPIKeyRequired     = errors.New("route is only open to API keys")
+	ErrAdminRequired      = errors.New("request is not authenticated as an admin")
+)
+
+const (
+	// ScopeTransactionsWrite allows creating and confirming transactions
+	ScopeTransactionsWrite = "transactions:write"
+	// ScopeTransactionsRead allows fetching any user's transactions by ID, one at a time or in batches
+	ScopeTransactionsRead = "transactions:read"
+)
+
+// apiKeyPrefix makes keys recognisable in logs and secret scanners
+const apiKeyPrefix = "gbk_"
+
+// APIKeyAuthEnabled reports whether transaction routes require an X-API-Key, read from API_KEY_AUTH
+func APIKeyAuthEnabled() bool {
+	return os.Getenv("API_KEY_AUTH") == "true"
+}
+
+// defaultAdminName is the admin holding the single token configured with ADMIN_TOKEN
+const defaultAdminName = "admin"
+
+// AdminTokens returns the bearer tokens accepted on /admin routes by the name of the admin holding
+// each, read from ADMIN_TOKENS as comma-separated name:token pairs (e.g. ADMIN_TOKENS=alice:...,ops:...).
+// The name is recorded as the actor of the admin's audit records. A single ADMIN_TOKEN is accepted
+// as the admin "admin". Pairs without a name or token are ignored; without any token the admin routes
+// are disabled.
+func AdminTokens() map[string]string {
+	tokens := map[string]string{}
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		tokens[defaultAdminName] = token
+	}
+	for _, pair := range strings.Split(os.Getenv("ADMIN_TOKENS"), ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if ok && name != "" && token != "" {
+			tokens[name] = token
+		}
+	}
+	return tokens
+}
+
+// NewAPIKey generates a random API key; it is shown to the caller once and only its hash is stored
+func NewAPIKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// HashAPIKey returns the stored form of an API key. Keys are random, so a plain SHA-256 is sufficient.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKeyScope verifies the principal holds the scope and, when a source is given, may use it.
+// A key without sources may use any source.
+func CheckAPIKeyScope(principal models.APIPrincipal, scope, source string) error {
+	if !slices.Contains(principal.Scopes, scope) {
+		return ErrInsufficientScope
+	}
+	if source != "" && len(principal.Sources) > 0 && !slices.Contains(principal.Sources, source) {
+		return ErrSourceNotPermitted
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrForbidden          = errors.New("token does not grant access to this user")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrLoginDisabled      = errors.New("login is disabled")
+	// ErrInvalidRefreshToken covers unknown, expired and revoked refresh tokens
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+const (
+	// DefaultAccessTokenTTLSeconds is used when ACCESS_TOKEN_TTL_SECONDS is not configured
+	DefaultAccessTokenTTLSeconds = 900
+	// DefaultRefreshTokenTTLSeconds is used when REFRESH_TOKEN_TTL_SECONDS is not configured
+	DefaultRefreshTokenTTLSeconds = 30 * 24 * 60 * 60
+)
+
+// refreshTokenPrefix makes refresh tokens recognisable in logs and secret scanners
+const refreshTokenPrefix = "gbr_"
+
+// unknownUserPasswordHash is checked when a username does not exist, so a failed login takes as long
+// whether or not it does
+const unknownUserPasswordHash = "$2a$10$cyAit2izDZYoO2GKNvUvLugEZMCUkLSy47awa3CdjauBKR2j19BCu"
+
+// JWTSecret returns the HS256 secret of user access tokens, read from JWT_SECRET. When it is empty
+// login is disabled and user routes reject every request, unless an API key grants access.
+func JWTSecret() string {
+	return os.Getenv("JWT_SECRET")
+}
+
+// ParseAccessToken verifies an HS256 access token signed with secret and returns the user ID in its
+// subject. Tokens without an expiry are rejected.
+func ParseAccessToken(token, secret string) (int64, error) {
+	parsed, err := jwt.ParseWithClaims(token, &jwt.RegisteredClaims{}, func(*jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	subject, err := parsed.Claims.GetSubject()
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	userID, err := ValidateID(subject)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+// AccessTokenTTL returns how long an issued access token is valid, read from ACCESS_TOKEN_TTL_SECONDS
+func AccessTokenTTL() time.Duration {
+	return time.Duration(GetEnvInt("ACCESS_TOKEN_TTL_SECONDS", DefaultAccessTokenTTLSeconds)) * time.Second
+}
+
+// NewAccessToken signs an HS256 access token for the user, valid from now until expiresAt
+func NewAccessToken(userID int64, secret string, now, expiresAt time.Time) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.FormatInt(userID, 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// RefreshTokenTTL returns how long a refresh token can be exchanged, read from REFRESH_TOKEN_TTL_SECONDS
+func RefreshTokenTTL() time.Duration {
+	return time.Duration(GetEnvInt("REFRESH_TOKEN_TTL_SECONDS", DefaultRefreshTokenTTLSeconds)) * time.Second
+}
+
+// NewRefreshToken generates a random refresh token; it is returned to the client once and only its
+// hash is stored
+func NewRefreshToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return refreshTokenPrefix + base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashRefreshToken returns the stored form of a refresh token. Tokens are random, so a plain SHA-256
+// is sufficient.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashConfirmationToken returns the stored form of a step-up confirmation token. Tokens are random, so a
+// plain SHA-256 is sufficient.
+func HashConfirmationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashPassword returns the bcrypt hash stored for a login password
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// CheckPassword compares a password with its stored hash. An empty hash, for a user without a
+// password or an unknown one, never matches but is as slow to reject as a wrong password.
+func CheckPassword(hash, password string) error {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword([]byte(unknownUserPasswordHash), []byte(password))
+		return ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+type userIDContextKey struct{}
+
+// WithUserID returns a context carrying the ID of the authenticated user
+func WithUserID(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, userIDContextKey{}, userID)
+}
+
+// UserIDFromContext returns the ID of the user authenticated by a bearer token, if any
+func UserIDFromContext(ctx context.Context) (int64, bool) {
+	userID, ok := ctx.Value(userIDContextKey{}).(int64)
+	return userID, ok
+}
+
+type adminContextKey struct{}
+
+// WithAdmin returns a context carrying the name of the admin authenticated by their token
+func WithAdmin(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, adminContextKey{}, name)
+}
+
+// AdminFromContext returns the name of the admin authenticated by their token, if any
+func AdminFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(adminContextKey{}).(string)
+	return name, ok
+}
+
+// AuthorizeUser rejects acting on another user than the authenticated one. Requests without an
+// authenticated user got past an API key check instead, whose scopes limit what they may do; the
+// routes reject requests without any credential before they get here.
+func AuthorizeUser(ctx context.Context, userID int64) error {
+	if authenticated, ok := UserIDFromContext(ctx); ok && authenticated != userID {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package helpers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// CheckNotModified sets the ETag header and reports whether the client's If-None-Match already
+// names etag, in which case a 304 has been written. etag must be a quoted strong entity tag.
+func CheckNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	// If-None-Match uses the weak comparison, so a W/ prefix from an intermediary still matches
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,182 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrUnsupportedCurrency = errors.New("unsupported currency")
+	ErrCurrencyMismatch    = errors.New("currency does not match the account currency")
+)
+
+// DefaultRates holds the exchange rate of each supported currency against EUR
+var DefaultRates = map[string]float64{
+	"EUR": 1.0,
+	"USD": 1.08,
+	"GBP": 0.85,
+}
+
+// DefaultAccountCurrency is the currency of accounts opened without one, matching the column default
+const DefaultAccountCurrency = "EUR"
+
+// DefaultCurrencyPrecision is used for currencies missing from CurrencyPrecision
+const DefaultCurrencyPrecision = 2
+
+// CurrencyPrecision holds the number of minor-unit decimal places per currency. It mirrors the
+// seed of the currencies table and is replaced by the table's rows at startup.
+var CurrencyPrecision = map[string]int{
+	"EUR": 2,
+	"USD": 2,
+	"GBP": 2,
+	"JPY": 0,
+	"BTC": 8,
+}
+
+// SetCurrencyPrecision replaces CurrencyPrecision with the decimals loaded from the currencies
+// table. It is called once before the server starts, so readers need no locking.
+func SetCurrencyPrecision(precision map[string]int) {
+	CurrencyPrecision = precision
+}
+
+// currencyPrecision returns the number of minor-unit decimal places of a currency
+func currencyPrecision(currency string) int {
+	precision, ok := CurrencyPrecision[currency]
+	if !ok {
+		return DefaultCurrencyPrecision
+	}
+	return precision
+}
+
+// FormatAmount renders an amount with the number of decimals used by its currency. It is meant for
+// configured limits and rates; ledger amounts are formatted exactly with FormatMoney.
+func FormatAmount(amount float64, currency string) string {
+	return strconv.FormatFloat(amount, 'f', currencyPrecision(currency), 64)
+}
+
+// FormatMoney renders an amount in minor units with its currency's decimals, without passing through
+// a float, so "1050" EUR is "10.50" and "150000000" BTC is "1.50000000"
+func FormatMoney(minor int64, currency string) string {
+	precision := currencyPrecision(currency)
+
+	sign := ""
+	magnitude := uint64(minor)
+	if minor < 0 {
+		sign = "-"
+		magnitude = -magnitude
+	}
+
+	digits := strconv.FormatUint(magnitude, 10)
+	if precision == 0 {
+		return sign + digits
+	}
+	if len(digits) <= precision {
+		digits = strings.Repeat("0", precision-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-precision] + "." + digits[len(digits)-precision:]
+}
+
+// ToMinorUnits converts a configured amount, such as a limit or threshold, to an integer count of its
+// currency's minor units
+func ToMinorUnits(amount float64, currency string) int64 {
+	return int64(math.Round(amount * math.Pow10(currencyPrecision(currency))))
+}
+
+// FromMinorUnits converts an integer count of minor units back to an amount, for arithmetic with
+// exchange rates
+func FromMinorUnits(minor int64, currency string) float64 {
+	return float64(minor) / math.Pow10(currencyPrecision(currency))
+}
+
+// NumericFromMinorUnits returns an amount in minor units as an exact NUMERIC, for the legacy
+// balance and amount columns
+func NumericFromMinorUnits(minor int64, currency string) pgtype.Numeric {
+	return pgtype.Numeric{Int: big.NewInt(minor), Exp: -int32(currencyPrecision(currency)), Valid: true}
+}
+
+// MinorUnitsFromNumeric converts a NUMERIC to minor units of the currency. It reports false when the
+// value is NULL, has more decimals than the currency or does not fit in an int64.
+func MinorUnitsFromNumeric(value pgtype.Numeric, currency string) (int64, bool) {
+	if !value.Valid || value.NaN || value.InfinityModifier != pgtype.Finite {
+		return 0, false
+	}
+
+	minor := new(big.Int).Set(value.Int)
+	shift := int64(value.Exp) + int64(currencyPrecision(currency))
+	if shift >= 0 {
+		minor.Mul(minor, new(big.Int).Exp(big.NewInt(10), big.NewInt(shift), nil))
+	} else {
+		var remainder big.Int
+		minor.QuoRem(minor, new(big.Int).Exp(big.NewInt(10), big.NewInt(-shift), nil), &remainder)
+		if remainder.Sign() != 0 {
+			return 0, false
+		}
+	}
+
+	if !minor.IsInt64() {
+		return 0, false
+	}
+	return minor.Int64(), true
+}
+
+// MoneyJSON returns an amount in minor units ready for JSON encoding with its currency's fixed precision.
+// It is a string by default, or a JSON number literal when MONEY_JSON_FORMAT=number;
+// both avoid the float64 artifacts clients see with raw values like 100.1.
+func MoneyJSON(minor int64, currency string) interface{} {
+	formatted := FormatMoney(minor, currency)
+	if os.Getenv("MONEY_JSON_FORMAT") == "number" {
+		return json.Number(formatted)
+	}
+	return formatted
+}
+
+// CurrencyConverter provides exchange rates between supported currencies
+type CurrencyConverter interface {
+	Rate(from, to string) (float64, error)
+}
+
+// StaticConverter converts using a fixed table of rates against a common base
+type StaticConverter struct {
+	rates map[string]float64
+}
+
+func NewStaticConverter(rates map[string]float64) *StaticConverter {
+	return &StaticConverter{rates: rates}
+}
+
+func (c *StaticConverter) Rate(from, to string) (float64, error) {
+	fromRate, ok := c.rates[from]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+
+	toRate, ok := c.rates[to]
+	if !ok {
+		return 0, ErrUnsupportedCurrency
+	}
+
+	return toRate / fromRate, nil
+}
+
+// CrossCurrencyTransactionsEnabled reports whether transactions in another currency than the account's are
+// converted instead of rejected, read from ALLOW_CROSS_CURRENCY_TRANSACTIONS
+func CrossCurrencyTransactionsEnabled() bool {
+	return os.Getenv("ALLOW_CROSS_CURRENCY_TRANSACTIONS") == "true"
+}
+
+// ConvertMinorUnits applies an exchange rate to an amount in minor units of from and rounds the result
+// to the minor units of to, so a conversion to JPY has no decimals and one to BTC keeps eight
+func ConvertMinorUnits(amount int64, from, to string, rate float64) (int64, error) {
+	converted := math.Round(FromMinorUnits(amount, from) * rate * math.Pow10(currencyPrecision(to)))
+	if converted >= math.MaxInt64 || converted < math.MinInt64 {
+		return 0, ErrBalanceOverflow
+	}
+	return int64(converted), nil
+}
@@ -0,0 +1,95 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrDailyCapExceeded   = errors.New("daily debit cap exceeded")
+	ErrDailyLimitExceeded = errors.New("daily transaction limit exceeded")
+)
+
+// DailyLimitedTypes are the transaction types counted towards the daily transaction limit
+var DailyLimitedTypes = []string{"win", "withdrawal"}
+
+// DailyCap limits the total of some transaction types per account and UTC day. Limits are per
+// currency and in the account currency, so each currency gets a cap that makes sense for it.
+type DailyCap struct {
+	Types  []string
+	Limits map[string]float64
+	// Err is returned when a transaction would exceed the cap
+	Err error
+}
+
+// DailyCaps returns the debit cap over every non-corrective debit and the transaction limit over wins
+// and withdrawals, a fraud control on top of it
+func DailyCaps() []DailyCap {
+	return []DailyCap{
+		{Types: CappedDebitTypes(), Limits: DailyDebitCaps(), Err: ErrDailyCapExceeded},
+		{Types: DailyLimitedTypes, Limits: currencyLimits("DAILY_TRANSACTION_LIMIT_"), Err: ErrDailyLimitExceeded},
+	}
+}
+
+// DailyDebitCaps reads the maximum total debited per account and UTC day for each currency from
+// DAILY_DEBIT_CAP_<CURRENCY>, e.g. DAILY_DEBIT_CAP_JPY=1500000. Zero or unset means uncapped; the
+// transaction limit is read the same way from DAILY_TRANSACTION_LIMIT_<CURRENCY>.
+func DailyDebitCaps() map[string]float64 {
+	return currencyLimits("DAILY_DEBIT_CAP_")
+}
+
+// currencyLimits reads the positive amounts of prefix<CURRENCY> for every supported currency
+func currencyLimits(prefix string) map[string]float64 {
+	limits := map[string]float64{}
+	for currency := range CurrencyPrecision {
+		limit, err := strconv.ParseFloat(os.Getenv(prefix+currency), 64)
+		if err == nil && limit > 0 {
+			limits[currency] = limit
+		}
+	}
+	return limits
+}
+
+// IsCappedDebit reports whether a transaction type counts towards the daily debit cap. Corrective
+// types are exempt so that reversals and adjustments can always be booked.
+func IsCappedDebit(name string) bool {
+	transactionType, ok := LookupTransactionType(name)
+	return ok && transactionType.Sign < 0 && !transactionType.AllowNegative
+}
+
+// CappedDebitTypes lists the transaction types counted towards the daily debit cap, sorted by name
+func CappedDebitTypes() []string {
+	var names []string
+	for name := range transactionTypes {
+		if IsCappedDebit(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOfDay returns the start of the UTC day containing t, when daily caps reset
+func StartOfDay(t time.Time) time.Time {
+	return PeriodStart(t, IntervalDay)
+}
+
+// Applies reports whether the cap limits a transaction type in the currency
+func (c DailyCap) Applies(currency, transactionType string) bool {
+	_, ok := c.Limits[currency]
+	return ok && slices.Contains(c.Types, transactionType)
+}
+
+// Check rejects an amount that would take the day's total above the cap of the currency. Both are in
+// minor units, so a total exactly at the cap is allowed.
+func (c DailyCap) Check(currency string, totalTodayMinor, amountMinor int64) error {
+	limit, ok := c.Limits[currency]
+	if ok && totalTodayMinor+amountMinor > ToMinorUnits(limit, currency) {
+		return c.Err
+	}
+	return nil
+}
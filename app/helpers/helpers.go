@@ -7,33 +7,141 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"os"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 var (
 	ErrBodyCannotBeEmpty      = errors.New("request body cannot be empty")
 	ErrInvalidID              = errors.New("invalid ID format")
+	ErrIDTooLarge             = errors.New("ID is too large")
 	ErrInvalidAmount          = errors.New("invalid amount format")
 	ErrAmountMustBePositive   = errors.New("amount must be a positive number")
+	ErrTooManyDecimals        = errors.New("amount has more decimal places than its currency")
 	ErrInsufficientBalance    = errors.New("insufficient balance")
+	ErrBalanceOverflow        = errors.New("balance would exceed the supported range")
 	ErrInvalidTransactionType = errors.New("invalid transaction type")
 	ErrUserNotFound           = errors.New("user not found")
 	ErrAccountNotFound        = errors.New("user account not found")
 	ErrTransactionNotFound    = errors.New("user transaction not found")
 	ErrDuplicateUser          = errors.New("user already exists")
 	ErrDuplicateAccount       = errors.New("user account already exists")
+	ErrInvalidTimestamp       = errors.New("invalid timestamp format")
+	ErrTransferAmountZero     = errors.New("transfer amount rounds to zero")
+	ErrSelfTransfer           = errors.New("source and destination accounts must differ")
+	ErrAccountFrozen          = errors.New("account is frozen")
+	ErrAccountClosed          = errors.New("account is closed")
+	ErrLockTimeout            = errors.New("timed out waiting for a row lock")
+	ErrDuplicateReference     = errors.New("client reference already used for this account")
+	ErrAmountCannotBeZero     = errors.New("amount cannot be zero")
+	ErrConfirmationNotFound   = errors.New("confirmation token not found")
+	ErrVelocityExceeded       = errors.New("too many transactions in a short time")
+	ErrConfirmationExpired    = errors.New("confirmation token expired")
+	ErrConfirmationUsed       = errors.New("confirmation token already used")
+	ErrBalanceNotZero         = errors.New("account balance must be zero to close")
+	ErrInvalidSource          = errors.New("invalid source type")
+	ErrSourceMismatch         = errors.New("body source does not match the Source-Type header")
+	ErrInvalidUserLookup      = errors.New("exactly one of email or username is required")
+	ErrInvalidTransactionID   = errors.New("invalid transaction ID")
+	ErrAmountInputConflict    = errors.New("exactly one of amount or amount_minor is required")
 )
 
+// Account statuses stored in accounts.status
+const (
+	AccountStatusActive = "active"
+	AccountStatusFrozen = "frozen"
+	AccountStatusClosed = "closed"
+)
+
+// DateLayout is the format expected for calendar dates such as date of birth
+const DateLayout = "2006-01-02"
+
+// DefaultMinUserAge is used when MIN_USER_AGE is not configured
+const DefaultMinUserAge = 18
+
+// Transaction amount input modes, selected with TRANSACTION_AMOUNT_MODE
+const (
+	AmountModeStrict = "strict"
+	AmountModeSigned = "signed"
+)
+
+// DefaultMiniStatementSize is used when MINI_STATEMENT_SIZE is not configured
+const DefaultMiniStatementSize = 5
+
+// DefaultStepUpTokenTTLSeconds is used when STEP_UP_TOKEN_TTL_SECONDS is not configured
+const DefaultStepUpTokenTTLSeconds = 300
+
+// DefaultVelocityWindowSeconds is used when VELOCITY_WINDOW_SECONDS is not configured
+const DefaultVelocityWindowSeconds = 60
+
+// DefaultShutdownTimeoutSeconds is used when SERVER_SHUTDOWN_TIMEOUT_SECONDS is not configured
+const DefaultShutdownTimeoutSeconds = 30
+
+// DefaultGzipLevel balances CPU against response size when GZIP_LEVEL is not configured
+const DefaultGzipLevel = 6
+
+// DefaultLockTimeoutMillis is used when LOCK_TIMEOUT_MS is not configured
+const DefaultLockTimeoutMillis = 5000
+
+// DefaultSlowQueryThresholdMillis is used when SLOW_QUERY_THRESHOLD_MS is not configured
+const DefaultSlowQueryThresholdMillis = 500
+
+// PostgreSQL SQLSTATE codes mapped by HandleDatabaseError
+const (
+	PgUniqueViolation      = "23505"
+	PgForeignKeyViolation  = "23503"
+	PgCheckViolation       = "23514"
+	PgNotNullViolation     = "23502"
+	PgSerializationFailure = "40001"
+	PgDeadlockDetected     = "40P01"
+	PgLockNotAvailable     = "55P03"
+)
+
+// LockRetryAfterSeconds is sent in Retry-After when a row lock could not be acquired
+const LockRetryAfterSeconds = 1
+
+// validate is shared across requests so custom validations are registered once
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("minage", validateMinAge)
+	v.RegisterValidation("required_unless_signed", validateRequiredUnlessSigned)
+	v.RegisterValidation("required_if_kyc", validateRequiredIfKYC)
+	v.RegisterValidation("source", validateSource)
+	v.RegisterValidation("money", validateMoney)
+	return v
+}
+
+// RequestIDHeader carries the ID that correlates a request with its log records
+const RequestIDHeader = "X-Request-ID"
+
 type ValidationErrorResponse struct {
 	Errors map[string]string `json:"errors"`
+	// RequestID lets support find the failing call in the logs
+	RequestID string `json:"request_id,omitempty"`
 }
 
 type ErrorResponse struct {
 	Error string `json:"error,omitempty"`
+	// AccountStatus tells the client which account status rejected the request, so it does not retry blindly
+	AccountStatus string `json:"account_status,omitempty"`
+	// RequestID lets support find the failing call in the logs
+	RequestID string `json:"request_id,omitempty"`
+	// Reference identifies an internal error in the logs without exposing its details
+	Reference string `json:"reference,omitempty"`
+}
+
+// responseRequestID returns the request ID the RequestID middleware set on the response, if any
+func responseRequestID(w http.ResponseWriter) string {
+	return w.Header().Get(RequestIDHeader)
 }
 
 // Common response functions
@@ -49,9 +157,16 @@ func RespondCreated(w http.ResponseWriter, message string, data interface{}) {
 	json.NewEncoder(w).Encode(data)
 }
 
+func RespondJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(data)
+}
+
 func RespondError(w http.ResponseWriter, statusCode int, message string) {
 	response := ErrorResponse{
-		Error: message,
+		Error:     message,
+		RequestID: responseRequestID(w),
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -60,13 +175,34 @@ func RespondError(w http.ResponseWriter, statusCode int, message string) {
 
 func RespondValidationError(w http.ResponseWriter, errors map[string]string) {
 	response := ValidationErrorResponse{
-		Errors: errors,
+		Errors:    errors,
+		RequestID: responseRequestID(w),
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusUnprocessableEntity)
 	json.NewEncoder(w).Encode(response)
 }
 
+// MaxTransactionIDLength matches the limit on client-supplied transactionId values
+const MaxTransactionIDLength = 128
+
+// ValidateTransactionID checks a transaction ID path value before it is queried. IDs are generated
+// UUIDs or client-supplied references, so only the length accepted at creation is enforced.
+func ValidateTransactionID(transactionID string) error {
+	if transactionID == "" || len(transactionID) > MaxTransactionIDLength {
+		return ErrInvalidTransactionID
+	}
+	return nil
+}
+
+// likeEscaper escapes the LIKE wildcards and the escape character itself
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// PrefixPattern returns an ILIKE pattern matching values that start with prefix, taken literally
+func PrefixPattern(prefix string) string {
+	return likeEscaper.Replace(prefix) + "%"
+}
+
 // Entity-specific validation functions
 func ValidateID(userIDStr string) (int64, error) {
 	if userIDStr == "" {
@@ -74,6 +210,10 @@ func ValidateID(userIDStr string) (int64, error) {
 	}
 
 	userID, err := strconv.ParseInt(userIDStr, 10, 64)
+	// A well-formed number beyond int64 is reported separately from a malformed one
+	if errors.Is(err, strconv.ErrRange) && !strings.HasPrefix(userIDStr, "-") {
+		return 0, ErrIDTooLarge
+	}
 	if err != nil {
 		return 0, ErrInvalidID
 	}
@@ -85,27 +225,143 @@ func ValidateID(userIDStr string) (int64, error) {
 	return userID, nil
 }
 
-func ParseAmount(amountStr string) (float64, error) {
-	if amountStr == "" {
+// parseMinorUnits parses a decimal string with at most precision decimal places into an exact count of
+// minor units, so amounts never pass through a binary float
+func parseMinorUnits(amountStr string, precision int) (int64, error) {
+	digits := amountStr
+	if strings.HasPrefix(digits, "+") || strings.HasPrefix(digits, "-") {
+		digits = digits[1:]
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
 		return 0, ErrInvalidAmount
 	}
+	for _, part := range []string{whole, fraction} {
+		if strings.TrimLeft(part, "0123456789") != "" {
+			return 0, ErrInvalidAmount
+		}
+	}
+	if len(fraction) > precision {
+		return 0, ErrTooManyDecimals
+	}
+
+	scale := int64(math.Pow10(precision))
+	var minor int64
+	if whole != "" {
+		units, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil || units > math.MaxInt64/scale {
+			return 0, ErrInvalidAmount
+		}
+		minor = units * scale
+	}
+	if fraction != "" {
+		fractionMinor, _ := strconv.ParseInt(fraction+strings.Repeat("0", precision-len(fraction)), 10, 64)
+		if minor > math.MaxInt64-fractionMinor {
+			return 0, ErrInvalidAmount
+		}
+		minor += fractionMinor
+	}
+
+	if strings.HasPrefix(amountStr, "-") {
+		return -minor, nil
+	}
+	return minor, nil
+}
 
-	amount, err := strconv.ParseFloat(amountStr, 64)
+// ParseMinorUnits parses a positive amount such as "100.50" into integer minor units (10050),
+// rejecting more than two decimal places
+func ParseMinorUnits(amountStr string) (int64, error) {
+	return ParseAmount(amountStr, DefaultAccountCurrency)
+}
+
+// ParseSignedAmount parses an amount whose sign encodes credit (+) or debit (-) into minor units of
+// the currency
+func ParseSignedAmount(amountStr, currency string) (int64, error) {
+	minor, err := parseMinorUnits(amountStr, currencyPrecision(currency))
 	if err != nil {
-		return 0, ErrInvalidAmount
+		return 0, err
+	}
+
+	if minor == 0 {
+		return 0, ErrAmountCannotBeZero
+	}
+
+	return minor, nil
+}
+
+// MoneyOptions adjusts what ParseMoney accepts; the zero value rejects zero amounts
+type MoneyOptions struct {
+	// AllowZero accepts "0" and "0.00", e.g. for status-only adjustments or informational entries
+	AllowZero bool
+}
+
+// ParseMoney parses a non-negative amount into minor units of the currency, rejecting more decimal
+// places than the currency has. Zero is rejected unless options.AllowZero is set.
+func ParseMoney(amountStr, currency string, options MoneyOptions) (int64, error) {
+	minor, err := parseMinorUnits(amountStr, currencyPrecision(currency))
+	if err != nil {
+		return 0, err
 	}
 
-	if amount <= 0 {
+	if minor < 0 || (minor == 0 && !options.AllowZero) {
 		return 0, ErrAmountMustBePositive
 	}
 
-	return float64(math.Round(amount*100) / 100), nil
+	return minor, nil
+}
+
+// ParseAmount parses a transaction amount, which must be positive, into minor units of the currency
+func ParseAmount(amountStr, currency string) (int64, error) {
+	return ParseMoney(amountStr, currency, MoneyOptions{})
+}
+
+// ParseTimestamp parses an optional RFC3339 timestamp, returning the zero time when empty
+func ParseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, ErrInvalidTimestamp
+	}
+
+	return timestamp.UTC(), nil
 }
 
 // Error handling and response mapping
 func HandleDatabaseError(w http.ResponseWriter, err error, entityType string) {
 	log.Printf("Database error for %s: %v", entityType, err)
 
+	// Lock contention is transient, so tell the client when to retry
+	if errors.Is(err, ErrLockTimeout) {
+		w.Header().Set("Retry-After", strconv.Itoa(LockRetryAfterSeconds))
+		RespondError(w, http.StatusServiceUnavailable, "Account is busy, please retry")
+		return
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		RespondError(w, http.StatusNotFound, fmt.Sprintf("%s not found", entityType))
+		return
+	}
+
+	// Map SQLSTATE codes precisely before falling back to message matching
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case PgUniqueViolation:
+			RespondError(w, http.StatusConflict, fmt.Sprintf("%s already exists", entityType))
+			return
+		case PgForeignKeyViolation, PgCheckViolation, PgNotNullViolation:
+			RespondError(w, http.StatusBadRequest, "Invalid reference or constraint violation")
+			return
+		case PgSerializationFailure, PgDeadlockDetected:
+			w.Header().Set("Retry-After", strconv.Itoa(LockRetryAfterSeconds))
+			RespondError(w, http.StatusServiceUnavailable, "Concurrent update conflict, please retry")
+			return
+		}
+	}
+
 	errStr := strings.ToLower(err.Error())
 
 	switch {
@@ -124,6 +380,22 @@ func HandleDatabaseError(w http.ResponseWriter, err error, entityType string) {
 	}
 }
 
+// amountErrorMessages are the client messages of the amount parsing errors
+var amountErrorMessages = map[error]string{
+	ErrAmountMustBePositive: "Amount must be a positive number",
+	ErrAmountCannotBeZero:   "Amount cannot be zero",
+	ErrInvalidAmount:        "Invalid amount specified",
+	ErrTooManyDecimals:      "Amount has more decimal places than the currency allows",
+	ErrAmountInputConflict:  "Exactly one of amount or amount_minor is required",
+}
+
+// AmountErrorMessage returns the client message of an amount parsing error, so it can be reported as a
+// field error, and false for any other error
+func AmountErrorMessage(err error) (string, bool) {
+	message, ok := amountErrorMessages[err]
+	return message, ok
+}
+
 // Business logic error handling
 func HandleAPIError(w http.ResponseWriter, err error) {
 	log.Printf("API error: %v", err)
@@ -136,18 +408,106 @@ func HandleAPIError(w http.ResponseWriter, err error) {
 		RespondError(w, http.StatusNotFound, "User Transaction not found")
 	case ErrInsufficientBalance:
 		RespondError(w, http.StatusBadRequest, "Insufficient balance for this transaction")
-	case ErrAmountMustBePositive:
-		RespondError(w, http.StatusBadRequest, "Amount must be a positive number")
-	case ErrInvalidAmount:
-		RespondError(w, http.StatusBadRequest, "Invalid amount specified")
+	case ErrBalanceOverflow:
+		RespondError(w, http.StatusBadRequest, "Transaction would take the balance beyond the supported range")
+	case ErrAmountMustBePositive, ErrAmountCannotBeZero, ErrInvalidAmount, ErrTooManyDecimals, ErrAmountInputConflict:
+		RespondError(w, http.StatusBadRequest, amountErrorMessages[err])
 	case ErrInvalidTransactionType:
 		RespondError(w, http.StatusBadRequest, "Invalid transaction type")
 	case ErrInvalidID:
-		RespondError(w, http.StatusBadRequest, "Invalid ID format")
+		RespondError(w, http.StatusBadRequest, "Invalid ID format, expected a positive integer")
+	case ErrInvalidMetadata:
+		RespondError(w, http.StatusBadRequest, "Metadata must be an object of string or number values")
+	case ErrMetadataTooLarge:
+		RespondError(w, http.StatusBadRequest, AccountMetadataLimitsMessage())
+	case ErrInvalidUserLookup:
+		RespondError(w, http.StatusBadRequest, "Exactly one of email or username is required")
+	case ErrInvalidTransactionID:
+		RespondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid transaction ID, expected 1 to %d characters", MaxTransactionIDLength))
+	case ErrIDTooLarge:
+		RespondError(w, http.StatusBadRequest, fmt.Sprintf("ID is too large, the maximum is %d", int64(math.MaxInt64)))
 	case ErrDuplicateUser:
 		RespondError(w, http.StatusConflict, "User already exists")
 	case ErrDuplicateAccount:
 		RespondError(w, http.StatusConflict, "User Account already exists")
+	case ErrInvalidTimestamp:
+		RespondError(w, http.StatusBadRequest, "Invalid timestamp, expected RFC3339 format")
+	case ErrUnsupportedCurrency:
+		RespondError(w, http.StatusBadRequest, "Unsupported currency")
+	case ErrCurrencyMismatch:
+		RespondError(w, http.StatusBadRequest, "Currency does not match the account currency")
+	case ErrTransferAmountZero:
+		RespondError(w, http.StatusBadRequest, "Transfer amount must be at least 0.01")
+	case ErrSelfTransfer:
+		RespondError(w, http.StatusBadRequest, "Cannot transfer to the same account")
+	case ErrAccountFrozen:
+		RespondJSON(w, http.StatusForbidden, ErrorResponse{Error: "Account is frozen", AccountStatus: AccountStatusFrozen, RequestID: responseRequestID(w)})
+	case ErrAccountClosed:
+		RespondJSON(w, http.StatusForbidden, ErrorResponse{Error: "Account is closed", AccountStatus: AccountStatusClosed, RequestID: responseRequestID(w)})
+	case ErrInvalidSource:
+		RespondError(w, http.StatusBadRequest, "Invalid source type, expected one of: "+strings.Join(ValidSources(), " "))
+	case ErrSourceMismatch:
+		RespondError(w, http.StatusBadRequest, "Source in the body does not match the Source-Type header")
+	case ErrReferenceInUse:
+		RespondError(w, http.StatusConflict, "Client reference already used by another account")
+	case ErrReferenceMismatch:
+		RespondError(w, http.StatusConflict, "Client reference already used for a transaction with a different amount or type")
+	case ErrBalanceNotZero:
+		RespondError(w, http.StatusConflict, "Account balance must be zero to close")
+	case ErrInvalidAccountType:
+		RespondError(w, http.StatusBadRequest, "Invalid account type")
+	case ErrTransactionTypeNotAllowed:
+		RespondError(w, http.StatusBadRequest, "Transaction type is not allowed for this account type")
+	case ErrSourceNotAllowed:
+		RespondError(w, http.StatusBadRequest, "Source is not allowed for this account type")
+	case ErrWithdrawalLimitExceeded:
+		RespondError(w, http.StatusBadRequest, "Withdrawal exceeds the limit for this account type")
+	case ErrConfirmationNotFound:
+		RespondError(w, http.StatusNotFound, "Confirmation token not found")
+	case ErrConfirmationExpired:
+		RespondError(w, http.StatusGone, "Confirmation token has expired")
+	case ErrConfirmationUsed:
+		RespondError(w, http.StatusConflict, "Confirmation token has already been used")
+	case ErrInvalidAPIKey:
+		RespondError(w, http.StatusUnauthorized, "Invalid or missing API key")
+	case ErrInvalidToken:
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		RespondError(w, http.StatusUnauthorized, "Invalid or missing bearer token")
+	case ErrForbidden:
+		RespondError(w, http.StatusForbidden, "Token does not grant access to this user")
+	case ErrAdminRequired:
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		RespondError(w, http.StatusUnauthorized, "Admin authentication required")
+	case ErrAPIKeyRequired:
+		RespondError(w, http.StatusForbidden, "This route returns data across users and requires an API key")
+	case ErrInvalidCredentials:
+		RespondError(w, http.StatusUnauthorized, "Invalid username or password")
+	case ErrLoginDisabled:
+		RespondError(w, http.StatusNotFound, "Login is disabled")
+	case ErrInvalidRefreshToken:
+		RespondError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+	case ErrRefreshTokenReused:
+		RespondError(w, http.StatusUnauthorized, "Refresh token was already used; all sessions of this login were revoked, please log in again")
+	case ErrInsufficientScope:
+		RespondError(w, http.StatusForbidden, "API key scope does not allow this operation")
+	case ErrSourceNotPermitted:
+		RespondError(w, http.StatusForbidden, "API key is not allowed to use this source")
+	case ErrOperationNotPermitted:
+		RespondError(w, http.StatusForbidden, "API key is not allowed to perform this operation")
+	case ErrDailyCapExceeded:
+		RespondError(w, http.StatusBadRequest, "Transaction exceeds the daily debit limit for this currency")
+	case ErrDailyLimitExceeded:
+		RespondError(w, http.StatusBadRequest, "Transaction exceeds the daily win and withdrawal limit of this account")
+	case ErrVelocityExceeded:
+		RespondError(w, http.StatusTooManyRequests, "Too many transactions in a short time, please retry later")
+	case ErrInvalidPagination:
+		RespondError(w, http.StatusBadRequest, "Limit must be between 1 and 100 and offset must not be negative")
+	case ErrInvalidInterval:
+		RespondError(w, http.StatusBadRequest, "Interval must be one of: hour day week")
+	case ErrInvalidRange:
+		RespondError(w, http.StatusBadRequest, "The to timestamp must be after from")
+	case ErrTooManyBuckets:
+		RespondError(w, http.StatusBadRequest, fmt.Sprintf("The range may span at most %d periods", MaxTimeseriesBuckets))
 	default:
 		log.Printf("Unhandled business error: %v", err)
 		RespondError(w, http.StatusInternalServerError, "An unexpected error occurred")
@@ -166,8 +526,12 @@ func ValidateBodyWithDetails(r *http.Request, reqData interface{}) (bool, map[st
 		return false, map[string]string{"body": "Request body cannot be empty"}
 	}
 
+	return ValidateStructWithDetails(reqData)
+}
+
+// ValidateStructWithDetails validates an already decoded struct, keyed by JSON field name
+func ValidateStructWithDetails(reqData interface{}) (bool, map[string]string) {
 	// Validate the decoded data using the validator
-	validate := validator.New()
 	err := validate.Struct(reqData)
 
 	if err != nil {
@@ -226,6 +590,9 @@ func isEmptyStruct(v interface{}) bool {
 		if field.Kind() == reflect.Float64 && field.Float() != 0 {
 			return false
 		}
+		if field.Kind() == reflect.Slice && field.Len() > 0 {
+			return false
+		}
 	}
 
 	return true
@@ -254,7 +621,7 @@ func getJSONFieldName(structType reflect.Type, fieldName string) string {
 // Helper function to generate user-friendly validation error messages
 func generateValidationErrorMessage(fieldName string, err validator.FieldError) string {
 	switch err.Tag() {
-	case "required":
+	case "required", "required_unless_signed", "required_if_kyc":
 		return fmt.Sprintf("The %s field is required", fieldName)
 	case "email":
 		return fmt.Sprintf("The %s must be a valid email address", fieldName)
@@ -282,17 +649,191 @@ func generateValidationErrorMessage(fieldName string, err validator.FieldError)
 		return fmt.Sprintf("The %s must be a valid URL", fieldName)
 	case "uuid":
 		return fmt.Sprintf("The %s must be a valid UUID", fieldName)
+	case "datetime":
+		return fmt.Sprintf("The %s must be a valid date in %s format", fieldName, err.Param())
+	case "source":
+		return fmt.Sprintf("The %s must be one of: %s", fieldName, strings.Join(ValidSources(), " "))
+	case "money":
+		return fmt.Sprintf("The %s must be a non-negative decimal amount", fieldName)
+	case "minage":
+		return fmt.Sprintf("The %s must indicate an age of at least %d years", fieldName, MinUserAge())
+	case "iso3166_1_alpha2":
+		return fmt.Sprintf("The %s must be a valid ISO 3166-1 alpha-2 country code", fieldName)
 	default:
 		return fmt.Sprintf("The %s field is invalid", fieldName)
 	}
 }
 
-func IsValidSource(source string) bool {
-	validSources := map[string]bool{
-		"game":    true,
-		"server":  true,
-		"payment": true,
+// GetEnvInt reads a non-negative integer from the environment, falling back when unset or invalid
+func GetEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// VelocityLimit returns the maximum number of transactions per account within the rolling window,
+// read from VELOCITY_MAX_TRANSACTIONS and VELOCITY_WINDOW_SECONDS. A zero maximum disables the check.
+func VelocityLimit() (int, time.Duration) {
+	window := GetEnvInt("VELOCITY_WINDOW_SECONDS", DefaultVelocityWindowSeconds)
+	if window == 0 {
+		window = DefaultVelocityWindowSeconds
+	}
+	return GetEnvInt("VELOCITY_MAX_TRANSACTIONS", 0), time.Duration(window) * time.Second
+}
+
+// GzipLevel returns the response compression level from GZIP_LEVEL, between 1 (fastest) and 9 (smallest)
+func GzipLevel() int {
+	level := GetEnvInt("GZIP_LEVEL", DefaultGzipLevel)
+	if level < 1 || level > 9 {
+		return DefaultGzipLevel
 	}
+	return level
+}
+
+// Request log formats accepted in LOG_FORMAT
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogFormat returns the request log format from LOG_FORMAT, defaulting to text for unknown values
+func LogFormat() string {
+	if os.Getenv("LOG_FORMAT") == LogFormatJSON {
+		return LogFormatJSON
+	}
+	return LogFormatText
+}
+
+// ServerTimingEnabled reports whether responses carry a Server-Timing header, read from SERVER_TIMING
+func ServerTimingEnabled() bool {
+	return os.Getenv("SERVER_TIMING") == "true"
+}
+
+// LockTimeoutMillis returns how long a transaction waits for a row lock, read from LOCK_TIMEOUT_MS.
+// Zero disables the timeout.
+func LockTimeoutMillis() int {
+	return GetEnvInt("LOCK_TIMEOUT_MS", DefaultLockTimeoutMillis)
+}
+
+// SlowQueryThreshold returns the duration above which a database query is logged, read from
+// SLOW_QUERY_THRESHOLD_MS. Zero disables slow-query logging.
+func SlowQueryThreshold() time.Duration {
+	return time.Duration(GetEnvInt("SLOW_QUERY_THRESHOLD_MS", DefaultSlowQueryThresholdMillis)) * time.Millisecond
+}
 
-	return validSources[source]
+// StepUpThreshold returns the amount above which a transaction needs a confirmation step,
+// read from STEP_UP_THRESHOLD; zero or unset disables step-up
+func StepUpThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("STEP_UP_THRESHOLD"), 64)
+	if err != nil || threshold < 0 {
+		return 0
+	}
+	return threshold
+}
+
+// ShutdownTimeout returns how long in-flight requests may take to finish on SIGINT or SIGTERM,
+// read from SERVER_SHUTDOWN_TIMEOUT_SECONDS
+func ShutdownTimeout() time.Duration {
+	return time.Duration(GetEnvInt("SERVER_SHUTDOWN_TIMEOUT_SECONDS", DefaultShutdownTimeoutSeconds)) * time.Second
+}
+
+// MiniStatementSize returns the number of transactions in a mini-statement, read from MINI_STATEMENT_SIZE.
+// A value that is not a positive integer up to MaxPageLimit is an error, so it is rejected at startup.
+func MiniStatementSize() (int32, error) {
+	size, err := positiveEnvInt("MINI_STATEMENT_SIZE", DefaultMiniStatementSize)
+	if err != nil {
+		return 0, err
+	}
+	if size > MaxPageLimit {
+		return 0, fmt.Errorf("MINI_STATEMENT_SIZE must be at most %d, got %d", MaxPageLimit, size)
+	}
+	return int32(size), nil
+}
+
+// StepUpTokenTTL returns how long a confirmation token stays valid, read from STEP_UP_TOKEN_TTL_SECONDS
+func StepUpTokenTTL() time.Duration {
+	return time.Duration(GetEnvInt("STEP_UP_TOKEN_TTL_SECONDS", DefaultStepUpTokenTTLSeconds)) * time.Second
+}
+
+// SignedAmountMode reports whether TRANSACTION_AMOUNT_MODE=signed, letting clients omit the
+// transaction type and encode credit or debit in the sign of the amount
+func SignedAmountMode() bool {
+	return os.Getenv("TRANSACTION_AMOUNT_MODE") == AmountModeSigned
+}
+
+// MinUserAge returns the minimum age required to register, read from MIN_USER_AGE
+func MinUserAge() int {
+	return GetEnvInt("MIN_USER_AGE", DefaultMinUserAge)
+}
+
+// KYCFieldsRequired reports whether new users must send date_of_birth and country, set with
+// REQUIRE_KYC_FIELDS=true. It is off by default so clients written before these fields existed keep working.
+func KYCFieldsRequired() bool {
+	return os.Getenv("REQUIRE_KYC_FIELDS") == "true"
+}
+
+// validateRequiredIfKYC makes a field required when KYC fields are required
+func validateRequiredIfKYC(fl validator.FieldLevel) bool {
+	return fl.Field().String() != "" || !KYCFieldsRequired()
+}
+
+// validateRequiredUnlessSigned makes a field required except in signed amount mode
+func validateRequiredUnlessSigned(fl validator.FieldLevel) bool {
+	return fl.Field().String() != "" || SignedAmountMode()
+}
+
+// validateSource checks a Source-Type value against the configured VALID_SOURCES
+func validateSource(fl validator.FieldLevel) bool {
+	return IsValidSource(fl.Field().String())
+}
+
+// validateMoney checks that a decimal literal is a non-negative amount without an exponent; its
+// decimals are checked against the currency when it is parsed
+func validateMoney(fl validator.FieldLevel) bool {
+	whole, fraction, _ := strings.Cut(strings.TrimPrefix(fl.Field().String(), "+"), ".")
+	return (whole != "" || fraction != "") && strings.Trim(whole+fraction, "0123456789") == ""
+}
+
+// validateMinAge checks that a date of birth makes the user at least MinUserAge years old
+func validateMinAge(fl validator.FieldLevel) bool {
+	dateOfBirth, err := time.Parse(DateLayout, fl.Field().String())
+	if err != nil {
+		return false
+	}
+
+	return !dateOfBirth.AddDate(MinUserAge(), 0, 0).After(time.Now())
+}
+
+// CheckAccountActive returns the error matching an account status that blocks money movement
+func CheckAccountActive(status string) error {
+	switch status {
+	case AccountStatusFrozen:
+		return ErrAccountFrozen
+	case AccountStatusClosed:
+		return ErrAccountClosed
+	default:
+		return nil
+	}
+}
+
+// ResolveSource is the single place a transaction source is validated. The Source-Type header is
+// required; a source in the body is optional but must name the same source. Values are case-insensitive.
+func ResolveSource(header, body string) (string, error) {
+	source := strings.ToLower(strings.TrimSpace(header))
+	if !IsValidSource(source) {
+		return "", ErrInvalidSource
+	}
+
+	if body == "" {
+		return source, nil
+	}
+	if bodySource := strings.ToLower(strings.TrimSpace(body)); bodySource != source {
+		if !IsValidSource(bodySource) {
+			return "", ErrInvalidSource
+		}
+		return "", ErrSourceMismatch
+	}
+	return source, nil
 }
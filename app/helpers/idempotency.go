@@ -0,0 +1,40 @@
+package helpers
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrReferenceInUse is returned under global scope when another account already used a client reference
+var ErrReferenceInUse = errors.New("client reference already used by another account")
+
+// ErrReferenceMismatch is returned when a client reference is replayed with a different amount or type
+var ErrReferenceMismatch = errors.New("client reference already used for a different transaction")
+
+// Idempotency scopes for client references
+const (
+	IdempotencyScopeAccount = "account"
+	IdempotencyScopeGlobal  = "global"
+)
+
+// IdempotencyScope returns how far a client reference must be unique, read from IDEMPOTENCY_SCOPE:
+// "account" (default) lets different accounts reuse a reference, "global" reserves it system-wide
+func IdempotencyScope() string {
+	if os.Getenv("IDEMPOTENCY_SCOPE") == IdempotencyScopeGlobal {
+		return IdempotencyScopeGlobal
+	}
+	return IdempotencyScopeAccount
+}
+
+// IdempotencyKeyHeader lets clients retry a POST without applying it twice
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyKeyMaxEntries bounds memory use of the Idempotency-Key response store
+const IdempotencyKeyMaxEntries = 10000
+
+// IdempotencyKeyTTL returns how long a response is replayed for a repeated Idempotency-Key, read from
+// IDEMPOTENCY_KEY_TTL_SECONDS (default 86400, 0 disables)
+func IdempotencyKeyTTL() time.Duration {
+	return time.Duration(GetEnvInt("IDEMPOTENCY_KEY_TTL_SECONDS", 86400)) * time.Second
+}
@@ -0,0 +1,149 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrJSONTooDeep      = errors.New("JSON body is nested too deeply")
+	ErrJSONArrayTooLong = errors.New("JSON body contains an array that is too long")
+)
+
+const (
+	DefaultJSONMaxDepth       = 32
+	DefaultJSONMaxArrayLength = 1000
+	DefaultJSONMaxBodyBytes   = 1 << 20
+)
+
+// DefaultJSONContentTypes are the request media types accepted when JSON_CONTENT_TYPES is unset
+var DefaultJSONContentTypes = []string{"application/json", "application/vnd.gobanking.v1+json"}
+
+// JSONContentTypesFromEnv reads the comma-separated allowlist of request media types from JSON_CONTENT_TYPES
+func JSONContentTypesFromEnv() []string {
+	value := os.Getenv("JSON_CONTENT_TYPES")
+	if value == "" {
+		return DefaultJSONContentTypes
+	}
+
+	var types []string
+	for _, mediaType := range strings.Split(value, ",") {
+		if mediaType = strings.ToLower(strings.TrimSpace(mediaType)); mediaType != "" {
+			types = append(types, mediaType)
+		}
+	}
+	return types
+}
+
+// IsJSONContentType reports whether a Content-Type header names an allowed media type. Parameters are
+// ignored except charset, which must be UTF-8 when present since JSON bodies are always UTF-8.
+func IsJSONContentType(contentType string, allowed []string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+		return false
+	}
+
+	for _, allowedType := range allowed {
+		if mediaType == allowedType {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONLimits bounds the byte size of a request body and the shape of the JSON document in it
+type JSONLimits struct {
+	MaxDepth       int
+	MaxArrayLength int
+	MaxBodyBytes   int64
+}
+
+// JSONLimitsFromEnv reads JSON_MAX_DEPTH, JSON_MAX_ARRAY_LENGTH and JSON_MAX_BODY_BYTES, falling back
+// to the defaults when unset. A value that is not a positive integer is an error, so a typo cannot
+// switch a limit off.
+func JSONLimitsFromEnv() (JSONLimits, error) {
+	maxDepth, err := positiveEnvInt("JSON_MAX_DEPTH", DefaultJSONMaxDepth)
+	if err != nil {
+		return JSONLimits{}, err
+	}
+	maxArrayLength, err := positiveEnvInt("JSON_MAX_ARRAY_LENGTH", DefaultJSONMaxArrayLength)
+	if err != nil {
+		return JSONLimits{}, err
+	}
+	maxBodyBytes, err := positiveEnvInt("JSON_MAX_BODY_BYTES", DefaultJSONMaxBodyBytes)
+	if err != nil {
+		return JSONLimits{}, err
+	}
+
+	return JSONLimits{MaxDepth: maxDepth, MaxArrayLength: maxArrayLength, MaxBodyBytes: int64(maxBodyBytes)}, nil
+}
+
+// positiveEnvInt reads a positive integer from the environment, falling back when unset
+func positiveEnvInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, value)
+	}
+	return parsed, nil
+}
+
+// CheckJSONLimits walks the document token by token and stops at the first limit violation,
+// so pathological bodies are rejected without being decoded into Go values.
+func CheckJSONLimits(body []byte, limits JSONLimits) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+
+	// Element counts of the currently open arrays; -1 marks an open object
+	var open []int
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// End of input, or a syntax error left for the real decoder to report
+			return nil
+		}
+
+		// Count values placed directly inside an array
+		if n := len(open); n > 0 && open[n-1] >= 0 {
+			if delim, ok := token.(json.Delim); !ok || delim == '[' || delim == '{' {
+				open[n-1]++
+				if open[n-1] > limits.MaxArrayLength {
+					return ErrJSONArrayTooLong
+				}
+			}
+		}
+
+		delim, ok := token.(json.Delim)
+		if !ok {
+			continue
+		}
+
+		switch delim {
+		case '[', '{':
+			if len(open) >= limits.MaxDepth {
+				return ErrJSONTooDeep
+			}
+			if delim == '[' {
+				open = append(open, 0)
+			} else {
+				open = append(open, -1)
+			}
+		case ']', '}':
+			open = open[:len(open)-1]
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package helpers
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Link is a hypermedia reference rendered inside a response's _links object
+type Link struct {
+	Href string `json:"href"`
+}
+
+// BasePath returns the prefix the API is exposed under, read from API_BASE_PATH (e.g. "/api/v1")
+func BasePath() string {
+	basePath := strings.Trim(os.Getenv("API_BASE_PATH"), "/")
+	if basePath == "" {
+		return ""
+	}
+	return "/" + basePath
+}
+
+// APIPath builds an href under the base path, escaping each path segment
+func APIPath(segments ...string) string {
+	var builder strings.Builder
+	builder.WriteString(BasePath())
+	for _, segment := range segments {
+		builder.WriteString("/")
+		builder.WriteString(url.PathEscape(segment))
+	}
+	return builder.String()
+}
+
+// TrustProxyHeaders reports whether X-Forwarded-Proto and X-Forwarded-Host may be used,
+// which is only safe when the server is reachable exclusively through a trusted proxy
+func TrustProxyHeaders() bool {
+	return os.Getenv("TRUST_PROXY_HEADERS") == "true"
+}
+
+// RequestScheme returns the scheme the client used to reach the API
+func RequestScheme(r *http.Request) string {
+	if TrustProxyHeaders() {
+		switch proto := strings.ToLower(firstForwardedValue(r.Header.Get("X-Forwarded-Proto"))); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// RequestHost returns the host the client used to reach the API
+func RequestHost(r *http.Request) string {
+	if TrustProxyHeaders() {
+		if host := firstForwardedValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+			return host
+		}
+	}
+	return r.Host
+}
+
+// ClientIP returns the address of the client. Behind a trusted proxy it is the last X-Forwarded-For
+// entry, the one the proxy appended itself; earlier entries are client-controlled.
+func ClientIP(r *http.Request) string {
+	if TrustProxyHeaders() {
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			values := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(values[len(values)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// AbsoluteURL turns an href built by APIPath into an absolute URL as seen by the client
+func AbsoluteURL(r *http.Request, href string) string {
+	return RequestScheme(r) + "://" + RequestHost(r) + href
+}
+
+// firstForwardedValue keeps the value set by the proxy closest to the client
+func firstForwardedValue(value string) string {
+	first, _, _ := strings.Cut(value, ",")
+	return strings.TrimSpace(first)
+}
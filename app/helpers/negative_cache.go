@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"sync"
+	"time"
+)
+
+// NegativeCacheMaxEntries bounds memory use when clients scan many nonexistent IDs
+const NegativeCacheMaxEntries = 10000
+
+// UserNegativeCacheTTL returns how long a missing user ID is remembered, read from
+// USER_NEGATIVE_CACHE_TTL_SECONDS. Zero (the default) disables the cache.
+func UserNegativeCacheTTL() time.Duration {
+	return time.Duration(GetEnvInt("USER_NEGATIVE_CACHE_TTL_SECONDS", 0)) * time.Second
+}
+
+// NegativeCache remembers IDs recently confirmed missing. Lookups that raced with an
+// invalidation are discarded, so an ID created while it was being looked up is never cached.
+type NegativeCache struct {
+	mu         sync.Mutex
+	ttl        func() time.Duration
+	now        func() time.Time
+	expires    map[int64]time.Time
+	generation uint64
+}
+
+// NewNegativeCache creates a cache whose TTL is read on every insert, so it follows the configuration
+// loaded after package initialization
+func NewNegativeCache(ttl func() time.Duration, now func() time.Time) *NegativeCache {
+	return &NegativeCache{ttl: ttl, now: now, expires: map[int64]time.Time{}}
+}
+
+// Generation must be read before the lookup whose miss is later passed to Add
+func (c *NegativeCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// Contains reports whether id was confirmed missing within the TTL
+func (c *NegativeCache) Contains(id int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.expires[id]
+	if !ok {
+		return false
+	}
+	if !c.now().Before(expires) {
+		delete(c.expires, id)
+		return false
+	}
+	return true
+}
+
+// Add records a miss for id observed by a lookup that started at generation
+func (c *NegativeCache) Add(id int64, generation uint64) {
+	ttl := c.ttl()
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// An invalidation happened during the lookup, so the miss may already be stale
+	if generation != c.generation {
+		return
+	}
+
+	now := c.now()
+	if len(c.expires) >= NegativeCacheMaxEntries {
+		for cachedID, expires := range c.expires {
+			if !now.Before(expires) {
+				delete(c.expires, cachedID)
+			}
+		}
+		if len(c.expires) >= NegativeCacheMaxEntries {
+			return
+		}
+	}
+	c.expires[id] = now.Add(ttl)
+}
+
+// Invalidate forgets id, e.g. once it has been created
+func (c *NegativeCache) Invalidate(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	delete(c.expires, id)
+}
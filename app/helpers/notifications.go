@@ -0,0 +1,17 @@
+package helpers
+
+// Alert kinds a user can opt out of in their notification preferences
+const (
+	NotificationLowBalance       = "low_balance"
+	NotificationLargeTransaction = "large_transaction"
+)
+
+// Delivery channels for alerts
+const (
+	NotificationChannelEmail = "email"
+	NotificationChannelSMS   = "sms"
+	NotificationChannelPush  = "push"
+)
+
+// DefaultNotificationChannel is used for users who have not stored any preferences
+const DefaultNotificationChannel = NotificationChannelEmail
@@ -0,0 +1,66 @@
+package helpers
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+var ErrInvalidPagination = errors.New("invalid pagination parameters")
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 100
+)
+
+// DefaultTransactionPageLimit is the page size of a user's transaction list
+const DefaultTransactionPageLimit = 20
+
+// DefaultUserPageLimit is the page size of the user list
+const DefaultUserPageLimit = 20
+
+// ParsePagination reads the optional limit and offset query parameters
+func ParsePagination(query url.Values) (limit int32, offset int32, err error) {
+	return ParsePaginationWithDefault(query, DefaultPageLimit)
+}
+
+// ParsePaginationWithDefault is ParsePagination with a listing-specific default limit
+func ParsePaginationWithDefault(query url.Values, defaultLimit int32) (limit int32, offset int32, err error) {
+	limit, err = parseLimit(query, defaultLimit)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	offset, err = parsePageParam(query.Get("offset"), 0)
+	if err != nil {
+		return 0, 0, ErrInvalidPagination
+	}
+
+	return limit, offset, nil
+}
+
+// ParseLimit reads the optional limit query parameter, for keyset paginated listings without an offset
+func ParseLimit(query url.Values) (int32, error) {
+	return parseLimit(query, DefaultPageLimit)
+}
+
+func parseLimit(query url.Values, defaultLimit int32) (int32, error) {
+	limit, err := parsePageParam(query.Get("limit"), defaultLimit)
+	if err != nil || limit == 0 || limit > MaxPageLimit {
+		return 0, ErrInvalidPagination
+	}
+	return limit, nil
+}
+
+func parsePageParam(value string, fallback int32) (int32, error) {
+	if value == "" {
+		return fallback, nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 32)
+	if err != nil || parsed < 0 {
+		return 0, ErrInvalidPagination
+	}
+
+	return int32(parsed), nil
+}
@@ -0,0 +1,74 @@
+package helpers
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/rathorevk/GoBanking/app/models"
+)
+
+var ErrOperationNotPermitted = errors.New("operation not permitted for this API key")
+
+// ScopeBalancesRead allows reading account balances
+const ScopeBalancesRead = "balances:read"
+
+// Operations checked by Authorize
+const (
+	OperationCreateTransaction = "create_transaction"
+	OperationReadBalance       = "read_balance"
+)
+
+// SourcePermissions lists what a principal acting as a source may do
+type SourcePermissions struct {
+	TransactionTypes []string
+	ReadBalances     bool
+}
+
+// sourcePermissions keeps game servers to game outcomes and away from other users' balances
+var sourcePermissions = map[string]SourcePermissions{
+	"game": {
+		TransactionTypes: []string{"win", "lose"},
+	},
+	"server": {
+		TransactionTypes: []string{"win", "lose"},
+		ReadBalances:     true,
+	},
+	"payment": {
+		TransactionTypes: []string{"deposit", "withdrawal"},
+		ReadBalances:     true,
+	},
+}
+
+// Authorize checks that the principal may perform the operation. A principal without sources is
+// only limited by its scopes; otherwise every source it may act as must permit the operation,
+// and transactions are limited to the types of the request's source.
+func Authorize(principal models.APIPrincipal, operation, source, transactionType string) error {
+	switch operation {
+	case OperationReadBalance:
+		if !slices.Contains(principal.Scopes, ScopeBalancesRead) {
+			return ErrInsufficientScope
+		}
+		for _, principalSource := range principal.Sources {
+			if !sourcePermissions[principalSource].ReadBalances {
+				return ErrOperationNotPermitted
+			}
+		}
+		return nil
+	case OperationCreateTransaction:
+		if !slices.Contains(principal.Scopes, ScopeTransactionsWrite) {
+			return ErrInsufficientScope
+		}
+		if len(principal.Sources) == 0 {
+			return nil
+		}
+		if !slices.Contains(principal.Sources, source) {
+			return ErrSourceNotPermitted
+		}
+		if !slices.Contains(sourcePermissions[source].TransactionTypes, transactionType) {
+			return ErrOperationNotPermitted
+		}
+		return nil
+	default:
+		return ErrOperationNotPermitted
+	}
+}
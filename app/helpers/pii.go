@@ -0,0 +1,32 @@
+package helpers
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	// ScopeUsersRead allows reading user profiles
+	ScopeUsersRead = "users:read"
+	// ScopeUsersPII allows receiving the PII fields of user profiles; without it they are omitted
+	ScopeUsersPII = "users:pii"
+)
+
+// DefaultPIIFields are the user response fields omitted for callers without the users:pii scope
+var DefaultPIIFields = []string{"email", "full_name", "date_of_birth"}
+
+// PIIFields returns the JSON fields omitted from user responses, read as a comma-separated list from PII_FIELDS
+func PIIFields() []string {
+	value := os.Getenv("PII_FIELDS")
+	if value == "" {
+		return DefaultPIIFields
+	}
+
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
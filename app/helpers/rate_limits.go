@@ -0,0 +1,35 @@
+package helpers
+
+import "strings"
+
+// DefaultExpensiveEndpointConcurrency bounds simultaneous runs of each expensive endpoint
+const DefaultExpensiveEndpointConcurrency = 4
+
+// ExpensiveEndpointConcurrency returns how many requests each expensive endpoint (exports, ledger
+// verification) may serve at once, read from EXPENSIVE_ENDPOINT_CONCURRENCY. Zero disables the limit.
+func ExpensiveEndpointConcurrency() int {
+	return GetEnvInt("EXPENSIVE_ENDPOINT_CONCURRENCY", DefaultExpensiveEndpointConcurrency)
+}
+
+// SourceRateLimits reads the requests per minute allowed for each source from
+// RATE_LIMIT_<SOURCE>_PER_MINUTE, e.g. RATE_LIMIT_GAME_PER_MINUTE. Zero or unset means unlimited.
+func SourceRateLimits() map[string]int {
+	limits := map[string]int{}
+	for _, source := range ValidSources() {
+		if limit := GetEnvInt("RATE_LIMIT_"+strings.ToUpper(source)+"_PER_MINUTE", 0); limit > 0 {
+			limits[source] = limit
+		}
+	}
+	return limits
+}
+
+// IPRateLimit reads the requests per second allowed per client IP from RATE_LIMIT_IP_PER_SECOND
+// and the burst from RATE_LIMIT_IP_BURST, which defaults to the rate. A zero rate means unlimited.
+func IPRateLimit() (perSecond, burst int) {
+	perSecond = GetEnvInt("RATE_LIMIT_IP_PER_SECOND", 0)
+	burst = GetEnvInt("RATE_LIMIT_IP_BURST", perSecond)
+	if burst < 1 {
+		burst = 1
+	}
+	return perSecond, burst
+}
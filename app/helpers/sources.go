@@ -0,0 +1,60 @@
+package helpers
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultTransactionSources are the valid Source-Type values when VALID_SOURCES is unset
+var DefaultTransactionSources = []string{"game", "server", "payment"}
+
+// validSources is the set IsValidSource consults. It is replaced as a whole, so the middleware can
+// read it concurrently without locking.
+var validSources atomic.Pointer[map[string]struct{}]
+
+func init() {
+	SetValidSources(DefaultTransactionSources)
+}
+
+// LoadValidSources reads the valid Source-Type values from VALID_SOURCES, a comma-separated list such
+// as game,server,payment,atm. Values are case-insensitive; the defaults apply when it is unset or empty.
+func LoadValidSources() {
+	var sources []string
+	for _, source := range strings.Split(os.Getenv("VALID_SOURCES"), ",") {
+		if source = strings.ToLower(strings.TrimSpace(source)); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 {
+		sources = DefaultTransactionSources
+	}
+	SetValidSources(sources)
+}
+
+// SetValidSources replaces the valid Source-Type values
+func SetValidSources(sources []string) {
+	set := make(map[string]struct{}, len(sources))
+	for _, source := range sources {
+		set[source] = struct{}{}
+	}
+	validSources.Store(&set)
+}
+
+// ValidSources lists the valid Source-Type values, sorted by name
+func ValidSources() []string {
+	set := *validSources.Load()
+	sources := make([]string, 0, len(set))
+	for source := range set {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// IsValidSource reports whether a Source-Type value is accepted
+func IsValidSource(source string) bool {
+	_, ok := (*validSources.Load())[source]
+	return ok
+}
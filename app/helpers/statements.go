@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"net/url"
+	"time"
+)
+
+// DefaultStatementPeriod is the span of a statement when from is omitted
+const DefaultStatementPeriod = 30 * 24 * time.Hour
+
+// StatementRange is a validated statement period, from inclusive and to exclusive
+type StatementRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// ParseStatementRange reads the RFC3339 from and to query parameters. To defaults to now and from to
+// DefaultStatementPeriod before to.
+func ParseStatementRange(query url.Values, now time.Time) (StatementRange, error) {
+	to, err := ParseTimestamp(query.Get("to"))
+	if err != nil {
+		return StatementRange{}, err
+	}
+	if to.IsZero() {
+		to = now.UTC()
+	}
+
+	from, err := ParseTimestamp(query.Get("from"))
+	if err != nil {
+		return StatementRange{}, err
+	}
+	if from.IsZero() {
+		from = to.Add(-DefaultStatementPeriod)
+	}
+
+	if !from.Before(to) {
+		return StatementRange{}, ErrInvalidRange
+	}
+
+	return StatementRange{From: from, To: to}, nil
+}
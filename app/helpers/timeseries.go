@@ -0,0 +1,106 @@
+package helpers
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+var (
+	ErrInvalidInterval = errors.New("invalid timeseries interval")
+	ErrInvalidRange    = errors.New("timeseries range must end after it starts")
+	ErrTooManyBuckets  = errors.New("timeseries range has too many periods")
+)
+
+// Timeseries intervals accepted by the balance timeseries endpoint
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+const (
+	// DefaultTimeseriesBuckets is the number of periods returned when from is omitted
+	DefaultTimeseriesBuckets = 30
+	// MaxTimeseriesBuckets bounds the periods of a single request
+	MaxTimeseriesBuckets = 366
+)
+
+// TimeseriesRange is a validated interval with the first period start and the exclusive end
+type TimeseriesRange struct {
+	Interval string
+	From     time.Time
+	To       time.Time
+}
+
+// PeriodStart returns the start of the period containing t; weeks start on Monday, all in UTC
+func PeriodStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	switch interval {
+	case IntervalHour:
+		return t.Truncate(time.Hour)
+	case IntervalWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+}
+
+// NextPeriod returns the start of the period following the one starting at start
+func NextPeriod(start time.Time, interval string) time.Time {
+	switch interval {
+	case IntervalHour:
+		return start.Add(time.Hour)
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// ParseTimeseriesRange reads the interval (default day), from and to (default now) query parameters.
+// From is aligned down to the start of its period and defaults to DefaultTimeseriesBuckets periods before to.
+func ParseTimeseriesRange(query url.Values, now time.Time) (TimeseriesRange, error) {
+	interval := query.Get("interval")
+	if interval == "" {
+		interval = IntervalDay
+	}
+	if interval != IntervalHour && interval != IntervalDay && interval != IntervalWeek {
+		return TimeseriesRange{}, ErrInvalidInterval
+	}
+
+	to, err := ParseTimestamp(query.Get("to"))
+	if err != nil {
+		return TimeseriesRange{}, err
+	}
+	if to.IsZero() {
+		to = now.UTC()
+	}
+
+	from, err := ParseTimestamp(query.Get("from"))
+	if err != nil {
+		return TimeseriesRange{}, err
+	}
+	if from.IsZero() {
+		from = PeriodStart(to, interval)
+		for i := 1; i < DefaultTimeseriesBuckets; i++ {
+			from = PeriodStart(from.Add(-time.Nanosecond), interval)
+		}
+	}
+	from = PeriodStart(from, interval)
+
+	if !from.Before(to) {
+		return TimeseriesRange{}, ErrInvalidRange
+	}
+
+	buckets := 0
+	for start := from; start.Before(to); start = NextPeriod(start, interval) {
+		buckets++
+		if buckets > MaxTimeseriesBuckets {
+			return TimeseriesRange{}, ErrTooManyBuckets
+		}
+	}
+
+	return TimeseriesRange{Interval: interval, From: from, To: to}, nil
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import "math"
+
+// TransactionType describes how a transaction type affects the account balance
+type TransactionType struct {
+	Name string
+	// Sign is +1 for credits and -1 for debits
+	Sign int64
+	// AllowNegative lets corrective debits drive the balance below zero
+	AllowNegative bool
+}
+
+// transactionTypes is the registry of every transaction type the ledger understands
+var transactionTypes = map[string]TransactionType{
+	"win":        {Name: "win", Sign: 1},
+	"deposit":    {Name: "deposit", Sign: 1},
+	"lose":       {Name: "lose", Sign: -1},
+	"withdrawal": {Name: "withdrawal", Sign: -1},
+	"reversal":   {Name: "reversal", Sign: -1, AllowNegative: true},
+	"adjustment": {Name: "adjustment", Sign: -1, AllowNegative: true},
+}
+
+// LookupTransactionType returns the registered transaction type with the given name
+func LookupTransactionType(name string) (TransactionType, bool) {
+	transactionType, ok := transactionTypes[name]
+	return transactionType, ok
+}
+
+// ApplyTransaction computes the balance after applying a positive amount of the given type. Both are in
+// minor units, so repeated transactions cannot accumulate rounding errors; a result beyond the range of
+// an int64 is rejected with ErrBalanceOverflow instead of wrapping around.
+func ApplyTransaction(balance, amount int64, name string) (int64, error) {
+	transactionType, ok := LookupTransactionType(name)
+	if !ok {
+		return 0, ErrInvalidTransactionType
+	}
+	if amount < 0 {
+		return 0, ErrAmountMustBePositive
+	}
+
+	if transactionType.Sign > 0 && balance > math.MaxInt64-amount {
+		return 0, ErrBalanceOverflow
+	}
+	if transactionType.Sign < 0 && balance < math.MinInt64+amount {
+		return 0, ErrBalanceOverflow
+	}
+
+	newBalance := balance + transactionType.Sign*amount
+	if newBalance < 0 && !transactionType.AllowNegative {
+		return 0, ErrInsufficientBalance
+	}
+
+	return newBalance, nil
+}
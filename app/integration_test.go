@@ -0,0 +1,325 @@
+//go:build integration
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/rathorevk/GoBanking/app/database"
+	"github.com/rathorevk/GoBanking/app/database/sqlc"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUserTransactionBalance(t *testing.T) {
+	server, cleanup := startTestServer(t)
+	defer cleanup()
+
+	// Create a user with its default account
+	resp := sendRequest(t, server, "POST", "/user", nil, `{"username":"integration","full_name":"Integration User","email":"integration@example.com","date_of_birth":"1990-05-17","country":"DE"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var created struct {
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	require.NotZero(t, created.User.ID)
+	token := bearerToken(t, created.User.ID)
+	gameHeaders := map[string]string{"Authorization": token, "Source-Type": "game"}
+
+	// Credit the new account
+	transactionPath := fmt.Sprintf("/user/%d/transaction", created.User.ID)
+	resp = sendRequest(t, server, "POST", transactionPath, gameHeaders, `{"state":"win","amount":"10.15","transactionId":"integration-win-001"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// A debit beyond the balance maps to the insufficient balance error
+	resp = sendRequest(t, server, "POST", transactionPath, gameHeaders, `{"state":"lose","amount":"20.00","transactionId":"integration-lose-001"}`)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	// The balance reflects only the applied transaction
+	resp = sendRequest(t, server, "GET", fmt.Sprintf("/user/%d/balance", created.User.ID), map[string]string{"Authorization": token}, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var balance struct {
+		Balance string `json:"balance"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&balance))
+	assert.Equal(t, "10.15", balance.Balance)
+
+	// The mini-statement query returns the account with its one transaction
+	resp = sendRequest(t, server, "GET", fmt.Sprintf("/user/%d/mini-statement", created.User.ID), map[string]string{"Authorization": token}, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var statement struct {
+		AccountID    int64  `json:"account_id"`
+		Balance      string `json:"balance"`
+		Transactions []struct {
+			TransactionID string `json:"transaction_id"`
+			Amount        string `json:"amount"`
+		} `json:"transactions"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&statement))
+	assert.Equal(t, "10.15", statement.Balance)
+	require.Len(t, statement.Transactions, 1)
+	assert.Equal(t, "integration-win-001", statement.Transactions[0].TransactionID)
+	assert.Equal(t, "10.15", statement.Transactions[0].Amount)
+
+	// Without since the export pages through the whole history
+	resp = sendRequest(t, server, "GET", fmt.Sprintf("/user/%d/account/%d/export", created.User.ID, statement.AccountID), map[string]string{"Authorization": token}, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var export struct {
+		TransactionCount int `json:"transaction_count"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&export))
+	assert.Equal(t, 1, export.TransactionCount)
+}
+
+func TestNetWorthSnapshotDuringTransfers(t *testing.T) {
+	server, cleanup := startTestServer(t)
+	defer cleanup()
+
+	// A user with 100.00 on the default EUR account and 100.00 on a USD account
+	resp := sendRequest(t, server, "POST", "/user", nil, `{"username":"snapshot","full_name":"Snapshot User","email":"snapshot@example.com","date_of_birth":"1990-05-17","country":"DE"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var created struct {
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+		Account struct {
+			ID int64 `json:"id"`
+		} `json:"account"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	token := bearerToken(t, created.User.ID)
+	auth := map[string]string{"Authorization": token}
+
+	resp = sendRequest(t, server, "POST", fmt.Sprintf("/user/%d/transaction", created.User.ID), map[string]string{"Authorization": token, "Source-Type": "game"}, `{"state":"win","amount":"100.00","transactionId":"snapshot-win-001"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = sendRequest(t, server, "POST", "/accounts", auth, fmt.Sprintf(`{"user_id":"%d","currency":"USD","balance":100}`, created.User.ID))
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var usd struct {
+		ID int64 `json:"id"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&usd))
+
+	// Move one minor unit back and forth between the accounts; each move commits both rows together
+	done := make(chan error, 1)
+	go func() {
+		pool := database.DBClient.Pool
+		for i := 0; i < 200; i++ {
+			from, to := created.Account.ID, usd.ID
+			if i%2 == 1 {
+				from, to = to, from
+			}
+			tx, err := pool.Begin(context.Background())
+			if err != nil {
+				done <- err
+				return
+			}
+			for _, move := range []struct {
+				id    int64
+				delta int64
+			}{{from, -1}, {to, 1}} {
+				if _, err := tx.Exec(context.Background(), "UPDATE accounts SET balance_minor = balance_minor + $2, balance = (balance_minor + $2) / 100.0 WHERE id = $1", move.id, move.delta); err != nil {
+					_ = tx.Rollback(context.Background())
+					done <- err
+					return
+				}
+			}
+			if err := tx.Commit(context.Background()); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	// Every snapshot sees either none or both rows of a move, so the balances always add up to 200.00
+	for running := true; running; {
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+			running = false
+		default:
+		}
+
+		resp := sendRequest(t, server, "GET", fmt.Sprintf("/user/%d/networth", created.User.ID), auth, "")
+		var netWorth struct {
+			Accounts []struct {
+				Balance string `json:"balance"`
+			} `json:"accounts"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&netWorth))
+		resp.Body.Close()
+
+		total := int64(0)
+		for _, account := range netWorth.Accounts {
+			cents, err := strconv.ParseInt(strings.Replace(account.Balance, ".", "", 1), 10, 64)
+			require.NoError(t, err)
+			total += cents
+		}
+		require.Len(t, netWorth.Accounts, 2)
+		assert.Equal(t, int64(20000), total)
+	}
+}
+
+func TestSoftDeletedUserIsNotFound(t *testing.T) {
+	server, cleanup := startTestServer(t)
+	defer cleanup()
+
+	resp := sendRequest(t, server, "POST", "/user", nil, `{"username":"deleted","full_name":"Deleted User","email":"deleted@example.com","date_of_birth":"1990-05-17","country":"DE"}`)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var created struct {
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+		Account struct {
+			ID int64 `json:"id"`
+		} `json:"account"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	userPath := fmt.Sprintf("/user/%d", created.User.ID)
+	accountPath := fmt.Sprintf("%s/account/%d", userPath, created.Account.ID)
+	auth := map[string]string{"Authorization": bearerToken(t, created.User.ID)}
+
+	del := func() int {
+		resp := sendRequest(t, server, "DELETE", userPath, auth, "")
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	require.Equal(t, http.StatusOK, del())
+
+	// The user, its account by ID and a repeated delete all answer 404
+	for _, path := range []string{userPath, userPath + "/balance", accountPath, accountPath + "/balance", accountPath + "/mini-statement"} {
+		resp := sendRequest(t, server, "GET", path, auth, "")
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+	assert.Equal(t, http.StatusNotFound, del())
+
+	// The account list of a deleted user is empty
+	resp = sendRequest(t, server, "GET", userPath+"/accounts", auth, "")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var accounts []json.RawMessage
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&accounts))
+	assert.Empty(t, accounts)
+}
+
+func TestCurrencyPrecisionLoadedFromTable(t *testing.T) {
+	_, cleanup := startTestServer(t)
+	defer cleanup()
+
+	assert.Equal(t, map[string]int{"BTC": 8, "EUR": 2, "GBP": 2, "JPY": 0, "USD": 2}, helpers.CurrencyPrecision)
+}
+
+// explainTx answers the queries sqlc sends with their plans instead of their rows
+type explainTx struct {
+	pgx.Tx
+	plan string
+}
+
+// errExplained stops the sqlc method once the plan is read, as the plan rows are not transactions
+var errExplained = errors.New("query explained")
+
+func (tx *explainTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := tx.Tx.Query(ctx, "EXPLAIN "+sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	tx.plan = strings.Join(lines, "\n")
+	return nil, errExplained
+}
+
+func TestAdminTransactionsUseInsertedAtIndex(t *testing.T) {
+	_, cleanup := startTestServer(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	cursor := sqlc.ListAdminTransactionsParams{
+		AfterInsertedAt: models.NewTimestamp(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)),
+		AfterID:         pgtype.Text{String: "tx-b", Valid: true},
+		PageSize:        51,
+	}
+
+	tests := []struct {
+		name   string
+		params sqlc.ListAdminTransactionsParams
+		list   func(queries *sqlc.Queries, params sqlc.ListAdminTransactionsParams) error
+	}{
+		{
+			name:   "Newest first, first page",
+			params: sqlc.ListAdminTransactionsParams{PageSize: 51},
+			list: func(queries *sqlc.Queries, params sqlc.ListAdminTransactionsParams) error {
+				_, err := queries.ListAdminTransactions(ctx, params)
+				return err
+			},
+		},
+		{
+			name:   "Newest first, after a cursor",
+			params: cursor,
+			list: func(queries *sqlc.Queries, params sqlc.ListAdminTransactionsParams) error {
+				_, err := queries.ListAdminTransactions(ctx, params)
+				return err
+			},
+		},
+		{
+			name:   "Oldest first, after a cursor",
+			params: cursor,
+			list: func(queries *sqlc.Queries, params sqlc.ListAdminTransactionsParams) error {
+				_, err := queries.ListAdminTransactionsAscending(ctx, sqlc.ListAdminTransactionsAscendingParams(params))
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := database.DBClient.Pool.Begin(ctx)
+			require.NoError(t, err)
+			defer tx.Rollback(ctx)
+
+			// The test table is tiny, so rule out sequential scans to see whether the index can serve the query
+			_, err = tx.Exec(ctx, "SET LOCAL enable_seqscan = off")
+			require.NoError(t, err)
+
+			explain := &explainTx{Tx: tx}
+			require.ErrorIs(t, tt.list(sqlc.New(explain), tt.params), errExplained)
+
+			// The index returns the rows in page order, with the keyset predicate as its condition
+			assert.Contains(t, explain.plan, "idx_transactions_inserted_at_id", explain.plan)
+			assert.Contains(t, explain.plan, "Index Cond: (ROW(t.inserted_at, t.id)", explain.plan)
+			assert.NotContains(t, explain.plan, "Sort", explain.plan)
+		})
+	}
+}
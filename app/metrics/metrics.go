@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "gobanking"
+
+// Transaction outcomes recorded by TransactionsTotal
+const (
+	OutcomeSuccess             = "success"
+	OutcomeInsufficientBalance = "insufficient_balance"
+	OutcomeRejected            = "rejected"
+)
+
+var (
+	// RequestsTotal counts the API requests by method, route template and status code
+	RequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "API requests by method, route and status code.",
+	}, []string{"method", "path", "status"})
+
+	// RequestDuration observes the API request latencies by method, route template and status code
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "API request latencies in seconds by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "path", "status"})
+
+	// RequestsInFlight is the number of API requests being served
+	RequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "API requests currently being served.",
+	})
+
+	// TransactionsTotal counts the executed transactions by type and outcome
+	TransactionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transactions_total",
+		Help:      "Transactions by type and outcome: success, insufficient_balance or rejected.",
+	}, []string{"type", "outcome"})
+)
+
+// Register adds the application collectors to registerer. The collectors record whether registered
+// or not, so tests can read them without a registry.
+func Register(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{RequestsTotal, RequestDuration, RequestsInFlight, TransactionsTotal} {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
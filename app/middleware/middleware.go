@@ -1,49 +1,1095 @@
 package middleware
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"math"
 	"net/http"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
 	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/metrics"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/rathorevk/GoBanking/app/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func LoggingMiddleware(next http.Handler) http.Handler {
+// requestLog is the JSON log record written per request
+type requestLog struct {
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	DurationMS float64 `json:"duration_ms"`
+	Bytes      int     `json:"bytes"`
+	RequestID  string  `json:"request_id,omitempty"`
+}
+
+// loggingWriter records the status code and the number of body bytes written
+type loggingWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *loggingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *loggingWriter) Write(body []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(body)
+	w.bytes += n
+	return n, err
+}
+
+func (w *loggingWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	flush(w.ResponseWriter)
+}
+
+// LoggingMiddleware logs one line per request in the given format (helpers.LogFormatText or
+// helpers.LogFormatJSON). JSON lines carry no log prefix so the pipeline can parse them as is.
+func LoggingMiddleware(format string) func(http.Handler) http.Handler {
+	if format == helpers.LogFormatJSON {
+		return logRequests(format, log.New(log.Writer(), "", 0))
+	}
+	return logRequests(format, log.Default())
+}
+
+func logRequests(format string, logger *log.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			writer := &loggingWriter{ResponseWriter: w}
+
+			next.ServeHTTP(writer, r)
+
+			// Handlers that never write get an implicit 200
+			if writer.status == 0 {
+				writer.status = http.StatusOK
+			}
+			duration := time.Since(start)
+
+			if format != helpers.LogFormatJSON {
+				logger.Printf("%s %s %d %dB %s request_id=%s", r.Method, r.RequestURI, writer.status, writer.bytes, duration, tracing.RequestIDFromContext(r.Context()))
+				return
+			}
+
+			record, err := json.Marshal(requestLog{
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     writer.status,
+				DurationMS: milliseconds(duration),
+				Bytes:      writer.bytes,
+				RequestID:  tracing.RequestIDFromContext(r.Context()),
+			})
+			if err != nil {
+				logger.Printf("Failed to encode request log: %v", err)
+				return
+			}
+			logger.Print(string(record))
+		})
+	}
+}
+
+// routeTemplate returns the path template of the matched route, e.g. /user/{userId}/balance, or the
+// request path when no route matched
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// MetricsMiddleware records the request count, in-flight requests and latency of every route.
+// Requests are labeled by route template rather than path, so user IDs do not explode the series.
+func MetricsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.RequestsInFlight.Inc()
+		defer metrics.RequestsInFlight.Dec()
+
 		start := time.Now()
+		writer := &loggingWriter{ResponseWriter: w}
+		next.ServeHTTP(writer, r)
 
-		next.ServeHTTP(w, r)
+		// Handlers that never write get an implicit 200
+		if writer.status == 0 {
+			writer.status = http.StatusOK
+		}
+		labels := []string{r.Method, routeTemplate(r), strconv.Itoa(writer.status)}
+		metrics.RequestsTotal.WithLabelValues(labels...).Inc()
+		metrics.RequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+	})
+}
+
+// Tracing starts a server span per request, continuing any incoming trace context
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeTemplate(r)
+		ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("http.route", route),
+			),
+		)
+		defer span.End()
 
-		// Log the request details
-		log.Printf("%s %s %s", r.Method, r.RequestURI, time.Since(start))
+		if userID, ok := mux.Vars(r)["userId"]; ok {
+			span.SetAttributes(attribute.String("user.id", userID))
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
-// Create panic handler
+// RequestIDHeader carries the ID that correlates a request with its log records
+const RequestIDHeader = helpers.RequestIDHeader
+
+// maxRequestIDLength bounds client-supplied request IDs copied into logs
+const maxRequestIDLength = 128
+
+// validRequestID accepts IDs of letters, digits, '-', '_' and '.' so they are safe to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID reuses a valid incoming X-Request-ID or generates one, echoes it in the response
+// and attaches it to the request context
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(tracing.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// headerTrackingWriter records whether the response header was already sent
+type headerTrackingWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *headerTrackingWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerTrackingWriter) Write(body []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(body)
+}
+
+func (w *headerTrackingWriter) Flush() {
+	w.wroteHeader = true
+	flush(w.ResponseWriter)
+}
+
+// newPanicReference returns a short random code that users can quote to support
+func newPanicReference() string {
+	reference := make([]byte, 6)
+	if _, err := rand.Read(reference); err != nil {
+		return "unavailable"
+	}
+	return hex.EncodeToString(reference)
+}
+
+// PanicHandler recovers from panics in the handlers, logs them with a stack trace and a reference code
+// and answers 500 with only the reference. When the handler had already started the response, the
+// status can no longer change and the partial response is left as is.
 func PanicHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &headerTrackingWriter{ResponseWriter: w}
 		defer func() {
-			error := recover()
-			if error != nil {
-				log.Println(error)
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// http.ErrAbortHandler is how a handler deliberately drops the connection, so let the server do that
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			reference := newPanicReference()
+			log.Printf("Panic serving %s %s (reference %s): %v\n%s", r.Method, r.URL.Path, reference, recovered, debug.Stack())
 
-				resp := helpers.ErrorResponse{Error: "Internal server error"}
-				json.NewEncoder(w).Encode(resp)
+			if writer.wroteHeader {
+				return
 			}
+			helpers.RespondJSON(w, http.StatusInternalServerError, helpers.ErrorResponse{
+				Error:     "Internal server error",
+				RequestID: w.Header().Get(RequestIDHeader),
+				Reference: reference,
+			})
 		}()
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(writer, r)
 	})
 }
 
-// SourceHeaderMatcher checks if the "Source" header is present in the request
+// SourceHeaderMatcher requires a single valid Source-Type header and normalizes it for the handlers
 func SourceHeaderMatcher(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		sourceHeader := r.Header.Get("Source-Type")
-		if !helpers.IsValidSource(sourceHeader) {
-			http.Error(w, "Source type is invalid", http.StatusForbidden)
+		// Reject ambiguous requests carrying more than one Source-Type value
+		values := r.Header.Values("Source-Type")
+		if len(values) > 1 || strings.Contains(r.Header.Get("Source-Type"), ",") {
+			helpers.RespondError(w, http.StatusBadRequest, "Multiple source types are not allowed")
+			return
+		}
+
+		source, err := helpers.ResolveSource(r.Header.Get("Source-Type"), "")
+		if err != nil {
+			helpers.HandleAPIError(w, err)
+			return
+		}
+
+		// Pass the normalized value on to the handlers
+		r.Header.Set("Source-Type", source)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// respondBodyReadError answers a request whose body could not be read, with 413 when it exceeded
+// the limit set by JSONLimitsGuard
+func respondBodyReadError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		helpers.RespondError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", tooLarge.Limit))
+		return
+	}
+	helpers.RespondError(w, http.StatusBadRequest, "Unable to read request body")
+}
+
+// JSONLimitsGuard rejects request bodies exceeding the configured byte size with 413, and those
+// exceeding the JSON depth or array length with 400. Later reads of the body, such as by the
+// idempotency store, stay bound by the byte size too.
+func JSONLimitsGuard(limits helpers.JSONLimits) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limits.MaxBodyBytes))
+			r.Body.Close()
+			if err != nil {
+				respondBodyReadError(w, err)
+				return
+			}
+
+			if err := helpers.CheckJSONLimits(body, limits); err != nil {
+				helpers.RespondError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			// Restore the body for the handler's own decoding
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireJSON rejects request bodies whose Content-Type is not one of the allowed JSON media types
+// with 415 Unsupported Media Type. Requests without a body pass through.
+func RequireJSON(allowed []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !helpers.IsJSONContentType(r.Header.Get("Content-Type"), allowed) {
+				helpers.RespondError(w, http.StatusUnsupportedMediaType, "Content-Type must be one of: "+strings.Join(allowed, ", "))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// hstsHeaderValue asks browsers to use HTTPS for a year, including subdomains
+const hstsHeaderValue = "max-age=31536000; includeSubDomains"
+
+// StrictTransportSecurity sets HSTS on responses served over HTTPS, including HTTPS
+// terminated at a trusted proxy
+func StrictTransportSecurity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if helpers.RequestScheme(r) == "https" {
+			w.Header().Set("Strict-Transport-Security", hstsHeaderValue)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serverTimingWriter adds the Server-Timing header just before the response headers are sent
+type serverTimingWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	timer       *tracing.DBTimer
+	wroteHeader bool
+}
+
+func (w *serverTimingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.Header().Set("Server-Timing", serverTimingValue(time.Since(w.start), w.timer))
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *serverTimingWriter) Write(body []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(body)
+}
+
+// Flush sends the header first, so the timing covers the handler up to the first flush
+func (w *serverTimingWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	flush(w.ResponseWriter)
+}
+
+// serverTimingValue formats the total handler time and, when queries ran, the time spent in them
+func serverTimingValue(total time.Duration, timer *tracing.DBTimer) string {
+	value := fmt.Sprintf("total;dur=%.3f", milliseconds(total))
+	if dbTime, queries := timer.Total(); queries > 0 {
+		value = fmt.Sprintf("db;dur=%.3f;desc=\"queries: %d\", %s", milliseconds(dbTime), queries, value)
+	}
+	return value
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ServerTiming reports server-side latency in a Server-Timing header, split into database and total time
+func ServerTiming(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, timer := tracing.WithDBTimer(r.Context())
+		timingWriter := &serverTimingWriter{ResponseWriter: w, start: time.Now(), timer: timer}
+
+		next.ServeHTTP(timingWriter, r.WithContext(ctx))
+
+		// Handlers that never write still get a response with the header
+		if !timingWriter.wroteHeader {
+			timingWriter.WriteHeader(http.StatusOK)
+		}
+	})
+}
+
+// APIKeyLookup resolves the hash of an API key to its principal, returning helpers.ErrInvalidAPIKey
+// for unknown keys
+type APIKeyLookup func(ctx context.Context, keyHash string) (models.APIPrincipal, error)
+
+type principalContextKey struct{}
+
+// PrincipalFromContext returns the principal authenticated by APIKeyAuth, if any
+func PrincipalFromContext(ctx context.Context) (models.APIPrincipal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(models.APIPrincipal)
+	return principal, ok
+}
+
+// APIKeyAuth authenticates the X-API-Key header and requires the key to hold scope. When the request
+// carries a Source-Type, the key must also be allowed to use that source.
+func APIKeyAuth(lookup APIKeyLookup, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := strings.TrimSpace(r.Header.Get("X-API-Key"))
+			if key == "" {
+				helpers.HandleAPIError(w, helpers.ErrInvalidAPIKey)
+				return
+			}
+
+			principal, err := lookup(r.Context(), helpers.HashAPIKey(key))
+			if errors.Is(err, helpers.ErrInvalidAPIKey) {
+				helpers.HandleAPIError(w, helpers.ErrInvalidAPIKey)
+				return
+			}
+			if err != nil {
+				log.Printf("API key lookup failed: %v", err)
+				helpers.RespondError(w, http.StatusInternalServerError, "Internal server error")
+				return
+			}
+
+			source := strings.ToLower(strings.TrimSpace(r.Header.Get("Source-Type")))
+			if err := helpers.CheckAPIKeyScope(principal, scope, source); err != nil {
+				helpers.HandleAPIError(w, err)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), principalContextKey{}, principal)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// AuthMiddleware authenticates "Authorization: Bearer <jwt>" with the HS256 secret and puts the user
+// ID of the token subject on the request context. On routes with a {userId}, the caller may only act
+// on their own user. Without a secret no token can be verified, so every request is rejected.
+func AuthMiddleware(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || secret == "" {
+				helpers.HandleAPIError(w, helpers.ErrInvalidToken)
+				return
+			}
+
+			userID, err := helpers.ParseAccessToken(strings.TrimSpace(token), secret)
+			if err != nil {
+				helpers.HandleAPIError(w, err)
+				return
+			}
+			ctx := helpers.WithUserID(r.Context(), userID)
+
+			if pathUserID, ok := mux.Vars(r)["userId"]; ok {
+				// Malformed IDs are left to the handler to report
+				if id, err := helpers.ValidateID(pathUserID); err == nil {
+					if err := helpers.AuthorizeUser(ctx, id); err != nil {
+						helpers.HandleAPIError(w, err)
+						return
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// UserOrAPIKeyAuth accepts either a user's bearer JWT, checked as by AuthMiddleware, or a service
+// client's X-API-Key holding scope, checked as by APIKeyAuth. Requests carrying an X-API-Key take
+// the API key path. An empty secret or a nil lookup turns that method off; with both off, every
+// request is rejected with 401.
+func UserOrAPIKeyAuth(secret string, lookup APIKeyLookup, scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		var userAuth, keyAuth http.Handler
+		if secret != "" {
+			userAuth = AuthMiddleware(secret)(next)
+		}
+		if lookup != nil {
+			keyAuth = APIKeyAuth(lookup, scope)(next)
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case keyAuth != nil && (userAuth == nil || r.Header.Get("X-API-Key") != ""):
+				keyAuth.ServeHTTP(w, r)
+			case userAuth != nil:
+				userAuth.ServeHTTP(w, r)
+			default:
+				helpers.HandleAPIError(w, helpers.ErrInvalidToken)
+			}
+		})
+	}
+}
+
+// RequireAPIKey only lets through requests with a principal authenticated by APIKeyAuth. It guards
+// routes returning data across users, which only service clients with a scoped API key may call:
+// users authenticated by a bearer token get 403, and requests without any credential 401.
+func RequireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := PrincipalFromContext(r.Context()); ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if _, ok := helpers.UserIDFromContext(r.Context()); ok {
+			helpers.HandleAPIError(w, helpers.ErrAPIKeyRequired)
+			return
+		}
+		helpers.HandleAPIError(w, helpers.ErrInvalidAPIKey)
+	})
+}
+
+// redactingWriter holds back the response so fields can be removed before it is sent
+type redactingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *redactingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *redactingWriter) Write(body []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(body)
+}
+
+// redactFields removes the fields from a JSON object, from each object of a JSON array, and from each
+// object of the arrays in an object, as in paginated lists like {"users": [...], "total": 2}
+func redactFields(body []byte, fields []string) ([]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return nil, err
+	}
+
+	redact := func(item interface{}) {
+		if object, ok := item.(map[string]interface{}); ok {
+			for _, field := range fields {
+				delete(object, field)
+			}
+		}
+	}
+	redactEach := func(item interface{}) bool {
+		items, ok := item.([]interface{})
+		for _, item := range items {
+			redact(item)
+		}
+		return ok
+	}
+	if !redactEach(value) {
+		redact(value)
+		if object, ok := value.(map[string]interface{}); ok {
+			for _, field := range object {
+				redactEach(field)
+			}
+		}
+	}
+
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append(redacted, '\n'), nil
+}
+
+// RedactPII omits the fields from successful JSON responses unless the caller is authorized to see
+// them: an API key principal holding privilegedScope, or a user authenticated by a bearer token, who
+// may only read their own profile. Everyone else, including requests without any principal, gets
+// the fields omitted.
+func RedactPII(privilegedScope string, fields []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if canReadPII(r.Context(), privilegedScope) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writer := &redactingWriter{ResponseWriter: w}
+			next.ServeHTTP(writer, r)
+			if writer.status == 0 {
+				writer.status = http.StatusOK
+			}
+
+			body := writer.body.Bytes()
+			if writer.status >= 200 && writer.status < 300 && helpers.IsJSONContentType(w.Header().Get("Content-Type"), []string{"application/json"}) {
+				redacted, err := redactFields(body, fields)
+				if err != nil {
+					// Never fall back to the unredacted body
+					log.Printf("Failed to redact response: %v", err)
+					helpers.RespondError(w, http.StatusInternalServerError, "Internal server error")
+					return
+				}
+				body = redacted
+			}
+
+			w.Header().Del("Content-Length")
+			w.WriteHeader(writer.status)
+			w.Write(body)
+		})
+	}
+}
+
+// canReadPII reports whether the request was authenticated by a principal allowed to see PII
+func canReadPII(ctx context.Context, privilegedScope string) bool {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return slices.Contains(principal.Scopes, privilegedScope)
+	}
+	_, ok := helpers.UserIDFromContext(ctx)
+	return ok
+}
+
+// tokenBucket allows bursts up to its capacity and refills at a fixed rate
+type tokenBucket struct {
+	tokens     float64
+	capacity   float64
+	perSecond  float64
+	lastRefill time.Time
+}
+
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.lastRefill).Seconds()*b.perSecond)
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / b.perSecond * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// SourceRateLimiter limits requests per Source-Type, so one busy integration cannot
+// starve the others. Each source has its own bucket of limit requests per minute.
+type SourceRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// NewSourceRateLimiter creates a limiter from requests-per-minute limits; sources without a limit are not limited
+func NewSourceRateLimiter(limits map[string]int) *SourceRateLimiter {
+	return newSourceRateLimiter(limits, time.Now)
+}
+
+func newSourceRateLimiter(limits map[string]int, now func() time.Time) *SourceRateLimiter {
+	buckets := map[string]*tokenBucket{}
+	for source, limit := range limits {
+		buckets[source] = &tokenBucket{
+			tokens:     float64(limit),
+			capacity:   float64(limit),
+			perSecond:  float64(limit) / 60,
+			lastRefill: now(),
+		}
+	}
+	return &SourceRateLimiter{buckets: buckets, now: now}
+}
+
+func (l *SourceRateLimiter) allow(source string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[source]
+	if !ok {
+		return true, 0
+	}
+	return bucket.take(l.now())
+}
+
+// Middleware rejects requests over their source's limit with 429. It expects the
+// Source-Type header to have been normalized by SourceHeaderMatcher.
+func (l *SourceRateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := l.allow(r.Header.Get("Source-Type"))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			helpers.RespondError(w, http.StatusTooManyRequests, "Rate limit exceeded for this source type")
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// maxTrackedClients bounds the per-IP buckets kept in memory
+const maxTrackedClients = 10000
+
+// IPRateLimiter limits requests per client IP, so a single abusive client cannot hammer an
+// endpoint. Each IP gets a bucket of burst requests refilled at perSecond.
+type IPRateLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	buckets   map[string]*tokenBucket
+	now       func() time.Time
+}
+
+// NewIPRateLimiter creates a limiter allowing perSecond requests per IP with bursts up to burst;
+// a rate of zero or less disables it
+func NewIPRateLimiter(perSecond, burst int) *IPRateLimiter {
+	return newIPRateLimiter(perSecond, burst, time.Now)
+}
+
+func newIPRateLimiter(perSecond, burst int, now func() time.Time) *IPRateLimiter {
+	return &IPRateLimiter{
+		perSecond: float64(perSecond),
+		burst:     float64(burst),
+		buckets:   map[string]*tokenBucket{},
+		now:       now,
+	}
+}
+
+func (l *IPRateLimiter) allow(ip string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	bucket, ok := l.buckets[ip]
+	if !ok {
+		if len(l.buckets) >= maxTrackedClients {
+			l.evictRefilled(now)
+		}
+		bucket = &tokenBucket{tokens: l.burst, capacity: l.burst, perSecond: l.perSecond, lastRefill: now}
+		l.buckets[ip] = bucket
+	}
+	return bucket.take(now)
+}
+
+// evictRefilled drops buckets idle long enough to be full again; a fresh bucket behaves the same
+func (l *IPRateLimiter) evictRefilled(now time.Time) {
+	refill := time.Duration(l.burst / l.perSecond * float64(time.Second))
+	for ip, bucket := range l.buckets {
+		if now.Sub(bucket.lastRefill) >= refill {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// Middleware rejects requests over their client IP's limit with 429
+func (l *IPRateLimiter) Middleware(next http.Handler) http.Handler {
+	if l.perSecond <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := l.allow(helpers.ClientIP(r))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			helpers.RespondError(w, http.StatusTooManyRequests, "Rate limit exceeded, please retry later")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminToken requires "Authorization: Bearer <token>" with one of the admin tokens, keyed by the name
+// of the admin holding it, and puts that name on the request context so audit records identify the
+// admin. It is a stopgap until role-based auth exists: without configured tokens the wrapped routes
+// answer 404, so they are never served unprotected.
+func AdminToken(tokens map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(tokens) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+
+			presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			admin := ""
+			// Every token is compared, so the response time does not reveal which one matched
+			for name, token := range tokens {
+				if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+					admin = name
+				}
+			}
+			if !ok || admin == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				helpers.RespondError(w, http.StatusUnauthorized, "Invalid or missing admin token")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(helpers.WithAdmin(r.Context(), admin)))
+		})
+	}
+}
+
+// concurrencyRetryAfterSeconds is sent in Retry-After when a concurrency limit is saturated
+const concurrencyRetryAfterSeconds = 1
+
+// ConcurrencyLimit bounds how many requests run the wrapped handler at once. Requests beyond the
+// limit are not queued but rejected with 503 and Retry-After, so a stampede on an expensive
+// endpoint cannot pile up database connections or memory. A limit of zero or less disables it.
+func ConcurrencyLimit(n int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if n <= 0 {
+			return next
+		}
+
+		slots := make(chan struct{}, n)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+				next.ServeHTTP(w, r)
+			default:
+				w.Header().Set("Retry-After", strconv.Itoa(concurrencyRetryAfterSeconds))
+				helpers.RespondError(w, http.StatusServiceUnavailable, "Too many concurrent requests for this endpoint, please retry")
+			}
+		})
+	}
+}
+
+// gzipResponseWriter compresses the body once the status allows one
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	pool        *sync.Pool
+	writer      *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status != http.StatusNoContent && status != http.StatusNotModified {
+			w.Header().Del("Content-Length")
+			w.Header().Set("Content-Encoding", "gzip")
+			w.writer = w.pool.Get().(*gzip.Writer)
+			w.writer.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(body []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.writer == nil {
+		return w.ResponseWriter.Write(body)
+	}
+	return w.writer.Write(body)
+}
+
+// Flush sends the data compressed so far, so streamed responses reach the client as they are written
+func (w *gzipResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.writer != nil {
+		w.writer.Flush()
+	}
+	flush(w.ResponseWriter)
+}
+
+// flush flushes the writer when it supports it
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close flushes the compressed body and returns the writer to the pool
+func (w *gzipResponseWriter) close() {
+	if w.writer == nil {
+		return
+	}
+	w.writer.Close()
+	w.writer.Reset(io.Discard)
+	w.pool.Put(w.writer)
+}
+
+// Gzip compresses responses for clients accepting gzip at the given level (1-9). Writers are
+// pooled per middleware, so requests do not allocate a new compressor each time.
+func Gzip(level int) func(http.Handler) http.Handler {
+	pool := &sync.Pool{
+		New: func() interface{} {
+			// The level is validated by helpers.GzipLevel, so this cannot fail
+			writer, _ := gzip.NewWriterLevel(io.Discard, level)
+			return writer
+		},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gzipWriter := &gzipResponseWriter{ResponseWriter: w, pool: pool}
+			defer gzipWriter.close()
+			next.ServeHTTP(gzipWriter, r)
+		})
+	}
+}
+
+// idempotentResponse is the stored outcome of a request carrying an Idempotency-Key. Until the
+// first request completes, done is false and repeats are rejected as in progress.
+type idempotentResponse struct {
+	bodyHash    [sha256.Size]byte
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyRecorder captures the response of the first request for a key while passing it through
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *idempotencyRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *idempotencyRecorder) Write(body []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(body)
+	return w.ResponseWriter.Write(body)
+}
+
+// IdempotencyStore replays the response of a POST for a repeated Idempotency-Key. Records are
+// namespaced by method, path and the authenticated user or API key, so a key reused for POST /user
+// and POST /user/{userId}/transaction, or by two callers of POST /transfer, runs every request. The
+// middleware must therefore run after authentication. Server errors are not stored, so the client
+// can retry them.
+type IdempotencyStore struct {
+	mu           sync.Mutex
+	ttl          time.Duration
+	maxBodyBytes int64
+	now          func() time.Time
+	responses    map[string]*idempotentResponse
+}
+
+// NewIdempotencyStore creates an in-memory store keeping responses for ttl; zero or less disables it.
+// Request bodies larger than maxBodyBytes are rejected with 413 before they are hashed.
+func NewIdempotencyStore(ttl time.Duration, maxBodyBytes int64) *IdempotencyStore {
+	return newIdempotencyStore(ttl, maxBodyBytes, time.Now)
+}
+
+func newIdempotencyStore(ttl time.Duration, maxBodyBytes int64, now func() time.Time) *IdempotencyStore {
+	return &IdempotencyStore{ttl: ttl, maxBodyBytes: maxBodyBytes, now: now, responses: map[string]*idempotentResponse{}}
+}
+
+// idempotencyRecordKey namespaces a client key by the operation it was sent to and the caller
+func idempotencyRecordKey(r *http.Request, key string) string {
+	caller := "anonymous"
+	if userID, ok := helpers.UserIDFromContext(r.Context()); ok {
+		caller = "user:" + strconv.FormatInt(userID, 10)
+	} else if principal, ok := PrincipalFromContext(r.Context()); ok {
+		caller = "api-key:" + strconv.FormatInt(principal.KeyID, 10)
+	}
+	return r.Method + " " + r.URL.Path + " " + caller + " " + key
+}
+
+// reserve returns the stored response for recordKey, or nil after reserving it for the caller
+func (s *IdempotencyStore) reserve(recordKey string, bodyHash [sha256.Size]byte) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if response, ok := s.responses[recordKey]; ok && now.Before(response.expires) {
+		return response, true
+	}
+
+	if len(s.responses) >= helpers.IdempotencyKeyMaxEntries {
+		for key, response := range s.responses {
+			if response.done && !now.Before(response.expires) {
+				delete(s.responses, key)
+			}
+		}
+		if len(s.responses) >= helpers.IdempotencyKeyMaxEntries {
+			return nil, false
+		}
+	}
+
+	s.responses[recordKey] = &idempotentResponse{bodyHash: bodyHash, expires: now.Add(s.ttl)}
+	return nil, true
+}
+
+// complete stores the response for recordKey, or releases the key when it should not be replayed
+func (s *IdempotencyStore) complete(recordKey string, recorder *idempotencyRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+		delete(s.responses, recordKey)
+		return
+	}
+
+	response := s.responses[recordKey]
+	response.done = true
+	response.status = recorder.status
+	response.contentType = recorder.Header().Get("Content-Type")
+	response.body = recorder.body.Bytes()
+	response.expires = s.now().Add(s.ttl)
+}
+
+// Middleware applies the store to POST requests carrying an Idempotency-Key header. A repeat with a
+// different body answers 422, and a repeat while the first request is still running answers 409.
+func (s *IdempotencyStore) Middleware(next http.Handler) http.Handler {
+	if s.ttl <= 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(helpers.IdempotencyKeyHeader)
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.maxBodyBytes))
+		if err != nil {
+			respondBodyReadError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		bodyHash := sha256.Sum256(body)
+
+		recordKey := idempotencyRecordKey(r, key)
+		stored, ok := s.reserve(recordKey, bodyHash)
+		if !ok {
+			helpers.RespondError(w, http.StatusServiceUnavailable, "Too many pending idempotency keys, please retry")
+			return
+		}
+		if stored != nil {
+			switch {
+			case stored.bodyHash != bodyHash:
+				helpers.RespondError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request body")
+			case !stored.done:
+				helpers.RespondError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+			default:
+				w.Header().Set("Content-Type", stored.contentType)
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.status)
+				w.Write(stored.body)
+			}
+			return
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: w}
+		defer s.complete(recordKey, recorder)
+		next.ServeHTTP(recorder, r)
+	})
+}
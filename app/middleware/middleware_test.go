@@ -0,0 +1,1465 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rathorevk/GoBanking/app/helpers"
+	"github.com/rathorevk/GoBanking/app/metrics"
+	"github.com/rathorevk/GoBanking/app/models"
+	"github.com/rathorevk/GoBanking/app/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestSourceHeaderMatcher(t *testing.T) {
+	tests := []struct {
+		name           string
+		headers        []string
+		expectedStatus int
+		expectedSource string
+	}{
+		{
+			name:           "Valid source",
+			headers:        []string{"game"},
+			expectedStatus: http.StatusOK,
+			expectedSource: "game",
+		},
+		{
+			name:           "Mixed case source",
+			headers:        []string{"Game"},
+			expectedStatus: http.StatusOK,
+			expectedSource: "game",
+		},
+		{
+			name:           "Source with surrounding whitespace",
+			headers:        []string{" payment "},
+			expectedStatus: http.StatusOK,
+			expectedSource: "payment",
+		},
+		{
+			name:           "Missing source",
+			headers:        nil,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid source",
+			headers:        []string{"casino"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Multiple source headers",
+			headers:        []string{"game", "server"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Comma separated sources",
+			headers:        []string{"game, server"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var receivedSource string
+			handler := SourceHeaderMatcher(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				receivedSource = r.Header.Get("Source-Type")
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req, err := http.NewRequest("POST", "/user/1/transaction", nil)
+			assert.NoError(t, err)
+			for _, value := range tt.headers {
+				req.Header.Add("Source-Type", value)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.expectedSource, receivedSource)
+		})
+	}
+}
+
+func TestSourceHeaderMatcherConfiguredSources(t *testing.T) {
+	t.Setenv("VALID_SOURCES", "game, ATM,,")
+	helpers.LoadValidSources()
+	t.Cleanup(func() { helpers.SetValidSources(helpers.DefaultTransactionSources) })
+	assert.Equal(t, []string{"atm", "game"}, helpers.ValidSources())
+
+	handler := SourceHeaderMatcher(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func(source string) int {
+		req := httptest.NewRequest("POST", "/user/1/transaction", nil)
+		req.Header.Set("Source-Type", source)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// Requests keep reading the set while it is reloaded
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				send("game")
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		helpers.LoadValidSources()
+	}
+	wg.Wait()
+
+	assert.Equal(t, http.StatusOK, send("atm"))
+	assert.Equal(t, http.StatusOK, send("game"))
+	assert.Equal(t, http.StatusBadRequest, send("payment"))
+
+	// Unset falls back to the defaults
+	t.Setenv("VALID_SOURCES", "")
+	helpers.LoadValidSources()
+	assert.Equal(t, []string{"game", "payment", "server"}, helpers.ValidSources())
+	assert.Equal(t, http.StatusOK, send("payment"))
+}
+
+func TestJSONLimitsGuard(t *testing.T) {
+	limits := helpers.JSONLimits{MaxDepth: 4, MaxArrayLength: 3, MaxBodyBytes: 32 << 10}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectCalled   bool
+	}{
+		{
+			name:           "Body within limits",
+			body:           `{"entries":[{"user_id":1},{"user_id":2}]}`,
+			expectedStatus: http.StatusOK,
+			expectCalled:   true,
+		},
+		{
+			name:           "Deeply nested body",
+			body:           strings.Repeat("[", 10000) + strings.Repeat("]", 10000),
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Huge array",
+			body:           `{"entries":[1,2,3,4,5,6,7,8,9,10]}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid JSON is left to the handler",
+			body:           `invalid-json`,
+			expectedStatus: http.StatusOK,
+			expectCalled:   true,
+		},
+		{
+			name:           "Body over the size limit",
+			body:           `{"memo":"` + strings.Repeat("a", 32<<10) + `"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			handler := JSONLimitsGuard(limits)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+				assert.Equal(t, tt.body, string(body))
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req, err := http.NewRequest("POST", "/batch", strings.NewReader(tt.body))
+			assert.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.expectCalled, called)
+		})
+	}
+}
+
+func TestJSONLimitsFromEnv(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		expectedLimits helpers.JSONLimits
+		expectedErr    string
+	}{
+		{
+			name:           "Defaults",
+			expectedLimits: helpers.JSONLimits{MaxDepth: helpers.DefaultJSONMaxDepth, MaxArrayLength: helpers.DefaultJSONMaxArrayLength, MaxBodyBytes: helpers.DefaultJSONMaxBodyBytes},
+		},
+		{
+			name:           "Configured",
+			env:            map[string]string{"JSON_MAX_DEPTH": "8", "JSON_MAX_ARRAY_LENGTH": "50", "JSON_MAX_BODY_BYTES": "4096"},
+			expectedLimits: helpers.JSONLimits{MaxDepth: 8, MaxArrayLength: 50, MaxBodyBytes: 4096},
+		},
+		{name: "Zero depth", env: map[string]string{"JSON_MAX_DEPTH": "0"}, expectedErr: "JSON_MAX_DEPTH"},
+		{name: "Negative array length", env: map[string]string{"JSON_MAX_ARRAY_LENGTH": "-1"}, expectedErr: "JSON_MAX_ARRAY_LENGTH"},
+		{name: "Body size that is not a number", env: map[string]string{"JSON_MAX_BODY_BYTES": "1MB"}, expectedErr: "JSON_MAX_BODY_BYTES"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"JSON_MAX_DEPTH", "JSON_MAX_ARRAY_LENGTH", "JSON_MAX_BODY_BYTES"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			limits, err := helpers.JSONLimitsFromEnv()
+			if tt.expectedErr != "" {
+				assert.ErrorContains(t, err, tt.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedLimits, limits)
+		})
+	}
+}
+
+func TestRequireJSON(t *testing.T) {
+	allowed := []string{"application/json", "application/vnd.gobanking.v1+json"}
+
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Plain JSON", contentType: "application/json", body: `{}`, expectedStatus: http.StatusOK},
+		{name: "JSON with charset", contentType: "application/json; charset=utf-8", body: `{}`, expectedStatus: http.StatusOK},
+		{name: "Charset is case-insensitive", contentType: "Application/JSON; Charset=UTF-8", body: `{}`, expectedStatus: http.StatusOK},
+		{name: "Vendored type", contentType: "application/vnd.gobanking.v1+json", body: `{}`, expectedStatus: http.StatusOK},
+		{name: "Vendored type with charset", contentType: "application/vnd.gobanking.v1+json; charset=utf-8", body: `{}`, expectedStatus: http.StatusOK},
+		{name: "Unlisted vendored type", contentType: "application/vnd.other.v1+json", body: `{}`, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Non UTF-8 charset", contentType: "application/json; charset=iso-8859-1", body: `{}`, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Prefix is not enough", contentType: "application/jsonp", body: `{}`, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Form body", contentType: "application/x-www-form-urlencoded", body: `a=1`, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Missing Content-Type", contentType: "", body: `{}`, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "Malformed Content-Type", contentType: "application/json; charset", body: `{}`, expectedStatus: http.StatusUnsupportedMediaType},
+		{name: "No body", contentType: "", body: "", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequireJSON(allowed)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			var req *http.Request
+			if tt.body == "" {
+				req = httptest.NewRequest("POST", "/user/1/close-all", nil)
+			} else {
+				req = httptest.NewRequest("POST", "/user/1/transaction", strings.NewReader(tt.body))
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	router := mux.NewRouter()
+	router.Use(Tracing)
+	router.HandleFunc("/user/{userId}/balance", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}).Methods("GET")
+
+	req, err := http.NewRequest("GET", "/user/42/balance", nil)
+	assert.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+
+	span := spans[0]
+	assert.Equal(t, "GET /user/{userId}/balance", span.Name())
+	assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+	assert.Contains(t, span.Attributes(), attribute.String("http.route", "/user/{userId}/balance"))
+	assert.Contains(t, span.Attributes(), attribute.String("user.id", "42"))
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name       string
+		incoming   string
+		expectKeep bool
+	}{
+		{name: "Incoming ID is reused", incoming: "req-42.a_b", expectKeep: true},
+		{name: "Missing ID is generated", incoming: "", expectKeep: false},
+		{name: "Unsafe ID is replaced", incoming: "bad id\nforged", expectKeep: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = tracing.RequestIDFromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest("GET", "/user/1/balance", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.NotEmpty(t, seen)
+			assert.Equal(t, seen, recorder.Header().Get(RequestIDHeader))
+			assert.Equal(t, tt.expectKeep, seen == tt.incoming)
+		})
+	}
+}
+
+func TestRequestIDInErrorResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		expected string
+	}{
+		{
+			name:     "Error response",
+			handler:  func(w http.ResponseWriter, r *http.Request) { helpers.HandleAPIError(w, helpers.ErrInvalidID) },
+			expected: `{"error":"Invalid ID format, expected a positive integer","request_id":"req-42"}`,
+		},
+		{
+			name: "Validation error response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				helpers.RespondValidationError(w, map[string]string{"amount": "required"})
+			},
+			expected: `{"errors":{"amount":"required"},"request_id":"req-42"}`,
+		},
+		{
+			name:     "Account status error response",
+			handler:  func(w http.ResponseWriter, r *http.Request) { helpers.HandleAPIError(w, helpers.ErrAccountFrozen) },
+			expected: `{"error":"Account is frozen","account_status":"frozen","request_id":"req-42"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/user/1/balance", nil)
+			req.Header.Set(RequestIDHeader, "req-42")
+			recorder := httptest.NewRecorder()
+			RequestID(tt.handler).ServeHTTP(recorder, req)
+
+			assert.JSONEq(t, tt.expected, recorder.Body.String())
+		})
+	}
+
+	// Without the middleware there is no ID to report
+	recorder := httptest.NewRecorder()
+	helpers.RespondError(recorder, http.StatusBadRequest, "Invalid ID format")
+	assert.JSONEq(t, `{"error":"Invalid ID format"}`, recorder.Body.String())
+}
+
+func TestStrictTransportSecurity(t *testing.T) {
+	tests := []struct {
+		name         string
+		trustProxy   string
+		forwardProto string
+		expectHSTS   bool
+	}{
+		{name: "Plain HTTP", expectHSTS: false},
+		{name: "Untrusted forwarded HTTPS", forwardProto: "https", expectHSTS: false},
+		{name: "Trusted forwarded HTTPS", trustProxy: "true", forwardProto: "https", expectHSTS: true},
+		{name: "Trusted forwarded HTTP", trustProxy: "true", forwardProto: "http", expectHSTS: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TRUST_PROXY_HEADERS", tt.trustProxy)
+
+			handler := StrictTransportSecurity(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/user/1/balance", nil)
+			if tt.forwardProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardProto)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectHSTS, recorder.Header().Get("Strict-Transport-Security") != "")
+		})
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	tokens := map[string]string{"alice": "s3cret", "ops": "t0ken"}
+
+	tests := []struct {
+		name           string
+		tokens         map[string]string
+		authorization  string
+		expectedStatus int
+		expectedAdmin  string
+	}{
+		{name: "Authorized", tokens: tokens, authorization: "Bearer s3cret", expectedStatus: http.StatusOK, expectedAdmin: "alice"},
+		{name: "Another admin's token", tokens: tokens, authorization: "Bearer t0ken", expectedStatus: http.StatusOK, expectedAdmin: "ops"},
+		{name: "Missing token", tokens: tokens, expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong token", tokens: tokens, authorization: "Bearer guess", expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong scheme", tokens: tokens, authorization: "Basic s3cret", expectedStatus: http.StatusUnauthorized},
+		{name: "Disabled without a configured token", tokens: map[string]string{}, authorization: "Bearer ", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called := false
+			admin := ""
+			handler := AdminToken(tt.tokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				admin, _ = helpers.AdminFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/admin/audit", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.expectedStatus == http.StatusOK, called)
+			assert.Equal(t, tt.expectedAdmin, admin)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", recorder.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	keys := map[string]models.APIPrincipal{
+		helpers.HashAPIKey("gbk_game"):     {KeyID: 1, Name: "game-server", Scopes: []string{helpers.ScopeTransactionsWrite}, Sources: []string{"game"}},
+		helpers.HashAPIKey("gbk_readonly"): {KeyID: 2, Name: "reporting"},
+		helpers.HashAPIKey("gbk_any"):      {KeyID: 3, Name: "backoffice", Scopes: []string{helpers.ScopeTransactionsWrite}},
+	}
+	lookup := func(ctx context.Context, keyHash string) (models.APIPrincipal, error) {
+		if keyHash == helpers.HashAPIKey("gbk_broken") {
+			return models.APIPrincipal{}, errors.New("connection refused")
+		}
+		principal, ok := keys[keyHash]
+		if !ok {
+			return models.APIPrincipal{}, helpers.ErrInvalidAPIKey
+		}
+		return principal, nil
+	}
+
+	tests := []struct {
+		name              string
+		key               string
+		source            string
+		expectedStatus    int
+		expectedPrincipal string
+	}{
+		{name: "Valid key", key: "gbk_game", source: "game", expectedStatus: http.StatusOK, expectedPrincipal: "game-server"},
+		{name: "Key without source restriction", key: "gbk_any", source: "payment", expectedStatus: http.StatusOK, expectedPrincipal: "backoffice"},
+		{name: "Missing key", expectedStatus: http.StatusUnauthorized},
+		{name: "Invalid key", key: "gbk_unknown", source: "game", expectedStatus: http.StatusUnauthorized},
+		{name: "Insufficient scope", key: "gbk_readonly", source: "game", expectedStatus: http.StatusForbidden},
+		{name: "Source not allowed for key", key: "gbk_game", source: "payment", expectedStatus: http.StatusForbidden},
+		{name: "Lookup failure", key: "gbk_broken", source: "game", expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var principal models.APIPrincipal
+			handler := APIKeyAuth(lookup, helpers.ScopeTransactionsWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal, _ = PrincipalFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("POST", "/user/1/transaction", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			if tt.source != "" {
+				req.Header.Set("Source-Type", tt.source)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.expectedPrincipal, principal.Name)
+		})
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	const secret = "test-secret"
+	sign := func(method jwt.SigningMethod, key interface{}, subject string, expiresIn time.Duration) string {
+		claims := jwt.RegisteredClaims{Subject: subject}
+		if expiresIn != 0 {
+			claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(expiresIn))
+		}
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		assert.NoError(t, err)
+		return "Bearer " + token
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		authorization  string
+		expectedStatus int
+		expectedUserID int64
+	}{
+		{name: "Own user", url: "/user/7/balance", authorization: sign(jwt.SigningMethodHS256, []byte(secret), "7", time.Hour), expectedStatus: http.StatusOK, expectedUserID: 7},
+		{name: "Route without a user", url: "/accounts", authorization: sign(jwt.SigningMethodHS256, []byte(secret), "7", time.Hour), expectedStatus: http.StatusOK, expectedUserID: 7},
+		{name: "Another user", url: "/user/8/balance", authorization: sign(jwt.SigningMethodHS256, []byte(secret), "7", time.Hour), expectedStatus: http.StatusForbidden},
+		{name: "Missing token", url: "/user/7/balance", expectedStatus: http.StatusUnauthorized},
+		{name: "Not a bearer token", url: "/user/7/balance", authorization: "Basic dXNlcjpwYXNz", expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong secret", url: "/user/7/balance", authorization: sign(jwt.SigningMethodHS256, []byte("other-secret"), "7", time.Hour), expectedStatus: http.StatusUnauthorized},
+		{name: "Expired token", url: "/user/7/balance", authorization: sign(jwt.SigningMethodHS256, []byte(secret), "7", -time.Minute), expectedStatus: http.StatusUnauthorized},
+		{name: "Token without expiry", url: "/user/7/balance", authorization: sign(jwt.SigningMethodHS256, []byte(secret), "7", 0), expectedStatus: http.StatusUnauthorized},
+		{name: "Algorithm other than HS256", url: "/user/7/balance", authorization: sign(jwt.SigningMethodHS512, []byte(secret), "7", time.Hour), expectedStatus: http.StatusUnauthorized},
+		{name: "Subject that is not a user ID", url: "/user/7/balance", authorization: sign(jwt.SigningMethodHS256, []byte(secret), "admin", time.Hour), expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userID int64
+			router := mux.NewRouter()
+			router.Use(AuthMiddleware(secret))
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				userID, _ = helpers.UserIDFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}
+			router.HandleFunc("/user/{userId}/balance", handler)
+			router.HandleFunc("/accounts", handler)
+
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.expectedUserID, userID)
+			if tt.expectedStatus == http.StatusUnauthorized {
+				assert.Equal(t, "Bearer", recorder.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	// Without a secret the routes fail closed, even for a token signed with an empty key
+	router := mux.NewRouter()
+	router.Use(AuthMiddleware(""))
+	router.HandleFunc("/user/{userId}/balance", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	for _, authorization := range []string{"", sign(jwt.SigningMethodHS256, []byte(""), "7", time.Hour)} {
+		req := httptest.NewRequest("GET", "/user/7/balance", nil)
+		req.Header.Set("Authorization", authorization)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func TestUserOrAPIKeyAuth(t *testing.T) {
+	const secret = "test-secret"
+	claims := jwt.RegisteredClaims{Subject: "7", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	assert.NoError(t, err)
+
+	lookup := func(ctx context.Context, keyHash string) (models.APIPrincipal, error) {
+		switch keyHash {
+		case helpers.HashAPIKey("gbk_reader"):
+			return models.APIPrincipal{KeyID: 1, Name: "reporting", Scopes: []string{helpers.ScopeBalancesRead}}, nil
+		case helpers.HashAPIKey("gbk_writer"):
+			return models.APIPrincipal{KeyID: 2, Name: "game-server", Scopes: []string{helpers.ScopeTransactionsWrite}}, nil
+		}
+		return models.APIPrincipal{}, helpers.ErrInvalidAPIKey
+	}
+
+	tests := []struct {
+		name              string
+		secret            string
+		lookup            APIKeyLookup
+		authorization     string
+		key               string
+		expectedStatus    int
+		expectedUserID    int64
+		expectedPrincipal string
+	}{
+		{name: "Bearer token alone", secret: secret, lookup: lookup, authorization: "Bearer " + token, expectedStatus: http.StatusOK, expectedUserID: 7},
+		{name: "API key alone", secret: secret, lookup: lookup, key: "gbk_reader", expectedStatus: http.StatusOK, expectedPrincipal: "reporting"},
+		{name: "API key without the scope", secret: secret, lookup: lookup, key: "gbk_writer", expectedStatus: http.StatusForbidden},
+		{name: "Invalid API key with a valid bearer token", secret: secret, lookup: lookup, authorization: "Bearer " + token, key: "gbk_unknown", expectedStatus: http.StatusUnauthorized},
+		{name: "No credentials", secret: secret, lookup: lookup, expectedStatus: http.StatusUnauthorized},
+		{name: "Bearer token with API keys disabled", secret: secret, authorization: "Bearer " + token, expectedStatus: http.StatusOK, expectedUserID: 7},
+		{name: "API key with JWTs disabled", lookup: lookup, key: "gbk_reader", expectedStatus: http.StatusOK, expectedPrincipal: "reporting"},
+		{name: "No credentials with API keys only", lookup: lookup, expectedStatus: http.StatusUnauthorized},
+		{name: "Both methods disabled", authorization: "Bearer " + token, key: "gbk_reader", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var userID int64
+			var principal models.APIPrincipal
+			router := mux.NewRouter()
+			router.Use(UserOrAPIKeyAuth(tt.secret, tt.lookup, helpers.ScopeBalancesRead))
+			router.HandleFunc("/user/{userId}/balance", func(w http.ResponseWriter, r *http.Request) {
+				userID, _ = helpers.UserIDFromContext(r.Context())
+				principal, _ = PrincipalFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/user/7/balance", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Equal(t, tt.expectedUserID, userID)
+			assert.Equal(t, tt.expectedPrincipal, principal.Name)
+		})
+	}
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		expectedStatus int
+	}{
+		{name: "API key principal", ctx: context.WithValue(context.Background(), principalContextKey{}, models.APIPrincipal{KeyID: 1, Name: "reporting"}), expectedStatus: http.StatusOK},
+		{name: "User bearer token", ctx: helpers.WithUserID(context.Background(), 7), expectedStatus: http.StatusForbidden},
+		{name: "No credentials", ctx: context.Background(), expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := RequireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/users", nil).WithContext(tt.ctx))
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+		})
+	}
+}
+
+func TestServerTiming(t *testing.T) {
+	tests := []struct {
+		name           string
+		dbQueries      int
+		writeBody      bool
+		expectedHeader *regexp.Regexp
+	}{
+		{
+			name:           "Handler with database queries",
+			dbQueries:      2,
+			writeBody:      true,
+			expectedHeader: regexp.MustCompile(`^db;dur=\d+\.\d{3};desc="queries: 2", total;dur=\d+\.\d{3}$`),
+		},
+		{
+			name:           "Handler without database queries",
+			writeBody:      true,
+			expectedHeader: regexp.MustCompile(`^total;dur=\d+\.\d{3}$`),
+		},
+		{
+			name:           "Handler that writes nothing",
+			expectedHeader: regexp.MustCompile(`^total;dur=\d+\.\d{3}$`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ServerTiming(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				timer := tracing.DBTimerFromContext(r.Context())
+				for i := 0; i < tt.dbQueries; i++ {
+					timer.Add(2 * time.Millisecond)
+				}
+				if tt.writeBody {
+					w.Write([]byte(`{"status":"ok"}`))
+				}
+			}))
+
+			req := httptest.NewRequest("GET", "/user/1/balance", nil)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			header := recorder.Header().Get("Server-Timing")
+			assert.Regexp(t, tt.expectedHeader, header)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		})
+	}
+}
+
+func TestFlushPassthrough(t *testing.T) {
+	handlers := map[string]func(http.Handler) http.Handler{
+		"Server timing": ServerTiming,
+		"Logging":       logRequests(helpers.LogFormatJSON, log.New(io.Discard, "", 0)),
+		"Gzip":          Gzip(6),
+	}
+
+	for name, middleware := range handlers {
+		t.Run(name, func(t *testing.T) {
+			handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				flusher, ok := w.(http.Flusher)
+				assert.True(t, ok)
+				flusher.Flush()
+			}))
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/user/1/account/1/export", nil))
+
+			assert.True(t, recorder.Flushed)
+			assert.Equal(t, http.StatusOK, recorder.Code)
+		})
+	}
+
+	// Flushing commits the header, so Server-Timing must already be set by then
+	handler := ServerTiming(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+	}))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/user/1/account/1/export", nil))
+	assert.Regexp(t, `^total;dur=\d+\.\d{3}$`, recorder.Header().Get("Server-Timing"))
+}
+
+func TestServerTimingValue(t *testing.T) {
+	_, timer := tracing.WithDBTimer(context.Background())
+	timer.Add(1500 * time.Microsecond)
+
+	assert.Equal(t, `db;dur=1.500;desc="queries: 1", total;dur=4.250`, serverTimingValue(4250*time.Microsecond, timer))
+}
+
+func TestSourceRateLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	limiter := newSourceRateLimiter(map[string]int{"game": 3, "payment": 1}, func() time.Time { return now })
+
+	handler := SourceHeaderMatcher(limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+
+	send := func(source string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/user/1/transaction", nil)
+		req.Header.Set("Source-Type", source)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// The game burst exhausts only the game limit
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send("game").Code)
+	}
+	rejected := send("Game")
+	assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	assert.Equal(t, "20", rejected.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, send("payment").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("payment").Code)
+
+	// Sources without a configured limit are not limited
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusOK, send("server").Code)
+	}
+
+	// Buckets refill independently: 20 seconds restore one game request but no payment request
+	now = now.Add(20 * time.Second)
+	assert.Equal(t, http.StatusOK, send("game").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("game").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("payment").Code)
+}
+
+func TestIPRateLimiter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	limiter := newIPRateLimiter(2, 3, func() time.Time { return now })
+
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remoteAddr, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/user/1/transaction", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// The burst exhausts only the limit of that IP
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send("203.0.113.7:51000", "").Code)
+	}
+	rejected := send("203.0.113.7:51001", "")
+	assert.Equal(t, http.StatusTooManyRequests, rejected.Code)
+	assert.Equal(t, "1", rejected.Header().Get("Retry-After"))
+	assert.Contains(t, rejected.Body.String(), "Rate limit exceeded")
+	assert.Equal(t, http.StatusOK, send("198.51.100.2:40000", "").Code)
+
+	// Forwarded addresses are ignored unless proxy headers are trusted
+	assert.Equal(t, http.StatusTooManyRequests, send("203.0.113.7:51002", "192.0.2.1").Code)
+
+	t.Setenv("TRUST_PROXY_HEADERS", "true")
+	// Behind the proxy each client is limited by the address the proxy appended, not a spoofed one
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, send("10.0.0.1:443", fmt.Sprintf("192.0.2.%d, 192.0.2.50", i)).Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:443", "192.0.2.99, 192.0.2.50").Code)
+
+	// Half a second restores one request at 2 per second
+	now = now.Add(500 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, send("10.0.0.1:443", "192.0.2.50").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.1:443", "192.0.2.50").Code)
+}
+
+func TestIPRateLimiterDisabled(t *testing.T) {
+	handler := NewIPRateLimiter(0, 1).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 10; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("POST", "/user/1/transaction", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	}
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	const limit = 2
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := ConcurrencyLimit(limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/user/1/account/1/export", nil))
+		return recorder
+	}
+
+	// Saturate the endpoint with requests blocked inside the handler
+	var wg sync.WaitGroup
+	results := make(chan int, limit)
+	for i := 0; i < limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results <- send().Code
+		}()
+		<-started
+	}
+
+	// Overflow is rejected immediately instead of waiting for a slot
+	overflow := send()
+	assert.Equal(t, http.StatusServiceUnavailable, overflow.Code)
+	assert.Equal(t, "1", overflow.Header().Get("Retry-After"))
+
+	close(release)
+	wg.Wait()
+	close(results)
+	for code := range results {
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	// Released slots serve new requests again
+	go func() { <-started }()
+	assert.Equal(t, http.StatusOK, send().Code)
+}
+
+func TestConcurrencyLimitDisabled(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := ConcurrencyLimit(0)(next)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+// gzipTestBody is a typical JSON listing, repetitive enough for levels to differ
+var gzipTestBody = []byte(strings.Repeat(`{"transaction_id":"tx-1","account_id":1,"amount":"10.15","type":"win","source":"game"},`, 200))
+
+func gzipExpected(t *testing.T, level int) []byte {
+	var buf bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&buf, level)
+	assert.NoError(t, err)
+	writer.Write(gzipTestBody)
+	writer.Close()
+	return buf.Bytes()
+}
+
+func TestGzip(t *testing.T) {
+	tests := []struct {
+		name           string
+		level          int
+		acceptEncoding string
+		status         int
+		expectGzip     bool
+	}{
+		{name: "Fastest level", level: 1, acceptEncoding: "gzip, deflate", status: http.StatusOK, expectGzip: true},
+		{name: "Smallest level", level: 9, acceptEncoding: "gzip", status: http.StatusOK, expectGzip: true},
+		{name: "Client without gzip", level: 6, acceptEncoding: "", status: http.StatusOK},
+		{name: "Not modified has no body", level: 6, acceptEncoding: "gzip", status: http.StatusNotModified},
+	}
+
+	// The levels must produce different output for the comparison below to tell them apart
+	assert.NotEqual(t, gzipExpected(t, 1), gzipExpected(t, 9))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Gzip(tt.level)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusOK {
+					w.Write(gzipTestBody)
+				}
+			}))
+
+			req := httptest.NewRequest("GET", "/admin/transactions", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.status, recorder.Code)
+			assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"))
+
+			if !tt.expectGzip {
+				assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+				if tt.status == http.StatusOK {
+					assert.Equal(t, gzipTestBody, recorder.Body.Bytes())
+				} else {
+					assert.Empty(t, recorder.Body.Bytes())
+				}
+				return
+			}
+
+			// Output identical to a writer at the configured level proves the level was applied
+			assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+			assert.Equal(t, gzipExpected(t, tt.level), recorder.Body.Bytes())
+
+			reader, err := gzip.NewReader(recorder.Body)
+			assert.NoError(t, err)
+			body, err := io.ReadAll(reader)
+			assert.NoError(t, err)
+			assert.Equal(t, gzipTestBody, body)
+		})
+	}
+}
+
+func TestGzipFlush(t *testing.T) {
+	var flushed []byte
+	recorder := httptest.NewRecorder()
+	handler := Gzip(6)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"transactions":[`))
+		flusher, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		flusher.Flush()
+		flushed = append(flushed, recorder.Body.Bytes()...)
+		w.Write([]byte(`]}`))
+	}))
+
+	req := httptest.NewRequest("GET", "/user/1/account/1/export", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(recorder, req)
+
+	assert.True(t, recorder.Flushed)
+
+	// The flushed part decompresses to what was written before the flush
+	reader, err := gzip.NewReader(bytes.NewReader(flushed))
+	assert.NoError(t, err)
+	partial, _ := io.ReadAll(reader)
+	assert.Equal(t, `{"transactions":[`, string(partial))
+
+	reader, err = gzip.NewReader(recorder.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"transactions":[]}`, string(body))
+}
+
+func TestGzipLevel(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected int
+	}{
+		{value: "", expected: helpers.DefaultGzipLevel},
+		{value: "1", expected: 1},
+		{value: "9", expected: 9},
+		{value: "0", expected: helpers.DefaultGzipLevel},
+		{value: "12", expected: helpers.DefaultGzipLevel},
+		{value: "fast", expected: helpers.DefaultGzipLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run("GZIP_LEVEL="+tt.value, func(t *testing.T) {
+			t.Setenv("GZIP_LEVEL", tt.value)
+			assert.Equal(t, tt.expected, helpers.GzipLevel())
+		})
+	}
+}
+
+func BenchmarkGzipLevels(b *testing.B) {
+	for _, level := range []int{1, 6, 9} {
+		b.Run(fmt.Sprintf("level-%d", level), func(b *testing.B) {
+			handler := Gzip(level)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(gzipTestBody)
+			}))
+			req := httptest.NewRequest("GET", "/admin/transactions", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+
+			var size int
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				recorder := httptest.NewRecorder()
+				handler.ServeHTTP(recorder, req)
+				size = recorder.Body.Len()
+			}
+			b.ReportMetric(float64(size), "bytes/response")
+		})
+	}
+}
+
+func TestIdempotencyStore(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	store := newIdempotencyStore(time.Hour, helpers.DefaultJSONMaxBodyBytes, func() time.Time { return now })
+
+	calls := map[string]int{}
+	router := mux.NewRouter()
+	router.Use(store.Middleware)
+	router.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		calls["user"]++
+		helpers.RespondSuccess(w, "User created", map[string]int{"call": calls["user"]})
+	}).Methods("POST")
+	router.HandleFunc("/user/{userId}/transaction", func(w http.ResponseWriter, r *http.Request) {
+		calls["transaction"]++
+		if r.Header.Get("Fail") == "true" {
+			helpers.RespondError(w, http.StatusInternalServerError, "Temporary failure")
+			return
+		}
+		helpers.RespondSuccess(w, "Transaction applied", map[string]int{"call": calls["transaction"]})
+	}).Methods("POST")
+
+	send := func(path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set(helpers.IdempotencyKeyHeader, "key-1")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// One key used for two different endpoints runs both
+	user := send("/user", `{"username":"a"}`, nil)
+	transaction := send("/user/1/transaction", `{"state":"win"}`, nil)
+	assert.Equal(t, http.StatusOK, user.Code)
+	assert.Equal(t, http.StatusOK, transaction.Code)
+	assert.Equal(t, map[string]int{"user": 1, "transaction": 1}, calls)
+	assert.Empty(t, user.Header().Get("Idempotent-Replayed"))
+
+	// A repeat on the same endpoint replays the stored response
+	replayed := send("/user", `{"username":"a"}`, nil)
+	assert.Equal(t, http.StatusOK, replayed.Code)
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, user.Body.String(), replayed.Body.String())
+	assert.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+	assert.Equal(t, 1, calls["user"])
+
+	// The path is part of the namespace, so another user's transaction runs too
+	assert.Equal(t, http.StatusOK, send("/user/2/transaction", `{"state":"win"}`, nil).Code)
+	assert.Equal(t, 2, calls["transaction"])
+
+	// Reusing the key with another body is rejected
+	assert.Equal(t, http.StatusUnprocessableEntity, send("/user", `{"username":"b"}`, nil).Code)
+	assert.Equal(t, 1, calls["user"])
+
+	// Server errors are not stored, so the retry runs again
+	assert.Equal(t, http.StatusInternalServerError, send("/user/3/transaction", `{}`, map[string]string{"Fail": "true"}).Code)
+	assert.Equal(t, http.StatusOK, send("/user/3/transaction", `{}`, nil).Code)
+	assert.Equal(t, 4, calls["transaction"])
+
+	// Stored responses expire after the TTL
+	now = now.Add(time.Hour)
+	assert.Empty(t, send("/user", `{"username":"a"}`, nil).Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls["user"])
+
+	// Bodies over the size limit are rejected before they are hashed
+	oversized := send("/user", strings.Repeat("a", helpers.DefaultJSONMaxBodyBytes+1), nil)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, oversized.Code)
+	assert.Equal(t, 2, calls["user"])
+}
+
+func TestIdempotencyStoreSeparatesCallers(t *testing.T) {
+	store := NewIdempotencyStore(time.Hour, helpers.DefaultJSONMaxBodyBytes)
+
+	calls := 0
+	handler := store.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		helpers.RespondSuccess(w, "Transfer completed", map[string]int{"call": calls})
+	}))
+
+	send := func(ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/transfer", strings.NewReader(`{"amount":"10.00"}`)).WithContext(ctx)
+		req.Header.Set(helpers.IdempotencyKeyHeader, "key-1")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+	alice := helpers.WithUserID(context.Background(), 7)
+	bob := helpers.WithUserID(context.Background(), 8)
+	service := context.WithValue(context.Background(), principalContextKey{}, models.APIPrincipal{KeyID: 7, Name: "payments"})
+
+	// The same key on a path without a user ID runs once per caller
+	assert.Empty(t, send(alice).Header().Get("Idempotent-Replayed"))
+	assert.Empty(t, send(bob).Header().Get("Idempotent-Replayed"))
+	assert.Empty(t, send(service).Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 3, calls)
+
+	// Each caller's repeat replays only their own response
+	replayed := send(bob)
+	assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, `{"call":2}`, replayed.Body.String())
+	assert.Equal(t, 3, calls)
+}
+
+func TestLoggingMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		handler  http.HandlerFunc
+		expected *regexp.Regexp
+	}{
+		{
+			name:   "JSON record with status and bytes",
+			format: helpers.LogFormatJSON,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":"User not found"}`))
+			},
+			expected: regexp.MustCompile(`^\{"method":"GET","path":"/user/1","status":404,"duration_ms":\d+(\.\d+)?,"bytes":26,"request_id":"req-1"\}\n$`),
+		},
+		{
+			name:     "JSON record for a handler that writes nothing",
+			format:   helpers.LogFormatJSON,
+			handler:  func(w http.ResponseWriter, r *http.Request) {},
+			expected: regexp.MustCompile(`^\{"method":"GET","path":"/user/1","status":200,"duration_ms":\d+(\.\d+)?,"bytes":0,"request_id":"req-1"\}\n$`),
+		},
+		{
+			name:   "Text line",
+			format: helpers.LogFormatText,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"status":"ok"}`))
+			},
+			expected: regexp.MustCompile(`^GET /user/1\?verbose=1 200 15B \S+ request_id=req-1\n$`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			handler := logRequests(tt.format, log.New(&output, "", 0))(tt.handler)
+
+			req := httptest.NewRequest("GET", "/user/1?verbose=1", nil)
+			req = req.WithContext(tracing.WithRequestID(req.Context(), "req-1"))
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Regexp(t, tt.expected, output.String())
+		})
+	}
+}
+
+func TestRedactPII(t *testing.T) {
+	lookup := func(ctx context.Context, keyHash string) (models.APIPrincipal, error) {
+		switch keyHash {
+		case helpers.HashAPIKey("gbk_support"):
+			return models.APIPrincipal{Name: "support", Scopes: []string{helpers.ScopeUsersRead, helpers.ScopeUsersPII}}, nil
+		case helpers.HashAPIKey("gbk_analytics"):
+			return models.APIPrincipal{Name: "analytics", Scopes: []string{helpers.ScopeUsersRead}}, nil
+		}
+		return models.APIPrincipal{}, helpers.ErrInvalidAPIKey
+	}
+	user := map[string]interface{}{"id": 7, "username": "jdoe", "full_name": "Jane Doe", "email": "jane@example.com", "country": "DE"}
+	users := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("missing") != "" {
+			helpers.RespondError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		helpers.RespondSuccess(w, "User retrieved successfully", user)
+	}
+	userList := func(w http.ResponseWriter, r *http.Request) {
+		helpers.RespondSuccess(w, "Users retrieved successfully", map[string]interface{}{"users": []interface{}{user}, "total": 1})
+	}
+	redact := RedactPII(helpers.ScopeUsersPII, []string{"email", "full_name"})
+
+	tests := []struct {
+		name           string
+		handler        http.Handler
+		url            string
+		apiKey         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Privileged key sees PII",
+			handler:        APIKeyAuth(lookup, helpers.ScopeUsersRead)(redact(http.HandlerFunc(users))),
+			url:            "/user/7",
+			apiKey:         "gbk_support",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"country":"DE","email":"jane@example.com","full_name":"Jane Doe","id":7,"username":"jdoe"}`,
+		},
+		{
+			name:           "Unprivileged key gets PII omitted",
+			handler:        APIKeyAuth(lookup, helpers.ScopeUsersRead)(redact(http.HandlerFunc(users))),
+			url:            "/user/7",
+			apiKey:         "gbk_analytics",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"country":"DE","id":7,"username":"jdoe"}`,
+		},
+		{
+			name:           "Unprivileged key gets PII omitted from lists",
+			handler:        APIKeyAuth(lookup, helpers.ScopeUsersRead)(redact(http.HandlerFunc(userList))),
+			url:            "/users",
+			apiKey:         "gbk_analytics",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"users":[{"country":"DE","id":7,"username":"jdoe"}],"total":1}`,
+		},
+		{
+			name:           "Errors pass through unchanged",
+			handler:        APIKeyAuth(lookup, helpers.ScopeUsersRead)(redact(http.HandlerFunc(users))),
+			url:            "/user/7?missing=1",
+			apiKey:         "gbk_analytics",
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"User not found"}`,
+		},
+		{
+			name: "User reading their own profile sees PII",
+			handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				redact(http.HandlerFunc(users)).ServeHTTP(w, r.WithContext(helpers.WithUserID(r.Context(), 7)))
+			}),
+			url:            "/user/7",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"country":"DE","email":"jane@example.com","full_name":"Jane Doe","id":7,"username":"jdoe"}`,
+		},
+		{
+			name:           "Without a principal PII is omitted",
+			handler:        redact(http.HandlerFunc(users)),
+			url:            "/user/7",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"country":"DE","id":7,"username":"jdoe"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.url, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			recorder := httptest.NewRecorder()
+			tt.handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.JSONEq(t, tt.expectedBody, recorder.Body.String())
+		})
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(MetricsMiddleware)
+	router.HandleFunc("/user/{userId}/balance", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, float64(1), testutil.ToFloat64(metrics.RequestsInFlight))
+		if mux.Vars(r)["userId"] == "0" {
+			helpers.RespondError(w, http.StatusNotFound, "Account not found")
+		}
+	}).Methods("GET")
+
+	requests := metrics.RequestsTotal.WithLabelValues("GET", "/user/{userId}/balance", "200")
+	notFound := metrics.RequestsTotal.WithLabelValues("GET", "/user/{userId}/balance", "404")
+	before, beforeNotFound := testutil.ToFloat64(requests), testutil.ToFloat64(notFound)
+
+	for _, path := range []string{"/user/1/balance", "/user/2/balance", "/user/0/balance"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	// Users share the route template series; handlers that never write count as 200
+	assert.Equal(t, before+2, testutil.ToFloat64(requests))
+	assert.Equal(t, beforeNotFound+1, testutil.ToFloat64(notFound))
+	assert.Equal(t, float64(0), testutil.ToFloat64(metrics.RequestsInFlight))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.RequestDuration))
+}
+
+func TestPanicHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        http.HandlerFunc
+		expectedStatus int
+		expectedBody   *regexp.Regexp
+		expectedLog    bool
+	}{
+		{
+			name:           "Panic before writing answers 500",
+			handler:        func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   regexp.MustCompile(`^\{"error":"Internal server error","reference":"[0-9a-f]{12}"\}\n$`),
+			expectedLog:    true,
+		},
+		{
+			name: "Panic after writing keeps the partial response",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte(`{"status":`))
+				panic("boom")
+			},
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   regexp.MustCompile(`^\{"status":$`),
+			expectedLog:    true,
+		},
+		{
+			name: "No panic",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				helpers.RespondSuccess(w, "ok", map[string]string{"status": "ok"})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   regexp.MustCompile(`^\{"status":"ok"\}\n$`),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var output bytes.Buffer
+			log.SetOutput(&output)
+			defer log.SetOutput(os.Stderr)
+
+			req := httptest.NewRequest("GET", "/user/1", nil)
+			recorder := httptest.NewRecorder()
+			PanicHandler(tt.handler).ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expectedStatus, recorder.Code)
+			assert.Regexp(t, tt.expectedBody, recorder.Body.String())
+			if tt.expectedLog {
+				// The stack trace points at the panicking handler
+				assert.Contains(t, output.String(), "Panic serving GET /user/1 (reference ")
+				assert.Contains(t, output.String(), "): boom")
+				assert.Contains(t, output.String(), "goroutine")
+			} else {
+				assert.Empty(t, output.String())
+			}
+		})
+	}
+}
+
+func TestPanicHandlerAbort(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	handler := PanicHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"transactions":[`))
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+
+	recorder := httptest.NewRecorder()
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/user/1/account/1/export", nil))
+	})
+	assert.True(t, recorder.Flushed)
+	assert.Empty(t, output.String())
+}
+
+func TestPanicHandlerReference(t *testing.T) {
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	defer log.SetOutput(os.Stderr)
+
+	handler := PanicHandler(RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("nil account")
+	})))
+
+	req := httptest.NewRequest("POST", "/transfer", nil)
+	req.Header.Set(RequestIDHeader, "req-7")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	var response helpers.ErrorResponse
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "Internal server error", response.Error)
+	assert.Equal(t, "req-7", response.RequestID)
+	assert.NotEmpty(t, response.Reference)
+
+	// The logged reference matches the one the client can quote; the stack stays in the logs
+	assert.Contains(t, output.String(), "Panic serving POST /transfer (reference "+response.Reference+"): nil account")
+	assert.Contains(t, output.String(), "runtime/debug.Stack")
+	assert.NotContains(t, recorder.Body.String(), "goroutine")
+	assert.NotContains(t, recorder.Body.String(), "nil account")
+}
@@ -1,31 +1,314 @@
 package models
 
+import "encoding/json"
+
+// User is the body of POST /user. DateOfBirth and Country are only required with REQUIRE_KYC_FIELDS=true,
+// but are validated whenever they are sent.
 type User struct {
-	ID       int64  `json:"id"`
+	ID          int64  `json:"id"`
+	Username    string `json:"username" validate:"required"`
+	FullName    string `json:"full_name" validate:"required"`
+	Email       string `json:"email" validate:"required,email"`
+	DateOfBirth string `json:"date_of_birth" validate:"required_if_kyc,omitempty,datetime=2006-01-02,minage"`
+	Country     string `json:"country" validate:"required_if_kyc,omitempty,iso3166_1_alpha2"`
+	// Password is optional and write-only: only its bcrypt hash is stored and it is never returned.
+	// bcrypt reads at most 72 bytes.
+	Password string `json:"password,omitempty" validate:"omitempty,min=8,max=72"`
+}
+
+// LoginRequest is the body of POST /auth/login
+type LoginRequest struct {
 	Username string `json:"username" validate:"required"`
-	FullName string `json:"full_name" validate:"required"`
-	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// RefreshRequest is the body of POST /auth/refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// TokenPair is a signed bearer JWT for the user in its subject and the refresh token that replaces it
+type TokenPair struct {
+	AccessToken           string    `json:"access_token"`
+	TokenType             string    `json:"token_type"`
+	ExpiresIn             int64     `json:"expires_in"`
+	ExpiresAt             Timestamp `json:"expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt Timestamp `json:"refresh_token_expires_at"`
 }
 
+// UserSummary is a user as listed by GET /users, without KYC details
+type UserSummary struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	FullName string `json:"full_name"`
+	Email    string `json:"email"`
+}
+
+// Account is the body of POST /accounts. Balance is the opening balance (zero when omitted), kept as the
+// decimal literal so it is parsed exactly in the currency's minor units, and Currency defaults to EUR.
 type Account struct {
-	ID       int64   `json:"id"`
-	UserID   string  `json:"user_id" validate:"required"`
-	Balance  float64 `json:"balance" validate:"required"`
-	Currency string  `json:"currency" default:"EUR" validate:"required,oneof=USD EUR GBP"`
-	Status   string  `json:"status" default:"active"`
+	ID       int64       `json:"id"`
+	UserID   string      `json:"user_id" validate:"required"`
+	Balance  json.Number `json:"balance" validate:"omitempty,money"`
+	Currency string      `json:"currency" default:"EUR" validate:"omitempty,oneof=USD EUR GBP"`
+	Status   string      `json:"status" default:"active"`
+	// AccountType defaults to general when omitted
+	AccountType string `json:"account_type" validate:"omitempty,oneof=general checking savings game"`
+	// Metadata holds partner-defined string or number attributes
+	Metadata json.RawMessage `json:"metadata,omitempty"`
 }
 
 type Transaction struct {
-	ID              string `json:"transactionId" validate:"required" db:"id,pk"`
-	AccountID       int64  `json:"account_id" validate:"required" db:"account_id,index"`
-	Amount          string `json:"amount" validate:"required" db:"amount"`
-	AmountFloat     float64
-	Source          string `json:"source" validate:"required,oneof=game server payment" db:"source"`
-	TransactionType string `json:"state" validate:"required,oneof=win lose" db:"transaction_type"`
-	InsertedAt      string `json:"inserted_at" db:"inserted_at"`
+	// ID is generated server-side when the client does not supply a transactionId
+	ID              string    `json:"transactionId" validate:"max=128" db:"id,pk"`
+	AccountID       int64     `json:"account_id" validate:"required" db:"account_id,index"`
+	Amount          string    `json:"amount" db:"amount"`
+	Source          string    `json:"source" db:"source"`
+	TransactionType string    `json:"state" validate:"required_unless_signed,omitempty,oneof=win lose deposit withdrawal" db:"transaction_type"`
+	InsertedAt      Timestamp `json:"inserted_at" db:"inserted_at"`
+	Memo            string    `json:"memo,omitempty" validate:"max=255" db:"memo"`
+	PayoutBatchID   string    `json:"-" db:"payout_batch_id"`
+	// ClientReference is an optional client-side dedupe key, unique per account
+	ClientReference string `json:"client_reference,omitempty" validate:"max=128" db:"client_reference"`
+	// Currency is optional; when given it must match the account currency unless conversion is enabled
+	Currency string `json:"currency,omitempty" validate:"omitempty,len=3"`
+	// AmountMinor is the amount in cents, an alternative to Amount; exactly one of them must be given
+	AmountMinor *int64 `json:"amount_minor,omitempty"`
+	// MinorAmount is the parsed amount in minor units of the account currency
+	MinorAmount int64 `json:"-"`
+	// BalanceAfterMinor is the account balance in minor units once the transaction was applied, when known
+	BalanceAfterMinor *int64 `json:"-"`
+	// BalanceVersion is the account version after the transaction, when known
+	BalanceVersion *int64 `json:"-"`
+}
+
+type ConfirmationRequired struct {
+	Status            string    `json:"status"`
+	ConfirmationToken string    `json:"confirmation_token"`
+	ExpiresAt         Timestamp `json:"expires_at"`
+	Amount            string    `json:"amount"`
+	Type              string    `json:"type"`
+}
+
+type TransactionConfirmRequest struct {
+	ConfirmationToken string `json:"confirmation_token" validate:"required"`
+}
+
+// APIPrincipal is the integration authenticated by an API key
+type APIPrincipal struct {
+	KeyID   int64
+	Name    string
+	Scopes  []string
+	Sources []string
+}
+
+type CreateAPIKeyRequest struct {
+	Name    string   `json:"name" validate:"required,max=100"`
+	Scopes  []string `json:"scopes" validate:"required,min=1,dive,oneof=transactions:write transactions:read balances:read users:read users:pii"`
+	Sources []string `json:"sources" validate:"dive,source"`
+}
+
+// TransactionBatchGetRequest lists the transactions to fetch in one call
+type TransactionBatchGetRequest struct {
+	IDs []string `json:"ids" validate:"required,min=1,max=100,dive,required,max=128"`
+}
+
+type PayoutEntry struct {
+	UserID int64  `json:"user_id" validate:"required,gt=0"`
+	Amount string `json:"amount" validate:"required"`
+	Memo   string `json:"memo" validate:"max=255"`
+}
+
+type PayoutRequest struct {
+	Entries []PayoutEntry `json:"entries" validate:"required,min=1,max=500,dive"`
+}
+
+// CorrectionRequest books a corrective transaction; only admins may create these types
+type CorrectionRequest struct {
+	Type   string `json:"type" validate:"required,oneof=reversal adjustment"`
+	Amount string `json:"amount" validate:"required"`
+	Memo   string `json:"memo" validate:"max=255"`
+}
+
+type PayoutResult struct {
+	Index         int    `json:"index"`
+	UserID        int64  `json:"user_id"`
+	Amount        string `json:"amount"`
+	Status        string `json:"status"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+type PayoutResponse struct {
+	BatchID string         `json:"batch_id"`
+	Mode    string         `json:"mode"`
+	Applied int            `json:"applied"`
+	Failed  int            `json:"failed"`
+	Results []PayoutResult `json:"results"`
 }
 
 type UserBalance struct {
-	UserID  int64  `json:"userId"`
-	Balance string `json:"balance"`
+	UserID    int64  `json:"userId"`
+	Balance   string `json:"balance"`
+	Pending   string `json:"pending"`
+	Available string `json:"available"`
+	Currency  string `json:"currency"`
+}
+
+type MiniStatementEntry struct {
+	TransactionID string    `json:"transaction_id"`
+	Type          string    `json:"type"`
+	Source        string    `json:"source"`
+	Amount        string    `json:"amount"`
+	InsertedAt    Timestamp `json:"inserted_at"`
+}
+
+type MiniStatement struct {
+	UserID       int64                `json:"userId"`
+	AccountID    int64                `json:"account_id"`
+	Currency     string               `json:"currency"`
+	Balance      string               `json:"balance"`
+	Transactions []MiniStatementEntry `json:"transactions"`
+}
+
+type StatementEntry struct {
+	TransactionID string    `json:"transaction_id"`
+	Type          string    `json:"type"`
+	Source        string    `json:"source"`
+	Amount        string    `json:"amount"`
+	BalanceAfter  *string   `json:"balance_after"`
+	InsertedAt    Timestamp `json:"inserted_at"`
+}
+
+type Statement struct {
+	UserID       int64            `json:"userId"`
+	AccountID    int64            `json:"account_id"`
+	Currency     string           `json:"currency"`
+	From         Timestamp        `json:"from"`
+	To           Timestamp        `json:"to"`
+	Transactions []StatementEntry `json:"transactions"`
+}
+
+// PoolStats is a snapshot of the database connection pool
+type PoolStats struct {
+	MaxConns          int32 `json:"max_conns"`
+	TotalConns        int32 `json:"total_conns"`
+	AcquiredConns     int32 `json:"acquired_conns"`
+	IdleConns         int32 `json:"idle_conns"`
+	ConstructingConns int32 `json:"constructing_conns"`
+	AcquireCount      int64 `json:"acquire_count"`
+	// EmptyAcquireCount counts acquires that had to wait for a connection, a sign of exhaustion
+	EmptyAcquireCount       int64  `json:"empty_acquire_count"`
+	CanceledAcquireCount    int64  `json:"canceled_acquire_count"`
+	AcquireDuration         string `json:"acquire_duration"`
+	NewConnsCount           int64  `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64  `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64  `json:"max_idle_destroy_count"`
+}
+
+type Meta struct {
+	// DailyDebitCaps maps a currency to the most an account in it may debit per UTC day
+	DailyDebitCaps map[string]string `json:"daily_debit_caps"`
+	CappedTypes    []string          `json:"capped_types"`
+}
+
+// SourceTypes maps a source to the transaction types each account type accepts from it
+type SourceTypes struct {
+	Sources map[string]map[string][]string `json:"sources"`
+}
+
+type BalancePoint struct {
+	Period  Timestamp `json:"period"`
+	Balance string    `json:"balance"`
+}
+
+type BalanceTimeseries struct {
+	UserID    int64          `json:"userId"`
+	AccountID int64          `json:"account_id"`
+	Currency  string         `json:"currency"`
+	Interval  string         `json:"interval"`
+	Points    []BalancePoint `json:"points"`
+}
+
+type AccountWorth struct {
+	AccountID int64   `json:"account_id"`
+	Currency  string  `json:"currency"`
+	Balance   string  `json:"balance"`
+	Rate      float64 `json:"rate"`
+	Converted string  `json:"converted"`
+}
+
+type ClosedAccount struct {
+	AccountID int64  `json:"account_id"`
+	Currency  string `json:"currency"`
+	Status    string `json:"status"`
+}
+
+type CloseAccountsResponse struct {
+	UserID   int64           `json:"user_id"`
+	Accounts []ClosedAccount `json:"accounts"`
+}
+
+type NonZeroBalance struct {
+	AccountID int64  `json:"account_id"`
+	Currency  string `json:"currency"`
+	Balance   string `json:"balance"`
+}
+
+// CloseAccountsConflict lists the accounts that kept a close-all request from closing anything
+type CloseAccountsConflict struct {
+	Error    string           `json:"error"`
+	Accounts []NonZeroBalance `json:"accounts"`
+}
+
+type FXRate struct {
+	From      string  `json:"from"`
+	To        string  `json:"to"`
+	Rate      float64 `json:"rate"`
+	Amount    string  `json:"amount"`
+	Converted string  `json:"converted"`
+}
+
+type NetWorth struct {
+	UserID   int64          `json:"userId"`
+	Base     string         `json:"base"`
+	Total    string         `json:"total"`
+	Accounts []AccountWorth `json:"accounts"`
+}
+
+// AccountMetadataUpdate replaces the metadata of an account
+type AccountMetadataUpdate struct {
+	Metadata json.RawMessage `json:"metadata" validate:"required"`
+}
+
+// AccountStatusUpdate freezes or reactivates an account
+type AccountStatusUpdate struct {
+	Status string `json:"status" validate:"required,oneof=active frozen"`
+}
+
+// NotificationPreferencesUpdate replaces a user's notification preferences
+type NotificationPreferencesUpdate struct {
+	LowBalanceAlerts       *bool  `json:"low_balance_alerts" validate:"required"`
+	LargeTransactionAlerts *bool  `json:"large_transaction_alerts" validate:"required"`
+	Channel                string `json:"channel" validate:"required,oneof=email sms push"`
+}
+
+// TransferRequest moves money between the accounts of two users. IDs may be JSON numbers or strings.
+type TransferRequest struct {
+	FromUserID json.Number `json:"from_user_id" validate:"required"`
+	ToUserID   json.Number `json:"to_user_id" validate:"required"`
+	Amount     string      `json:"amount" validate:"required"`
+	Memo       string      `json:"memo" validate:"max=255"`
+}
+
+// Transfer links the debit and credit transactions written for one transfer
+type Transfer struct {
+	TransferID          string `json:"transfer_id"`
+	FromUserID          int64  `json:"from_user_id"`
+	ToUserID            int64  `json:"to_user_id"`
+	Amount              string `json:"amount"`
+	Currency            string `json:"currency"`
+	DebitTransactionID  string `json:"debit_transaction_id"`
+	CreditTransactionID string `json:"credit_transaction_id"`
 }
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// TimestampLayout is the wire format of every timestamp in the API
+const TimestampLayout = time.RFC3339
+
+// Timestamp is a time.Time that always serializes as RFC3339 in UTC.
+// The zero value stands for a missing timestamp and is encoded as JSON null and SQL NULL.
+type Timestamp struct {
+	time.Time
+}
+
+// NewTimestamp wraps t, normalized to UTC
+func NewTimestamp(t time.Time) Timestamp {
+	return Timestamp{Time: t.UTC()}
+}
+
+func (t Timestamp) String() string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(TimestampLayout)
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.String() + `"`), nil
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) || bytes.Equal(data, []byte(`""`)) {
+		*t = Timestamp{}
+		return nil
+	}
+
+	parsed, err := time.Parse(`"`+TimestampLayout+`"`, string(data))
+	if err != nil {
+		return err
+	}
+
+	*t = NewTimestamp(parsed)
+	return nil
+}
+
+// ScanTimestamptz lets pgx scan timestamptz columns directly into a Timestamp
+func (t *Timestamp) ScanTimestamptz(v pgtype.Timestamptz) error {
+	if !v.Valid {
+		*t = Timestamp{}
+		return nil
+	}
+	*t = NewTimestamp(v.Time)
+	return nil
+}
+
+// TimestamptzValue lets pgx encode a Timestamp as a timestamptz parameter
+func (t Timestamp) TimestamptzValue() (pgtype.Timestamptz, error) {
+	return pgtype.Timestamptz{Time: t.Time, Valid: !t.IsZero()}, nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestampJSON(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+
+	tests := []struct {
+		name         string
+		timestamp    Timestamp
+		expectedJSON string
+	}{
+		{
+			name:         "UTC time",
+			timestamp:    NewTimestamp(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)),
+			expectedJSON: `"2025-01-02T03:04:05Z"`,
+		},
+		{
+			name:         "Other zones are normalized to UTC",
+			timestamp:    Timestamp{Time: time.Date(2025, 1, 2, 4, 4, 5, 0, berlin)},
+			expectedJSON: `"2025-01-02T03:04:05Z"`,
+		},
+		{
+			name:         "Zero value is null",
+			timestamp:    Timestamp{},
+			expectedJSON: `null`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.timestamp)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedJSON, string(data))
+
+			var parsed Timestamp
+			err = json.Unmarshal(data, &parsed)
+			assert.NoError(t, err)
+			assert.True(t, tt.timestamp.Equal(parsed.Time))
+			assert.Equal(t, time.UTC, parsed.Location())
+		})
+	}
+}
+
+func TestTimestampUnmarshalRejectsOtherFormats(t *testing.T) {
+	for _, input := range []string{`"2025-01-02 03:04:05"`, `"02/01/2025"`, `1735787045`} {
+		var parsed Timestamp
+		assert.Error(t, json.Unmarshal([]byte(input), &parsed), input)
+	}
+}
+
+func TestTransactionInsertedAtJSON(t *testing.T) {
+	transaction := Transaction{ID: "tx-1", InsertedAt: NewTimestamp(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))}
+
+	data, err := json.Marshal(transaction)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"inserted_at":"2025-01-02T03:04:05Z"`)
+}
+
+func TestTimestampDatabaseConversion(t *testing.T) {
+	var timestamp Timestamp
+	source := time.Date(2025, 1, 2, 4, 4, 5, 0, time.FixedZone("CET", 3600))
+
+	assert.NoError(t, timestamp.ScanTimestamptz(pgtype.Timestamptz{Time: source, Valid: true}))
+	assert.Equal(t, "2025-01-02T03:04:05Z", timestamp.String())
+
+	value, err := timestamp.TimestamptzValue()
+	assert.NoError(t, err)
+	assert.True(t, value.Valid)
+	assert.True(t, source.Equal(value.Time))
+
+	assert.NoError(t, timestamp.ScanTimestamptz(pgtype.Timestamptz{}))
+	assert.True(t, timestamp.IsZero())
+
+	value, err = timestamp.TimestamptzValue()
+	assert.NoError(t, err)
+	assert.False(t, value.Valid)
+}
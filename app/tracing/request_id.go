@@ -0,0 +1,16 @@
+package tracing
+
+import "context"
+
+type requestIDKey struct{}
+
+// WithRequestID attaches the request's ID to the context
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request's ID, or "" outside a request
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
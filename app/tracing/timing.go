@@ -0,0 +1,38 @@
+package tracing
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// DBTimer accumulates the time a request spends in database queries
+type DBTimer struct {
+	nanos   atomic.Int64
+	queries atomic.Int64
+}
+
+// Add records one query that took d
+func (t *DBTimer) Add(d time.Duration) {
+	t.nanos.Add(int64(d))
+	t.queries.Add(1)
+}
+
+// Total returns the accumulated query time and the number of queries
+func (t *DBTimer) Total() (time.Duration, int64) {
+	return time.Duration(t.nanos.Load()), t.queries.Load()
+}
+
+type dbTimerKey struct{}
+
+// WithDBTimer attaches a new DBTimer to the context
+func WithDBTimer(ctx context.Context) (context.Context, *DBTimer) {
+	timer := &DBTimer{}
+	return context.WithValue(ctx, dbTimerKey{}, timer), timer
+}
+
+// DBTimerFromContext returns the request's DBTimer, or nil outside a timed request
+func DBTimerFromContext(ctx context.Context) *DBTimer {
+	timer, _ := ctx.Value(dbTimerKey{}).(*DBTimer)
+	return timer
+}